@@ -2,6 +2,7 @@ package volume // import "github.com/docker/docker/api/server/router/volume"
 
 import (
 	"context"
+	"io"
 
 	"github.com/docker/docker/volume/service/opts"
 	// TODO return types need to be refactored into pkg
@@ -12,9 +13,11 @@ import (
 // Backend is the methods that need to be implemented to provide
 // volume specific functionality
 type Backend interface {
-	List(ctx context.Context, filter filters.Args) ([]*types.Volume, []string, error)
+	List(ctx context.Context, filter filters.Args, opts ...opts.ListOption) ([]*types.Volume, []string, error)
 	Get(ctx context.Context, name string, opts ...opts.GetOption) (*types.Volume, error)
 	Create(ctx context.Context, name, driverName string, opts ...opts.CreateOption) (*types.Volume, error)
 	Remove(ctx context.Context, name string, opts ...opts.RemoveOption) error
 	Prune(ctx context.Context, pruneFilters filters.Args) (*types.VolumesPruneReport, error)
+	Export(ctx context.Context, name string, w io.Writer) error
+	Import(ctx context.Context, name string, r io.Reader, opts ...opts.ImportOption) (*types.Volume, error)
 }
@@ -78,6 +78,35 @@ func (v *volumeRouter) deleteVolumes(ctx context.Context, w http.ResponseWriter,
 	return nil
 }
 
+func (v *volumeRouter) getVolumeExport(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.ParseForm(r); err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/x-tar")
+	return v.backend.Export(ctx, vars["name"], w)
+}
+
+func (v *volumeRouter) postVolumeImport(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := httputils.ParseForm(r); err != nil {
+		return err
+	}
+
+	var importOpts []opts.ImportOption
+	if driver := r.Form.Get("driver"); driver != "" {
+		importOpts = append(importOpts, opts.WithImportDriver(driver))
+	}
+	if httputils.BoolValue(r, "requireEmpty") {
+		importOpts = append(importOpts, opts.WithImportRequireEmpty)
+	}
+
+	volume, err := v.backend.Import(ctx, vars["name"], r.Body, importOpts...)
+	if err != nil {
+		return err
+	}
+	return httputils.WriteJSON(w, http.StatusOK, volume)
+}
+
 func (v *volumeRouter) postVolumesPrune(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	if err := httputils.ParseForm(r); err != nil {
 		return err
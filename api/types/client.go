@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"io"
 	"net"
+	"time"
 
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/filters"
@@ -117,6 +118,26 @@ type NetworkListOptions struct {
 type HijackedResponse struct {
 	Conn   net.Conn
 	Reader *bufio.Reader
+	// Control, when set by the client, gives transport-level control over
+	// the connection without type-asserting Conn.
+	Control HijackController
+}
+
+// HijackController controls transport-level behavior of a hijacked
+// connection: TCP keepalives, read and write deadlines, and half-close.
+type HijackController interface {
+	// SetKeepAlive enables TCP keepalive probes at the given period, or
+	// disables them for a zero or negative period. It fails for
+	// connections that are not TCP.
+	SetKeepAlive(period time.Duration) error
+	// SetReadDeadline sets the deadline for future reads.
+	SetReadDeadline(t time.Time) error
+	// SetWriteDeadline sets the deadline for future writes.
+	SetWriteDeadline(t time.Time) error
+	// CloseWrite half-closes the connection for writing. Unlike
+	// HijackedResponse.CloseWrite it fails when the connection does not
+	// support half-close.
+	CloseWrite() error
 }
 
 // Close closes the hijacked connection and reader.
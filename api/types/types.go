@@ -553,6 +553,22 @@ type ContainersPruneReport struct {
 type VolumesPruneReport struct {
 	VolumesDeleted []string
 	SpaceReclaimed uint64
+	// VolumesFailed lists volumes which matched the prune filters but could
+	// not be removed, with the error encountered for each.
+	// This field was added in API v1.42; older daemons omit it.
+	VolumesFailed []VolumesPruneFailure `json:",omitempty"`
+	// VolumesSkipped lists volumes which matched the prune filters but were
+	// protected from removal by the keep label.
+	// This field was added in API v1.42; older daemons omit it.
+	VolumesSkipped []string `json:",omitempty"`
+}
+
+// VolumesPruneFailure describes a volume which could not be pruned
+type VolumesPruneFailure struct {
+	// Name is the name of the volume
+	Name string
+	// Error is the error encountered while removing the volume
+	Error string
 }
 
 // ImagesPruneReport contains the response for Engine API:
@@ -0,0 +1,79 @@
+package client // import "github.com/docker/docker/client"
+
+import (
+	"context"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/versions"
+)
+
+// ServerCapabilities describes what the connected daemon supports, derived
+// centrally from the negotiated API version and the daemon's ping headers.
+// It replaces feature checks spread across consumers as version
+// comparisons. New capabilities can be added here without breaking callers.
+type ServerCapabilities struct {
+	// APIVersion is the effective API version the capabilities were
+	// derived from: the lower of the client's (possibly negotiated)
+	// version and what the daemon reports.
+	APIVersion string
+	// OSType is the daemon's operating system, e.g. "linux" or "windows".
+	OSType string
+	// Experimental reports whether the daemon runs with experimental
+	// features enabled.
+	Experimental bool
+	// BuildKit reports whether the daemon prefers the BuildKit builder.
+	BuildKit bool
+	// ContainerPlatform reports whether ContainerCreate accepts an image
+	// platform (API 1.41).
+	ContainerPlatform bool
+	// DeviceRequests reports whether generic device requests, such as
+	// GPUs, can be made (API 1.40).
+	DeviceRequests bool
+	// KernelMemoryTCP reports whether the kernel TCP buffer memory limit
+	// can be set (API 1.40).
+	KernelMemoryTCP bool
+	// WaitConditions reports whether ContainerWait supports server-side
+	// wait conditions (API 1.30).
+	WaitConditions bool
+	// ConfigOnlyNetworks reports whether config-only networks can be
+	// created (API 1.30).
+	ConfigOnlyNetworks bool
+	// IngressNetworks reports whether swarm ingress networks can be
+	// created (API 1.29).
+	IngressNetworks bool
+}
+
+// ServerCapabilities returns the connected daemon's capabilities. The
+// underlying ping is served from the same cache as ServerInfo, so repeated
+// capability lookups do not re-ping; see InvalidatePingCache.
+func (cli *Client) ServerCapabilities(ctx context.Context) (ServerCapabilities, error) {
+	ping, err := cli.ServerInfo(ctx)
+	if err != nil {
+		return ServerCapabilities{}, err
+	}
+	version := ping.APIVersion
+	if cli.version != "" && (version == "" || versions.LessThan(cli.version, version)) {
+		version = cli.version
+	}
+	return deriveCapabilities(ping, version), nil
+}
+
+// deriveCapabilities is the capability derivation table, mapping a ping
+// result and the effective API version to what can be used.
+func deriveCapabilities(ping types.Ping, version string) ServerCapabilities {
+	atLeast := func(minVersion string) bool {
+		return version != "" && versions.GreaterThanOrEqualTo(version, minVersion)
+	}
+	return ServerCapabilities{
+		APIVersion:         version,
+		OSType:             ping.OSType,
+		Experimental:       ping.Experimental,
+		BuildKit:           ping.BuilderVersion == types.BuilderBuildKit,
+		ContainerPlatform:  atLeast("1.41"),
+		DeviceRequests:     atLeast("1.40"),
+		KernelMemoryTCP:    atLeast("1.40") && ping.OSType != "windows",
+		WaitConditions:     atLeast("1.30"),
+		ConfigOnlyNetworks: atLeast("1.30"),
+		IngressNetworks:    atLeast("1.29"),
+	}
+}
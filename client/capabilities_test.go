@@ -0,0 +1,123 @@
+package client // import "github.com/docker/docker/client"
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+func TestDeriveCapabilities(t *testing.T) {
+	for _, tc := range []struct {
+		doc      string
+		ping     types.Ping
+		version  string
+		expected ServerCapabilities
+	}{
+		{
+			doc:     "old daemon",
+			ping:    types.Ping{OSType: "linux"},
+			version: "1.28",
+			expected: ServerCapabilities{
+				APIVersion: "1.28",
+				OSType:     "linux",
+			},
+		},
+		{
+			doc:     "1.30 daemon",
+			ping:    types.Ping{OSType: "linux"},
+			version: "1.30",
+			expected: ServerCapabilities{
+				APIVersion:         "1.30",
+				OSType:             "linux",
+				WaitConditions:     true,
+				ConfigOnlyNetworks: true,
+				IngressNetworks:    true,
+			},
+		},
+		{
+			doc:     "1.40 buildkit daemon",
+			ping:    types.Ping{OSType: "linux", BuilderVersion: types.BuilderBuildKit},
+			version: "1.40",
+			expected: ServerCapabilities{
+				APIVersion:         "1.40",
+				OSType:             "linux",
+				BuildKit:           true,
+				DeviceRequests:     true,
+				KernelMemoryTCP:    true,
+				WaitConditions:     true,
+				ConfigOnlyNetworks: true,
+				IngressNetworks:    true,
+			},
+		},
+		{
+			doc:     "1.41 experimental daemon",
+			ping:    types.Ping{OSType: "linux", Experimental: true},
+			version: "1.41",
+			expected: ServerCapabilities{
+				APIVersion:         "1.41",
+				OSType:             "linux",
+				Experimental:       true,
+				ContainerPlatform:  true,
+				DeviceRequests:     true,
+				KernelMemoryTCP:    true,
+				WaitConditions:     true,
+				ConfigOnlyNetworks: true,
+				IngressNetworks:    true,
+			},
+		},
+		{
+			doc:     "windows daemon has no kernel memory tcp",
+			ping:    types.Ping{OSType: "windows"},
+			version: "1.40",
+			expected: ServerCapabilities{
+				APIVersion:         "1.40",
+				OSType:             "windows",
+				DeviceRequests:     true,
+				WaitConditions:     true,
+				ConfigOnlyNetworks: true,
+				IngressNetworks:    true,
+			},
+		},
+	} {
+		assert.Check(t, is.DeepEqual(tc.expected, deriveCapabilities(tc.ping, tc.version)), tc.doc)
+	}
+}
+
+func TestServerCapabilitiesUsesPingCache(t *testing.T) {
+	var pings int
+	client := &Client{
+		version: "1.40",
+		pingTTL: time.Minute,
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			pings++
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header: http.Header{
+					"Api-Version":     {"1.41"},
+					"Ostype":          {"linux"},
+					"Builder-Version": {"2"},
+				},
+				Body: ioutil.NopCloser(bytes.NewReader(nil)),
+			}, nil
+		}),
+	}
+
+	for i := 0; i < 3; i++ {
+		caps, err := client.ServerCapabilities(context.Background())
+		assert.NilError(t, err)
+		// the client's pinned version is lower than the daemon's, so it
+		// bounds the capabilities
+		assert.Check(t, is.Equal("1.40", caps.APIVersion))
+		assert.Check(t, caps.BuildKit)
+		assert.Check(t, caps.DeviceRequests)
+		assert.Check(t, !caps.ContainerPlatform)
+	}
+	assert.Check(t, is.Equal(1, pings))
+}
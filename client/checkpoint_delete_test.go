@@ -1,24 +1,24 @@
-package client // import "github.com/docker/docker/client"
+package client_test
 
 import (
-	"bytes"
 	"context"
-	"fmt"
-	"io/ioutil"
 	"net/http"
-	"strings"
 	"testing"
 
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client/clienttest"
 	"github.com/docker/docker/errdefs"
 )
 
 func TestCheckpointDeleteError(t *testing.T) {
-	client := &Client{
-		client: newMockClient(errorMock(http.StatusInternalServerError, "Server error")),
+	rt := clienttest.NewTransport()
+	rt.Default(clienttest.ErrorResponse(http.StatusInternalServerError, "Server error"))
+	cli, err := clienttest.NewClient(rt)
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	err := client.CheckpointDelete(context.Background(), "container_id", types.CheckpointDeleteOptions{
+	err = cli.CheckpointDelete(context.Background(), "container_id", types.CheckpointDeleteOptions{
 		CheckpointID: "checkpoint_id",
 	})
 
@@ -28,24 +28,14 @@ func TestCheckpointDeleteError(t *testing.T) {
 }
 
 func TestCheckpointDelete(t *testing.T) {
-	expectedURL := "/containers/container_id/checkpoints/checkpoint_id"
-
-	client := &Client{
-		client: newMockClient(func(req *http.Request) (*http.Response, error) {
-			if !strings.HasPrefix(req.URL.Path, expectedURL) {
-				return nil, fmt.Errorf("Expected URL '%s', got '%s'", expectedURL, req.URL)
-			}
-			if req.Method != http.MethodDelete {
-				return nil, fmt.Errorf("expected DELETE method, got %s", req.Method)
-			}
-			return &http.Response{
-				StatusCode: http.StatusOK,
-				Body:       ioutil.NopCloser(bytes.NewReader([]byte(""))),
-			}, nil
-		}),
+	rt := clienttest.NewTransport()
+	rt.Handle(http.MethodDelete, "/containers/container_id/checkpoints/checkpoint_id", clienttest.RawResponse(http.StatusOK, "", nil))
+	cli, err := clienttest.NewClient(rt)
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	err := client.CheckpointDelete(context.Background(), "container_id", types.CheckpointDeleteOptions{
+	err = cli.CheckpointDelete(context.Background(), "container_id", types.CheckpointDeleteOptions{
 		CheckpointID: "checkpoint_id",
 	})
 
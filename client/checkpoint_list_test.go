@@ -1,54 +1,43 @@
-package client // import "github.com/docker/docker/client"
+package client_test
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
-	"fmt"
-	"io/ioutil"
 	"net/http"
-	"strings"
 	"testing"
 
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/client/clienttest"
 	"github.com/docker/docker/errdefs"
 )
 
 func TestCheckpointListError(t *testing.T) {
-	client := &Client{
-		client: newMockClient(errorMock(http.StatusInternalServerError, "Server error")),
+	rt := clienttest.NewTransport()
+	rt.Default(clienttest.ErrorResponse(http.StatusInternalServerError, "Server error"))
+	cli, err := clienttest.NewClient(rt)
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	_, err := client.CheckpointList(context.Background(), "container_id", types.CheckpointListOptions{})
+	_, err = cli.CheckpointList(context.Background(), "container_id", types.CheckpointListOptions{})
 	if !errdefs.IsSystem(err) {
 		t.Fatalf("expected a Server Error, got %[1]T: %[1]v", err)
 	}
 }
 
 func TestCheckpointList(t *testing.T) {
-	expectedURL := "/containers/container_id/checkpoints"
-
-	client := &Client{
-		client: newMockClient(func(req *http.Request) (*http.Response, error) {
-			if !strings.HasPrefix(req.URL.Path, expectedURL) {
-				return nil, fmt.Errorf("Expected URL '%s', got '%s'", expectedURL, req.URL)
-			}
-			content, err := json.Marshal([]types.Checkpoint{
-				{
-					Name: "checkpoint",
-				},
-			})
-			if err != nil {
-				return nil, err
-			}
-			return &http.Response{
-				StatusCode: http.StatusOK,
-				Body:       ioutil.NopCloser(bytes.NewReader(content)),
-			}, nil
-		}),
+	rt := clienttest.NewTransport()
+	rt.Handle(http.MethodGet, "/containers/container_id/checkpoints", clienttest.JSONResponse(http.StatusOK, []types.Checkpoint{
+		{
+			Name: "checkpoint",
+		},
+	}))
+	cli, err := clienttest.NewClient(rt)
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	checkpoints, err := client.CheckpointList(context.Background(), "container_id", types.CheckpointListOptions{})
+	checkpoints, err := cli.CheckpointList(context.Background(), "container_id", types.CheckpointListOptions{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -58,12 +47,15 @@ func TestCheckpointList(t *testing.T) {
 }
 
 func TestCheckpointListContainerNotFound(t *testing.T) {
-	client := &Client{
-		client: newMockClient(errorMock(http.StatusNotFound, "Server error")),
+	rt := clienttest.NewTransport()
+	rt.Default(clienttest.ErrorResponse(http.StatusNotFound, "Server error"))
+	cli, err := clienttest.NewClient(rt)
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	_, err := client.CheckpointList(context.Background(), "unknown", types.CheckpointListOptions{})
-	if err == nil || !IsErrNotFound(err) {
+	_, err = cli.CheckpointList(context.Background(), "unknown", types.CheckpointListOptions{})
+	if err == nil || !client.IsErrNotFound(err) {
 		t.Fatalf("expected a containerNotFound error, got %v", err)
 	}
 }
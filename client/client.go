@@ -49,6 +49,8 @@ import (
 	"net/url"
 	"path"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/docker/docker/api"
 	"github.com/docker/docker/api/types"
@@ -81,6 +83,39 @@ type Client struct {
 	customHTTPHeaders map[string]string
 	// manualOverride is set to true when the version was set by users.
 	manualOverride bool
+	// versionSource records where version came from; see VersionSource.
+	versionSource VersionSource
+	// retry is the policy applied to idempotent requests that fail for
+	// transient reasons; nil means requests are never retried.
+	retry *retryPolicy
+	// trace holds the hooks invoked around each request; nil disables
+	// tracing.
+	trace *TraceHooks
+	// metrics, when non-nil, accumulates transport metrics from every
+	// request.
+	metrics *MetricsCollector
+	// lenientVersioning makes the client silently drop request fields the
+	// effective API version does not support instead of rejecting the
+	// request.
+	lenientVersioning bool
+	// timeouts, when non-nil, holds the per-operation-class deadlines
+	// applied to requests whose context has none of its own.
+	timeouts *TimeoutConfig
+	// maxResponseSize bounds response bodies from non-streaming
+	// endpoints; zero or negative means unlimited.
+	maxResponseSize int64
+	// userAgent, when non-nil, replaces the default User-Agent wholesale;
+	// see WithUserAgent.
+	userAgent *string
+	// userAgentSuffix is appended to the default User-Agent; see
+	// WithUserAgentSuffix.
+	userAgentSuffix string
+	// hijackKeepAlive is the TCP keepalive period for hijacked
+	// connections; zero selects the default, negative disables.
+	hijackKeepAlive time.Duration
+	// hijackIdleReadTimeout, when positive, bounds how long reads on
+	// hijacked connections wait for data; see WithHijackIdleReadTimeout.
+	hijackIdleReadTimeout time.Duration
 
 	// negotiateVersion indicates if the client should automatically negotiate
 	// the API version to use when making requests. API version negotiation is
@@ -90,8 +125,37 @@ type Client struct {
 
 	// negotiated indicates that API version negotiation took place
 	negotiated bool
+
+	// negotiateLock makes negotiation one-shot when concurrent requests
+	// trigger it, and serializes manual (re-)negotiation with it.
+	negotiateLock sync.Mutex
+
+	// negotiateTimeout bounds the ping performed during API version
+	// negotiation, independent of the per-request context. Zero means the
+	// ping is bounded only by the caller's context.
+	negotiateTimeout time.Duration
+
+	// pingMu guards the cached ping result below.
+	pingMu sync.Mutex
+	// cachedPing is the last successful ping result; errors are never
+	// cached.
+	cachedPing *types.Ping
+	// cachedPingAt is when cachedPing was obtained.
+	cachedPingAt time.Time
+	// pingTTL is how long ServerInfo serves the cached ping result before
+	// pinging again. Zero or negative disables the cache.
+	pingTTL time.Duration
 }
 
+// defaultNegotiationTimeout bounds the negotiation ping so a hung daemon
+// cannot stall the first request indefinitely when lazy negotiation is
+// enabled. Override it with WithNegotiationTimeout.
+const defaultNegotiationTimeout = 5 * time.Second
+
+// defaultPingCacheTTL is how long ServerInfo considers a ping result fresh
+// when the TTL was not overridden with WithPingCacheTTL.
+const defaultPingCacheTTL = 30 * time.Second
+
 // CheckRedirect specifies the policy for dealing with redirect responses:
 // If the request is non-GET return `ErrRedirect`. Otherwise use the last response.
 //
@@ -122,11 +186,15 @@ func NewClientWithOpts(ops ...Opt) (*Client, error) {
 		return nil, err
 	}
 	c := &Client{
-		host:    DefaultDockerHost,
-		version: api.DefaultVersion,
-		client:  client,
-		proto:   defaultProto,
-		addr:    defaultAddr,
+		host:             DefaultDockerHost,
+		version:          api.DefaultVersion,
+		versionSource:    VersionSourceDefault,
+		client:           client,
+		proto:            defaultProto,
+		addr:             defaultAddr,
+		negotiateTimeout: defaultNegotiationTimeout,
+		pingTTL:          defaultPingCacheTTL,
+		maxResponseSize:  defaultMaxResponseSize,
 	}
 
 	for _, op := range ops {
@@ -180,8 +248,14 @@ func (cli *Client) Close() error {
 // It appends the query parameters to the path if they are not empty.
 func (cli *Client) getAPIPath(ctx context.Context, p string, query url.Values) string {
 	var apiPath string
-	if cli.negotiateVersion && !cli.negotiated {
-		cli.NegotiateAPIVersion(ctx)
+	if cli.negotiateVersion {
+		cli.negotiateLock.Lock()
+		if !cli.negotiated && !cli.manualOverride {
+			// errors are ignored here, as they always have been for lazy
+			// negotiation; the request proceeds with the default version
+			cli.negotiateAPIVersion(ctx)
+		}
+		cli.negotiateLock.Unlock()
 	}
 	if cli.version != "" {
 		v := strings.TrimPrefix(cli.version, "v")
@@ -203,10 +277,55 @@ func (cli *Client) ClientVersion() string {
 // was initialized with a fixed version (`opts.WithVersion(xx)`), no negotiation
 // will be performed.
 func (cli *Client) NegotiateAPIVersion(ctx context.Context) {
-	if !cli.manualOverride {
-		ping, _ := cli.Ping(ctx)
-		cli.negotiateAPIVersionPing(ping)
+	if cli.manualOverride {
+		cli.logSkippedNegotiation()
+		return
+	}
+	cli.negotiateLock.Lock()
+	defer cli.negotiateLock.Unlock()
+	cli.negotiateAPIVersion(ctx)
+}
+
+// NegotiateAPIVersionWithError is NegotiateAPIVersion with the failure
+// reported instead of swallowed: it returns a NegotiationError when the
+// daemon could not be reached, or when it responded with an API version
+// below the minimum this client supports. Like NegotiateAPIVersion it is a
+// no-op when a manual version override is in place.
+func (cli *Client) NegotiateAPIVersionWithError(ctx context.Context) error {
+	if cli.manualOverride {
+		cli.logSkippedNegotiation()
+		return nil
 	}
+	cli.negotiateLock.Lock()
+	defer cli.negotiateLock.Unlock()
+	return cli.negotiateAPIVersion(ctx)
+}
+
+// negotiateAPIVersion pings the daemon and adopts its API version, bounding
+// the ping with the client's negotiation timeout. The ping outcome is
+// applied even on failure, preserving the longstanding fallback behavior;
+// the error only reports why negotiation did not reach a healthy daemon.
+// The caller must hold negotiateLock.
+func (cli *Client) negotiateAPIVersion(ctx context.Context) error {
+	if cli.negotiateTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cli.negotiateTimeout)
+		defer cancel()
+	}
+	ping, err := cli.Ping(ctx)
+	cli.negotiateAPIVersionPing(ping)
+	if err != nil {
+		return NegotiationError{Cause: err}
+	}
+	apiVersion := ping.APIVersion
+	if apiVersion == "" {
+		// daemons predating versioning headers speak 1.24
+		apiVersion = "1.24"
+	}
+	if versions.LessThan(apiVersion, api.MinVersion) {
+		return NegotiationError{APIVersion: apiVersion, TooOld: true}
+	}
+	return nil
 }
 
 // NegotiateAPIVersionPing updates the client version to match the Ping.APIVersion
@@ -215,9 +334,13 @@ func (cli *Client) NegotiateAPIVersion(ctx context.Context) {
 // the client was initialized with a fixed version (`opts.WithVersion(xx)`), no
 // negotiation is performed.
 func (cli *Client) NegotiateAPIVersionPing(p types.Ping) {
-	if !cli.manualOverride {
-		cli.negotiateAPIVersionPing(p)
+	if cli.manualOverride {
+		cli.logSkippedNegotiation()
+		return
 	}
+	cli.negotiateLock.Lock()
+	defer cli.negotiateLock.Unlock()
+	cli.negotiateAPIVersionPing(p)
 }
 
 // negotiateAPIVersionPing queries the API and updates the version to match the
@@ -237,6 +360,7 @@ func (cli *Client) negotiateAPIVersionPing(p types.Ping) {
 	if versions.LessThan(p.APIVersion, cli.version) {
 		cli.version = p.APIVersion
 	}
+	cli.versionSource = VersionSourceNegotiation
 
 	// Store the results, so that automatic API version negotiation (if enabled)
 	// won't be performed on the next request.
@@ -9,10 +9,14 @@ import (
 	"os"
 	"runtime"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/docker/docker/api"
 	"github.com/docker/docker/api/types"
+	"github.com/pkg/errors"
 	"gotest.tools/v3/assert"
 	is "gotest.tools/v3/assert/cmp"
 	"gotest.tools/v3/env"
@@ -298,6 +302,95 @@ func TestNegotiateAPIVersionAutomatic(t *testing.T) {
 	assert.Equal(t, client.ClientVersion(), "1.35")
 }
 
+// TestNegotiateAPIVersionTimeout asserts that the negotiation ping is bounded
+// by the negotiation timeout, and that an unreachable daemon is reported as
+// such.
+func TestNegotiateAPIVersionTimeout(t *testing.T) {
+	httpClient := newMockClient(func(req *http.Request) (*http.Response, error) {
+		// a hung daemon: never respond until the request is given up on
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	})
+
+	client, err := NewClientWithOpts(
+		WithHTTPClient(httpClient),
+		WithAPIVersionNegotiation(),
+		WithNegotiationTimeout(10*time.Millisecond),
+	)
+	assert.NilError(t, err)
+
+	start := time.Now()
+	err = client.NegotiateAPIVersionWithError(context.Background())
+	assert.Check(t, time.Since(start) < 5*time.Second, "negotiation was not bounded by the timeout")
+	assert.Check(t, IsErrNegotiationFailed(err), "expected a NegotiationError, got %[1]T: %[1]v", err)
+
+	var nErr NegotiationError
+	assert.Assert(t, errors.As(err, &nErr))
+	assert.Check(t, !nErr.TooOld, "an unreachable daemon must not be reported as too old")
+	assert.Check(t, nErr.Cause != nil)
+}
+
+// TestNegotiateAPIVersionTooOld asserts that a daemon below the minimum
+// supported API version is reported with a typed error.
+func TestNegotiateAPIVersionTooOld(t *testing.T) {
+	httpClient := newMockClient(func(req *http.Request) (*http.Response, error) {
+		resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}
+		resp.Header.Set("API-Version", "1.10")
+		resp.Body = ioutil.NopCloser(strings.NewReader("OK"))
+		return resp, nil
+	})
+
+	client, err := NewClientWithOpts(
+		WithHTTPClient(httpClient),
+		WithAPIVersionNegotiation(),
+	)
+	assert.NilError(t, err)
+
+	err = client.NegotiateAPIVersionWithError(context.Background())
+	var nErr NegotiationError
+	assert.Assert(t, errors.As(err, &nErr), "expected a NegotiationError, got %[1]T: %[1]v", err)
+	assert.Check(t, nErr.TooOld)
+	assert.Check(t, is.Equal("1.10", nErr.APIVersion))
+	assert.ErrorContains(t, err, "too old")
+}
+
+// TestNegotiateAPIVersionConcurrent asserts that concurrent requests trigger
+// exactly one negotiation ping.
+func TestNegotiateAPIVersionConcurrent(t *testing.T) {
+	var pings int32
+	httpClient := newMockClient(func(req *http.Request) (*http.Response, error) {
+		resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}
+		if strings.Contains(req.URL.Path, "_ping") {
+			atomic.AddInt32(&pings, 1)
+			resp.Header.Set("API-Version", "1.35")
+			resp.Body = ioutil.NopCloser(strings.NewReader("OK"))
+			return resp, nil
+		}
+		resp.Header.Set("Content-Type", "application/json")
+		resp.Body = ioutil.NopCloser(strings.NewReader("{}"))
+		return resp, nil
+	})
+
+	client, err := NewClientWithOpts(
+		WithHTTPClient(httpClient),
+		WithAPIVersionNegotiation(),
+	)
+	assert.NilError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = client.Info(context.Background())
+		}()
+	}
+	wg.Wait()
+
+	assert.Check(t, is.Equal(int32(1), atomic.LoadInt32(&pings)))
+	assert.Equal(t, client.ClientVersion(), "1.35")
+}
+
 // TestNegotiateAPIVersionWithEmptyVersion asserts that initializing a client
 // with an empty version string does still allow API-version negotiation
 func TestNegotiateAPIVersionWithEmptyVersion(t *testing.T) {
@@ -0,0 +1,42 @@
+// Package clienttest provides helpers for testing code that talks to the
+// Docker API through the client package, without a daemon. A programmable
+// Transport serves canned responses per method and path and captures the
+// requests it saw; Server backs the tests that need real connection
+// semantics, such as the hijacked streams used by attach and exec.
+package clienttest // import "github.com/docker/docker/client/clienttest"
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/docker/docker/client"
+)
+
+// NewClient returns a client that sends every request through rt.
+// Additional options are applied after the transport is wired, so they may
+// override it.
+func NewClient(rt http.RoundTripper, ops ...client.Opt) (*client.Client, error) {
+	ops = append([]client.Opt{
+		client.WithHTTPClient(&http.Client{Transport: rt}),
+	}, ops...)
+	return client.NewClientWithOpts(ops...)
+}
+
+// versionlessPath strips the API version prefix the client puts in front of
+// every path, so handlers can be registered against the stable part.
+func versionlessPath(p string) string {
+	if !strings.HasPrefix(p, "/v") {
+		return p
+	}
+	rest := strings.TrimPrefix(p, "/v")
+	i := strings.Index(rest, "/")
+	if i < 0 {
+		return p
+	}
+	for _, r := range rest[:i] {
+		if (r < '0' || r > '9') && r != '.' {
+			return p
+		}
+	}
+	return rest[i:]
+}
@@ -0,0 +1,115 @@
+package clienttest_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/client/clienttest"
+	"github.com/docker/docker/errdefs"
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+func TestTransportHandlersAndCapture(t *testing.T) {
+	rt := clienttest.NewTransport()
+	rt.Handle(http.MethodGet, "/containers/json", clienttest.JSONResponse(http.StatusOK, []types.Container{
+		{ID: "container_id"},
+	}))
+
+	cli, err := clienttest.NewClient(rt)
+	assert.NilError(t, err)
+
+	containers, err := cli.ContainerList(context.Background(), types.ContainerListOptions{All: true})
+	assert.NilError(t, err)
+	assert.Assert(t, is.Len(containers, 1))
+	assert.Check(t, is.Equal("container_id", containers[0].ID))
+
+	requests := rt.Requests()
+	assert.Assert(t, is.Len(requests, 1))
+	assert.Check(t, is.Equal(http.MethodGet, requests[0].Method))
+	assert.Check(t, is.Equal("/containers/json", requests[0].Path))
+	assert.Check(t, is.Equal("1", requests[0].Query.Get("all")))
+}
+
+func TestTransportCapturesBody(t *testing.T) {
+	rt := clienttest.NewTransport()
+	rt.Handle(http.MethodPost, "/networks/create", clienttest.JSONResponse(http.StatusCreated, types.NetworkCreateResponse{ID: "network_id"}))
+
+	cli, err := clienttest.NewClient(rt)
+	assert.NilError(t, err)
+
+	_, err = cli.NetworkCreate(context.Background(), "mynet", types.NetworkCreate{Driver: "bridge"})
+	assert.NilError(t, err)
+
+	requests := rt.Requests()
+	assert.Assert(t, is.Len(requests, 1))
+	assert.Check(t, is.Contains(string(requests[0].Body), `"Driver":"bridge"`))
+}
+
+func TestTransportErrorResponse(t *testing.T) {
+	rt := clienttest.NewTransport()
+	rt.Handle(http.MethodGet, "/containers/nosuch/json", clienttest.ErrorResponse(http.StatusNotFound, "No such container: nosuch"))
+
+	cli, err := clienttest.NewClient(rt)
+	assert.NilError(t, err)
+
+	_, err = cli.ContainerInspect(context.Background(), "nosuch")
+	assert.Check(t, client.IsErrNotFound(err), "expected a not-found error, got %[1]T: %[1]v", err)
+}
+
+func TestTransportPlainTextError(t *testing.T) {
+	rt := clienttest.NewTransport()
+	rt.Default(clienttest.PlainTextErrorResponse(http.StatusInternalServerError, "Server error"))
+
+	cli, err := clienttest.NewClient(rt)
+	assert.NilError(t, err)
+
+	_, err = cli.Info(context.Background())
+	assert.Check(t, errdefs.IsSystem(err), "expected a system error, got %[1]T: %[1]v", err)
+	assert.ErrorContains(t, err, "Server error")
+}
+
+func TestTransportUnhandledPath(t *testing.T) {
+	cli, err := clienttest.NewClient(clienttest.NewTransport())
+	assert.NilError(t, err)
+
+	_, err = cli.Info(context.Background())
+	assert.Check(t, client.IsErrNotFound(err), "unhandled paths must fail like a missing route, got %[1]T: %[1]v", err)
+}
+
+func TestServerHijack(t *testing.T) {
+	srv := clienttest.NewServer()
+	defer srv.Close()
+
+	srv.HandleHijack("/containers/container_id/attach", func(conn net.Conn) {
+		// echo a single line back to the attached client
+		line, err := bufio.NewReader(conn).ReadString('\n')
+		if err != nil {
+			return
+		}
+		io.WriteString(conn, line)
+	})
+
+	cli, err := srv.Client()
+	assert.NilError(t, err)
+
+	attach, err := cli.ContainerAttach(context.Background(), "container_id", types.ContainerAttachOptions{
+		Stream: true,
+		Stdin:  true,
+		Stdout: true,
+	})
+	assert.NilError(t, err)
+	defer attach.Close()
+
+	_, err = io.WriteString(attach.Conn, "hello\n")
+	assert.NilError(t, err)
+	echoed, err := attach.Reader.ReadString('\n')
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("hello\n", echoed))
+}
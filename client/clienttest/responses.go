@@ -0,0 +1,51 @@
+package clienttest // import "github.com/docker/docker/client/clienttest"
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/docker/docker/api/types"
+)
+
+// RawResponse returns a handler serving the given body verbatim. An empty
+// contentType leaves the header unset.
+func RawResponse(statusCode int, contentType string, body []byte) Handler {
+	return func(*http.Request) (*http.Response, error) {
+		header := http.Header{}
+		if contentType != "" {
+			header.Set("Content-Type", contentType)
+		}
+		return &http.Response{
+			StatusCode: statusCode,
+			Header:     header,
+			Body:       ioutil.NopCloser(bytes.NewReader(body)),
+		}, nil
+	}
+}
+
+// JSONResponse returns a handler serving v marshalled as JSON, with the
+// content type the client expects from the daemon.
+func JSONResponse(statusCode int, v interface{}) Handler {
+	return func(req *http.Request) (*http.Response, error) {
+		body, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return RawResponse(statusCode, "application/json", body)(req)
+	}
+}
+
+// ErrorResponse returns a handler serving an error in the daemon's JSON
+// error shape, so the client's errdefs mapping applies as it would against
+// a real daemon.
+func ErrorResponse(statusCode int, message string) Handler {
+	return JSONResponse(statusCode, &types.ErrorResponse{Message: message})
+}
+
+// PlainTextErrorResponse returns a handler serving an error the way daemons
+// predating API 1.24 did: as a plain text body.
+func PlainTextErrorResponse(statusCode int, message string) Handler {
+	return RawResponse(statusCode, "", []byte(message))
+}
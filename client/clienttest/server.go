@@ -0,0 +1,71 @@
+package clienttest // import "github.com/docker/docker/client/clienttest"
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/docker/docker/client"
+)
+
+// Server is a real HTTP server for tests that need connection semantics a
+// RoundTripper cannot provide, such as the hijacked raw streams behind
+// attach and exec. Paths are matched without the API version prefix, like
+// on a Transport.
+type Server struct {
+	mux  *http.ServeMux
+	http *httptest.Server
+}
+
+// NewServer returns a started Server. The caller must Close it.
+func NewServer() *Server {
+	s := &Server{mux: http.NewServeMux()}
+	s.http = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		req.URL.Path = versionlessPath(req.URL.Path)
+		s.mux.ServeHTTP(w, req)
+	}))
+	return s
+}
+
+// Close shuts the server down.
+func (s *Server) Close() {
+	s.http.Close()
+}
+
+// Client returns a client connected to the server.
+func (s *Server) Client(ops ...client.Opt) (*client.Client, error) {
+	host := "tcp://" + s.http.Listener.Addr().String()
+	return client.NewClientWithOpts(append([]client.Opt{client.WithHost(host)}, ops...)...)
+}
+
+// Handle registers a handler on the server.
+func (s *Server) Handle(path string, handler http.Handler) {
+	s.mux.Handle(path, handler)
+}
+
+// HandleFunc registers a handler function on the server.
+func (s *Server) HandleFunc(path string, handler func(http.ResponseWriter, *http.Request)) {
+	s.mux.HandleFunc(path, handler)
+}
+
+// HandleHijack registers a handler that upgrades the connection the way the
+// daemon does for attach and exec, then hands the raw connection to fn. The
+// connection is closed when fn returns.
+func (s *Server) HandleHijack(path string, fn func(conn net.Conn)) {
+	s.mux.HandleFunc(path, func(w http.ResponseWriter, req *http.Request) {
+		hj, ok := w.(http.Hijacker)
+		if !ok {
+			http.Error(w, "cannot hijack connection", http.StatusInternalServerError)
+			return
+		}
+		conn, _, err := hj.Hijack()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer conn.Close()
+		fmt.Fprint(conn, "HTTP/1.1 101 UPGRADED\r\nContent-Type: application/vnd.docker.raw-stream\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\n")
+		fn(conn)
+	})
+}
@@ -0,0 +1,107 @@
+package clienttest // import "github.com/docker/docker/client/clienttest"
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// Handler produces the response for one request.
+type Handler func(*http.Request) (*http.Response, error)
+
+// TransportFunc is the RoundTripper equivalent of http.HandlerFunc: it lets
+// a bare function serve as the transport of an http.Client.
+type TransportFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip implements http.RoundTripper.
+func (tf TransportFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return tf(req)
+}
+
+// Request is a request captured by a Transport, with the body already read
+// so assertions do not have to deal with a consumed stream.
+type Request struct {
+	Method string
+	// Path is the request path with the API version prefix stripped.
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+}
+
+// Transport is a programmable http.RoundTripper. Handlers are registered
+// per method and path; requests with no matching handler go to the default
+// handler, or fail with a daemon-shaped "page not found" error when none is
+// set. Every request is captured for later assertions. A Transport is safe
+// for concurrent use.
+type Transport struct {
+	mu       sync.Mutex
+	handlers map[string]Handler
+	fallback Handler
+	requests []Request
+}
+
+// NewTransport returns an empty Transport.
+func NewTransport() *Transport {
+	return &Transport{handlers: map[string]Handler{}}
+}
+
+// Handle registers the handler for requests with the given method and path.
+// The path is matched without the API version prefix, e.g.
+// "/containers/json".
+func (t *Transport) Handle(method, path string, handler Handler) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.handlers[method+" "+path] = handler
+}
+
+// Default registers the handler for requests no other handler matches.
+func (t *Transport) Default(handler Handler) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.fallback = handler
+}
+
+// Requests returns the requests captured so far, oldest first.
+func (t *Transport) Requests() []Request {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Request(nil), t.requests...)
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		// hand the handler an unread body again
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	path := versionlessPath(req.URL.Path)
+
+	t.mu.Lock()
+	t.requests = append(t.requests, Request{
+		Method: req.Method,
+		Path:   path,
+		Query:  req.URL.Query(),
+		Header: req.Header.Clone(),
+		Body:   body,
+	})
+	handler, ok := t.handlers[req.Method+" "+path]
+	if !ok {
+		handler = t.fallback
+	}
+	t.mu.Unlock()
+
+	if handler == nil {
+		return ErrorResponse(http.StatusNotFound, "page not found")(req)
+	}
+	return handler(req)
+}
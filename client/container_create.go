@@ -24,7 +24,29 @@ type configWrapper struct {
 func (cli *Client) ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, platform *specs.Platform, containerName string) (container.ContainerCreateCreatedBody, error) {
 	var response container.ContainerCreateCreatedBody
 
-	if err := cli.NewVersionError("1.25", "stop timeout"); config != nil && config.StopTimeout != nil && err != nil {
+	err := cli.downgradeRequest(
+		versionedField{
+			name: "stop timeout", version: "1.25",
+			set:   func() bool { return config != nil && config.StopTimeout != nil },
+			clear: func() { config.StopTimeout = nil },
+		},
+		versionedField{
+			name: "device requests", version: "1.40",
+			set:   func() bool { return hostConfig != nil && len(hostConfig.DeviceRequests) > 0 },
+			clear: func() { hostConfig.DeviceRequests = nil },
+		},
+		versionedField{
+			name: "kernel memory TCP limit", version: "1.40",
+			set:   func() bool { return hostConfig != nil && hostConfig.KernelMemoryTCP != 0 },
+			clear: func() { hostConfig.KernelMemoryTCP = 0 },
+		},
+		versionedField{
+			name: "specify container image platform", version: "1.41",
+			set:   func() bool { return platform != nil },
+			clear: func() { platform = nil },
+		},
+	)
+	if err != nil {
 		return response, err
 	}
 
@@ -33,10 +55,6 @@ func (cli *Client) ContainerCreate(ctx context.Context, config *container.Config
 		hostConfig.AutoRemove = false
 	}
 
-	if err := cli.NewVersionError("1.41", "specify container image platform"); platform != nil && err != nil {
-		return response, err
-	}
-
 	query := url.Values{}
 	if platform != nil {
 		query.Set("platform", platforms.Format(*platform))
@@ -0,0 +1,133 @@
+package client // import "github.com/docker/docker/client"
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/pkg/errors"
+)
+
+// execInspectPollInterval is how often ContainerExecRun re-inspects an exec
+// whose stream has ended but which the daemon still reports as running.
+const execInspectPollInterval = 100 * time.Millisecond
+
+// ExecRunOptions holds parameters for ContainerExecRun.
+type ExecRunOptions struct {
+	// Cmd is the command to run and its arguments.
+	Cmd []string
+	// Env are additional environment variables, as "KEY=value".
+	Env []string
+	// WorkingDir is the working directory for the command.
+	WorkingDir string
+	// User is the user to run the command as.
+	User string
+	// Privileged runs the command with extended privileges.
+	Privileged bool
+	// TTY allocates a pseudo-terminal; stdout and stderr are then one
+	// combined stream and end up together in the result's Stdout.
+	TTY bool
+	// Stdin, when non-nil, is piped to the command's standard input.
+	Stdin io.Reader
+}
+
+// ExecRunResult is what a finished ContainerExecRun reports.
+type ExecRunResult struct {
+	// ExitCode is the command's exit status.
+	ExitCode int
+	// Stdout holds the command's standard output; with TTY set it holds
+	// the combined output.
+	Stdout []byte
+	// Stderr holds the command's standard error; always empty with TTY
+	// set.
+	Stderr []byte
+}
+
+// ContainerExecRun runs a command in a running container and returns its
+// demuxed output and exit code — the exec create/attach/demux/inspect dance,
+// including the inspect-after-stream-end race, handled in one call. The
+// exec's streams are read to the end before the exit code is collected;
+// cancelling the context tears the connection down and aborts the run.
+func (cli *Client) ContainerExecRun(ctx context.Context, containerID string, options ExecRunOptions) (ExecRunResult, error) {
+	var result ExecRunResult
+
+	execConfig := types.ExecConfig{
+		User:         options.User,
+		Privileged:   options.Privileged,
+		Tty:          options.TTY,
+		AttachStdin:  options.Stdin != nil,
+		AttachStdout: true,
+		AttachStderr: true,
+		Env:          options.Env,
+		WorkingDir:   options.WorkingDir,
+		Cmd:          options.Cmd,
+	}
+	createResp, err := cli.ContainerExecCreate(ctx, containerID, execConfig)
+	if err != nil {
+		return result, err
+	}
+
+	attachResp, err := cli.ContainerExecAttach(ctx, createResp.ID, types.ExecStartCheck{Tty: options.TTY})
+	if err != nil {
+		return result, err
+	}
+	defer attachResp.Close()
+
+	// tear the connection down when the context ends, so blocked reads
+	// return
+	watchDone := make(chan struct{})
+	defer close(watchDone)
+	go func() {
+		select {
+		case <-ctx.Done():
+			attachResp.Close()
+		case <-watchDone:
+		}
+	}()
+
+	if options.Stdin != nil {
+		go func() {
+			io.Copy(attachResp.Conn, options.Stdin)
+			attachResp.CloseWrite()
+		}()
+	}
+
+	if options.TTY {
+		var stdout []byte
+		stdout, err = ioutil.ReadAll(attachResp.Reader)
+		result.Stdout = stdout
+	} else {
+		var stdout, stderr bytes.Buffer
+		_, err = stdcopy.StdCopy(&stdout, &stderr, attachResp.Reader)
+		result.Stdout = stdout.Bytes()
+		result.Stderr = stderr.Bytes()
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		return result, errors.Wrap(err, "error reading exec output")
+	}
+
+	// the stream has ended, but the daemon may not have reaped the
+	// process yet; poll until it reports the exec as finished
+	for {
+		inspect, err := cli.ContainerExecInspect(ctx, createResp.ID)
+		if err != nil {
+			return result, err
+		}
+		if !inspect.Running {
+			result.ExitCode = inspect.ExitCode
+			return result, nil
+		}
+		select {
+		case <-ctx.Done():
+			return result, ctx.Err()
+		case <-time.After(execInspectPollInterval):
+		}
+	}
+}
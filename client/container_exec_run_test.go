@@ -0,0 +1,130 @@
+package client // import "github.com/docker/docker/client"
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/server/httputils"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/pkg/errors"
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+// newExecTestServer stands up a hijack-capable daemon stub for the exec
+// endpoints: create answers with a fixed ID, start hands the connection to
+// stream, and inspect reports the given exit code once the stream ran.
+func newExecTestServer(t *testing.T, exitCode int, stream func(r io.Reader, w io.Writer)) *Client {
+	t.Helper()
+	var inspects int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch {
+		case strings.HasSuffix(req.URL.Path, "/exec"):
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"Id":"exec123"}`)
+		case strings.HasSuffix(req.URL.Path, "/start"):
+			r, rw, err := httputils.HijackConnection(w)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			defer r.Close()
+			fmt.Fprint(rw, "HTTP/1.1 101 UPGRADED\r\nContent-Type: application/vnd.docker.raw-stream\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\n")
+			stream(r, rw)
+		case strings.HasSuffix(req.URL.Path, "/json"):
+			w.Header().Set("Content-Type", "application/json")
+			// report the exec as still running once, like a daemon that
+			// has not reaped the process yet
+			if atomic.AddInt32(&inspects, 1) == 1 {
+				fmt.Fprint(w, `{"Running":true,"ExitCode":0}`)
+				return
+			}
+			fmt.Fprintf(w, `{"Running":false,"ExitCode":%d}`, exitCode)
+		default:
+			http.Error(w, "unexpected request", http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	serverURL, err := url.Parse(ts.URL)
+	assert.NilError(t, err)
+	client, err := NewClientWithOpts(WithHost("tcp://" + serverURL.Host))
+	assert.NilError(t, err)
+	return client
+}
+
+func TestContainerExecRun(t *testing.T) {
+	client := newExecTestServer(t, 3, func(r io.Reader, w io.Writer) {
+		stdcopy.NewStdWriter(w, stdcopy.Stdout).Write([]byte("hello\n"))
+		stdcopy.NewStdWriter(w, stdcopy.Stderr).Write([]byte("oops\n"))
+	})
+
+	result, err := client.ContainerExecRun(context.Background(), "container_id", ExecRunOptions{
+		Cmd: []string{"sh", "-c", "echo hello; echo oops >&2; exit 3"},
+	})
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(3, result.ExitCode))
+	assert.Check(t, is.Equal("hello\n", string(result.Stdout)))
+	assert.Check(t, is.Equal("oops\n", string(result.Stderr)))
+}
+
+func TestContainerExecRunTTY(t *testing.T) {
+	client := newExecTestServer(t, 0, func(r io.Reader, w io.Writer) {
+		// a TTY stream is raw, not multiplexed
+		w.Write([]byte("combined output\n"))
+	})
+
+	result, err := client.ContainerExecRun(context.Background(), "container_id", ExecRunOptions{
+		Cmd: []string{"top"},
+		TTY: true,
+	})
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(0, result.ExitCode))
+	assert.Check(t, is.Equal("combined output\n", string(result.Stdout)))
+	assert.Check(t, is.Len(result.Stderr, 0))
+}
+
+func TestContainerExecRunStdin(t *testing.T) {
+	client := newExecTestServer(t, 0, func(r io.Reader, w io.Writer) {
+		// echo the first line of stdin back on stdout
+		line, err := bufio.NewReader(r).ReadString('\n')
+		if err != nil {
+			return
+		}
+		stdcopy.NewStdWriter(w, stdcopy.Stdout).Write([]byte(line))
+	})
+
+	result, err := client.ContainerExecRun(context.Background(), "container_id", ExecRunOptions{
+		Cmd:   []string{"cat"},
+		Stdin: bytes.NewBufferString("ping\n"),
+	})
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("ping\n", string(result.Stdout)))
+}
+
+func TestContainerExecRunCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	client := newExecTestServer(t, 0, func(r io.Reader, w io.Writer) {
+		// a command that never finishes
+		io.Copy(io.Writer(bytes.NewBuffer(nil)), r)
+	})
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	_, err := client.ContainerExecRun(ctx, "container_id", ExecRunOptions{
+		Cmd: []string{"sleep", "infinity"},
+	})
+	assert.Assert(t, errors.Is(err, context.Canceled), "got %v", err)
+}
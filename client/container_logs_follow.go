@@ -0,0 +1,207 @@
+package client // import "github.com/docker/docker/client"
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/pkg/errors"
+)
+
+// FollowLogsOptions holds parameters for ContainerLogsFollow.
+type FollowLogsOptions struct {
+	ShowStdout bool
+	ShowStderr bool
+	// Timestamps keeps the daemon's timestamps on the delivered lines.
+	// They are always requested internally — resuming depends on them —
+	// and stripped back out unless this is set.
+	Timestamps bool
+	// Details requests extra log attributes, as for ContainerLogs.
+	Details bool
+	// Since and Tail bound the initial connection, as for ContainerLogs.
+	Since string
+	Tail  string
+	// TTY must be set when the container runs with a TTY: its log stream
+	// is raw instead of multiplexed.
+	TTY bool
+	// Reconnecting, when non-nil, is called before each reconnection
+	// attempt with the error that ended the previous stream.
+	Reconnecting func(attempt int, err error)
+	// InitialBackoff and MaxBackoff shape the delay between reconnection
+	// attempts; zero values select defaults.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// ContainerLogsFollow follows a container's logs like ContainerLogs with
+// Follow set, but survives dropped connections: on a stream error it
+// reconnects with Since pointing at the last line it delivered, skipping the
+// lines it already has, so the caller sees one continuous stream with no
+// gaps and no duplicates. The stream ends — with io.EOF for the reader —
+// when the daemon closes it cleanly, and with the relevant error when the
+// context ends. The returned stream is multiplexed exactly like
+// ContainerLogs' unless TTY is set.
+func (cli *Client) ContainerLogsFollow(ctx context.Context, containerID string, options FollowLogsOptions) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go cli.followLogs(ctx, containerID, options, pw)
+	return pr
+}
+
+func (cli *Client) followLogs(ctx context.Context, containerID string, options FollowLogsOptions, pw *io.PipeWriter) {
+	state := &logResumeState{keepTS: options.Timestamps}
+
+	var stdout, stderr *logLineWriter
+	if options.TTY {
+		stdout = &logLineWriter{s: state, out: pw}
+		stderr = stdout
+	} else {
+		stdout = &logLineWriter{s: state, out: stdcopy.NewStdWriter(pw, stdcopy.Stdout)}
+		stderr = &logLineWriter{s: state, out: stdcopy.NewStdWriter(pw, stdcopy.Stderr)}
+	}
+
+	initialBackoff := options.InitialBackoff
+	if initialBackoff <= 0 {
+		initialBackoff = defaultRetryInitialBackoff
+	}
+	maxBackoff := options.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultRetryMaxBackoff
+	}
+
+	var (
+		since   = options.Since
+		tail    = options.Tail
+		attempt int
+		backoff = initialBackoff
+	)
+	for {
+		rc, err := cli.ContainerLogs(ctx, containerID, types.ContainerLogsOptions{
+			ShowStdout: options.ShowStdout,
+			ShowStderr: options.ShowStderr,
+			Timestamps: true,
+			Details:    options.Details,
+			Follow:     true,
+			Since:      since,
+			Tail:       tail,
+		})
+		if err == nil {
+			if options.TTY {
+				_, err = io.Copy(stdout, rc)
+			} else {
+				_, err = stdcopy.StdCopy(stdout, stderr, rc)
+			}
+			rc.Close()
+			if err == nil {
+				// the daemon ended the stream cleanly
+				pw.Close()
+				return
+			}
+			// delivered at least this stream; reset the backoff
+			attempt = 0
+			backoff = initialBackoff
+		}
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || ctx.Err() != nil {
+			pw.CloseWithError(ctx.Err())
+			return
+		}
+		if IsErrNotFound(err) {
+			// the container is gone; reconnecting cannot help
+			pw.CloseWithError(err)
+			return
+		}
+
+		attempt++
+		if options.Reconnecting != nil {
+			options.Reconnecting(attempt, err)
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			pw.CloseWithError(ctx.Err())
+			return
+		case <-timer.C:
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+
+		// resume from the last delivered line; lines up to and including
+		// it are skipped on the new stream
+		if ts, ok := state.last(); ok {
+			since = fmt.Sprintf("%d.%09d", ts.Unix(), ts.Nanosecond())
+			tail = ""
+			state.resume()
+		}
+	}
+}
+
+// logResumeState tracks the timestamp of the last delivered log line, shared
+// by the stdout and stderr writers of one followed stream.
+type logResumeState struct {
+	keepTS  bool
+	lastTS  time.Time
+	haveTS  bool
+	skipOld bool
+}
+
+func (s *logResumeState) last() (time.Time, bool) {
+	return s.lastTS, s.haveTS
+}
+
+// resume marks the boundary after a reconnect: lines not newer than the last
+// delivered one are duplicates and get dropped.
+func (s *logResumeState) resume() {
+	s.skipOld = true
+}
+
+// logLineWriter buffers one log stream into lines, drops boundary
+// duplicates after a reconnect, and strips the internal timestamps back out
+// before forwarding. Log streams are only ever written from one goroutine,
+// so no locking is needed.
+type logLineWriter struct {
+	s   *logResumeState
+	out io.Writer
+	buf []byte
+}
+
+func (w *logLineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		line := w.buf[:i+1]
+		if err := w.deliver(line); err != nil {
+			return 0, err
+		}
+		w.buf = w.buf[i+1:]
+	}
+}
+
+func (w *logLineWriter) deliver(line []byte) error {
+	rest := line
+	sp := bytes.IndexByte(line, ' ')
+	if sp > 0 {
+		if ts, err := time.Parse(time.RFC3339Nano, string(line[:sp])); err == nil {
+			if w.s.skipOld {
+				if !ts.After(w.s.lastTS) {
+					return nil
+				}
+				w.s.skipOld = false
+			}
+			w.s.lastTS = ts
+			w.s.haveTS = true
+			if !w.s.keepTS {
+				rest = line[sp+1:]
+			}
+		}
+	}
+	_, err := w.out.Write(rest)
+	return err
+}
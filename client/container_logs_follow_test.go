@@ -0,0 +1,181 @@
+package client // import "github.com/docker/docker/client"
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/pkg/stdcopy"
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+// brokenReader ends a log stream the way a dropped connection does.
+type brokenReader struct {
+	r   io.Reader
+	err error
+}
+
+func (r *brokenReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err == io.EOF {
+		err = r.err
+	}
+	return n, err
+}
+
+func muxLogLines(lines ...string) []byte {
+	var buf bytes.Buffer
+	w := stdcopy.NewStdWriter(&buf, stdcopy.Stdout)
+	for _, line := range lines {
+		w.Write([]byte(line + "\n"))
+	}
+	return buf.Bytes()
+}
+
+func TestContainerLogsFollowResumes(t *testing.T) {
+	const (
+		l1 = "2020-01-01T00:00:00.000000001Z one"
+		l2 = "2020-01-01T00:00:00.000000002Z two"
+		l3 = "2020-01-01T00:00:00.000000003Z three"
+	)
+	var (
+		mu     sync.Mutex
+		conns  int
+		sinces []string
+	)
+	client := &Client{
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			mu.Lock()
+			conns++
+			conn := conns
+			sinces = append(sinces, req.URL.Query().Get("since"))
+			mu.Unlock()
+			if conn == 1 {
+				// dies mid-stream after two lines
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body: ioutil.NopCloser(&brokenReader{
+						r:   bytes.NewReader(muxLogLines(l1, l2)),
+						err: io.ErrUnexpectedEOF,
+					}),
+				}, nil
+			}
+			// the resumed stream replays the boundary line, then ends
+			// cleanly
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader(muxLogLines(l2, l3))),
+			}, nil
+		}),
+	}
+
+	var reconnects int
+	rc := client.ContainerLogsFollow(context.Background(), "container_id", FollowLogsOptions{
+		ShowStdout:     true,
+		InitialBackoff: time.Millisecond,
+		Reconnecting:   func(attempt int, err error) { reconnects++ },
+	})
+	defer rc.Close()
+
+	var stdout, stderr bytes.Buffer
+	_, err := stdcopy.StdCopy(&stdout, &stderr, rc)
+	assert.NilError(t, err)
+
+	// continuous, deduplicated, and with the internal timestamps stripped
+	assert.Check(t, is.Equal("one\ntwo\nthree\n", stdout.String()))
+	assert.Check(t, is.Equal("", stderr.String()))
+	assert.Check(t, reconnects >= 1, "expected at least one reconnect")
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Assert(t, is.Len(sinces, 2))
+	assert.Check(t, is.Equal("", sinces[0]))
+	// resumed from the last delivered line's timestamp
+	assert.Check(t, is.Equal("1577836800.000000002", sinces[1]))
+}
+
+func TestContainerLogsFollowTTY(t *testing.T) {
+	const (
+		l1 = "2020-01-01T00:00:00.000000001Z one"
+		l2 = "2020-01-01T00:00:00.000000002Z two"
+	)
+	var conns int
+	client := &Client{
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			conns++
+			if conns == 1 {
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body: ioutil.NopCloser(&brokenReader{
+						r:   strings.NewReader(l1 + "\n"),
+						err: io.ErrUnexpectedEOF,
+					}),
+				}, nil
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(strings.NewReader(l1 + "\n" + l2 + "\n")),
+			}, nil
+		}),
+	}
+
+	rc := client.ContainerLogsFollow(context.Background(), "container_id", FollowLogsOptions{
+		ShowStdout:     true,
+		TTY:            true,
+		InitialBackoff: time.Millisecond,
+	})
+	defer rc.Close()
+
+	out, err := ioutil.ReadAll(rc)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("one\ntwo\n", string(out)))
+}
+
+func TestContainerLogsFollowKeepsTimestamps(t *testing.T) {
+	const l1 = "2020-01-01T00:00:00.000000001Z one"
+	client := &Client{
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader(muxLogLines(l1))),
+			}, nil
+		}),
+	}
+
+	rc := client.ContainerLogsFollow(context.Background(), "container_id", FollowLogsOptions{
+		ShowStdout: true,
+		Timestamps: true,
+	})
+	defer rc.Close()
+
+	var stdout, stderr bytes.Buffer
+	_, err := stdcopy.StdCopy(&stdout, &stderr, rc)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(l1+"\n", stdout.String()))
+}
+
+func TestContainerLogsFollowCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	client := &Client{
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(blockUntilDone{ctx: req.Context()}),
+			}, nil
+		}),
+	}
+
+	rc := client.ContainerLogsFollow(ctx, "container_id", FollowLogsOptions{ShowStdout: true})
+	defer rc.Close()
+
+	cancel()
+	_, err := ioutil.ReadAll(rc)
+	assert.ErrorContains(t, err, "context canceled")
+}
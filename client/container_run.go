@@ -0,0 +1,163 @@
+package client // import "github.com/docker/docker/client"
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/pkg/stdcopy"
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+// WaitConditionHealthy makes ContainerRun wait until the container's
+// healthcheck reports healthy, instead of waiting for an exit. It is
+// implemented client-side and is not a valid condition for ContainerWait.
+const WaitConditionHealthy = container.WaitCondition("healthy")
+
+// healthPollInterval is how often ContainerRun re-inspects a container when
+// waiting for it to become healthy.
+const healthPollInterval = 100 * time.Millisecond
+
+// ContainerRunOptions holds parameters for ContainerRun.
+type ContainerRunOptions struct {
+	// Config, HostConfig, NetworkingConfig and Platform describe the
+	// container, as for ContainerCreate.
+	Config           *container.Config
+	HostConfig       *container.HostConfig
+	NetworkingConfig *network.NetworkingConfig
+	Platform         *specs.Platform
+	// Name optionally names the container.
+	Name string
+	// Condition is what to wait for once the container is started:
+	// "not-running", "next-exit" (the default) or WaitConditionHealthy.
+	Condition container.WaitCondition
+	// Timeout bounds the whole run, from create to the awaited condition.
+	// Zero leaves the run bounded only by the caller's context.
+	Timeout time.Duration
+	// CaptureOutput collects the container's stdout and stderr, demuxed
+	// from the log stream, into the result.
+	CaptureOutput bool
+	// Remove deletes the container once the run finishes, succeed or
+	// fail, including on timeout and cancellation.
+	Remove bool
+}
+
+// ContainerRunResult is what a finished ContainerRun reports.
+type ContainerRunResult struct {
+	// ContainerID is the created container's ID. It is set as soon as the
+	// container is created, also when a later step fails.
+	ContainerID string
+	// StatusCode is the container's exit status; zero when waiting for
+	// WaitConditionHealthy.
+	StatusCode int64
+	// Stdout and Stderr hold the captured output when CaptureOutput was
+	// set.
+	Stdout []byte
+	Stderr []byte
+}
+
+// ContainerRun creates and starts a container, waits for the requested
+// condition, and optionally captures its output — the create/wait/start
+// dance that callers otherwise have to get right themselves. The wait is
+// registered before the container is started, so a fast exit cannot be
+// missed. See ContainerRunOptions for cleanup and timeout behavior.
+func (cli *Client) ContainerRun(ctx context.Context, options ContainerRunOptions) (ContainerRunResult, error) {
+	var result ContainerRunResult
+
+	if options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.Timeout)
+		defer cancel()
+	}
+
+	createResp, err := cli.ContainerCreate(ctx, options.Config, options.HostConfig, options.NetworkingConfig, options.Platform, options.Name)
+	if err != nil {
+		return result, err
+	}
+	result.ContainerID = createResp.ID
+	if options.Remove {
+		// removal must also happen when the run's context has expired
+		defer cli.ContainerRemove(context.Background(), createResp.ID, types.ContainerRemoveOptions{Force: true})
+	}
+
+	condition := options.Condition
+	if condition == "" {
+		condition = container.WaitConditionNextExit
+	}
+
+	var (
+		waitC <-chan container.ContainerWaitOKBody
+		errC  <-chan error
+	)
+	if condition != WaitConditionHealthy {
+		// register the wait before starting so the exit cannot race it
+		waitC, errC = cli.ContainerWait(ctx, createResp.ID, condition)
+	}
+
+	if err := cli.ContainerStart(ctx, createResp.ID, types.ContainerStartOptions{}); err != nil {
+		return result, err
+	}
+
+	if condition == WaitConditionHealthy {
+		if err := cli.waitForHealthy(ctx, createResp.ID); err != nil {
+			return result, err
+		}
+	} else {
+		select {
+		case waitBody := <-waitC:
+			result.StatusCode = waitBody.StatusCode
+			if waitBody.Error != nil {
+				return result, errors.New(waitBody.Error.Message)
+			}
+		case err := <-errC:
+			return result, err
+		}
+	}
+
+	if options.CaptureOutput {
+		logs, err := cli.ContainerLogs(ctx, createResp.ID, types.ContainerLogsOptions{ShowStdout: true, ShowStderr: true})
+		if err != nil {
+			return result, errors.Wrap(err, "unable to collect container output")
+		}
+		defer logs.Close()
+		var stdout, stderr bytes.Buffer
+		if _, err := stdcopy.StdCopy(&stdout, &stderr, logs); err != nil {
+			return result, errors.Wrap(err, "unable to demux container output")
+		}
+		result.Stdout = stdout.Bytes()
+		result.Stderr = stderr.Bytes()
+	}
+
+	return result, nil
+}
+
+// waitForHealthy polls the container until its healthcheck reports healthy,
+// erroring out when the container stops running before that happens.
+func (cli *Client) waitForHealthy(ctx context.Context, containerID string) error {
+	for {
+		inspect, err := cli.ContainerInspect(ctx, containerID)
+		if err != nil {
+			return err
+		}
+		if inspect.State != nil {
+			if inspect.State.Health == nil {
+				return errors.New("container has no healthcheck to wait for")
+			}
+			if inspect.State.Health.Status == types.Healthy {
+				return nil
+			}
+			if !inspect.State.Running {
+				return errors.Errorf("container exited with status %d before becoming healthy", inspect.State.ExitCode)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(healthPollInterval):
+		}
+	}
+}
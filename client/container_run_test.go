@@ -0,0 +1,191 @@
+package client // import "github.com/docker/docker/client"
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/pkg/errors"
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+// runMock answers the endpoints ContainerRun touches and records which were
+// hit, in order.
+type runMock struct {
+	mu       sync.Mutex
+	calls    []string
+	waitBody string
+	logs     []byte
+}
+
+func (m *runMock) called() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.calls...)
+}
+
+func (m *runMock) handle(req *http.Request) (*http.Response, error) {
+	m.mu.Lock()
+	m.calls = append(m.calls, req.Method+" "+req.URL.Path)
+	m.mu.Unlock()
+
+	ok := func(body []byte) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(bytes.NewReader(body)),
+		}, nil
+	}
+	switch {
+	case strings.HasSuffix(req.URL.Path, "/containers/create"):
+		return ok([]byte(`{"Id":"container_id"}`))
+	case strings.HasSuffix(req.URL.Path, "/wait"):
+		return ok([]byte(m.waitBody))
+	case strings.HasSuffix(req.URL.Path, "/logs"):
+		return ok(m.logs)
+	default:
+		return &http.Response{
+			StatusCode: http.StatusNoContent,
+			Body:       ioutil.NopCloser(bytes.NewReader(nil)),
+		}, nil
+	}
+}
+
+func TestContainerRunSuccess(t *testing.T) {
+	var logBuf bytes.Buffer
+	stdcopy.NewStdWriter(&logBuf, stdcopy.Stdout).Write([]byte("hello\n"))
+	stdcopy.NewStdWriter(&logBuf, stdcopy.Stderr).Write([]byte("oops\n"))
+
+	mock := &runMock{waitBody: `{"StatusCode":0}`, logs: logBuf.Bytes()}
+	client := &Client{version: "1.40", client: newMockClient(mock.handle)}
+
+	result, err := client.ContainerRun(context.Background(), ContainerRunOptions{
+		Config:        &container.Config{Image: "busybox"},
+		CaptureOutput: true,
+		Remove:        true,
+	})
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("container_id", result.ContainerID))
+	assert.Check(t, is.Equal(int64(0), result.StatusCode))
+	assert.Check(t, is.Equal("hello\n", string(result.Stdout)))
+	assert.Check(t, is.Equal("oops\n", string(result.Stderr)))
+
+	calls := mock.called()
+	assert.Assert(t, is.Len(calls, 5))
+	// the wait must be registered before the container is started
+	assert.Check(t, is.Equal("POST /v1.40/containers/create", calls[0]))
+	assert.Check(t, is.Equal("POST /v1.40/containers/container_id/wait", calls[1]))
+	assert.Check(t, is.Equal("POST /v1.40/containers/container_id/start", calls[2]))
+	assert.Check(t, is.Equal("GET /v1.40/containers/container_id/logs", calls[3]))
+	assert.Check(t, is.Equal("DELETE /v1.40/containers/container_id", calls[4]))
+}
+
+func TestContainerRunNonZeroExit(t *testing.T) {
+	mock := &runMock{waitBody: `{"StatusCode":3}`}
+	client := &Client{version: "1.40", client: newMockClient(mock.handle)}
+
+	result, err := client.ContainerRun(context.Background(), ContainerRunOptions{
+		Config: &container.Config{Image: "busybox"},
+	})
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(int64(3), result.StatusCode))
+
+	// without Remove the container must be left alone
+	for _, call := range mock.called() {
+		assert.Check(t, !strings.HasPrefix(call, "DELETE"), "unexpected call %q", call)
+	}
+}
+
+func TestContainerRunTimeout(t *testing.T) {
+	mock := &runMock{}
+	client := &Client{version: "1.40", client: newMockClient(func(req *http.Request) (*http.Response, error) {
+		if strings.HasSuffix(req.URL.Path, "/wait") {
+			mock.mu.Lock()
+			mock.calls = append(mock.calls, req.Method+" "+req.URL.Path)
+			mock.mu.Unlock()
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(blockUntilDone{ctx: req.Context()}),
+			}, nil
+		}
+		return mock.handle(req)
+	})}
+
+	result, err := client.ContainerRun(context.Background(), ContainerRunOptions{
+		Config:  &container.Config{Image: "busybox"},
+		Timeout: 50 * time.Millisecond,
+		Remove:  true,
+	})
+	assert.Assert(t, errors.Is(err, context.DeadlineExceeded), "got %v", err)
+	assert.Check(t, is.Equal("container_id", result.ContainerID))
+
+	// cleanup must still have happened
+	var removed bool
+	for _, call := range mock.called() {
+		if call == "DELETE /v1.40/containers/container_id" {
+			removed = true
+		}
+	}
+	assert.Check(t, removed, "container was not removed, calls: %v", mock.called())
+}
+
+func TestContainerRunCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	client := &Client{version: "1.40", client: newMockClient(func(req *http.Request) (*http.Response, error) {
+		if strings.HasSuffix(req.URL.Path, "/wait") {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(blockUntilDone{ctx: req.Context()}),
+			}, nil
+		}
+		return (&runMock{}).handle(req)
+	})}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	_, err := client.ContainerRun(ctx, ContainerRunOptions{
+		Config: &container.Config{Image: "busybox"},
+	})
+	assert.Assert(t, errors.Is(err, context.Canceled), "got %v", err)
+}
+
+func TestContainerRunWaitsForHealthy(t *testing.T) {
+	var inspects int
+	mock := &runMock{}
+	client := &Client{version: "1.40", client: newMockClient(func(req *http.Request) (*http.Response, error) {
+		if strings.HasSuffix(req.URL.Path, "/json") {
+			inspects++
+			status := "starting"
+			if inspects >= 2 {
+				status = "healthy"
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(strings.NewReader(`{"Id":"container_id","State":{"Running":true,"Health":{"Status":"` + status + `"}}}`)),
+			}, nil
+		}
+		return mock.handle(req)
+	})}
+
+	result, err := client.ContainerRun(context.Background(), ContainerRunOptions{
+		Config:    &container.Config{Image: "busybox"},
+		Condition: WaitConditionHealthy,
+	})
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("container_id", result.ContainerID))
+	assert.Check(t, inspects >= 2, "expected the health state to be polled, got %d inspects", inspects)
+
+	// no wait request is issued for the client-side healthy condition
+	for _, call := range mock.called() {
+		assert.Check(t, !strings.HasSuffix(call, "/wait"), "unexpected call %q", call)
+	}
+}
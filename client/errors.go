@@ -3,7 +3,10 @@ package client // import "github.com/docker/docker/client"
 import (
 	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/docker/docker/api"
 	"github.com/docker/docker/api/types/versions"
 	"github.com/docker/docker/errdefs"
 	"github.com/pkg/errors"
@@ -32,6 +35,91 @@ func ErrorConnectionFailed(host string) error {
 	return errConnectionFailed{host: host}
 }
 
+// APIError carries the typed details of an error response from the daemon,
+// so consumers do not have to string-match error messages. It is found with
+// errors.As; the error returned by endpoint wrappers still satisfies the
+// errdefs predicates (errdefs.IsNotFound and friends) derived from the
+// status code.
+type APIError struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+	// Message is the error message as reported by the daemon.
+	Message string
+	// Body is the raw response body, capped at 1MiB.
+	Body []byte
+	// ResourceID is the identifier parsed from conventional "No such
+	// <object>: <id>" messages, or empty when the message names none.
+	ResourceID string
+	// RequestID is the daemon's request-ID response header when present,
+	// for cross-referencing daemon logs.
+	RequestID string
+	// RetryAfter is the wait suggested by the Retry-After response header
+	// of a 429 or 503 response; zero when the header was absent. Callers
+	// of non-idempotent endpoints, which are never retried, can use it to
+	// schedule their own retry.
+	RetryAfter time.Duration
+
+	// bare indicates Message is the complete error text rather than a
+	// daemon message to prefix.
+	bare bool
+}
+
+// Error returns a string representation of an APIError
+func (e APIError) Error() string {
+	if e.bare {
+		return e.Message
+	}
+	return "Error response from daemon: " + e.Message
+}
+
+// parseResourceID extracts the identifier from the conventional "No such
+// <object>: <id>" daemon message form.
+func parseResourceID(message string) string {
+	if !strings.HasPrefix(message, "No such ") {
+		return ""
+	}
+	i := strings.Index(message, ": ")
+	if i < 0 {
+		return ""
+	}
+	id := strings.TrimSpace(message[i+2:])
+	if id == "" || strings.ContainsAny(id, " \t") {
+		return ""
+	}
+	return id
+}
+
+// NegotiationError is returned when API version negotiation fails.
+type NegotiationError struct {
+	// APIVersion is the API version the daemon reported, when it responded.
+	APIVersion string
+	// TooOld is true when the daemon responded but speaks an API version
+	// below the minimum this client supports; false means the daemon could
+	// not be reached at all.
+	TooOld bool
+	// Cause is the underlying ping error for an unreachable daemon.
+	Cause error
+}
+
+// Error returns a string representation of a NegotiationError
+func (e NegotiationError) Error() string {
+	if e.TooOld {
+		return fmt.Sprintf("daemon API version %s is too old (minimum supported version is %s)", e.APIVersion, api.MinVersion)
+	}
+	return fmt.Sprintf("API version negotiation failed: daemon unreachable: %v", e.Cause)
+}
+
+// Unwrap returns the underlying ping error, if any.
+func (e NegotiationError) Unwrap() error {
+	return e.Cause
+}
+
+// IsErrNegotiationFailed returns true if the error was raised by API version
+// negotiation.
+func IsErrNegotiationFailed(err error) bool {
+	return errors.As(err, &NegotiationError{})
+}
+
 // Deprecated: use the errdefs.NotFound() interface instead. Kept for backward compatibility
 type notFound interface {
 	error
@@ -51,6 +139,7 @@ func IsErrNotFound(err error) bool {
 type objectNotFoundError struct {
 	object string
 	id     string
+	err    error
 }
 
 func (e objectNotFoundError) NotFound() {}
@@ -59,12 +148,18 @@ func (e objectNotFoundError) Error() string {
 	return fmt.Sprintf("Error: No such %s: %s", e.object, e.id)
 }
 
+// Unwrap returns the underlying daemon error, so the typed details it
+// carries (such as APIError) stay reachable through errors.As.
+func (e objectNotFoundError) Unwrap() error {
+	return e.err
+}
+
 func wrapResponseError(err error, resp serverResponse, object, id string) error {
 	switch {
 	case err == nil:
 		return nil
 	case resp.statusCode == http.StatusNotFound:
-		return objectNotFoundError{object: object, id: id}
+		return objectNotFoundError{object: object, id: id, err: err}
 	case resp.statusCode == http.StatusNotImplemented:
 		return errdefs.NotImplemented(err)
 	default:
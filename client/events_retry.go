@@ -0,0 +1,169 @@
+package client // import "github.com/docker/docker/client"
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/pkg/errors"
+)
+
+// EventsRetryOptions configures EventsWithRetry.
+type EventsRetryOptions struct {
+	// InitialBackoff is the delay before the first reconnection attempt; it
+	// doubles on each consecutive failure and is reset when an event is
+	// received. Zero selects the default of 100ms.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between reconnection attempts. Zero selects
+	// the default of 2s.
+	MaxBackoff time.Duration
+	// Reconnecting, if set, is called before each reconnection attempt with
+	// the (1-based) number of consecutive failures and the error that broke
+	// the stream.
+	Reconnecting func(attempt int, err error)
+	// Connected, if set, is called each time a stream is established,
+	// including the first time.
+	Connected func()
+}
+
+// EventsWithRetry is Events with the reconnection loop every consumer ends
+// up writing folded in. When the stream breaks, it reconnects with backoff,
+// resuming from the timestamp of the last event delivered so no events are
+// lost in the gap; events the daemon replays at the resume boundary are
+// suppressed. The stream is at-least-once: duplicates can still slip
+// through when events lack timestamps.
+//
+// Only a context or query error, or — when options.Until is set — the end
+// of the requested window, ends the stream; everything else is retried
+// indefinitely. As with Events, it is up to the caller to close the stream
+// by cancelling the context.
+func (cli *Client) EventsWithRetry(ctx context.Context, options types.EventsOptions, retryOptions EventsRetryOptions) (<-chan events.Message, <-chan error) {
+	messages := make(chan events.Message)
+	errs := make(chan error, 1)
+
+	if retryOptions.InitialBackoff <= 0 {
+		retryOptions.InitialBackoff = defaultRetryInitialBackoff
+	}
+	if retryOptions.MaxBackoff <= 0 {
+		retryOptions.MaxBackoff = defaultRetryMaxBackoff
+	}
+
+	go func() {
+		defer close(errs)
+
+		var (
+			// lastNano is the timestamp of the newest event delivered, and
+			// seen the events already delivered with that timestamp; both
+			// feed duplicate suppression after a resume
+			lastNano int64
+			seen     map[string]struct{}
+			attempt  int
+			backoff  = retryOptions.InitialBackoff
+		)
+
+		opts := options
+		for {
+			err := func() error {
+				query, err := buildEventsQueryParams(cli.version, opts)
+				if err != nil {
+					return err
+				}
+				resp, err := cli.get(ctx, "/events", query, nil)
+				if err != nil {
+					return err
+				}
+				defer resp.body.Close()
+
+				if retryOptions.Connected != nil {
+					retryOptions.Connected()
+				}
+
+				decoder := json.NewDecoder(resp.body)
+				for {
+					var event events.Message
+					if err := decoder.Decode(&event); err != nil {
+						return err
+					}
+
+					nano := event.TimeNano
+					if nano == 0 {
+						nano = event.Time * int64(time.Second)
+					}
+					key := eventKey(event)
+					if nano < lastNano || (nano == lastNano && key != "") {
+						if _, dup := seen[key]; dup || nano < lastNano {
+							// replayed by the daemon at the resume boundary
+							continue
+						}
+					}
+
+					select {
+					case messages <- event:
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+
+					// an event made it through: the connection is healthy
+					attempt = 0
+					backoff = retryOptions.InitialBackoff
+					if nano > lastNano {
+						lastNano = nano
+						seen = map[string]struct{}{}
+					}
+					if nano == lastNano && key != "" {
+						seen[key] = struct{}{}
+					}
+				}
+			}()
+
+			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || ctx.Err() != nil {
+				errs <- err
+				return
+			}
+			if errors.Is(err, io.EOF) && opts.Until != "" {
+				// the daemon closed the stream because the requested window
+				// ended; mirror Events and report EOF
+				errs <- err
+				return
+			}
+
+			attempt++
+			if retryOptions.Reconnecting != nil {
+				retryOptions.Reconnecting(attempt, err)
+			}
+			timer := time.NewTimer(backoff)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				errs <- ctx.Err()
+				return
+			}
+			if backoff *= 2; backoff > retryOptions.MaxBackoff {
+				backoff = retryOptions.MaxBackoff
+			}
+
+			if lastNano > 0 {
+				// resume where the broken stream left off; the boundary
+				// event itself is filtered out above when replayed
+				opts.Since = fmt.Sprintf("%d.%09d", lastNano/int64(time.Second), lastNano%int64(time.Second))
+			}
+		}
+	}()
+
+	return messages, errs
+}
+
+// eventKey identifies an event among those sharing a timestamp, for
+// duplicate suppression across reconnects. Events without an actor cannot
+// be told apart and get an empty key.
+func eventKey(event events.Message) string {
+	if event.Actor.ID == "" {
+		return ""
+	}
+	return event.Type + "/" + event.Action + "/" + event.Actor.ID
+}
@@ -0,0 +1,179 @@
+package client // import "github.com/docker/docker/client"
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/pkg/errors"
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+// encodeEvents renders events the way the daemon streams them: one JSON
+// document after another.
+func encodeEvents(t *testing.T, msgs ...events.Message) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, m := range msgs {
+		assert.NilError(t, enc.Encode(m))
+	}
+	return buf.Bytes()
+}
+
+// blockUntilDone blocks reads until the context ends, standing in for an
+// idle event stream.
+type blockUntilDone struct {
+	ctx context.Context
+}
+
+func (r blockUntilDone) Read([]byte) (int, error) {
+	<-r.ctx.Done()
+	return 0, r.ctx.Err()
+}
+
+func TestEventsWithRetryResumes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	event := func(id string, nano int64) events.Message {
+		return events.Message{
+			Type:     events.ContainerEventType,
+			Action:   "create",
+			Actor:    events.Actor{ID: id},
+			Time:     nano / int64(time.Second),
+			TimeNano: nano,
+		}
+	}
+	base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).UnixNano()
+	e1 := event("c1", base+1)
+	e2 := event("c2", base+2)
+	e3 := event("c3", base+3)
+
+	var (
+		conns      int32
+		sinceConn2 = make(chan string, 1)
+	)
+	client := &Client{
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			switch atomic.AddInt32(&conns, 1) {
+			case 1:
+				// the stream drops after two events
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       ioutil.NopCloser(bytes.NewReader(encodeEvents(t, e1, e2))),
+				}, nil
+			default:
+				sinceConn2 <- req.URL.Query().Get("since")
+				// the daemon replays the boundary event, then continues
+				body := io.MultiReader(bytes.NewReader(encodeEvents(t, e2, e3)), blockUntilDone{ctx: ctx})
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       ioutil.NopCloser(body),
+				}, nil
+			}
+		}),
+	}
+
+	var reconnects int32
+	messages, errs := client.EventsWithRetry(ctx, types.EventsOptions{}, EventsRetryOptions{
+		InitialBackoff: time.Millisecond,
+		Reconnecting:   func(attempt int, err error) { atomic.AddInt32(&reconnects, 1) },
+	})
+
+	var got []string
+	for len(got) < 3 {
+		select {
+		case m := <-messages:
+			got = append(got, m.Actor.ID)
+		case err := <-errs:
+			t.Fatalf("unexpected stream error: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out; got %v", got)
+		}
+	}
+	assert.Check(t, is.DeepEqual([]string{"c1", "c2", "c3"}, got), "events must arrive exactly once, in order")
+
+	select {
+	case since := <-sinceConn2:
+		assert.Check(t, is.Equal("1577836800.000000002", since), "the reconnect must resume from the last delivered event")
+	case <-time.After(5 * time.Second):
+		t.Fatal("second connection never happened")
+	}
+	assert.Check(t, atomic.LoadInt32(&reconnects) >= 1, "the Reconnecting callback must be invoked")
+
+	cancel()
+	select {
+	case err := <-errs:
+		assert.Check(t, errors.Is(err, context.Canceled), "expected context.Canceled, got %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream did not end on cancellation")
+	}
+}
+
+func TestEventsWithRetryConnectedCallback(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := &Client{
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(blockUntilDone{ctx: ctx}),
+			}, nil
+		}),
+	}
+
+	connected := make(chan struct{}, 1)
+	_, errs := client.EventsWithRetry(ctx, types.EventsOptions{}, EventsRetryOptions{
+		Connected: func() {
+			select {
+			case connected <- struct{}{}:
+			default:
+			}
+		},
+	})
+
+	select {
+	case <-connected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the Connected callback was not invoked")
+	}
+
+	cancel()
+	select {
+	case <-errs:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream did not end on cancellation")
+	}
+}
+
+func TestEventsWithRetryUntilEnds(t *testing.T) {
+	client := &Client{
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			// the requested window is already over: an empty, complete stream
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader(nil)),
+			}, nil
+		}),
+	}
+
+	_, errs := client.EventsWithRetry(context.Background(), types.EventsOptions{Until: "1136073600"}, EventsRetryOptions{})
+
+	select {
+	case err := <-errs:
+		assert.Check(t, errors.Is(err, io.EOF), "a bounded stream must end with EOF, got %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("bounded stream did not end")
+	}
+}
@@ -0,0 +1,47 @@
+package client // import "github.com/docker/docker/client"
+
+import (
+	"context"
+	"net/http"
+)
+
+// headersCtxKey is the context key under which per-request headers travel.
+type headersCtxKey struct{}
+
+// hopByHopHeaders are owned by the transport and the hijack handshake; values
+// smuggled in through a context must never replace them.
+var hopByHopHeaders = map[string]struct{}{
+	"Connection":          {},
+	"Keep-Alive":          {},
+	"Proxy-Authenticate":  {},
+	"Proxy-Authorization": {},
+	"Te":                  {},
+	"Trailer":             {},
+	"Transfer-Encoding":   {},
+	"Upgrade":             {},
+}
+
+// WithHeaders returns a context carrying headers that the client adds to
+// every request made with it, including hijacked ones such as attach. They
+// override headers configured with WithHTTPHeaders, but not headers an
+// endpoint sets itself (registry auth, content type), and hop-by-hop headers
+// are ignored. When the context already carries headers the new values are
+// merged over them.
+func WithHeaders(ctx context.Context, headers http.Header) context.Context {
+	merged := http.Header{}
+	if existing, ok := ctx.Value(headersCtxKey{}).(http.Header); ok {
+		for k, v := range existing {
+			merged[k] = v
+		}
+	}
+	for k, v := range headers {
+		merged[http.CanonicalHeaderKey(k)] = v
+	}
+	return context.WithValue(ctx, headersCtxKey{}, merged)
+}
+
+// headersFromContext returns the headers set with WithHeaders, if any.
+func headersFromContext(ctx context.Context) http.Header {
+	headers, _ := ctx.Value(headersCtxKey{}).(http.Header)
+	return headers
+}
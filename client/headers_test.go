@@ -0,0 +1,129 @@
+package client // import "github.com/docker/docker/client"
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/docker/docker/api/server/httputils"
+	"github.com/docker/docker/api/types"
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+func TestWithHeadersMergePrecedence(t *testing.T) {
+	var gotHeaders http.Header
+	client := &Client{
+		customHTTPHeaders: map[string]string{
+			"X-Custom": "base",
+			"X-Tenant": "base-tenant",
+		},
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			gotHeaders = req.Header
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte("[]"))),
+			}, nil
+		}),
+	}
+
+	ctx := WithHeaders(context.Background(), http.Header{
+		"X-Tenant": {"tenant-42"},
+		"X-Extra":  {"1"},
+	})
+	_, err := client.ContainerList(ctx, types.ContainerListOptions{})
+	assert.NilError(t, err)
+
+	// construction-time headers are the base, per-call values override
+	assert.Check(t, is.Equal("base", gotHeaders.Get("X-Custom")))
+	assert.Check(t, is.Equal("tenant-42", gotHeaders.Get("X-Tenant")))
+	assert.Check(t, is.Equal("1", gotHeaders.Get("X-Extra")))
+}
+
+func TestWithHeadersNested(t *testing.T) {
+	ctx := WithHeaders(context.Background(), http.Header{"X-A": {"1"}, "X-B": {"1"}})
+	ctx = WithHeaders(ctx, http.Header{"X-B": {"2"}})
+
+	headers := headersFromContext(ctx)
+	assert.Check(t, is.Equal("1", headers.Get("X-A")))
+	assert.Check(t, is.Equal("2", headers.Get("X-B")))
+}
+
+func TestWithHeadersDoesNotOverrideEndpointHeaders(t *testing.T) {
+	var gotHeaders http.Header
+	client := &Client{
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			gotHeaders = req.Header
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader(nil)),
+			}, nil
+		}),
+	}
+
+	ctx := WithHeaders(context.Background(), http.Header{"X-Registry-Auth": {"from-context"}})
+	_, err := client.sendRequest(ctx, http.MethodPost, "/images/create", nil, nil, headers{"X-Registry-Auth": {"from-endpoint"}})
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("from-endpoint", gotHeaders.Get("X-Registry-Auth")))
+}
+
+func TestWithHeadersSkipsHopByHop(t *testing.T) {
+	var gotHeaders http.Header
+	client := &Client{
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			gotHeaders = req.Header
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader(nil)),
+			}, nil
+		}),
+	}
+
+	ctx := WithHeaders(context.Background(), http.Header{
+		"Connection": {"close"},
+		"Upgrade":    {"websocket"},
+		"X-Allowed":  {"yes"},
+	})
+	_, err := client.sendRequest(ctx, http.MethodGet, "/info", nil, nil, nil)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("", gotHeaders.Get("Connection")))
+	assert.Check(t, is.Equal("", gotHeaders.Get("Upgrade")))
+	assert.Check(t, is.Equal("yes", gotHeaders.Get("X-Allowed")))
+}
+
+func TestWithHeadersHijacked(t *testing.T) {
+	gotHeaders := make(chan http.Header, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		gotHeaders <- req.Header.Clone()
+		r, rw, err := httputils.HijackConnection(w)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprint(rw, "HTTP/1.1 101 UPGRADED\r\nContent-Type: application/vnd.docker.raw-stream\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\n")
+		r.Close()
+	}))
+	defer ts.Close()
+
+	serverURL, err := url.Parse(ts.URL)
+	assert.NilError(t, err)
+	client, err := NewClientWithOpts(WithHost("tcp://" + serverURL.Host))
+	assert.NilError(t, err)
+
+	ctx := WithHeaders(context.Background(), http.Header{
+		"X-Tenant":   {"tenant-42"},
+		"Connection": {"close"}, // must not break the upgrade handshake
+	})
+	resp, err := client.postHijacked(ctx, "/containers/id/attach", url.Values{}, nil, nil)
+	assert.NilError(t, err)
+	defer resp.Close()
+
+	headers := <-gotHeaders
+	assert.Check(t, is.Equal("tenant-42", headers.Get("X-Tenant")))
+	assert.Check(t, is.Equal("Upgrade", headers.Get("Connection")))
+}
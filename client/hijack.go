@@ -28,14 +28,19 @@ func (cli *Client) postHijacked(ctx context.Context, path string, query url.Valu
 	if err != nil {
 		return types.HijackedResponse{}, err
 	}
-	req = cli.addHeaders(req, headers)
+	req = cli.addHeaders(ctx, req, headers)
 
 	conn, err := cli.setupHijackConn(ctx, req, "tcp")
 	if err != nil {
 		return types.HijackedResponse{}, err
 	}
+	conn = cli.withIdleReadTimeout(conn)
 
-	return types.HijackedResponse{Conn: conn, Reader: bufio.NewReader(conn)}, err
+	return types.HijackedResponse{
+		Conn:    conn,
+		Reader:  bufio.NewReader(conn),
+		Control: &hijackControl{conn: conn},
+	}, err
 }
 
 // DialHijack returns a hijacked connection with negotiated protocol proto.
@@ -44,7 +49,7 @@ func (cli *Client) DialHijack(ctx context.Context, url, proto string, meta map[s
 	if err != nil {
 		return nil, err
 	}
-	req = cli.addHeaders(req, meta)
+	req = cli.addHeaders(ctx, req, meta)
 
 	return cli.setupHijackConn(ctx, req, proto)
 }
@@ -78,8 +83,14 @@ func (cli *Client) setupHijackConn(ctx context.Context, req *http.Request, proto
 	// state. Setting TCP KeepAlive on the socket connection will prohibit
 	// ECONNTIMEOUT unless the socket connection truly is broken
 	if tcpConn, ok := conn.(*net.TCPConn); ok {
-		tcpConn.SetKeepAlive(true)
-		tcpConn.SetKeepAlivePeriod(30 * time.Second)
+		period := cli.hijackKeepAlive
+		if period == 0 {
+			period = defaultHijackKeepAlive
+		}
+		if period > 0 {
+			tcpConn.SetKeepAlive(true)
+			tcpConn.SetKeepAlivePeriod(period)
+		}
 	}
 
 	clientconn := httputil.NewClientConn(conn, nil)
@@ -0,0 +1,162 @@
+package client // import "github.com/docker/docker/client"
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/pkg/errors"
+)
+
+// defaultHijackKeepAlive is the TCP keepalive period applied to hijacked
+// connections when WithHijackKeepAlive was not used.
+const defaultHijackKeepAlive = 30 * time.Second
+
+// WithHijackKeepAlive overrides the TCP keepalive period applied to hijacked
+// connections (attach, exec start), which defaults to 30s. Zero keeps the
+// default; a negative period disables keepalives.
+func WithHijackKeepAlive(period time.Duration) Opt {
+	return func(c *Client) error {
+		c.hijackKeepAlive = period
+		return nil
+	}
+}
+
+// WithHijackIdleReadTimeout makes reads on hijacked connections fail with a
+// HijackTimeoutError when no data arrived for the given duration, so a
+// stream severed by a silent middlebox does not block forever. The timeout
+// is between reads, not over the whole stream.
+func WithHijackIdleReadTimeout(timeout time.Duration) Opt {
+	return func(c *Client) error {
+		c.hijackIdleReadTimeout = timeout
+		return nil
+	}
+}
+
+// HijackTimeoutError is returned from reads on a hijacked connection when
+// the configured idle read timeout elapsed without data; see
+// WithHijackIdleReadTimeout.
+type HijackTimeoutError struct {
+	// IdleTimeout is the configured idle read timeout.
+	IdleTimeout time.Duration
+}
+
+// Error returns a string representation of a HijackTimeoutError
+func (e HijackTimeoutError) Error() string {
+	return fmt.Sprintf("no data received on hijacked connection in %v", e.IdleTimeout)
+}
+
+// Timeout reports the error as a timeout, for net.Error-style checks.
+func (e HijackTimeoutError) Timeout() bool {
+	return true
+}
+
+// Temporary reports the error as temporary: the connection is still usable
+// and a later read may succeed.
+func (e HijackTimeoutError) Temporary() bool {
+	return true
+}
+
+// hijackControl implements types.HijackController on top of the (possibly
+// wrapped) connection handed to the caller.
+type hijackControl struct {
+	conn net.Conn
+}
+
+var _ types.HijackController = &hijackControl{}
+
+func (c *hijackControl) SetKeepAlive(period time.Duration) error {
+	tcpConn := tcpConnOf(c.conn)
+	if tcpConn == nil {
+		return errors.New("keepalive is only supported on TCP connections")
+	}
+	if period <= 0 {
+		return tcpConn.SetKeepAlive(false)
+	}
+	if err := tcpConn.SetKeepAlive(true); err != nil {
+		return err
+	}
+	return tcpConn.SetKeepAlivePeriod(period)
+}
+
+func (c *hijackControl) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+func (c *hijackControl) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+func (c *hijackControl) CloseWrite() error {
+	if cw, ok := c.conn.(types.CloseWriter); ok {
+		return cw.CloseWrite()
+	}
+	return errors.New("connection does not support half-close")
+}
+
+// tcpConnOf digs the TCP connection out from under the hijack wrappers, or
+// returns nil when the transport is not TCP.
+func tcpConnOf(conn net.Conn) *net.TCPConn {
+	for {
+		switch c := conn.(type) {
+		case *net.TCPConn:
+			return c
+		case *hijackedConn:
+			conn = c.Conn
+		case *hijackedConnCloseWriter:
+			conn = c.Conn
+		case *idleReadConn:
+			conn = c.Conn
+		case *idleReadConnCloseWriter:
+			conn = c.Conn
+		default:
+			return nil
+		}
+	}
+}
+
+// idleReadConn arms a read deadline before every read, so a hijacked stream
+// that goes quiet for longer than the timeout fails with a typed error
+// instead of blocking forever.
+type idleReadConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *idleReadConn) Read(b []byte) (int, error) {
+	if err := c.Conn.SetReadDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+	n, err := c.Conn.Read(b)
+	if nErr, ok := err.(net.Error); ok && nErr.Timeout() {
+		err = HijackTimeoutError{IdleTimeout: c.timeout}
+	}
+	return n, err
+}
+
+// idleReadConnCloseWriter is an idleReadConn whose underlying connection
+// supports half-close; mirrors hijackedConnCloseWriter.
+type idleReadConnCloseWriter struct {
+	*idleReadConn
+}
+
+var _ types.CloseWriter = &idleReadConnCloseWriter{}
+
+func (c *idleReadConnCloseWriter) CloseWrite() error {
+	conn := c.Conn.(types.CloseWriter)
+	return conn.CloseWrite()
+}
+
+// withIdleReadTimeout wraps conn per the client's hijack idle read timeout,
+// preserving half-close support.
+func (cli *Client) withIdleReadTimeout(conn net.Conn) net.Conn {
+	if cli.hijackIdleReadTimeout <= 0 {
+		return conn
+	}
+	wrapped := &idleReadConn{Conn: conn, timeout: cli.hijackIdleReadTimeout}
+	if _, ok := conn.(types.CloseWriter); ok {
+		return &idleReadConnCloseWriter{wrapped}
+	}
+	return wrapped
+}
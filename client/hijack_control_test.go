@@ -0,0 +1,131 @@
+package client // import "github.com/docker/docker/client"
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/server/httputils"
+	"github.com/pkg/errors"
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+// newHijackControlClient stands up a hijack-capable server on the given
+// network ("tcp" or "unix") and returns a client connected to it. serve gets
+// the server half of the hijacked connection.
+func newHijackControlClient(t *testing.T, network string, serve func(r io.ReadCloser, w io.Writer), opts ...Opt) *Client {
+	t.Helper()
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r, rw, err := httputils.HijackConnection(w)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer r.Close()
+		fmt.Fprint(rw, "HTTP/1.1 101 UPGRADED\r\nContent-Type: application/vnd.docker.raw-stream\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\n")
+		serve(r, rw)
+	})
+
+	var host string
+	switch network {
+	case "unix":
+		path := filepath.Join(t.TempDir(), "docker.sock")
+		l, err := net.Listen("unix", path)
+		assert.NilError(t, err)
+		srv := &http.Server{Handler: handler}
+		go srv.Serve(l)
+		t.Cleanup(func() { srv.Close() })
+		host = "unix://" + path
+	default:
+		ts := httptest.NewServer(handler)
+		t.Cleanup(ts.Close)
+		serverURL, err := url.Parse(ts.URL)
+		assert.NilError(t, err)
+		host = "tcp://" + serverURL.Host
+	}
+
+	client, err := NewClientWithOpts(append([]Opt{WithHost(host)}, opts...)...)
+	assert.NilError(t, err)
+	return client
+}
+
+func TestHijackControlKeepAliveAndCloseWrite(t *testing.T) {
+	sawEOF := make(chan struct{})
+	client := newHijackControlClient(t, "tcp", func(r io.ReadCloser, w io.Writer) {
+		io.Copy(ioutil.Discard, r)
+		close(sawEOF)
+	})
+
+	resp, err := client.postHijacked(context.Background(), "/containers/id/attach", url.Values{}, nil, nil)
+	assert.NilError(t, err)
+	defer resp.Close()
+
+	assert.Assert(t, resp.Control != nil)
+	assert.NilError(t, resp.Control.SetKeepAlive(10*time.Second))
+	assert.NilError(t, resp.Control.SetKeepAlive(-1), "a negative period disables keepalives")
+
+	// half-close: the server side must observe EOF
+	assert.NilError(t, resp.Control.CloseWrite())
+	select {
+	case <-sawEOF:
+	case <-time.After(2 * time.Second):
+		t.Fatal("server did not observe EOF after CloseWrite")
+	}
+}
+
+func TestHijackControlKeepAliveNonTCP(t *testing.T) {
+	client := newHijackControlClient(t, "unix", func(r io.ReadCloser, w io.Writer) {
+		io.Copy(ioutil.Discard, r)
+	})
+
+	resp, err := client.postHijacked(context.Background(), "/containers/id/attach", url.Values{}, nil, nil)
+	assert.NilError(t, err)
+	defer resp.Close()
+
+	err = resp.Control.SetKeepAlive(10 * time.Second)
+	assert.ErrorContains(t, err, "only supported on TCP")
+}
+
+func TestHijackIdleReadTimeout(t *testing.T) {
+	client := newHijackControlClient(t, "tcp", func(r io.ReadCloser, w io.Writer) {
+		// send nothing; the client's idle timeout must fire
+		io.Copy(ioutil.Discard, r)
+	}, WithHijackIdleReadTimeout(100*time.Millisecond))
+
+	resp, err := client.postHijacked(context.Background(), "/containers/id/attach", url.Values{}, nil, nil)
+	assert.NilError(t, err)
+	defer resp.Close()
+
+	_, err = resp.Reader.ReadByte()
+	var timeoutErr HijackTimeoutError
+	assert.Assert(t, errors.As(err, &timeoutErr), "got %[1]T: %[1]v", err)
+	assert.Check(t, is.Equal(100*time.Millisecond, timeoutErr.IdleTimeout))
+	var nErr net.Error
+	assert.Assert(t, errors.As(err, &nErr))
+	assert.Check(t, nErr.Timeout())
+}
+
+func TestHijackControlReadDeadline(t *testing.T) {
+	client := newHijackControlClient(t, "tcp", func(r io.ReadCloser, w io.Writer) {
+		io.Copy(ioutil.Discard, r)
+	})
+
+	resp, err := client.postHijacked(context.Background(), "/containers/id/attach", url.Values{}, nil, nil)
+	assert.NilError(t, err)
+	defer resp.Close()
+
+	assert.NilError(t, resp.Control.SetReadDeadline(time.Now().Add(50*time.Millisecond)))
+	_, err = resp.Reader.ReadByte()
+	var nErr net.Error
+	assert.Assert(t, errors.As(err, &nErr), "got %[1]T: %[1]v", err)
+	assert.Check(t, nErr.Timeout())
+}
@@ -0,0 +1,131 @@
+package client // import "github.com/docker/docker/client"
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/pkg/errors"
+)
+
+// defaultPullConcurrency is how many images ImagesPull fetches at once when
+// the caller does not say otherwise.
+const defaultPullConcurrency = 3
+
+// ImagesPullOptions holds parameters for ImagesPull.
+type ImagesPullOptions struct {
+	// Concurrency is the maximum number of images pulled at the same
+	// time. Zero or a negative value selects a default.
+	Concurrency int
+	// PullOptions apply to every individual pull.
+	PullOptions types.ImagePullOptions
+}
+
+// PullProgress is one progress event from one of the pulls driven by
+// ImagesPull.
+type PullProgress struct {
+	// Ref is the image reference the event belongs to.
+	Ref string
+	// LayerID identifies the layer within the image, when the event is
+	// about a layer.
+	LayerID string
+	// Status is the daemon's progress status, e.g. "Downloading".
+	Status string
+	// Current and Total are the transferred and expected byte counts for
+	// the layer; both are zero for untyped status lines.
+	Current int64
+	Total   int64
+}
+
+// PullResult is the outcome of one of the pulls driven by ImagesPull.
+type PullResult struct {
+	// Ref is the image reference that was pulled.
+	Ref string
+	// Err is nil when the pull completed successfully.
+	Err error
+}
+
+// ImagesPull pulls the given images, up to options.Concurrency of them at a
+// time, demultiplexing each pull's JSON progress stream onto the returned
+// progress channel. When all pulls have finished the progress channel is
+// closed and one PullResult per reference — in the order the references were
+// given — is delivered on the result channel. Cancelling the context aborts
+// the in-flight pulls; their results report the cancellation.
+func (cli *Client) ImagesPull(ctx context.Context, refs []string, options ImagesPullOptions) (<-chan PullProgress, <-chan []PullResult) {
+	concurrency := options.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultPullConcurrency
+	}
+
+	progressC := make(chan PullProgress)
+	resultC := make(chan []PullResult, 1)
+
+	go func() {
+		defer close(resultC)
+
+		var (
+			wg      sync.WaitGroup
+			sem     = make(chan struct{}, concurrency)
+			results = make([]PullResult, len(refs))
+		)
+		for i, ref := range refs {
+			wg.Add(1)
+			go func(i int, ref string) {
+				defer wg.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+				results[i] = PullResult{Ref: ref, Err: cli.pullWithProgress(ctx, ref, options.PullOptions, progressC)}
+			}(i, ref)
+		}
+		wg.Wait()
+		close(progressC)
+		resultC <- results
+	}()
+
+	return progressC, resultC
+}
+
+// pullWithProgress runs a single pull, forwarding its progress stream as
+// typed events.
+func (cli *Client) pullWithProgress(ctx context.Context, ref string, options types.ImagePullOptions, progressC chan<- PullProgress) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	body, err := cli.ImagePull(ctx, ref, options)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	dec := json.NewDecoder(body)
+	for {
+		var msg jsonmessage.JSONMessage
+		if err := dec.Decode(&msg); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			// reads from the body fail once the context is cancelled;
+			// report the cancellation rather than the read error
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			return errors.Wrapf(err, "error decoding pull progress for %s", ref)
+		}
+		if msg.Error != nil {
+			return msg.Error
+		}
+		event := PullProgress{Ref: ref, LayerID: msg.ID, Status: msg.Status}
+		if msg.Progress != nil {
+			event.Current = msg.Progress.Current
+			event.Total = msg.Progress.Total
+		}
+		select {
+		case progressC <- event:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
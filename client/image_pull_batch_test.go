@@ -0,0 +1,160 @@
+package client // import "github.com/docker/docker/client"
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/pkg/jsonmessage"
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+func encodePullProgress(t *testing.T, msgs ...jsonmessage.JSONMessage) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, msg := range msgs {
+		assert.NilError(t, enc.Encode(msg))
+	}
+	return buf.Bytes()
+}
+
+func TestImagesPullMergedProgress(t *testing.T) {
+	client := &Client{
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			image := req.URL.Query().Get("fromImage")
+			body := encodePullProgress(t,
+				jsonmessage.JSONMessage{ID: "layer1", Status: "Downloading", Progress: &jsonmessage.JSONProgress{Current: 50, Total: 100}},
+				jsonmessage.JSONMessage{Status: "Status: Downloaded newer image for " + image},
+			)
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader(body)),
+			}, nil
+		}),
+	}
+
+	refs := []string{"image1", "image2", "image3"}
+	progressC, resultC := client.ImagesPull(context.Background(), refs, ImagesPullOptions{})
+
+	perRef := map[string][]PullProgress{}
+	for event := range progressC {
+		perRef[event.Ref] = append(perRef[event.Ref], event)
+	}
+	assert.Check(t, is.Len(perRef, 3))
+	for _, ref := range refs {
+		events := perRef[ref]
+		assert.Assert(t, is.Len(events, 2), "events for %s", ref)
+		assert.Check(t, is.Equal("layer1", events[0].LayerID))
+		assert.Check(t, is.Equal("Downloading", events[0].Status))
+		assert.Check(t, is.Equal(int64(50), events[0].Current))
+		assert.Check(t, is.Equal(int64(100), events[0].Total))
+		assert.Check(t, is.Equal("", events[1].LayerID))
+	}
+
+	results := <-resultC
+	assert.Assert(t, is.Len(results, 3))
+	for i, result := range results {
+		// results come back in the order the refs were given
+		assert.Check(t, is.Equal(refs[i], result.Ref))
+		assert.Check(t, result.Err == nil, "pull of %s: %v", result.Ref, result.Err)
+	}
+}
+
+func TestImagesPullErrorResult(t *testing.T) {
+	client := &Client{
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			var body []byte
+			if req.URL.Query().Get("fromImage") == "broken" {
+				body = encodePullProgress(t,
+					jsonmessage.JSONMessage{Status: "Pulling from library/broken"},
+					jsonmessage.JSONMessage{Error: &jsonmessage.JSONError{Message: "manifest unknown"}},
+				)
+			} else {
+				body = encodePullProgress(t, jsonmessage.JSONMessage{Status: "Download complete"})
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader(body)),
+			}, nil
+		}),
+	}
+
+	progressC, resultC := client.ImagesPull(context.Background(), []string{"good", "broken"}, ImagesPullOptions{})
+	for range progressC {
+	}
+	results := <-resultC
+	assert.Assert(t, is.Len(results, 2))
+	assert.Check(t, results[0].Err == nil)
+	assert.ErrorContains(t, results[1].Err, "manifest unknown")
+}
+
+func TestImagesPullConcurrencyLimit(t *testing.T) {
+	var inFlight, maxInFlight int64
+	client := &Client{
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			cur := atomic.AddInt64(&inFlight, 1)
+			for {
+				max := atomic.LoadInt64(&maxInFlight)
+				if cur <= max || atomic.CompareAndSwapInt64(&maxInFlight, max, cur) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt64(&inFlight, -1)
+			body := encodePullProgress(t, jsonmessage.JSONMessage{Status: "Download complete"})
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader(body)),
+			}, nil
+		}),
+	}
+
+	progressC, resultC := client.ImagesPull(context.Background(), []string{"a", "b", "c", "d", "e"}, ImagesPullOptions{Concurrency: 2})
+	for range progressC {
+	}
+	<-resultC
+	assert.Check(t, atomic.LoadInt64(&maxInFlight) <= 2, "saw %d concurrent pulls", maxInFlight)
+}
+
+func TestImagesPullCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{}, 1)
+	client := &Client{
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			select {
+			case started <- struct{}{}:
+			default:
+			}
+			// never-ending progress stream
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(blockUntilDone{ctx: req.Context()}),
+			}, nil
+		}),
+	}
+
+	progressC, resultC := client.ImagesPull(ctx, []string{"a", "b"}, ImagesPullOptions{})
+	go func() {
+		for range progressC {
+		}
+	}()
+
+	<-started
+	cancel()
+
+	select {
+	case results := <-resultC:
+		for _, result := range results {
+			assert.Check(t, result.Err != nil, "pull of %s should have been cancelled", result.Ref)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancellation did not stop the pulls")
+	}
+}
@@ -0,0 +1,134 @@
+package client // import "github.com/docker/docker/client"
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MetricsCollector accumulates transport-level metrics from every request a
+// client makes, giving long-running services visibility into connection
+// reuse and daemon health. A collector may be shared by several clients.
+// All methods are safe for concurrent use.
+type MetricsCollector struct {
+	dials         int64
+	dialErrors    int64
+	reusedConns   int64
+	tlsHandshakes int64
+	tlsNanos      int64
+	inFlight      int64
+	errors        int64
+
+	mu       sync.Mutex
+	requests map[string]int64
+}
+
+// TransportMetrics is a point-in-time snapshot of a MetricsCollector.
+type TransportMetrics struct {
+	// Dials is the number of connections dialed.
+	Dials int64
+	// DialErrors is the number of dials that failed.
+	DialErrors int64
+	// ReusedConns counts requests served over an already-open connection.
+	ReusedConns int64
+	// TLSHandshakes is the number of TLS handshakes performed.
+	TLSHandshakes int64
+	// TLSHandshakeTime is the cumulative time spent in TLS handshakes.
+	TLSHandshakeTime time.Duration
+	// InFlight is the number of requests currently in progress.
+	InFlight int64
+	// Errors counts requests that failed below the HTTP layer.
+	Errors int64
+	// Requests counts request attempts by method and path group, keyed
+	// like "GET containers".
+	Requests map[string]int64
+}
+
+// NewMetricsCollector returns an empty collector, for use with WithMetrics.
+func NewMetricsCollector() *MetricsCollector {
+	return &MetricsCollector{requests: map[string]int64{}}
+}
+
+// Snapshot returns the current counter values.
+func (m *MetricsCollector) Snapshot() TransportMetrics {
+	m.mu.Lock()
+	requests := make(map[string]int64, len(m.requests))
+	for k, v := range m.requests {
+		requests[k] = v
+	}
+	m.mu.Unlock()
+	return TransportMetrics{
+		Dials:            atomic.LoadInt64(&m.dials),
+		DialErrors:       atomic.LoadInt64(&m.dialErrors),
+		ReusedConns:      atomic.LoadInt64(&m.reusedConns),
+		TLSHandshakes:    atomic.LoadInt64(&m.tlsHandshakes),
+		TLSHandshakeTime: time.Duration(atomic.LoadInt64(&m.tlsNanos)),
+		InFlight:         atomic.LoadInt64(&m.inFlight),
+		Errors:           atomic.LoadInt64(&m.errors),
+		Requests:         requests,
+	}
+}
+
+func (m *MetricsCollector) requestStarted(req *http.Request) {
+	atomic.AddInt64(&m.inFlight, 1)
+	key := req.Method + " " + pathGroup(req.URL.Path)
+	m.mu.Lock()
+	m.requests[key]++
+	m.mu.Unlock()
+}
+
+func (m *MetricsCollector) requestFinished(err error) {
+	atomic.AddInt64(&m.inFlight, -1)
+	if err != nil {
+		atomic.AddInt64(&m.errors, 1)
+	}
+}
+
+// traceContext attaches the httptrace hooks that feed the collector to the
+// context of one request.
+func (m *MetricsCollector) traceContext(ctx context.Context) context.Context {
+	var tlsStart time.Time
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		ConnectStart: func(network, addr string) {
+			atomic.AddInt64(&m.dials, 1)
+		},
+		ConnectDone: func(network, addr string, err error) {
+			if err != nil {
+				atomic.AddInt64(&m.dialErrors, 1)
+			}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				atomic.AddInt64(&m.reusedConns, 1)
+			}
+		},
+		TLSHandshakeStart: func() {
+			tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			atomic.AddInt64(&m.tlsHandshakes, 1)
+			atomic.AddInt64(&m.tlsNanos, int64(time.Since(tlsStart)))
+		},
+	})
+}
+
+// pathGroup reduces a request path to its first segment after the API
+// version prefix, so counters group all requests of one object type
+// ("containers", "images", ...) regardless of identifiers in the path.
+func pathGroup(p string) string {
+	p = strings.TrimPrefix(p, "/")
+	if rest := strings.TrimPrefix(p, "v"); rest != p {
+		if i := strings.Index(rest, "/"); i >= 0 && strings.Trim(rest[:i], "0123456789.") == "" {
+			p = rest[i+1:]
+		}
+	}
+	if i := strings.Index(p, "/"); i >= 0 {
+		p = p[:i]
+	}
+	return p
+}
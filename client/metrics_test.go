@@ -0,0 +1,109 @@
+package client // import "github.com/docker/docker/client"
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptrace"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+func TestMetricsRequestCounters(t *testing.T) {
+	metrics := NewMetricsCollector()
+	client := &Client{
+		version: "1.40",
+		metrics: metrics,
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte("[]"))),
+			}, nil
+		}),
+	}
+
+	_, err := client.ContainerList(context.Background(), types.ContainerListOptions{})
+	assert.NilError(t, err)
+	_, err = client.ContainerList(context.Background(), types.ContainerListOptions{})
+	assert.NilError(t, err)
+
+	snap := metrics.Snapshot()
+	assert.Check(t, is.Equal(int64(2), snap.Requests["GET containers"]))
+	assert.Check(t, is.Equal(int64(0), snap.Errors))
+	assert.Check(t, is.Equal(int64(0), snap.InFlight))
+}
+
+func TestMetricsErrorsAndInFlight(t *testing.T) {
+	metrics := NewMetricsCollector()
+	client := &Client{
+		metrics: metrics,
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			// while the request runs it must show up as in flight
+			if inFlight := metrics.Snapshot().InFlight; inFlight != 1 {
+				t.Errorf("expected 1 request in flight, got %d", inFlight)
+			}
+			return nil, io.EOF
+		}),
+	}
+
+	_, err := client.ContainerList(context.Background(), types.ContainerListOptions{})
+	assert.Assert(t, err != nil)
+
+	snap := metrics.Snapshot()
+	assert.Check(t, is.Equal(int64(1), snap.Errors))
+	assert.Check(t, is.Equal(int64(0), snap.InFlight))
+}
+
+func TestMetricsHTTPTraceCounters(t *testing.T) {
+	metrics := NewMetricsCollector()
+	client := &Client{
+		metrics: metrics,
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			// drive the httptrace hooks the way http.Transport would for a
+			// fresh TLS connection followed by a reused one
+			trace := httptrace.ContextClientTrace(req.Context())
+			assert.Assert(t, trace != nil, "the collector must attach a ClientTrace")
+			trace.ConnectStart("tcp", "example:2376")
+			trace.ConnectDone("tcp", "example:2376", nil)
+			trace.TLSHandshakeStart()
+			time.Sleep(time.Millisecond)
+			trace.TLSHandshakeDone(tls.ConnectionState{}, nil)
+			trace.GotConn(httptrace.GotConnInfo{Reused: false})
+			trace.GotConn(httptrace.GotConnInfo{Reused: true})
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader(nil)),
+			}, nil
+		}),
+	}
+
+	_, err := client.sendRequest(context.Background(), http.MethodGet, "/info", nil, nil, nil)
+	assert.NilError(t, err)
+
+	snap := metrics.Snapshot()
+	assert.Check(t, is.Equal(int64(1), snap.Dials))
+	assert.Check(t, is.Equal(int64(0), snap.DialErrors))
+	assert.Check(t, is.Equal(int64(1), snap.ReusedConns))
+	assert.Check(t, is.Equal(int64(1), snap.TLSHandshakes))
+	assert.Check(t, snap.TLSHandshakeTime > 0)
+}
+
+func TestPathGroup(t *testing.T) {
+	for path, expected := range map[string]string{
+		"/v1.40/containers/abc123/json": "containers",
+		"/containers/json":              "containers",
+		"/v1.40/_ping":                  "_ping",
+		"/_ping":                        "_ping",
+		"/v1.40/images/create":          "images",
+		"/version":                      "version",
+	} {
+		assert.Check(t, is.Equal(expected, pathGroup(path)), "path %q", path)
+	}
+}
@@ -9,11 +9,33 @@ import (
 
 // NetworkCreate creates a new network in the docker host.
 func (cli *Client) NetworkCreate(ctx context.Context, name string, options types.NetworkCreate) (types.NetworkCreateResponse, error) {
+	var response types.NetworkCreateResponse
+
+	err := cli.downgradeRequest(
+		versionedField{
+			name: "attachable network", version: "1.25",
+			set:   func() bool { return options.Attachable },
+			clear: func() { options.Attachable = false },
+		},
+		versionedField{
+			name: "ingress network", version: "1.29",
+			set:   func() bool { return options.Ingress },
+			clear: func() { options.Ingress = false },
+		},
+		versionedField{
+			name: "config-only network", version: "1.30",
+			set:   func() bool { return options.ConfigOnly || options.ConfigFrom != nil },
+			clear: func() { options.ConfigOnly = false; options.ConfigFrom = nil },
+		},
+	)
+	if err != nil {
+		return response, err
+	}
+
 	networkCreateRequest := types.NetworkCreateRequest{
 		NetworkCreate: options,
 		Name:          name,
 	}
-	var response types.NetworkCreateResponse
 	serverResp, err := cli.post(ctx, "/networks/create", nil, networkCreateRequest, nil)
 	defer ensureReaderClosed(serverResp)
 	if err != nil {
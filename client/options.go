@@ -4,6 +4,7 @@ import (
 	"context"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"time"
@@ -48,10 +49,8 @@ func FromEnv(c *Client) error {
 		}
 	}
 
-	if version := os.Getenv("DOCKER_API_VERSION"); version != "" {
-		if err := WithVersion(version)(c); err != nil {
-			return err
-		}
+	if err := WithVersionFromEnv()(c); err != nil {
+		return err
 	}
 	return nil
 }
@@ -119,6 +118,52 @@ func WithHTTPHeaders(headers map[string]string) Opt {
 	}
 }
 
+// WithProxy applies the proxy selection function to the client transport,
+// overriding the transport's environment-based default. The function is not
+// consulted for unix-socket and npipe connections, which never go through a
+// proxy.
+func WithProxy(proxy func(*http.Request) (*url.URL, error)) Opt {
+	return func(c *Client) error {
+		if transport, ok := c.client.Transport.(*http.Transport); ok {
+			transport.Proxy = func(req *http.Request) (*url.URL, error) {
+				// the check happens per request so the option works
+				// regardless of its order relative to WithHost
+				if c.proto == "unix" || c.proto == "npipe" {
+					return nil, nil
+				}
+				return proxy(req)
+			}
+			return nil
+		}
+		return errors.Errorf("cannot apply proxy to transport: %T", c.client.Transport)
+	}
+}
+
+// WithNoProxy makes the client connect directly, ignoring any proxy
+// configured in the environment.
+func WithNoProxy() Opt {
+	return func(c *Client) error {
+		if transport, ok := c.client.Transport.(*http.Transport); ok {
+			transport.Proxy = nil
+			return nil
+		}
+		return errors.Errorf("cannot apply proxy to transport: %T", c.client.Transport)
+	}
+}
+
+// ProxyFromURL returns a proxy selection function that routes every request
+// through the given proxy URL, for use with WithProxy.
+func ProxyFromURL(rawURL string) (func(*http.Request) (*url.URL, error), error) {
+	proxyURL, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse proxy URL %q", rawURL)
+	}
+	if proxyURL.Scheme == "" || proxyURL.Host == "" {
+		return nil, errors.Errorf("invalid proxy URL %q: scheme and host are required", rawURL)
+	}
+	return http.ProxyURL(proxyURL), nil
+}
+
 // WithScheme overrides the client scheme with the specified one
 func WithScheme(scheme string) Opt {
 	return func(c *Client) error {
@@ -150,16 +195,59 @@ func WithTLSClientConfig(cacertPath, certPath, keyPath string) Opt {
 
 // WithVersion overrides the client version with the specified one. If an empty
 // version is specified, the value will be ignored to allow version negotiation.
+// An explicit version set this way has the highest precedence; it wins over
+// WithVersionFromEnv and suppresses negotiation.
 func WithVersion(version string) Opt {
 	return func(c *Client) error {
 		if version != "" {
-			c.version = version
-			c.manualOverride = true
+			c.setVersion(version, VersionSourceExplicit)
 		}
 		return nil
 	}
 }
 
+// WithMetrics makes the client record transport metrics — dials, connection
+// reuse, TLS handshake durations, request and error counts — into the given
+// collector. When the option is not used, no metrics code runs at all.
+func WithMetrics(metrics *MetricsCollector) Opt {
+	return func(c *Client) error {
+		c.metrics = metrics
+		return nil
+	}
+}
+
+// WithPingCacheTTL overrides how long ServerInfo serves a cached ping
+// result before asking the daemon again. Zero or a negative duration
+// disables the cache entirely.
+func WithPingCacheTTL(ttl time.Duration) Opt {
+	return func(c *Client) error {
+		c.pingTTL = ttl
+		return nil
+	}
+}
+
+// WithLenientVersioning makes the client silently drop request fields that
+// the effective API version does not support, instead of rejecting the
+// request with a version error. Use it when talking to daemons of unknown
+// age and degraded behavior is preferable to a failure.
+func WithLenientVersioning() Opt {
+	return func(c *Client) error {
+		c.lenientVersioning = true
+		return nil
+	}
+}
+
+// WithNegotiationTimeout overrides the default deadline applied to the ping
+// performed during API version negotiation, so a hung daemon cannot stall
+// the first request indefinitely. Zero or a negative duration removes the
+// bound, leaving the ping limited only by the caller's context.
+func WithNegotiationTimeout(timeout time.Duration) Opt {
+	return func(c *Client) error {
+		c.negotiateTimeout = timeout
+		return nil
+	}
+}
+
 // WithAPIVersionNegotiation enables automatic API version negotiation for the client.
 // With this option enabled, the client automatically negotiates the API version
 // to use when making requests. API version negotiation is performed on the first
@@ -1,10 +1,13 @@
 package client
 
 import (
+	"net/http"
+	"net/url"
 	"testing"
 	"time"
 
 	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
 )
 
 func TestOptionWithTimeout(t *testing.T) {
@@ -14,3 +17,54 @@ func TestOptionWithTimeout(t *testing.T) {
 	assert.Check(t, c.client != nil)
 	assert.Equal(t, c.client.Timeout, timeout)
 }
+
+func TestOptionWithProxy(t *testing.T) {
+	proxy, err := ProxyFromURL("http://proxy.example.com:3128")
+	assert.NilError(t, err)
+
+	c, err := NewClientWithOpts(WithHost("tcp://docker.example.com:2376"), WithProxy(proxy))
+	assert.NilError(t, err)
+
+	transport, ok := c.client.Transport.(*http.Transport)
+	assert.Assert(t, ok)
+	req, err := http.NewRequest(http.MethodGet, "http://docker.example.com:2376/v1.40/info", nil)
+	assert.NilError(t, err)
+	proxyURL, err := transport.Proxy(req)
+	assert.NilError(t, err)
+	assert.Assert(t, proxyURL != nil, "the proxy func must be consulted for TCP hosts")
+	assert.Check(t, is.Equal("proxy.example.com:3128", proxyURL.Host))
+}
+
+func TestOptionWithProxyUnixSocket(t *testing.T) {
+	proxy := func(*http.Request) (*url.URL, error) {
+		return url.Parse("http://proxy.example.com:3128")
+	}
+
+	// option order must not matter: the host is checked per request
+	c, err := NewClientWithOpts(WithProxy(proxy), WithHost("unix:///var/run/docker.sock"))
+	assert.NilError(t, err)
+
+	transport, ok := c.client.Transport.(*http.Transport)
+	assert.Assert(t, ok)
+	req, err := http.NewRequest(http.MethodGet, "http://docker/v1.40/info", nil)
+	assert.NilError(t, err)
+	proxyURL, err := transport.Proxy(req)
+	assert.NilError(t, err)
+	assert.Check(t, is.Nil(proxyURL), "unix-socket connections must bypass the proxy")
+}
+
+func TestOptionWithNoProxy(t *testing.T) {
+	c, err := NewClientWithOpts(WithHost("tcp://docker.example.com:2376"), WithNoProxy())
+	assert.NilError(t, err)
+
+	transport, ok := c.client.Transport.(*http.Transport)
+	assert.Assert(t, ok)
+	assert.Check(t, transport.Proxy == nil)
+}
+
+func TestProxyFromURLInvalid(t *testing.T) {
+	for _, rawURL := range []string{"", "proxy.example.com:3128", "://bad"} {
+		_, err := ProxyFromURL(rawURL)
+		assert.Check(t, err != nil, "expected an error for %q", rawURL)
+	}
+}
@@ -19,7 +19,7 @@ func (cli *Client) Ping(ctx context.Context) (types.Ping, error) {
 	// Using cli.buildRequest() + cli.doRequest() instead of cli.sendRequest()
 	// because ping requests are used during API version negotiation, so we want
 	// to hit the non-versioned /_ping endpoint, not /v1.xx/_ping
-	req, err := cli.buildRequest(http.MethodHead, path.Join(cli.basePath, "/_ping"), nil, nil)
+	req, err := cli.buildRequest(ctx, http.MethodHead, path.Join(cli.basePath, "/_ping"), nil, nil)
 	if err != nil {
 		return ping, err
 	}
@@ -29,13 +29,17 @@ func (cli *Client) Ping(ctx context.Context) (types.Ping, error) {
 		switch serverResp.statusCode {
 		case http.StatusOK, http.StatusInternalServerError:
 			// Server handled the request, so parse the response
-			return parsePingResponse(cli, serverResp)
+			ping, err := parsePingResponse(cli, serverResp)
+			if err == nil {
+				cli.cachePing(ping)
+			}
+			return ping, err
 		}
 	} else if IsErrConnectionFailed(err) {
 		return ping, err
 	}
 
-	req, err = cli.buildRequest(http.MethodGet, path.Join(cli.basePath, "/_ping"), nil, nil)
+	req, err = cli.buildRequest(ctx, http.MethodGet, path.Join(cli.basePath, "/_ping"), nil, nil)
 	if err != nil {
 		return ping, err
 	}
@@ -44,7 +48,11 @@ func (cli *Client) Ping(ctx context.Context) (types.Ping, error) {
 	if err != nil {
 		return ping, err
 	}
-	return parsePingResponse(cli, serverResp)
+	ping, err = parsePingResponse(cli, serverResp)
+	if err == nil {
+		cli.cachePing(ping)
+	}
+	return ping, err
 }
 
 func parsePingResponse(cli *Client, resp serverResponse) (types.Ping, error) {
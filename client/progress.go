@@ -0,0 +1,89 @@
+package client // import "github.com/docker/docker/client"
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/docker/docker/pkg/jsonmessage"
+)
+
+// Progress is one decoded frame of the JSON progress stream returned by
+// ImagePull, ImageLoad, ImagePush and ImageBuild.
+type Progress struct {
+	// ID identifies what the frame is about, typically a layer.
+	ID string
+	// Status is the daemon's progress status, e.g. "Downloading".
+	Status string
+	// Current and Total are the transferred and expected byte counts;
+	// zero for untyped status frames.
+	Current int64
+	Total   int64
+	// Aux carries out-of-band data, such as the image ID on a build;
+	// unmarshal it into the operation's aux type.
+	Aux json.RawMessage
+}
+
+// ProgressError is an error frame from a progress stream, ending the
+// operation.
+type ProgressError struct {
+	// Code is the error code reported by the daemon; zero when the frame
+	// carried none.
+	Code int
+	// Message is the daemon's error message.
+	Message string
+}
+
+func (e ProgressError) Error() string {
+	return e.Message
+}
+
+// ProgressDecoder decodes a raw progress stream into typed frames, so
+// consumers stop hand-rolling jsonmessage decoding — and its error frame
+// handling — themselves.
+type ProgressDecoder struct {
+	dec *json.Decoder
+}
+
+// NewProgressDecoder returns a decoder for the given progress stream, as
+// returned by ImagePull, ImageLoad, ImagePush or ImageBuild. Closing the
+// stream stays with the caller.
+func NewProgressDecoder(r io.Reader) *ProgressDecoder {
+	return &ProgressDecoder{dec: json.NewDecoder(r)}
+}
+
+// Next returns the next frame of the stream. It returns io.EOF when the
+// stream ended cleanly, and a ProgressError when the daemon reported the
+// operation failed.
+func (d *ProgressDecoder) Next() (Progress, error) {
+	var msg jsonmessage.JSONMessage
+	if err := d.dec.Decode(&msg); err != nil {
+		return Progress{}, err
+	}
+	if msg.Error != nil {
+		return Progress{}, ProgressError{Code: msg.Error.Code, Message: msg.Error.Message}
+	}
+	progress := Progress{ID: msg.ID, Status: msg.Status}
+	if msg.Progress != nil {
+		progress.Current = msg.Progress.Current
+		progress.Total = msg.Progress.Total
+	}
+	if msg.Aux != nil {
+		progress.Aux = json.RawMessage(*msg.Aux)
+	}
+	return progress, nil
+}
+
+// DrainProgress consumes a progress stream until it ends, returning nil on
+// clean completion and the operation's error otherwise. Use it when only the
+// outcome matters, not the progress itself.
+func DrainProgress(r io.Reader) error {
+	dec := NewProgressDecoder(r)
+	for {
+		if _, err := dec.Next(); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
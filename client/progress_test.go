@@ -0,0 +1,77 @@
+package client // import "github.com/docker/docker/client"
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/pkg/errors"
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+func TestProgressDecoderSuccessStream(t *testing.T) {
+	stream := strings.Join([]string{
+		`{"id":"layer1","status":"Downloading","progressDetail":{"current":50,"total":100}}`,
+		`{"status":"Download complete"}`,
+	}, "\n")
+
+	dec := NewProgressDecoder(strings.NewReader(stream))
+
+	p, err := dec.Next()
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("layer1", p.ID))
+	assert.Check(t, is.Equal("Downloading", p.Status))
+	assert.Check(t, is.Equal(int64(50), p.Current))
+	assert.Check(t, is.Equal(int64(100), p.Total))
+
+	p, err = dec.Next()
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("Download complete", p.Status))
+
+	_, err = dec.Next()
+	assert.Check(t, is.Equal(io.EOF, err))
+}
+
+func TestProgressDecoderErrorFrame(t *testing.T) {
+	stream := `{"status":"Pulling"}` + "\n" +
+		`{"errorDetail":{"code":1,"message":"manifest unknown"},"error":"manifest unknown"}`
+
+	dec := NewProgressDecoder(strings.NewReader(stream))
+	_, err := dec.Next()
+	assert.NilError(t, err)
+
+	_, err = dec.Next()
+	var progressErr ProgressError
+	assert.Assert(t, errors.As(err, &progressErr), "expected a ProgressError, got %[1]T: %[1]v", err)
+	assert.Check(t, is.Equal(1, progressErr.Code))
+	assert.Check(t, is.Equal("manifest unknown", progressErr.Message))
+	assert.Check(t, is.Error(err, "manifest unknown"))
+}
+
+func TestProgressDecoderAux(t *testing.T) {
+	stream := `{"aux":{"ID":"sha256:deadbeef"}}`
+
+	dec := NewProgressDecoder(strings.NewReader(stream))
+	p, err := dec.Next()
+	assert.NilError(t, err)
+	assert.Assert(t, p.Aux != nil)
+
+	var result types.BuildResult
+	assert.NilError(t, json.Unmarshal(p.Aux, &result))
+	assert.Check(t, is.Equal("sha256:deadbeef", result.ID))
+}
+
+func TestDrainProgress(t *testing.T) {
+	ok := `{"status":"one"}` + "\n" + `{"status":"two"}`
+	assert.NilError(t, DrainProgress(strings.NewReader(ok)))
+
+	failed := `{"status":"one"}` + "\n" + `{"errorDetail":{"message":"no space left on device"}}`
+	err := DrainProgress(strings.NewReader(failed))
+	assert.ErrorContains(t, err, "no space left on device")
+
+	truncated := `{"status":"one"}` + "\n" + `{"stat`
+	assert.Assert(t, DrainProgress(strings.NewReader(truncated)) != nil, "a truncated stream is not clean completion")
+}
@@ -12,6 +12,7 @@ import (
 	"net/url"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/versions"
@@ -78,7 +79,7 @@ func encodeBody(obj interface{}, headers headers) (io.Reader, headers, error) {
 	return body, headers, nil
 }
 
-func (cli *Client) buildRequest(method, path string, body io.Reader, headers headers) (*http.Request, error) {
+func (cli *Client) buildRequest(ctx context.Context, method, path string, body io.Reader, headers headers) (*http.Request, error) {
 	expectedPayload := (method == http.MethodPost || method == http.MethodPut)
 	if expectedPayload && body == nil {
 		body = bytes.NewReader([]byte{})
@@ -88,7 +89,7 @@ func (cli *Client) buildRequest(method, path string, body io.Reader, headers hea
 	if err != nil {
 		return nil, err
 	}
-	req = cli.addHeaders(req, headers)
+	req = cli.addHeaders(ctx, req, headers)
 
 	if cli.proto == "unix" || cli.proto == "npipe" {
 		// For local communications, it doesn't matter what the host is. We just
@@ -106,23 +107,108 @@ func (cli *Client) buildRequest(method, path string, body io.Reader, headers hea
 }
 
 func (cli *Client) sendRequest(ctx context.Context, method, path string, query url.Values, body io.Reader, headers headers) (serverResponse, error) {
-	req, err := cli.buildRequest(method, cli.getAPIPath(ctx, path, query), body, headers)
+	class := classifyRequest(path)
+	var cancel context.CancelFunc
+	if d := cli.requestDeadline(ctx, class); d > 0 {
+		ctx, cancel = context.WithTimeout(ctx, d)
+	}
+
+	req, err := cli.buildRequest(ctx, method, cli.getAPIPath(ctx, path, query), body, headers)
 	if err != nil {
+		if cancel != nil {
+			cancel()
+		}
 		return serverResponse{}, err
 	}
 	resp, err := cli.doRequest(ctx, req)
+	if cancel != nil {
+		if err != nil || resp.body == nil {
+			cancel()
+		} else {
+			// the deadline must span the body read too; release it when
+			// the caller closes the body
+			resp.body = cancelOnCloseBody{ReadCloser: resp.body, cancel: cancel}
+		}
+	}
 	if err != nil {
 		return resp, errdefs.FromStatusCode(err, resp.statusCode)
 	}
+	if class == timeoutClassStreaming && cli.timeouts != nil && cli.timeouts.StreamingIdle > 0 && resp.body != nil {
+		resp.body = newIdleTimeoutBody(resp.body, cli.timeouts.StreamingIdle)
+	}
+	if class != timeoutClassStreaming && cli.maxResponseSize > 0 && resp.body != nil {
+		resp.body = &limitedResponseBody{rc: resp.body, limit: cli.maxResponseSize, endpoint: path}
+	}
 	err = cli.checkResponseErr(resp)
 	return resp, errdefs.FromStatusCode(err, resp.statusCode)
 }
 
+// doRequest performs the request, retrying it under the client's retry
+// policy when the request is idempotent and the failure looks transient.
 func (cli *Client) doRequest(ctx context.Context, req *http.Request) (serverResponse, error) {
+	if cli.retry == nil || !cli.retry.retriable(req) {
+		return cli.doRequestOnce(ctx, req)
+	}
+
+	var (
+		resp serverResponse
+		err  error
+	)
+	for attempt := 1; ; attempt++ {
+		resp, err = cli.doRequestOnce(ctx, req)
+		var retryAfter time.Duration
+		var hasRetryAfter bool
+		if err != nil {
+			if attempt >= cli.retry.maxAttempts || !retriableError(err) || !cli.retry.takeBudget() {
+				if attempt > 1 {
+					err = errors.Wrapf(err, "after %d attempts", attempt)
+				}
+				return resp, err
+			}
+		} else if retriableStatus(resp.statusCode) && attempt < cli.retry.maxAttempts {
+			retryAfter, hasRetryAfter = parseRetryAfter(resp.header)
+			if retryAfter > cli.retry.maxRetryAfter {
+				retryAfter = cli.retry.maxRetryAfter
+			}
+			// don't wait out a Retry-After the caller's deadline cannot
+			// accommodate; surface the response instead
+			if deadline, ok := ctx.Deadline(); ok && hasRetryAfter && time.Until(deadline) <= retryAfter {
+				return resp, nil
+			}
+			if !cli.retry.takeBudget() {
+				return resp, nil
+			}
+			// the failed attempt's body is not returned to the caller;
+			// release the connection before retrying
+			ensureReaderClosed(resp)
+		} else {
+			return resp, nil
+		}
+		var werr error
+		if hasRetryAfter {
+			werr = cli.retry.sleep(ctx, retryAfter)
+		} else {
+			werr = cli.retry.wait(ctx, attempt)
+		}
+		if werr != nil {
+			return resp, werr
+		}
+	}
+}
+
+func (cli *Client) doRequestOnce(ctx context.Context, req *http.Request) (serverResponse, error) {
 	serverResp := serverResponse{statusCode: -1, reqURL: req.URL}
 
 	req = req.WithContext(ctx)
+	if cli.metrics != nil {
+		req = req.WithContext(cli.metrics.traceContext(req.Context()))
+		cli.metrics.requestStarted(req)
+	}
+	cli.traceRequest(req)
 	resp, err := cli.client.Do(req)
+	if cli.metrics != nil {
+		cli.metrics.requestFinished(err)
+	}
 	if err != nil {
 		if cli.scheme != "https" && strings.Contains(err.Error(), "malformed HTTP response") {
 			return serverResp, fmt.Errorf("%v.\n* Are you trying to connect to a TLS-enabled daemon without TLS?", err)
@@ -138,6 +224,14 @@ func (cli *Client) doRequest(ctx context.Context, req *http.Request) (serverResp
 			return serverResp, err
 		}
 
+		// a local socket that is missing or not accessible is a setup
+		// problem the user can fix; say so instead of the bare errno
+		if cli.proto == "unix" || cli.proto == "npipe" {
+			if errors.Is(err, os.ErrPermission) || errors.Is(err, os.ErrNotExist) {
+				return serverResp, SocketError{Host: cli.host, Path: cli.addr, Cause: err}
+			}
+		}
+
 		if nErr, ok := err.(*url.Error); ok {
 			if nErr, ok := nErr.Err.(*net.OpError); ok {
 				if os.IsPermission(nErr.Err) {
@@ -182,7 +276,7 @@ func (cli *Client) doRequest(ctx context.Context, req *http.Request) (serverResp
 
 	if resp != nil {
 		serverResp.statusCode = resp.StatusCode
-		serverResp.body = resp.Body
+		serverResp.body = cli.traceResponse(resp)
 		serverResp.header = resp.Header
 	}
 	return serverResp, nil
@@ -193,6 +287,14 @@ func (cli *Client) checkResponseErr(serverResp serverResponse) error {
 		return nil
 	}
 
+	apiErr := APIError{StatusCode: serverResp.statusCode}
+	if serverResp.header != nil {
+		apiErr.RequestID = serverResp.header.Get("X-Request-Id")
+		if d, ok := parseRetryAfter(serverResp.header); ok {
+			apiErr.RetryAfter = d
+		}
+	}
+
 	var body []byte
 	var err error
 	if serverResp.body != nil {
@@ -205,12 +307,17 @@ func (cli *Client) checkResponseErr(serverResp serverResponse) error {
 		if err != nil {
 			return err
 		}
+		apiErr.Body = body
 		if bodyR.N == 0 {
-			return fmt.Errorf("request returned %s with a message (> %d bytes) for API route and version %s, check if the server supports the requested API version", http.StatusText(serverResp.statusCode), bodyMax, serverResp.reqURL)
+			apiErr.Message = fmt.Sprintf("request returned %s with a message (> %d bytes) for API route and version %s, check if the server supports the requested API version", http.StatusText(serverResp.statusCode), bodyMax, serverResp.reqURL)
+			apiErr.bare = true
+			return apiErr
 		}
 	}
 	if len(body) == 0 {
-		return fmt.Errorf("request returned %s for API route and version %s, check if the server supports the requested API version", http.StatusText(serverResp.statusCode), serverResp.reqURL)
+		apiErr.Message = fmt.Sprintf("request returned %s for API route and version %s, check if the server supports the requested API version", http.StatusText(serverResp.statusCode), serverResp.reqURL)
+		apiErr.bare = true
+		return apiErr
 	}
 
 	var ct string
@@ -219,20 +326,86 @@ func (cli *Client) checkResponseErr(serverResp serverResponse) error {
 	}
 
 	var errorMessage string
-	if (cli.version == "" || versions.GreaterThan(cli.version, "1.23")) && ct == "application/json" {
+	if (cli.version == "" || versions.GreaterThan(cli.version, "1.23")) && isJSONBody(ct, body) {
 		var errorResponse types.ErrorResponse
-		if err := json.Unmarshal(body, &errorResponse); err != nil {
-			return errors.Wrap(err, "Error reading JSON")
+		if err := json.Unmarshal(body, &errorResponse); err == nil {
+			errorMessage = strings.TrimSpace(errorResponse.Message)
 		}
-		errorMessage = strings.TrimSpace(errorResponse.Message)
-	} else {
-		errorMessage = strings.TrimSpace(string(body))
+		// when the JSON is broken — a proxy may claim application/json
+		// and send something else — fall through to the raw body rather
+		// than surfacing a JSON syntax error
+	}
+	if errorMessage == "" {
+		errorMessage = cleanErrorBody(ct, body)
+	}
+	if errorMessage == "" {
+		apiErr.Message = fmt.Sprintf("request returned %s for API route and version %s, check if the server supports the requested API version", http.StatusText(serverResp.statusCode), serverResp.reqURL)
+		apiErr.bare = true
+		return apiErr
+	}
+
+	apiErr.Message = errorMessage
+	apiErr.ResourceID = parseResourceID(errorMessage)
+	return apiErr
+}
+
+// errorBodyMaxLen bounds how much of a non-JSON error body ends up in an
+// error message.
+const errorBodyMaxLen = 256
+
+// isJSONBody reports whether an error body should be decoded as the API's
+// JSON error envelope, judging by the Content-Type header and, for proxies
+// that mislabel their responses, the body itself.
+func isJSONBody(ct string, body []byte) bool {
+	if strings.HasPrefix(ct, "application/json") {
+		return true
 	}
+	trimmed := bytes.TrimLeft(body, " \t\r\n")
+	return len(trimmed) > 0 && trimmed[0] == '{'
+}
 
-	return errors.Wrap(errors.New(errorMessage), "Error response from daemon")
+// cleanErrorBody turns a plain-text or HTML error body — a reverse proxy's
+// 502 page, an old daemon's text error — into a single-line message of
+// bounded length.
+func cleanErrorBody(ct string, body []byte) string {
+	text := string(body)
+	if strings.HasPrefix(ct, "text/html") || strings.HasPrefix(strings.TrimSpace(text), "<") {
+		text = stripHTMLTags(text)
+	}
+	// collapse newlines and runs of whitespace
+	text = strings.Join(strings.Fields(text), " ")
+	if len(text) > errorBodyMaxLen {
+		text = text[:errorBodyMaxLen] + " [truncated]"
+	}
+	return text
 }
 
-func (cli *Client) addHeaders(req *http.Request, headers headers) *http.Request {
+// stripHTMLTags drops everything between angle brackets, leaving the text
+// content of an HTML error page.
+func stripHTMLTags(s string) string {
+	var b strings.Builder
+	inTag := false
+	for _, r := range s {
+		switch {
+		case r == '<':
+			inTag = true
+		case r == '>':
+			inTag = false
+		case !inTag:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func (cli *Client) addHeaders(ctx context.Context, req *http.Request, headers headers) *http.Request {
+	// identify the library first, so configured headers can still take the
+	// User-Agent over wholesale; an explicit WithUserAgent or
+	// WithUserAgentSuffix wins over those below
+	if !versions.LessThan(cli.version, "1.25") {
+		req.Header.Set("User-Agent", defaultUserAgent)
+	}
+
 	// Add CLI Config's HTTP Headers BEFORE we set the Docker headers
 	// then the user can't change OUR headers
 	for k, v := range cli.customHTTPHeaders {
@@ -242,6 +415,22 @@ func (cli *Client) addHeaders(req *http.Request, headers headers) *http.Request
 		req.Header.Set(k, v)
 	}
 
+	// Per-request headers from the context override the configured ones,
+	// but not the headers the endpoint itself sets below.
+	for k, v := range headersFromContext(ctx) {
+		if _, ok := hopByHopHeaders[k]; ok {
+			continue
+		}
+		if versions.LessThan(cli.version, "1.25") && k == "User-Agent" {
+			continue
+		}
+		req.Header[k] = v
+	}
+
+	if (cli.userAgent != nil || cli.userAgentSuffix != "") && !versions.LessThan(cli.version, "1.25") {
+		req.Header.Set("User-Agent", cli.resolveUserAgent())
+	}
+
 	if headers != nil {
 		for k, v := range headers {
 			req.Header[k] = v
@@ -3,6 +3,7 @@ package client // import "github.com/docker/docker/client"
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"math/rand"
@@ -12,6 +13,7 @@ import (
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/errdefs"
+	"github.com/pkg/errors"
 	"gotest.tools/v3/assert"
 	is "gotest.tools/v3/assert/cmp"
 )
@@ -91,6 +93,130 @@ func TestPlainTextError(t *testing.T) {
 	}
 }
 
+func TestAPIErrorJSON(t *testing.T) {
+	client := &Client{
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			header := http.Header{}
+			header.Set("Content-Type", "application/json")
+			header.Set("X-Request-Id", "req-12345")
+			body, err := json.Marshal(&types.ErrorResponse{Message: "No such container: nothing"})
+			if err != nil {
+				return nil, err
+			}
+			return &http.Response{
+				StatusCode: http.StatusNotFound,
+				Header:     header,
+				Body:       ioutil.NopCloser(bytes.NewReader(body)),
+			}, nil
+		}),
+	}
+
+	_, err := client.ContainerInspect(context.Background(), "nothing")
+	assert.Check(t, errdefs.IsNotFound(err), "the errdefs predicates must still hold, got %[1]T: %[1]v", err)
+
+	var apiErr APIError
+	assert.Assert(t, errors.As(err, &apiErr), "expected an APIError, got %[1]T: %[1]v", err)
+	assert.Check(t, is.Equal(http.StatusNotFound, apiErr.StatusCode))
+	assert.Check(t, is.Equal("No such container: nothing", apiErr.Message))
+	assert.Check(t, is.Equal("nothing", apiErr.ResourceID))
+	assert.Check(t, is.Equal("req-12345", apiErr.RequestID))
+	assert.Check(t, is.Contains(string(apiErr.Body), "No such container"))
+	// the outer message is unchanged; the daemon's own phrasing is on the
+	// typed error
+	assert.Check(t, is.ErrorContains(err, "No such container: nothing"))
+	assert.Check(t, is.Equal("Error response from daemon: No such container: nothing", apiErr.Error()))
+}
+
+func TestAPIErrorPlainText(t *testing.T) {
+	client := &Client{
+		client: newMockClient(plainTextErrorMock(http.StatusInternalServerError, "Server error")),
+	}
+
+	_, err := client.ContainerList(context.Background(), types.ContainerListOptions{})
+	var apiErr APIError
+	assert.Assert(t, errors.As(err, &apiErr), "expected an APIError, got %[1]T: %[1]v", err)
+	assert.Check(t, is.Equal(http.StatusInternalServerError, apiErr.StatusCode))
+	assert.Check(t, is.Equal("Server error", apiErr.Message))
+	assert.Check(t, is.Equal("", apiErr.ResourceID))
+	assert.Check(t, is.DeepEqual([]byte("Server error"), apiErr.Body))
+}
+
+func TestAPIErrorHTMLBody(t *testing.T) {
+	// an HTML 502 page from a reverse proxy in front of the daemon
+	page := "<html>\n<head><title>502 Bad Gateway</title></head>\n<body>\n<center><h1>502 Bad Gateway</h1></center>\n<hr><center>nginx</center>\n</body>\n</html>"
+	client := &Client{
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			header := http.Header{}
+			header.Set("Content-Type", "text/html")
+			return &http.Response{
+				StatusCode: http.StatusBadGateway,
+				Header:     header,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(page))),
+			}, nil
+		}),
+	}
+
+	_, err := client.ContainerList(context.Background(), types.ContainerListOptions{})
+	var apiErr APIError
+	assert.Assert(t, errors.As(err, &apiErr), "expected an APIError, got %[1]T: %[1]v", err)
+	assert.Check(t, is.Equal(http.StatusBadGateway, apiErr.StatusCode))
+	assert.Check(t, is.Equal("502 Bad Gateway 502 Bad Gateway nginx", apiErr.Message))
+	assert.Check(t, !strings.Contains(err.Error(), "<"), "markup must not leak into the message: %v", err)
+}
+
+func TestAPIErrorBrokenJSON(t *testing.T) {
+	// a proxy claiming application/json but sending something else must not
+	// surface a JSON syntax error
+	client := &Client{
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			header := http.Header{}
+			header.Set("Content-Type", "application/json")
+			return &http.Response{
+				StatusCode: http.StatusServiceUnavailable,
+				Header:     header,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte("upstream connect error"))),
+			}, nil
+		}),
+	}
+
+	_, err := client.ContainerList(context.Background(), types.ContainerListOptions{})
+	var apiErr APIError
+	assert.Assert(t, errors.As(err, &apiErr), "expected an APIError, got %[1]T: %[1]v", err)
+	assert.Check(t, is.Equal(http.StatusServiceUnavailable, apiErr.StatusCode))
+	assert.Check(t, is.Equal("upstream connect error", apiErr.Message))
+	assert.Check(t, !strings.Contains(err.Error(), "invalid character"), "got %v", err)
+}
+
+func TestAPIErrorEmptyBody(t *testing.T) {
+	client := &Client{
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusBadGateway,
+				Header:     http.Header{},
+				Body:       ioutil.NopCloser(bytes.NewReader(nil)),
+			}, nil
+		}),
+	}
+
+	_, err := client.ContainerList(context.Background(), types.ContainerListOptions{})
+	var apiErr APIError
+	assert.Assert(t, errors.As(err, &apiErr), "expected an APIError, got %[1]T: %[1]v", err)
+	assert.Check(t, is.Equal(http.StatusBadGateway, apiErr.StatusCode))
+	assert.Check(t, is.ErrorContains(err, "request returned Bad Gateway"))
+}
+
+func TestAPIErrorBodyTruncation(t *testing.T) {
+	client := &Client{
+		client: newMockClient(plainTextErrorMock(http.StatusInternalServerError, strings.Repeat("x", 2*errorBodyMaxLen))),
+	}
+
+	_, err := client.ContainerList(context.Background(), types.ContainerListOptions{})
+	var apiErr APIError
+	assert.Assert(t, errors.As(err, &apiErr), "expected an APIError, got %[1]T: %[1]v", err)
+	assert.Check(t, is.Contains(apiErr.Message, "[truncated]"))
+	assert.Check(t, len(apiErr.Message) < errorBodyMaxLen+20, "message length %d", len(apiErr.Message))
+}
+
 func TestInfiniteError(t *testing.T) {
 	infinitR := rand.New(rand.NewSource(42))
 	client := &Client{
@@ -0,0 +1,56 @@
+package client // import "github.com/docker/docker/client"
+
+import (
+	"fmt"
+	"io"
+)
+
+// defaultMaxResponseSize is the response body limit applied to non-streaming
+// endpoints unless overridden with WithMaxResponseSize. It is generous: no
+// well-behaved daemon response comes anywhere near it.
+const defaultMaxResponseSize = 32 << 20 // 32 MiB
+
+// ResponseLimitError is returned when a non-streaming endpoint's response
+// body exceeds the client's configured maximum size.
+type ResponseLimitError struct {
+	// Endpoint is the API route whose response was too large.
+	Endpoint string
+	// Limit is the configured maximum body size in bytes.
+	Limit int64
+}
+
+func (e ResponseLimitError) Error() string {
+	return fmt.Sprintf("response body for %s exceeds the configured limit of %d bytes", e.Endpoint, e.Limit)
+}
+
+// WithMaxResponseSize overrides the maximum response body size accepted from
+// non-streaming endpoints. Zero or a negative value removes the limit.
+// Streaming endpoints (logs, events, pull progress) are never limited.
+func WithMaxResponseSize(limit int64) Opt {
+	return func(c *Client) error {
+		c.maxResponseSize = limit
+		return nil
+	}
+}
+
+// limitedResponseBody aborts the read as soon as more than the configured
+// number of bytes has arrived, without buffering the body first.
+type limitedResponseBody struct {
+	rc       io.ReadCloser
+	read     int64
+	limit    int64
+	endpoint string
+}
+
+func (b *limitedResponseBody) Read(p []byte) (int, error) {
+	n, err := b.rc.Read(p)
+	b.read += int64(n)
+	if b.read > b.limit {
+		return 0, ResponseLimitError{Endpoint: b.endpoint, Limit: b.limit}
+	}
+	return n, err
+}
+
+func (b *limitedResponseBody) Close() error {
+	return b.rc.Close()
+}
@@ -0,0 +1,107 @@
+package client // import "github.com/docker/docker/client"
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/pkg/errors"
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+func TestResponseLimitExceeded(t *testing.T) {
+	// valid JSON, so the failure comes from the size limit and not from
+	// the decoder giving up early
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for i := 0; i < 200; i++ {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(`{"Id":"` + string(bytes.Repeat([]byte("x"), 64)) + `"}`)
+	}
+	buf.WriteByte(']')
+	oversized := buf.Bytes()
+	client := &Client{
+		maxResponseSize: 1024,
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader(oversized)),
+			}, nil
+		}),
+	}
+
+	_, err := client.ContainerList(context.Background(), types.ContainerListOptions{})
+	var limitErr ResponseLimitError
+	assert.Assert(t, errors.As(err, &limitErr), "expected a ResponseLimitError, got %[1]T: %[1]v", err)
+	assert.Check(t, is.Equal("/containers/json", limitErr.Endpoint))
+	assert.Check(t, is.Equal(int64(1024), limitErr.Limit))
+	assert.Check(t, is.ErrorContains(err, "exceeds the configured limit of 1024 bytes"))
+}
+
+func TestResponseLimitUnderLimit(t *testing.T) {
+	client := &Client{
+		maxResponseSize: 1024,
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte("[]"))),
+			}, nil
+		}),
+	}
+
+	_, err := client.ContainerList(context.Background(), types.ContainerListOptions{})
+	assert.NilError(t, err)
+}
+
+func TestResponseLimitStreamingExempt(t *testing.T) {
+	oversized := bytes.Repeat([]byte("x"), 10*1024)
+	client := &Client{
+		maxResponseSize: 1024,
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader(oversized)),
+			}, nil
+		}),
+	}
+
+	resp, err := client.sendRequest(context.Background(), http.MethodGet, "/events", nil, nil, nil)
+	assert.NilError(t, err)
+	defer resp.body.Close()
+
+	body, err := ioutil.ReadAll(resp.body)
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(body, len(oversized)))
+}
+
+func TestResponseLimitBoundedRead(t *testing.T) {
+	// an endless body: the limit check must trip while streaming, not
+	// after buffering everything
+	endless := rand.New(rand.NewSource(42))
+	client := &Client{
+		maxResponseSize: 4096,
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(endless),
+			}, nil
+		}),
+	}
+
+	resp, err := client.sendRequest(context.Background(), http.MethodGet, "/info", nil, nil, nil)
+	assert.NilError(t, err)
+	defer resp.body.Close()
+
+	_, err = io.Copy(ioutil.Discard, resp.body)
+	var limitErr ResponseLimitError
+	assert.Assert(t, errors.As(err, &limitErr), "expected a ResponseLimitError, got %[1]T: %[1]v", err)
+	assert.Check(t, is.Equal("/info", limitErr.Endpoint))
+}
@@ -0,0 +1,237 @@
+package client // import "github.com/docker/docker/client"
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultRetryMaxAttempts    = 3
+	defaultRetryInitialBackoff = 100 * time.Millisecond
+	defaultRetryMaxBackoff     = 2 * time.Second
+	defaultRetryMaxRetryAfter  = 30 * time.Second
+	defaultRetryBudget         = 10
+)
+
+// RetryOptions configures the retry policy applied by WithRetry.
+type RetryOptions struct {
+	// MaxAttempts is the total number of attempts per request, including the
+	// first. Zero or negative selects the default of 3.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry; it doubles on each
+	// subsequent retry. Zero selects the default of 100ms.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between retries. Zero selects the default
+	// of 2s.
+	MaxBackoff time.Duration
+	// Methods lists HTTP methods to retry in addition to GET and HEAD. Only
+	// whitelist a method for an endpoint you know to be idempotent; the
+	// policy applies to every request made with it.
+	Methods []string
+	// MaxRetryAfter caps how long a Retry-After response header is honored
+	// before retrying a 429 or 503 response. Zero selects the default of
+	// 30s.
+	MaxRetryAfter time.Duration
+	// Budget is the number of retries available to the whole client at
+	// once, shared across requests and replenished at one per second, so
+	// many concurrently failing requests cannot amplify into a retry
+	// storm. Zero selects the default of 10; a negative value removes the
+	// budget.
+	Budget int
+}
+
+// retryPolicy is the normalized form of RetryOptions held by the client.
+type retryPolicy struct {
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	maxRetryAfter  time.Duration
+	methods        map[string]struct{}
+	budget         *retryBudget
+}
+
+// retryBudget is a token bucket shared by every request of a client: each
+// retry takes a token, and tokens replenish at one per second up to the
+// configured maximum.
+type retryBudget struct {
+	mu     sync.Mutex
+	max    float64
+	tokens float64
+	last   time.Time
+}
+
+func (b *retryBudget) take() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds()
+		if b.tokens > b.max {
+			b.tokens = b.max
+		}
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// takeBudget takes a retry token from the shared budget; a policy without a
+// budget always has one available.
+func (p *retryPolicy) takeBudget() bool {
+	return p.budget == nil || p.budget.take()
+}
+
+// retriable reports whether a request is safe to send more than once. Beyond
+// the method whitelist, the request must not carry a body: a body reader may
+// already have been consumed by the failed attempt, and replaying it is not
+// possible in general.
+func (p *retryPolicy) retriable(req *http.Request) bool {
+	if req.Body != nil {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		return true
+	}
+	_, ok := p.methods[req.Method]
+	return ok
+}
+
+// retriableError reports whether an attempt failed in a way an immediate
+// retry could fix: the connection died or the daemon was briefly unreachable,
+// as opposed to the daemon rejecting the request.
+func retriableError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if IsErrConnectionFailed(err) {
+		return true
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	if nErr, ok := errors.Cause(err).(net.Error); ok && nErr.Timeout() {
+		return true
+	}
+	return strings.Contains(err.Error(), "connection reset by peer") ||
+		strings.Contains(err.Error(), "EOF")
+}
+
+// retriableStatus reports whether a response status indicates a transient
+// condition in front of the daemon, such as a proxy that lost its backend.
+func retriableStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// parseRetryAfter reads the Retry-After response header, which carries
+// either a number of seconds or an HTTP-date.
+func parseRetryAfter(header http.Header) (time.Duration, bool) {
+	if header == nil {
+		return 0, false
+	}
+	val := header.Get("Retry-After")
+	if val == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(val); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	if when, err := http.ParseTime(val); err == nil {
+		if d := time.Until(when); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// wait sleeps for the backoff of the given (1-based) attempt, or returns
+// early with the context error.
+func (p *retryPolicy) wait(ctx context.Context, attempt int) error {
+	backoff := p.initialBackoff
+	for i := 1; i < attempt && backoff < p.maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > p.maxBackoff {
+		backoff = p.maxBackoff
+	}
+	return p.sleep(ctx, backoff)
+}
+
+// sleep waits out the given delay, or returns early with the context error.
+func (p *retryPolicy) sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// WithRetry makes the client transparently retry idempotent requests that
+// fail for transient reasons, with exponential backoff. Responses with a 429
+// or 503 status are retried after the wait their Retry-After header asks
+// for, capped by MaxRetryAfter and the caller's context deadline. Requests
+// that carry a body, and methods other than GET, HEAD and those whitelisted
+// in the options, are never retried; their typed error exposes the
+// Retry-After value instead.
+func WithRetry(options RetryOptions) Opt {
+	return func(c *Client) error {
+		policy := &retryPolicy{
+			maxAttempts:    options.MaxAttempts,
+			initialBackoff: options.InitialBackoff,
+			maxBackoff:     options.MaxBackoff,
+			maxRetryAfter:  options.MaxRetryAfter,
+		}
+		if policy.maxAttempts <= 0 {
+			policy.maxAttempts = defaultRetryMaxAttempts
+		}
+		if policy.initialBackoff <= 0 {
+			policy.initialBackoff = defaultRetryInitialBackoff
+		}
+		if policy.maxBackoff <= 0 {
+			policy.maxBackoff = defaultRetryMaxBackoff
+		}
+		if policy.maxRetryAfter <= 0 {
+			policy.maxRetryAfter = defaultRetryMaxRetryAfter
+		}
+		budget := options.Budget
+		if budget == 0 {
+			budget = defaultRetryBudget
+		}
+		if budget > 0 {
+			policy.budget = &retryBudget{max: float64(budget), tokens: float64(budget)}
+		}
+		if len(options.Methods) > 0 {
+			policy.methods = make(map[string]struct{}, len(options.Methods))
+			for _, m := range options.Methods {
+				policy.methods[m] = struct{}{}
+			}
+		}
+		c.retry = policy
+		return nil
+	}
+}
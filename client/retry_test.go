@@ -0,0 +1,251 @@
+package client // import "github.com/docker/docker/client"
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/pkg/errors"
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+// testRetryOptions keeps the backoff short so exhaustion tests stay fast.
+var testRetryOptions = RetryOptions{InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+func TestRetryEOFThenSuccess(t *testing.T) {
+	var attempts int32
+	client := &Client{
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			if atomic.AddInt32(&attempts, 1) < 3 {
+				return nil, io.EOF
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte("[]"))),
+			}, nil
+		}),
+	}
+	assert.NilError(t, WithRetry(testRetryOptions)(client))
+
+	containers, err := client.ContainerList(context.Background(), types.ContainerListOptions{})
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(containers, 0))
+	assert.Check(t, is.Equal(int32(3), atomic.LoadInt32(&attempts)))
+}
+
+func TestRetryExhaustionNotesAttempts(t *testing.T) {
+	var attempts int32
+	client := &Client{
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			atomic.AddInt32(&attempts, 1)
+			return nil, io.EOF
+		}),
+	}
+	assert.NilError(t, WithRetry(testRetryOptions)(client))
+
+	_, err := client.ContainerList(context.Background(), types.ContainerListOptions{})
+	assert.ErrorContains(t, err, "after 3 attempts")
+	assert.Check(t, is.Equal(int32(3), atomic.LoadInt32(&attempts)))
+}
+
+func TestRetryDoesNotRetryPost(t *testing.T) {
+	var attempts int32
+	client := &Client{
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			atomic.AddInt32(&attempts, 1)
+			return nil, io.EOF
+		}),
+	}
+	assert.NilError(t, WithRetry(testRetryOptions)(client))
+
+	_, err := client.ContainerCreate(context.Background(), &container.Config{}, &container.HostConfig{}, &network.NetworkingConfig{}, nil, "nothing")
+	assert.Assert(t, err != nil)
+	assert.Check(t, is.Equal(int32(1), atomic.LoadInt32(&attempts)), "POST requests must not be retried")
+}
+
+func TestRetryWhitelistedMethod(t *testing.T) {
+	var attempts int32
+	client := &Client{
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			if atomic.AddInt32(&attempts, 1) < 2 {
+				return nil, io.EOF
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader(nil)),
+			}, nil
+		}),
+	}
+	options := testRetryOptions
+	options.Methods = []string{http.MethodDelete}
+	assert.NilError(t, WithRetry(options)(client))
+
+	err := client.NetworkRemove(context.Background(), "network_id")
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(int32(2), atomic.LoadInt32(&attempts)))
+}
+
+func TestRetryTransientStatus(t *testing.T) {
+	var attempts int32
+	client := &Client{
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			if atomic.AddInt32(&attempts, 1) < 2 {
+				return &http.Response{
+					StatusCode: http.StatusBadGateway,
+					Body:       ioutil.NopCloser(strings.NewReader("bad gateway")),
+				}, nil
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte("[]"))),
+			}, nil
+		}),
+	}
+	assert.NilError(t, WithRetry(testRetryOptions)(client))
+
+	_, err := client.ContainerList(context.Background(), types.ContainerListOptions{})
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(int32(2), atomic.LoadInt32(&attempts)))
+}
+
+func TestRetryHonorsContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	client := &Client{
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			cancel()
+			return nil, io.EOF
+		}),
+	}
+	assert.NilError(t, WithRetry(RetryOptions{InitialBackoff: time.Minute})(client))
+
+	_, err := client.ContainerList(ctx, types.ContainerListOptions{})
+	assert.Check(t, errors.Is(err, context.Canceled), "expected context.Canceled, got %v", err)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	mkHeader := func(val string) http.Header {
+		return http.Header{"Retry-After": {val}}
+	}
+
+	d, ok := parseRetryAfter(mkHeader("7"))
+	assert.Check(t, ok)
+	assert.Check(t, is.Equal(7*time.Second, d))
+
+	d, ok = parseRetryAfter(mkHeader(time.Now().Add(3 * time.Second).UTC().Format(http.TimeFormat)))
+	assert.Check(t, ok)
+	assert.Check(t, d > time.Second && d <= 3*time.Second, "got %v", d)
+
+	d, ok = parseRetryAfter(mkHeader(time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat)))
+	assert.Check(t, ok, "a date in the past still asks for a retry")
+	assert.Check(t, is.Equal(time.Duration(0), d))
+
+	_, ok = parseRetryAfter(mkHeader("soon"))
+	assert.Check(t, !ok)
+	_, ok = parseRetryAfter(mkHeader("-1"))
+	assert.Check(t, !ok)
+	_, ok = parseRetryAfter(http.Header{})
+	assert.Check(t, !ok)
+	_, ok = parseRetryAfter(nil)
+	assert.Check(t, !ok)
+}
+
+func TestRetryTooManyRequests(t *testing.T) {
+	var attempts int32
+	client := &Client{
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			if atomic.AddInt32(&attempts, 1) < 2 {
+				return &http.Response{
+					StatusCode: http.StatusTooManyRequests,
+					Header:     http.Header{"Retry-After": {"0"}},
+					Body:       ioutil.NopCloser(strings.NewReader("slow down")),
+				}, nil
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte("[]"))),
+			}, nil
+		}),
+	}
+	assert.NilError(t, WithRetry(testRetryOptions)(client))
+
+	_, err := client.ContainerList(context.Background(), types.ContainerListOptions{})
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(int32(2), atomic.LoadInt32(&attempts)))
+}
+
+func TestRetryBudgetExhaustion(t *testing.T) {
+	var attempts int32
+	client := &Client{
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			atomic.AddInt32(&attempts, 1)
+			return &http.Response{
+				StatusCode: http.StatusTooManyRequests,
+				Header:     http.Header{"Retry-After": {"0"}},
+				Body:       ioutil.NopCloser(strings.NewReader("slow down")),
+			}, nil
+		}),
+	}
+	options := testRetryOptions
+	options.MaxAttempts = 5
+	options.Budget = 1
+	assert.NilError(t, WithRetry(options)(client))
+
+	_, err := client.ContainerList(context.Background(), types.ContainerListOptions{})
+	var apiErr APIError
+	assert.Assert(t, errors.As(err, &apiErr), "got %v", err)
+	assert.Check(t, is.Equal(http.StatusTooManyRequests, apiErr.StatusCode))
+	assert.Check(t, is.Equal(int32(2), atomic.LoadInt32(&attempts)), "a budget of 1 allows a single retry")
+}
+
+func TestRetryAfterRespectsDeadline(t *testing.T) {
+	var attempts int32
+	client := &Client{
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			atomic.AddInt32(&attempts, 1)
+			return &http.Response{
+				StatusCode: http.StatusTooManyRequests,
+				Header:     http.Header{"Retry-After": {"5"}},
+				Body:       ioutil.NopCloser(strings.NewReader("slow down")),
+			}, nil
+		}),
+	}
+	assert.NilError(t, WithRetry(testRetryOptions)(client))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := client.ContainerList(ctx, types.ContainerListOptions{})
+	var apiErr APIError
+	assert.Assert(t, errors.As(err, &apiErr), "got %v", err)
+	assert.Check(t, is.Equal(5*time.Second, apiErr.RetryAfter))
+	assert.Check(t, time.Since(start) < 2*time.Second, "must not wait out a Retry-After the deadline cannot accommodate")
+	assert.Check(t, is.Equal(int32(1), atomic.LoadInt32(&attempts)))
+}
+
+func TestRetryAfterExposedWithoutRetry(t *testing.T) {
+	client := &Client{
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusTooManyRequests,
+				Header:     http.Header{"Retry-After": {"7"}},
+				Body:       ioutil.NopCloser(strings.NewReader("slow down")),
+			}, nil
+		}),
+	}
+
+	// a POST is never retried, but the error still carries the hint
+	_, err := client.ContainerCreate(context.Background(), &container.Config{}, &container.HostConfig{}, &network.NetworkingConfig{}, nil, "nothing")
+	var apiErr APIError
+	assert.Assert(t, errors.As(err, &apiErr), "got %v", err)
+	assert.Check(t, is.Equal(7*time.Second, apiErr.RetryAfter))
+}
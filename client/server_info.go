@@ -0,0 +1,45 @@
+package client // import "github.com/docker/docker/client"
+
+import (
+	"context"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+// ServerInfo returns what the daemon reported on the last successful ping —
+// API version, OS type, experimental mode and builder version — going to the
+// daemon only when there is no sufficiently fresh cached result. How long a
+// result stays fresh is set with WithPingCacheTTL; use InvalidatePingCache
+// to force the next call to ping. Failed pings are never cached, so an
+// unreachable daemon is reported on every call.
+func (cli *Client) ServerInfo(ctx context.Context) (types.Ping, error) {
+	cli.pingMu.Lock()
+	if cli.cachedPing != nil && cli.pingTTL > 0 && time.Since(cli.cachedPingAt) < cli.pingTTL {
+		ping := *cli.cachedPing
+		cli.pingMu.Unlock()
+		return ping, nil
+	}
+	cli.pingMu.Unlock()
+	return cli.Ping(ctx)
+}
+
+// InvalidatePingCache drops the cached ping result, so the next ServerInfo
+// call asks the daemon again. Use it after an operation that is known to
+// change what the daemon reports.
+func (cli *Client) InvalidatePingCache() {
+	cli.pingMu.Lock()
+	cli.cachedPing = nil
+	cli.pingMu.Unlock()
+}
+
+// cachePing stores a successful ping result for ServerInfo.
+func (cli *Client) cachePing(ping types.Ping) {
+	if cli.pingTTL <= 0 {
+		return
+	}
+	cli.pingMu.Lock()
+	cli.cachedPing = &ping
+	cli.cachedPingAt = time.Now()
+	cli.pingMu.Unlock()
+}
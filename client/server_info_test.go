@@ -0,0 +1,129 @@
+package client // import "github.com/docker/docker/client"
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+// pingCountingClient returns a client whose transport answers pings with the
+// given API version and appends each request's method to *methods.
+func pingCountingClient(ttl time.Duration, methods *[]string) *Client {
+	return &Client{
+		pingTTL: ttl,
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			*methods = append(*methods, req.Method)
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header: http.Header{
+					"Api-Version":         {"1.40"},
+					"Ostype":              {"linux"},
+					"Docker-Experimental": {"true"},
+				},
+				Body: ioutil.NopCloser(bytes.NewReader(nil)),
+			}, nil
+		}),
+	}
+}
+
+func TestServerInfoCachesPing(t *testing.T) {
+	var methods []string
+	client := pingCountingClient(time.Minute, &methods)
+
+	for i := 0; i < 3; i++ {
+		info, err := client.ServerInfo(context.Background())
+		assert.NilError(t, err)
+		assert.Check(t, is.Equal("1.40", info.APIVersion))
+		assert.Check(t, is.Equal("linux", info.OSType))
+		assert.Check(t, info.Experimental)
+	}
+	assert.Check(t, is.Len(methods, 1), "only the first call should ping")
+}
+
+func TestServerInfoTTLExpiry(t *testing.T) {
+	var methods []string
+	client := pingCountingClient(20*time.Millisecond, &methods)
+
+	_, err := client.ServerInfo(context.Background())
+	assert.NilError(t, err)
+	time.Sleep(30 * time.Millisecond)
+	_, err = client.ServerInfo(context.Background())
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(methods, 2), "an expired entry should be refreshed")
+}
+
+func TestServerInfoInvalidation(t *testing.T) {
+	var methods []string
+	client := pingCountingClient(time.Minute, &methods)
+
+	_, err := client.ServerInfo(context.Background())
+	assert.NilError(t, err)
+	client.InvalidatePingCache()
+	_, err = client.ServerInfo(context.Background())
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(methods, 2))
+}
+
+func TestServerInfoDoesNotCacheErrors(t *testing.T) {
+	var requests int
+	client := &Client{
+		pingTTL: time.Minute,
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			requests++
+			// fail the HEAD and its GET fallback on the first ping
+			if requests <= 2 {
+				return nil, io.EOF
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Api-Version": {"1.40"}},
+				Body:       ioutil.NopCloser(bytes.NewReader(nil)),
+			}, nil
+		}),
+	}
+
+	_, err := client.ServerInfo(context.Background())
+	assert.Assert(t, err != nil)
+	info, err := client.ServerInfo(context.Background())
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("1.40", info.APIVersion))
+}
+
+func TestPingFallsBackToGET(t *testing.T) {
+	var methods []string
+	client := &Client{
+		pingTTL: time.Minute,
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			methods = append(methods, req.Method)
+			if req.Method == http.MethodHead {
+				// daemon predates HEAD support on /_ping
+				return &http.Response{
+					StatusCode: http.StatusMethodNotAllowed,
+					Body:       ioutil.NopCloser(bytes.NewReader(nil)),
+				}, nil
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Api-Version": {"1.40"}},
+				Body:       ioutil.NopCloser(bytes.NewReader(nil)),
+			}, nil
+		}),
+	}
+
+	ping, err := client.Ping(context.Background())
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("1.40", ping.APIVersion))
+	assert.Check(t, is.DeepEqual([]string{http.MethodHead, http.MethodGet}, methods))
+
+	// the GET result must have been cached like any other
+	_, err = client.ServerInfo(context.Background())
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(methods, 2))
+}
@@ -0,0 +1,42 @@
+package client // import "github.com/docker/docker/client"
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// SocketError is returned when the local daemon socket could not be dialed
+// for a reason the user can act on themselves: the socket does not exist, or
+// its permissions do not admit the user. It wraps the underlying dial error,
+// so errors.Is against the syscall errno keeps working.
+type SocketError struct {
+	// Host is the daemon host the client is configured with, e.g.
+	// "unix:///var/run/docker.sock".
+	Host string
+	// Path is the filesystem path of the socket that was dialed.
+	Path string
+	// Cause is the underlying dial error.
+	Cause error
+}
+
+// Error returns a string representation of a SocketError
+func (e SocketError) Error() string {
+	switch {
+	case errors.Is(e.Cause, os.ErrNotExist):
+		return fmt.Sprintf("cannot connect to the Docker daemon socket at %s: %s does not exist; is the docker daemon running on this host?", e.Host, e.Path)
+	case errors.Is(e.Cause, os.ErrPermission):
+		msg := fmt.Sprintf("permission denied while trying to connect to the Docker daemon socket at %s", e.Host)
+		if desc := describeSocket(e.Path); desc != "" {
+			msg += " (" + desc + ")"
+		}
+		return msg + "; add your user to the group owning the socket, or run with elevated privileges"
+	}
+	return fmt.Sprintf("cannot connect to the Docker daemon socket at %s: %v", e.Host, e.Cause)
+}
+
+// Unwrap returns the underlying dial error.
+func (e SocketError) Unwrap() error {
+	return e.Cause
+}
@@ -0,0 +1,85 @@
+package client // import "github.com/docker/docker/client"
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/pkg/errors"
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+// socketErrClient returns a client for the given unix socket path whose
+// dials fail with the given errno, the way the net package reports it.
+func socketErrClient(t *testing.T, path string, errno syscall.Errno) *Client {
+	t.Helper()
+	client, err := NewClientWithOpts(
+		WithHost("unix://"+path),
+		WithDialContext(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return nil, &net.OpError{
+				Op:   "dial",
+				Net:  "unix",
+				Addr: &net.UnixAddr{Name: path, Net: "unix"},
+				Err:  os.NewSyscallError("connect", errno),
+			}
+		}),
+	)
+	assert.NilError(t, err)
+	return client
+}
+
+func TestSocketErrorPermissionDenied(t *testing.T) {
+	// a real socket file, so the error can describe its owner and mode
+	path := filepath.Join(t.TempDir(), "docker.sock")
+	l, err := net.Listen("unix", path)
+	assert.NilError(t, err)
+	defer l.Close()
+
+	client := socketErrClient(t, path, syscall.EACCES)
+	_, err = client.Ping(context.Background())
+
+	var sockErr SocketError
+	assert.Assert(t, errors.As(err, &sockErr), "got %[1]T: %[1]v", err)
+	assert.Check(t, is.Equal("unix://"+path, sockErr.Host))
+	assert.ErrorContains(t, err, "permission denied")
+	assert.ErrorContains(t, err, path)
+	assert.ErrorContains(t, err, "mode")
+	assert.ErrorContains(t, err, "elevated privileges")
+	assert.Check(t, errors.Is(err, syscall.EACCES), "must unwrap to the errno")
+	assert.Check(t, errors.Is(err, os.ErrPermission))
+}
+
+func TestSocketErrorNotExist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "docker.sock")
+	client := socketErrClient(t, path, syscall.ENOENT)
+	_, err := client.Ping(context.Background())
+
+	var sockErr SocketError
+	assert.Assert(t, errors.As(err, &sockErr), "got %[1]T: %[1]v", err)
+	assert.ErrorContains(t, err, path)
+	assert.ErrorContains(t, err, "is the docker daemon running")
+	assert.Check(t, errors.Is(err, syscall.ENOENT), "must unwrap to the errno")
+	assert.Check(t, errors.Is(err, os.ErrNotExist))
+}
+
+func TestSocketErrorOnlyForLocalSockets(t *testing.T) {
+	client, err := NewClientWithOpts(
+		WithHost("tcp://127.0.0.1:2375"),
+		WithDialContext(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return nil, &net.OpError{
+				Op:  "dial",
+				Net: "tcp",
+				Err: os.NewSyscallError("connect", syscall.EACCES),
+			}
+		}),
+	)
+	assert.NilError(t, err)
+
+	_, err = client.Ping(context.Background())
+	var sockErr SocketError
+	assert.Check(t, !errors.As(err, &sockErr), "tcp endpoints must not produce a SocketError")
+}
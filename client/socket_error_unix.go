@@ -0,0 +1,35 @@
+// +build !windows
+
+package client // import "github.com/docker/docker/client"
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// describeSocket stats the socket and reports its owner, group and mode, so
+// a permission-denied error tells the user what to compare their own
+// identity against. It returns an empty string when the socket cannot be
+// stat'd.
+func describeSocket(path string) string {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	desc := "mode " + fi.Mode().String()
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		owner := strconv.Itoa(int(st.Uid))
+		if u, err := user.LookupId(owner); err == nil {
+			owner = u.Username
+		}
+		group := strconv.Itoa(int(st.Gid))
+		if g, err := user.LookupGroupId(group); err == nil {
+			group = g.Name
+		}
+		desc = fmt.Sprintf("owned by %s:%s, %s", owner, group, desc)
+	}
+	return desc
+}
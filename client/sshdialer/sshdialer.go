@@ -0,0 +1,205 @@
+// Package sshdialer reaches a daemon's unix socket through an SSH
+// connection, without shelling out to an ssh binary. It produces a dial
+// function the client uses for every request, including hijacked attach
+// streams. The package is separate from client so that consumers who do not
+// need it do not pull in golang.org/x/crypto.
+//
+// Typical use:
+//
+//	dialer, err := sshdialer.New(sshdialer.Config{
+//		Host:            "bastion.example.com:22",
+//		User:            "core",
+//		KeyFile:         "/home/me/.ssh/id_rsa",
+//		HostKeyCallback: hostKeys,
+//	})
+//	...
+//	cli, err := client.NewClientWithOpts(dialer.ClientOpts()...)
+package sshdialer // import "github.com/docker/docker/client/sshdialer"
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	defaultRemoteSocket = "/var/run/docker.sock"
+	defaultSSHPort      = "22"
+	defaultTimeout      = 30 * time.Second
+)
+
+// Config describes how to reach the daemon.
+type Config struct {
+	// Host is the SSH server to go through, as "host" or "host:port";
+	// port 22 is assumed when none is given.
+	Host string
+	// User is the user to authenticate as.
+	User string
+	// KeyFile is the path of a private key to authenticate with, in any
+	// format ssh.ParsePrivateKey accepts. Optional when AuthMethods are
+	// supplied.
+	KeyFile string
+	// AuthMethods are additional ways to authenticate, such as
+	// ssh.PublicKeysCallback backed by an SSH agent.
+	AuthMethods []ssh.AuthMethod
+	// HostKeyCallback verifies the SSH server's host key and is required:
+	// use ssh.FixedHostKey, a known_hosts implementation, or
+	// ssh.InsecureIgnoreHostKey to explicitly opt out of verification.
+	HostKeyCallback ssh.HostKeyCallback
+	// RemoteSocket is the daemon socket path on the remote host. It
+	// defaults to /var/run/docker.sock.
+	RemoteSocket string
+	// Timeout bounds the TCP dial and SSH handshake. It defaults to 30s.
+	Timeout time.Duration
+}
+
+// Dialer opens connections to the remote daemon socket over a shared SSH
+// connection, which is established on first use and re-established for new
+// requests when it drops. It is safe for concurrent use.
+type Dialer struct {
+	addr         string
+	remoteSocket string
+	timeout      time.Duration
+	clientConfig *ssh.ClientConfig
+
+	mu     sync.Mutex
+	client *ssh.Client
+}
+
+// New validates the configuration and returns a Dialer. No connection is
+// made until the first request.
+func New(config Config) (*Dialer, error) {
+	if config.Host == "" {
+		return nil, errors.New("ssh host is required")
+	}
+	if config.HostKeyCallback == nil {
+		return nil, errors.New("a host key callback is required; use ssh.InsecureIgnoreHostKey to explicitly disable host key verification")
+	}
+
+	auth := append([]ssh.AuthMethod(nil), config.AuthMethods...)
+	if config.KeyFile != "" {
+		key, err := ioutil.ReadFile(config.KeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read ssh key file")
+		}
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to parse ssh key file")
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if len(auth) == 0 {
+		return nil, errors.New("no ssh authentication configured")
+	}
+
+	addr := config.Host
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, defaultSSHPort)
+	}
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	remoteSocket := config.RemoteSocket
+	if remoteSocket == "" {
+		remoteSocket = defaultRemoteSocket
+	}
+
+	return &Dialer{
+		addr:         addr,
+		remoteSocket: remoteSocket,
+		timeout:      timeout,
+		clientConfig: &ssh.ClientConfig{
+			User:            config.User,
+			Auth:            auth,
+			HostKeyCallback: config.HostKeyCallback,
+			Timeout:         timeout,
+		},
+	}, nil
+}
+
+// ClientOpts returns the client options wiring a client to the dialer.
+func (d *Dialer) ClientOpts() []client.Opt {
+	return []client.Opt{
+		// the host only needs to be well-formed; every connection goes
+		// through DialContext
+		client.WithHost("tcp://docker"),
+		client.WithDialContext(d.DialContext),
+	}
+}
+
+// DialContext connects to the remote daemon socket, for use with
+// client.WithDialContext. The network and addr arguments are ignored; they
+// describe the placeholder host the client was configured with.
+func (d *Dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	sshClient, fresh, err := d.sshClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := sshClient.Dial("unix", d.remoteSocket)
+	if err == nil || fresh {
+		return conn, errors.Wrapf(err, "unable to reach %s via ssh host %s", d.remoteSocket, d.addr)
+	}
+
+	// the cached SSH connection has likely died since it was established;
+	// re-establish it and retry once
+	d.invalidate(sshClient)
+	sshClient, _, err = d.sshClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conn, err = sshClient.Dial("unix", d.remoteSocket)
+	return conn, errors.Wrapf(err, "unable to reach %s via ssh host %s", d.remoteSocket, d.addr)
+}
+
+// Close tears down the SSH connection. The dialer remains usable; the next
+// request reconnects.
+func (d *Dialer) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.client == nil {
+		return nil
+	}
+	err := d.client.Close()
+	d.client = nil
+	return err
+}
+
+// sshClient returns the shared SSH connection, establishing it when there
+// is none. fresh reports whether this call established it.
+func (d *Dialer) sshClient(ctx context.Context) (sshClient *ssh.Client, fresh bool, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.client != nil {
+		return d.client, false, nil
+	}
+
+	netConn, err := (&net.Dialer{Timeout: d.timeout}).DialContext(ctx, "tcp", d.addr)
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "unable to connect to ssh host %s", d.addr)
+	}
+	conn, chans, reqs, err := ssh.NewClientConn(netConn, d.addr, d.clientConfig)
+	if err != nil {
+		netConn.Close()
+		return nil, false, errors.Wrapf(err, "ssh handshake with %s failed", d.addr)
+	}
+	d.client = ssh.NewClient(conn, chans, reqs)
+	return d.client, true, nil
+}
+
+// invalidate drops the cached SSH connection, unless another request
+// already replaced it.
+func (d *Dialer) invalidate(old *ssh.Client) {
+	d.mu.Lock()
+	if d.client == old {
+		d.client = nil
+	}
+	d.mu.Unlock()
+	old.Close()
+}
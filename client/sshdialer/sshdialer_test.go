@@ -0,0 +1,228 @@
+package sshdialer // import "github.com/docker/docker/client/sshdialer"
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/docker/docker/client"
+	"golang.org/x/crypto/ssh"
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+// testSSHServer is an in-process SSH server that accepts the test client
+// key and answers direct-streamlocal channels (what ssh.Client.Dial("unix")
+// opens) with a mock daemon speaking just enough HTTP for Ping.
+type testSSHServer struct {
+	addr       string
+	hostSigner ssh.Signer
+
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+func newTestSSHServer(t *testing.T, clientKey ssh.PublicKey) *testSSHServer {
+	t.Helper()
+
+	hostKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NilError(t, err)
+	hostSigner, err := ssh.NewSignerFromKey(hostKey)
+	assert.NilError(t, err)
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if !bytes.Equal(key.Marshal(), clientKey.Marshal()) {
+				return nil, errUnknownKey
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(hostSigner)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	server := &testSSHServer{addr: listener.Addr().String(), hostSigner: hostSigner}
+	go func() {
+		for {
+			netConn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			server.mu.Lock()
+			server.conns = append(server.conns, netConn)
+			server.mu.Unlock()
+			go server.handle(netConn, config)
+		}
+	}()
+	return server
+}
+
+var errUnknownKey = errorString("unknown public key")
+
+type errorString string
+
+func (e errorString) Error() string { return string(e) }
+
+func (s *testSSHServer) handle(netConn net.Conn, config *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(netConn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "direct-streamlocal@openssh.com" {
+			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		channel, channelReqs, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go ssh.DiscardRequests(channelReqs)
+		go serveMockDaemon(channel)
+	}
+}
+
+// connCount reports how many SSH connections the server has accepted.
+func (s *testSSHServer) connCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.conns)
+}
+
+// closeConn severs the nth accepted connection at the TCP level, as a
+// dropped session would.
+func (s *testSSHServer) closeConn(n int) {
+	s.mu.Lock()
+	conn := s.conns[n]
+	s.mu.Unlock()
+	conn.Close()
+}
+
+// serveMockDaemon answers any request on the channel the way the daemon
+// answers a ping.
+func serveMockDaemon(channel ssh.Channel) {
+	defer channel.Close()
+	br := bufio.NewReader(channel)
+	for {
+		req, err := http.ReadRequest(br)
+		if err != nil {
+			return
+		}
+		req.Body.Close()
+		resp := "HTTP/1.1 200 OK\r\nApi-Version: 1.40\r\nContent-Length: 0\r\n\r\n"
+		if _, err := channel.Write([]byte(resp)); err != nil {
+			return
+		}
+	}
+}
+
+func writeTestKeyFile(t *testing.T) (string, ssh.PublicKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NilError(t, err)
+
+	dir, err := ioutil.TempDir("", "sshdialer-test")
+	assert.NilError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	keyFile := filepath.Join(dir, "id_rsa")
+	pemBytes := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	})
+	assert.NilError(t, ioutil.WriteFile(keyFile, pemBytes, 0600))
+
+	signer, err := ssh.NewSignerFromKey(key)
+	assert.NilError(t, err)
+	return keyFile, signer.PublicKey()
+}
+
+func TestDialerPing(t *testing.T) {
+	keyFile, clientKey := writeTestKeyFile(t)
+	server := newTestSSHServer(t, clientKey)
+
+	dialer, err := New(Config{
+		Host:            server.addr,
+		User:            "test",
+		KeyFile:         keyFile,
+		HostKeyCallback: ssh.FixedHostKey(server.hostSigner.PublicKey()),
+	})
+	assert.NilError(t, err)
+	defer dialer.Close()
+
+	cli, err := client.NewClientWithOpts(dialer.ClientOpts()...)
+	assert.NilError(t, err)
+	defer cli.Close()
+
+	ping, err := cli.Ping(context.Background())
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("1.40", ping.APIVersion))
+}
+
+func TestDialerReconnects(t *testing.T) {
+	keyFile, clientKey := writeTestKeyFile(t)
+	server := newTestSSHServer(t, clientKey)
+
+	dialer, err := New(Config{
+		Host:            server.addr,
+		User:            "test",
+		KeyFile:         keyFile,
+		HostKeyCallback: ssh.FixedHostKey(server.hostSigner.PublicKey()),
+	})
+	assert.NilError(t, err)
+	defer dialer.Close()
+
+	cli, err := client.NewClientWithOpts(dialer.ClientOpts()...)
+	assert.NilError(t, err)
+	defer cli.Close()
+
+	_, err = cli.Ping(context.Background())
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(1, server.connCount()))
+
+	// sever the SSH session under the client; the next request must
+	// transparently re-establish it
+	server.closeConn(0)
+	cli.Close() // drop pooled HTTP connections riding the dead session
+
+	cli, err = client.NewClientWithOpts(dialer.ClientOpts()...)
+	assert.NilError(t, err)
+	defer cli.Close()
+
+	_, err = cli.Ping(context.Background())
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(2, server.connCount()))
+}
+
+func TestNewValidation(t *testing.T) {
+	keyFile, _ := writeTestKeyFile(t)
+
+	_, err := New(Config{User: "test", KeyFile: keyFile, HostKeyCallback: ssh.InsecureIgnoreHostKey()})
+	assert.ErrorContains(t, err, "ssh host is required")
+
+	_, err = New(Config{Host: "example.com", User: "test", KeyFile: keyFile})
+	assert.ErrorContains(t, err, "host key callback is required")
+
+	_, err = New(Config{Host: "example.com", User: "test", HostKeyCallback: ssh.InsecureIgnoreHostKey()})
+	assert.ErrorContains(t, err, "no ssh authentication configured")
+
+	d, err := New(Config{Host: "example.com", User: "test", KeyFile: keyFile, HostKeyCallback: ssh.InsecureIgnoreHostKey()})
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("example.com:22", d.addr))
+	assert.Check(t, is.Equal(defaultRemoteSocket, d.remoteSocket))
+}
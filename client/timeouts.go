@@ -0,0 +1,148 @@
+package client // import "github.com/docker/docker/client"
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// timeoutClass buckets API operations by how long they may legitimately run.
+type timeoutClass int
+
+const (
+	// timeoutClassControl covers short-lived control operations: inspect,
+	// list, create, remove and the like.
+	timeoutClassControl timeoutClass = iota
+	// timeoutClassBuild covers builds and commits, which are slow but do
+	// finish.
+	timeoutClassBuild
+	// timeoutClassStreaming covers open-ended operations — pulls, pushes,
+	// followed logs, events, waits — which must never be cut off by a
+	// wall-clock deadline.
+	timeoutClassStreaming
+)
+
+// TimeoutConfig holds per-class deadlines for WithTimeouts. A single
+// http.Client timeout cannot serve both a 50ms inspect and a multi-hour
+// pull; these apply per operation class instead, and only when the caller's
+// context carries no deadline of its own.
+type TimeoutConfig struct {
+	// Control bounds short-lived control operations. Zero leaves them
+	// unbounded.
+	Control time.Duration
+	// Build bounds builds and commits. Zero leaves them unbounded.
+	Build time.Duration
+	// StreamingIdle bounds how long a streaming response may go without
+	// delivering any data before the stream is aborted. Zero disables the
+	// idle check. Streaming operations never get a wall-clock deadline.
+	StreamingIdle time.Duration
+}
+
+// WithTimeouts applies per-operation-class timeouts to the client, as an
+// alternative to WithTimeout's single bound. A deadline on the per-call
+// context always takes precedence.
+func WithTimeouts(config TimeoutConfig) Opt {
+	return func(c *Client) error {
+		c.timeouts = &config
+		return nil
+	}
+}
+
+// classifyRequest buckets a request into a timeout class by its route.
+func classifyRequest(path string) timeoutClass {
+	switch {
+	case strings.HasPrefix(path, "/build"),
+		strings.HasSuffix(path, "/commit"):
+		return timeoutClassBuild
+	case strings.HasSuffix(path, "/events"),
+		strings.HasSuffix(path, "/images/create"),
+		strings.HasSuffix(path, "/push"),
+		strings.HasSuffix(path, "/logs"),
+		strings.HasSuffix(path, "/stats"),
+		strings.HasSuffix(path, "/wait"),
+		strings.HasSuffix(path, "/attach"),
+		strings.HasPrefix(path, "/session"):
+		return timeoutClassStreaming
+	default:
+		return timeoutClassControl
+	}
+}
+
+// requestDeadline returns the wall-clock bound to apply to a request, or
+// zero when none applies: the operation is streaming, the class has no bound
+// configured, or the caller's context already carries a deadline.
+func (cli *Client) requestDeadline(ctx context.Context, class timeoutClass) time.Duration {
+	if cli.timeouts == nil {
+		return 0
+	}
+	if _, ok := ctx.Deadline(); ok {
+		return 0
+	}
+	switch class {
+	case timeoutClassControl:
+		return cli.timeouts.Control
+	case timeoutClassBuild:
+		return cli.timeouts.Build
+	default:
+		return 0
+	}
+}
+
+// cancelOnCloseBody releases a request's deadline context once the caller is
+// done with the body, so the deadline spans the body read as well.
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b cancelOnCloseBody) Close() error {
+	b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// idleTimeoutBody aborts a streaming response body when no data arrives for
+// the configured duration. The underlying body is closed from the timer, so
+// a blocked Read returns promptly.
+type idleTimeoutBody struct {
+	rc    io.ReadCloser
+	d     time.Duration
+	timer *time.Timer
+
+	mu       sync.Mutex
+	timedOut bool
+}
+
+func newIdleTimeoutBody(rc io.ReadCloser, d time.Duration) *idleTimeoutBody {
+	b := &idleTimeoutBody{rc: rc, d: d}
+	b.timer = time.AfterFunc(d, func() {
+		b.mu.Lock()
+		b.timedOut = true
+		b.mu.Unlock()
+		rc.Close()
+	})
+	return b
+}
+
+func (b *idleTimeoutBody) Read(p []byte) (int, error) {
+	n, err := b.rc.Read(p)
+	if err != nil {
+		b.mu.Lock()
+		timedOut := b.timedOut
+		b.mu.Unlock()
+		if timedOut {
+			return n, errors.Errorf("stream aborted: no data received in %v", b.d)
+		}
+		return n, err
+	}
+	b.timer.Reset(b.d)
+	return n, nil
+}
+
+func (b *idleTimeoutBody) Close() error {
+	b.timer.Stop()
+	return b.rc.Close()
+}
@@ -0,0 +1,120 @@
+package client // import "github.com/docker/docker/client"
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/pkg/errors"
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+func TestTimeoutsControlOperation(t *testing.T) {
+	client := &Client{
+		timeouts: &TimeoutConfig{Control: 20 * time.Millisecond},
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			if _, ok := req.Context().Deadline(); !ok {
+				t.Error("a control operation should carry a deadline")
+			}
+			// a hung daemon: wait for the deadline to fire
+			<-req.Context().Done()
+			return nil, req.Context().Err()
+		}),
+	}
+
+	start := time.Now()
+	_, err := client.ContainerList(context.Background(), types.ContainerListOptions{})
+	assert.Assert(t, errors.Is(err, context.DeadlineExceeded), "got %v", err)
+	assert.Check(t, time.Since(start) < 5*time.Second, "the configured deadline was not applied")
+}
+
+func TestTimeoutsStreamingExempt(t *testing.T) {
+	client := &Client{
+		timeouts: &TimeoutConfig{Control: 20 * time.Millisecond},
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			if _, ok := req.Context().Deadline(); ok {
+				t.Error("a streaming operation must not get a wall-clock deadline")
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte("{}"))),
+			}, nil
+		}),
+	}
+
+	// same client, streaming endpoint: must not inherit the control bound
+	body, err := client.Events(context.Background(), types.EventsOptions{})
+	select {
+	case e := <-err:
+		t.Fatalf("unexpected error: %v", e)
+	case <-body:
+	}
+}
+
+func TestTimeoutsCallerContextWins(t *testing.T) {
+	deadline := time.Now().Add(time.Hour)
+	client := &Client{
+		timeouts: &TimeoutConfig{Control: 20 * time.Millisecond},
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			if d, ok := req.Context().Deadline(); !ok || !d.Equal(deadline) {
+				t.Errorf("the caller's deadline should win, got %v", d)
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte("[]"))),
+			}, nil
+		}),
+	}
+
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	_, err := client.ContainerList(ctx, types.ContainerListOptions{})
+	assert.NilError(t, err)
+}
+
+func TestTimeoutsStreamingIdle(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	client := &Client{
+		timeouts: &TimeoutConfig{StreamingIdle: 20 * time.Millisecond},
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       pr,
+			}, nil
+		}),
+	}
+
+	resp, err := client.sendRequest(context.Background(), http.MethodGet, "/events", nil, nil, nil)
+	assert.NilError(t, err)
+	defer resp.body.Close()
+
+	_, err = resp.body.Read(make([]byte, 1))
+	assert.ErrorContains(t, err, "no data received in")
+}
+
+func TestClassifyRequest(t *testing.T) {
+	for path, expected := range map[string]timeoutClass{
+		"/containers/json":          timeoutClassControl,
+		"/containers/abc123/json":   timeoutClassControl,
+		"/volumes/create":           timeoutClassControl,
+		"/build":                    timeoutClassBuild,
+		"/containers/abc123/commit": timeoutClassBuild,
+		"/events":                   timeoutClassStreaming,
+		"/images/create":            timeoutClassStreaming,
+		"/images/busybox/push":      timeoutClassStreaming,
+		"/containers/abc123/logs":   timeoutClassStreaming,
+		"/containers/abc123/stats":  timeoutClassStreaming,
+		"/containers/abc123/wait":   timeoutClassStreaming,
+		"/containers/abc123/attach": timeoutClassStreaming,
+		"/session":                  timeoutClassStreaming,
+	} {
+		assert.Check(t, is.Equal(expected, classifyRequest(path)), "path %q", path)
+	}
+}
@@ -0,0 +1,166 @@
+package client // import "github.com/docker/docker/client"
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// traceBodyCap bounds how much of a response body is copied into
+// TraceResponse.Body.
+const traceBodyCap = 8 * 1024 // 8KiB
+
+// redactedHeaders are request headers whose values carry credentials and are
+// replaced with a placeholder before reaching trace hooks.
+var redactedHeaders = []string{"Authorization", "X-Registry-Auth", "X-Registry-Config"}
+
+// TraceRequest describes a request about to be sent. All fields are copies;
+// mutating them has no effect on the request.
+type TraceRequest struct {
+	// Method is the HTTP method.
+	Method string
+	// Path is the request path, including the negotiated version prefix.
+	Path string
+	// APIVersion is the API version the client is using, if any.
+	APIVersion string
+	// Headers is a sanitized copy of the request headers: values of
+	// credential-carrying headers are redacted.
+	Headers http.Header
+}
+
+// TraceResponse describes a received response.
+type TraceResponse struct {
+	// StatusCode is the HTTP status code.
+	StatusCode int
+	// Headers is a copy of the response headers.
+	Headers http.Header
+	// Body holds up to 8KiB of the response body for JSON responses. It is
+	// nil for streaming responses, which cannot be read ahead of the caller.
+	Body []byte
+}
+
+// TraceHooks is a set of callbacks invoked around each API request the
+// client sends. Either hook may be nil. Hooks observe copies of the wire
+// data and cannot influence the request.
+type TraceHooks struct {
+	// Request is called before the request is sent.
+	Request func(TraceRequest)
+	// Response is called when a response is received. It is not called when
+	// the request fails below the HTTP layer.
+	Response func(TraceResponse)
+}
+
+// WithTraceHooks makes the client invoke the given hooks around every
+// request, for debugging the wire traffic without a proxy in between.
+func WithTraceHooks(hooks TraceHooks) Opt {
+	return func(c *Client) error {
+		c.trace = &hooks
+		return nil
+	}
+}
+
+// sanitizeHeaders returns a copy of h with credential values redacted.
+func sanitizeHeaders(h http.Header) http.Header {
+	out := make(http.Header, len(h))
+	for k, v := range h {
+		out[k] = append([]string(nil), v...)
+	}
+	for _, k := range redactedHeaders {
+		if out.Get(k) != "" {
+			out.Set(k, "*****")
+		}
+	}
+	return out
+}
+
+// traceRequest reports a request about to be sent to the trace hooks.
+func (cli *Client) traceRequest(req *http.Request) {
+	if cli.trace == nil || cli.trace.Request == nil {
+		return
+	}
+	cli.trace.Request(TraceRequest{
+		Method:     req.Method,
+		Path:       req.URL.Path,
+		APIVersion: cli.version,
+		Headers:    sanitizeHeaders(req.Header),
+	})
+}
+
+// traceResponse reports a received response to the trace hooks, returning a
+// body reader equivalent to the one passed in. For JSON responses a capped
+// prefix of the body is copied into the trace; streaming responses are left
+// untouched, as reading ahead of the caller would stall them.
+func (cli *Client) traceResponse(resp *http.Response) io.ReadCloser {
+	if cli.trace == nil || cli.trace.Response == nil {
+		return resp.Body
+	}
+	tr := TraceResponse{
+		StatusCode: resp.StatusCode,
+		Headers:    sanitizeHeaders(resp.Header),
+	}
+	body := resp.Body
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") && body != nil {
+		buf := make([]byte, traceBodyCap)
+		n, _ := io.ReadFull(body, buf)
+		tr.Body = buf[:n]
+		body = &tracedBody{
+			Reader: io.MultiReader(bytes.NewReader(buf[:n]), body),
+			Closer: body,
+		}
+	}
+	cli.trace.Response(tr)
+	return body
+}
+
+// tracedBody stitches the traced prefix of a body back in front of the rest
+// of it.
+type tracedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// CurlTraceHooks returns hooks that write a curl-style transcript of each
+// request and response to w. Lines for the request are prefixed with "> ",
+// those for the response with "< ". Writes are serialized, but transcripts
+// of concurrent requests may interleave.
+func CurlTraceHooks(w io.Writer) TraceHooks {
+	var mu sync.Mutex
+	return TraceHooks{
+		Request: func(tr TraceRequest) {
+			mu.Lock()
+			defer mu.Unlock()
+			fmt.Fprintf(w, "> %s %s\n", tr.Method, tr.Path)
+			for _, k := range sortedHeaderKeys(tr.Headers) {
+				for _, v := range tr.Headers[k] {
+					fmt.Fprintf(w, "> %s: %s\n", k, v)
+				}
+			}
+		},
+		Response: func(tr TraceResponse) {
+			mu.Lock()
+			defer mu.Unlock()
+			fmt.Fprintf(w, "< %d %s\n", tr.StatusCode, http.StatusText(tr.StatusCode))
+			for _, k := range sortedHeaderKeys(tr.Headers) {
+				for _, v := range tr.Headers[k] {
+					fmt.Fprintf(w, "< %s: %s\n", k, v)
+				}
+			}
+			if len(tr.Body) > 0 {
+				fmt.Fprintf(w, "< %s\n", bytes.TrimRight(tr.Body, "\n"))
+			}
+		},
+	}
+}
+
+func sortedHeaderKeys(h http.Header) []string {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
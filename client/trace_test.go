@@ -0,0 +1,141 @@
+package client // import "github.com/docker/docker/client"
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+func TestTraceHooksOrderAndFields(t *testing.T) {
+	client := &Client{
+		version: "1.40",
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			header := http.Header{}
+			header.Set("Content-Type", "application/json")
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     header,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte("[]"))),
+			}, nil
+		}),
+		customHTTPHeaders: map[string]string{"Authorization": "Bearer secret"},
+	}
+
+	var calls []string
+	var gotReq TraceRequest
+	var gotResp TraceResponse
+	err := WithTraceHooks(TraceHooks{
+		Request: func(tr TraceRequest) {
+			calls = append(calls, "request")
+			gotReq = tr
+		},
+		Response: func(tr TraceResponse) {
+			calls = append(calls, "response")
+			gotResp = tr
+		},
+	})(client)
+	assert.NilError(t, err)
+
+	containers, err := client.ContainerList(context.Background(), types.ContainerListOptions{})
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(containers, 0), "the traced body must still reach the caller")
+
+	assert.Check(t, is.DeepEqual([]string{"request", "response"}, calls))
+	assert.Check(t, is.Equal(http.MethodGet, gotReq.Method))
+	assert.Check(t, is.Equal("/v1.40/containers/json", gotReq.Path))
+	assert.Check(t, is.Equal("1.40", gotReq.APIVersion))
+	assert.Check(t, is.Equal("*****", gotReq.Headers.Get("Authorization")), "credentials must be redacted")
+	assert.Check(t, is.Equal(http.StatusOK, gotResp.StatusCode))
+	assert.Check(t, is.Equal("[]", string(gotResp.Body)))
+}
+
+func TestTraceHooksBodyCap(t *testing.T) {
+	big := bytes.Repeat([]byte("x"), traceBodyCap+100)
+	client := &Client{
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			header := http.Header{}
+			header.Set("Content-Type", "application/json")
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     header,
+				Body:       ioutil.NopCloser(bytes.NewReader(big)),
+			}, nil
+		}),
+	}
+
+	var gotResp TraceResponse
+	err := WithTraceHooks(TraceHooks{
+		Response: func(tr TraceResponse) { gotResp = tr },
+	})(client)
+	assert.NilError(t, err)
+
+	resp, err := client.sendRequest(context.Background(), http.MethodGet, "/info", nil, nil, nil)
+	assert.NilError(t, err)
+	body, err := ioutil.ReadAll(resp.body)
+	assert.NilError(t, err)
+	assert.NilError(t, resp.body.Close())
+
+	assert.Check(t, is.Len(gotResp.Body, traceBodyCap), "the traced body copy must be capped")
+	assert.Check(t, is.DeepEqual(big, body), "the caller must see the full body")
+}
+
+func TestTraceHooksStreamingBodyUntouched(t *testing.T) {
+	client := &Client{
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			header := http.Header{}
+			header.Set("Content-Type", "application/vnd.docker.raw-stream")
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     header,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte("stream"))),
+			}, nil
+		}),
+	}
+
+	var gotResp TraceResponse
+	err := WithTraceHooks(TraceHooks{
+		Response: func(tr TraceResponse) { gotResp = tr },
+	})(client)
+	assert.NilError(t, err)
+
+	resp, err := client.sendRequest(context.Background(), http.MethodGet, "/info", nil, nil, nil)
+	assert.NilError(t, err)
+	body, err := ioutil.ReadAll(resp.body)
+	assert.NilError(t, err)
+	assert.Check(t, is.Nil(gotResp.Body), "streaming bodies must not be copied into the trace")
+	assert.Check(t, is.Equal("stream", string(body)))
+}
+
+func TestCurlTraceHooks(t *testing.T) {
+	client := &Client{
+		version: "1.40",
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			header := http.Header{}
+			header.Set("Content-Type", "application/json")
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     header,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte("[]"))),
+			}, nil
+		}),
+	}
+
+	var transcript bytes.Buffer
+	assert.NilError(t, WithTraceHooks(CurlTraceHooks(&transcript))(client))
+
+	_, err := client.ContainerList(context.Background(), types.ContainerListOptions{})
+	assert.NilError(t, err)
+
+	out := transcript.String()
+	assert.Check(t, is.Contains(out, "> GET /v1.40/containers/json\n"))
+	assert.Check(t, is.Contains(out, "< 200 OK\n"))
+	assert.Check(t, is.Contains(out, "< Content-Type: application/json\n"))
+	assert.Check(t, strings.Contains(out, "< []\n"), "the body must appear in the transcript:\n%s", out)
+}
@@ -0,0 +1,53 @@
+package client // import "github.com/docker/docker/client"
+
+import (
+	"runtime"
+
+	"github.com/docker/docker/api"
+	"github.com/pkg/errors"
+)
+
+// defaultUserAgent is how the client identifies itself when the caller did
+// not take over the User-Agent with WithUserAgent. WithUserAgentSuffix
+// appends to it rather than replacing it.
+var defaultUserAgent = "docker-client/" + api.DefaultVersion + " (" + runtime.GOOS + ")"
+
+// WithUserAgent takes full control of the User-Agent header, replacing the
+// library's own identification. Most embedders should prefer
+// WithUserAgentSuffix, which keeps the library identified; combining the two
+// options is a construction-time error.
+func WithUserAgent(ua string) Opt {
+	return func(c *Client) error {
+		if c.userAgentSuffix != "" {
+			return errors.New("conflicting options: WithUserAgent and WithUserAgentSuffix cannot both be used")
+		}
+		c.userAgent = &ua
+		return nil
+	}
+}
+
+// WithUserAgentSuffix appends the given string to the library's default
+// User-Agent, so embedding tools can identify themselves without hiding the
+// client library underneath. Combining it with WithUserAgent is a
+// construction-time error.
+func WithUserAgentSuffix(suffix string) Opt {
+	return func(c *Client) error {
+		if c.userAgent != nil {
+			return errors.New("conflicting options: WithUserAgent and WithUserAgentSuffix cannot both be used")
+		}
+		c.userAgentSuffix = suffix
+		return nil
+	}
+}
+
+// resolveUserAgent composes the User-Agent the client sends on every
+// request, including hijacked ones.
+func (cli *Client) resolveUserAgent() string {
+	if cli.userAgent != nil {
+		return *cli.userAgent
+	}
+	if cli.userAgentSuffix != "" {
+		return defaultUserAgent + " " + cli.userAgentSuffix
+	}
+	return defaultUserAgent
+}
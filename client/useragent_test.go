@@ -0,0 +1,101 @@
+package client // import "github.com/docker/docker/client"
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/docker/docker/api/server/httputils"
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+// userAgentCapturingClient returns a client whose requests never leave the
+// process, and a pointer that ends up holding the sent User-Agent.
+func userAgentCapturingClient(t *testing.T, opts ...Opt) (*Client, *string) {
+	t.Helper()
+	var gotUA string
+	opts = append(opts, WithHTTPClient(newMockClient(func(req *http.Request) (*http.Response, error) {
+		gotUA = req.Header.Get("User-Agent")
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(bytes.NewReader(nil)),
+		}, nil
+	})))
+	client, err := NewClientWithOpts(opts...)
+	assert.NilError(t, err)
+	return client, &gotUA
+}
+
+func TestUserAgentDefault(t *testing.T) {
+	client, gotUA := userAgentCapturingClient(t)
+	_, err := client.sendRequest(context.Background(), http.MethodGet, "/info", nil, nil, nil)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(defaultUserAgent, *gotUA))
+}
+
+func TestUserAgentSuffix(t *testing.T) {
+	client, gotUA := userAgentCapturingClient(t, WithUserAgentSuffix("myciagent/2.3"))
+	_, err := client.sendRequest(context.Background(), http.MethodGet, "/info", nil, nil, nil)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(defaultUserAgent+" myciagent/2.3", *gotUA))
+}
+
+func TestUserAgentFullOverride(t *testing.T) {
+	client, gotUA := userAgentCapturingClient(t, WithUserAgent("custom/1.0"))
+	_, err := client.sendRequest(context.Background(), http.MethodGet, "/info", nil, nil, nil)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("custom/1.0", *gotUA))
+}
+
+func TestUserAgentWinsOverConfiguredHeaders(t *testing.T) {
+	client, gotUA := userAgentCapturingClient(t,
+		WithHTTPHeaders(map[string]string{"User-Agent": "from-config/1"}),
+		WithUserAgentSuffix("myciagent/2.3"),
+	)
+	_, err := client.sendRequest(context.Background(), http.MethodGet, "/info", nil, nil, nil)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(defaultUserAgent+" myciagent/2.3", *gotUA))
+}
+
+func TestUserAgentConflictingOptions(t *testing.T) {
+	_, err := NewClientWithOpts(WithUserAgent("custom/1.0"), WithUserAgentSuffix("myciagent/2.3"))
+	assert.ErrorContains(t, err, "conflicting options")
+
+	_, err = NewClientWithOpts(WithUserAgentSuffix("myciagent/2.3"), WithUserAgent("custom/1.0"))
+	assert.ErrorContains(t, err, "conflicting options")
+}
+
+func TestUserAgentHijacked(t *testing.T) {
+	gotUA := make(chan string, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		gotUA <- req.Header.Get("User-Agent")
+		r, rw, err := httputils.HijackConnection(w)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprint(rw, "HTTP/1.1 101 UPGRADED\r\nContent-Type: application/vnd.docker.raw-stream\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\n")
+		r.Close()
+	}))
+	defer ts.Close()
+
+	serverURL, err := url.Parse(ts.URL)
+	assert.NilError(t, err)
+	client, err := NewClientWithOpts(
+		WithHost("tcp://"+serverURL.Host),
+		WithUserAgentSuffix("myciagent/2.3"),
+	)
+	assert.NilError(t, err)
+
+	resp, err := client.postHijacked(context.Background(), "/containers/id/attach", url.Values{}, nil, nil)
+	assert.NilError(t, err)
+	defer resp.Close()
+
+	assert.Check(t, is.Equal(defaultUserAgent+" myciagent/2.3", <-gotUA))
+}
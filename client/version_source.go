@@ -0,0 +1,78 @@
+package client // import "github.com/docker/docker/client"
+
+import (
+	"os"
+
+	"github.com/sirupsen/logrus"
+)
+
+// VersionSource identifies where the client's API version came from, so
+// callers can tell a deliberate pin from a negotiated or defaulted version.
+type VersionSource string
+
+const (
+	// VersionSourceDefault means the version is the library default
+	// (api.DefaultVersion); no other source provided one.
+	VersionSourceDefault VersionSource = "default"
+	// VersionSourceNegotiation means the version was negotiated with the
+	// daemon.
+	VersionSourceNegotiation VersionSource = "negotiation"
+	// VersionSourceEnv means the version came from the DOCKER_API_VERSION
+	// environment variable, read because WithVersionFromEnv (or FromEnv)
+	// was used.
+	VersionSourceEnv VersionSource = "environment"
+	// VersionSourceExplicit means the version was pinned with WithVersion.
+	VersionSourceExplicit VersionSource = "explicit"
+)
+
+// versionSourceRank orders the sources by precedence: an explicit pin beats
+// the environment, the environment beats negotiation, and anything beats the
+// library default.
+var versionSourceRank = map[VersionSource]int{
+	VersionSourceDefault:     0,
+	VersionSourceNegotiation: 1,
+	VersionSourceEnv:         2,
+	VersionSourceExplicit:    3,
+}
+
+// VersionSource reports which source the client's current API version came
+// from; see ClientVersion for the version itself.
+func (cli *Client) VersionSource() VersionSource {
+	if cli.versionSource == "" {
+		return VersionSourceDefault
+	}
+	return cli.versionSource
+}
+
+// setVersion adopts version from the given source, unless a source of higher
+// precedence already set one, in which case the new value is ignored and the
+// decision logged at debug level. Option ordering therefore does not matter:
+// WithVersion wins over WithVersionFromEnv either way around.
+func (cli *Client) setVersion(version string, source VersionSource) {
+	if versionSourceRank[source] < versionSourceRank[cli.VersionSource()] {
+		logrus.Debugf("ignoring API version %s from %s source: version %s already set by %s source", version, source, cli.version, cli.VersionSource())
+		return
+	}
+	cli.version = version
+	cli.versionSource = source
+	cli.manualOverride = source == VersionSourceEnv || source == VersionSourceExplicit
+}
+
+// logSkippedNegotiation records at debug level that negotiation was
+// requested while a higher-precedence version source is in place.
+func (cli *Client) logSkippedNegotiation() {
+	logrus.Debugf("skipping API version negotiation: version %s set by %s source", cli.version, cli.VersionSource())
+}
+
+// WithVersionFromEnv overrides the client version with the value of the
+// DOCKER_API_VERSION environment variable, if it is set and non-empty. The
+// environment is only consulted when this option (or FromEnv) is used, and
+// the value loses to an explicit WithVersion but beats negotiation.
+func WithVersionFromEnv() Opt {
+	return func(c *Client) error {
+		if version := os.Getenv("DOCKER_API_VERSION"); version != "" {
+			c.setVersion(version, VersionSourceEnv)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,124 @@
+package client // import "github.com/docker/docker/client"
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api"
+	"github.com/docker/docker/api/types"
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+	"gotest.tools/v3/env"
+)
+
+// TestVersionSourcePrecedence exercises every combination of the three
+// version sources: an explicit pin beats the environment, the environment
+// beats negotiation, and negotiation beats the library default.
+func TestVersionSourcePrecedence(t *testing.T) {
+	for _, tc := range []struct {
+		doc       string
+		env       string
+		opts      []Opt
+		negotiate string // daemon version to negotiate with, empty for none
+		version   string
+		source    VersionSource
+	}{
+		{
+			doc:     "library default",
+			version: api.DefaultVersion,
+			source:  VersionSourceDefault,
+		},
+		{
+			doc:     "environment is not read unless requested",
+			env:     "1.39",
+			version: api.DefaultVersion,
+			source:  VersionSourceDefault,
+		},
+		{
+			doc:     "environment when requested",
+			env:     "1.39",
+			opts:    []Opt{WithVersionFromEnv()},
+			version: "1.39",
+			source:  VersionSourceEnv,
+		},
+		{
+			doc:     "unset environment leaves the default",
+			opts:    []Opt{WithVersionFromEnv()},
+			version: api.DefaultVersion,
+			source:  VersionSourceDefault,
+		},
+		{
+			doc:     "environment via FromEnv",
+			env:     "1.39",
+			opts:    []Opt{FromEnv},
+			version: "1.39",
+			source:  VersionSourceEnv,
+		},
+		{
+			doc:     "explicit pin",
+			opts:    []Opt{WithVersion("1.38")},
+			version: "1.38",
+			source:  VersionSourceExplicit,
+		},
+		{
+			doc:     "explicit pin beats the environment",
+			env:     "1.39",
+			opts:    []Opt{WithVersionFromEnv(), WithVersion("1.38")},
+			version: "1.38",
+			source:  VersionSourceExplicit,
+		},
+		{
+			doc:     "explicit pin beats the environment regardless of option order",
+			env:     "1.39",
+			opts:    []Opt{WithVersion("1.38"), WithVersionFromEnv()},
+			version: "1.38",
+			source:  VersionSourceExplicit,
+		},
+		{
+			doc:       "negotiation without pins",
+			negotiate: "1.35",
+			version:   "1.35",
+			source:    VersionSourceNegotiation,
+		},
+		{
+			doc:       "environment beats negotiation",
+			env:       "1.39",
+			opts:      []Opt{WithVersionFromEnv()},
+			negotiate: "1.35",
+			version:   "1.39",
+			source:    VersionSourceEnv,
+		},
+		{
+			doc:       "explicit pin beats negotiation",
+			opts:      []Opt{WithVersion("1.38")},
+			negotiate: "1.35",
+			version:   "1.38",
+			source:    VersionSourceExplicit,
+		},
+		{
+			doc:       "explicit pin beats everything",
+			env:       "1.39",
+			opts:      []Opt{WithVersionFromEnv(), WithVersion("1.38")},
+			negotiate: "1.35",
+			version:   "1.38",
+			source:    VersionSourceExplicit,
+		},
+	} {
+		t.Run(tc.doc, func(t *testing.T) {
+			defer env.PatchAll(t, map[string]string{"DOCKER_API_VERSION": tc.env})()
+			client, err := NewClientWithOpts(tc.opts...)
+			assert.NilError(t, err)
+			if tc.negotiate != "" {
+				client.NegotiateAPIVersionPing(types.Ping{APIVersion: tc.negotiate})
+			}
+			assert.Check(t, is.Equal(tc.version, client.ClientVersion()))
+			assert.Check(t, is.Equal(tc.source, client.VersionSource()))
+		})
+	}
+}
+
+// TestVersionSourceZeroValue covers clients not built through
+// NewClientWithOpts, which never had a source applied.
+func TestVersionSourceZeroValue(t *testing.T) {
+	client := &Client{}
+	assert.Check(t, is.Equal(VersionSourceDefault, client.VersionSource()))
+}
@@ -0,0 +1,36 @@
+package client // import "github.com/docker/docker/client"
+
+import "github.com/docker/docker/api/types/versions"
+
+// versionedField ties an optional request field to the minimum API version
+// that understands it, so endpoint wrappers can declare their version
+// requirements instead of hand-rolling checks.
+type versionedField struct {
+	// name is how the field is reported in errors, e.g. "stop timeout".
+	name string
+	// version is the minimum API version that supports the field.
+	version string
+	// set reports whether the caller supplied the field.
+	set func() bool
+	// clear removes the field from the request so that an older daemon
+	// never sees it.
+	clear func()
+}
+
+// downgradeRequest reconciles a request with the effective API version.
+// Fields the version does not support are rejected with an error naming the
+// field and the version it requires, or — when the client was built with
+// WithLenientVersioning — cleared from the request before marshaling.
+func (cli *Client) downgradeRequest(fields ...versionedField) error {
+	for _, f := range fields {
+		if !f.set() || cli.version == "" || !versions.LessThan(cli.version, f.version) {
+			continue
+		}
+		if cli.lenientVersioning {
+			f.clear()
+			continue
+		}
+		return cli.NewVersionError(f.version, f.name)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package client // import "github.com/docker/docker/client"
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	volumetypes "github.com/docker/docker/api/types/volume"
+	"gotest.tools/v3/assert"
+)
+
+// captureBodyClient returns a client pinned to the given API version whose
+// transport records every request body into out.
+func captureBodyClient(version string, lenient bool, out *[]byte) *Client {
+	return &Client{
+		version:           version,
+		lenientVersioning: lenient,
+		client: newMockClient(func(req *http.Request) (*http.Response, error) {
+			body, err := ioutil.ReadAll(req.Body)
+			if err != nil {
+				return nil, err
+			}
+			*out = body
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte("{}"))),
+			}, nil
+		}),
+	}
+}
+
+func TestContainerCreateStrictRejectsUnsupportedField(t *testing.T) {
+	var body []byte
+	client := captureBodyClient("1.24", false, &body)
+
+	timeout := 10
+	_, err := client.ContainerCreate(context.Background(), &container.Config{StopTimeout: &timeout}, nil, nil, nil, "")
+	assert.ErrorContains(t, err, `"stop timeout" requires API version 1.25`)
+	assert.Check(t, body == nil, "no request should have been sent")
+}
+
+func TestContainerCreateLenientStripsUnsupportedField(t *testing.T) {
+	var body []byte
+	client := captureBodyClient("1.24", true, &body)
+
+	timeout := 10
+	_, err := client.ContainerCreate(context.Background(), &container.Config{Image: "foo", StopTimeout: &timeout}, nil, nil, nil, "")
+	assert.NilError(t, err)
+	assert.Check(t, !strings.Contains(string(body), "StopTimeout"), "emitted JSON: %s", body)
+	assert.Check(t, strings.Contains(string(body), `"Image":"foo"`), "emitted JSON: %s", body)
+}
+
+func TestContainerCreateStrictRejectsDeviceRequests(t *testing.T) {
+	var body []byte
+	client := captureBodyClient("1.39", false, &body)
+
+	hostConfig := &container.HostConfig{
+		Resources: container.Resources{
+			DeviceRequests: []container.DeviceRequest{{Driver: "nvidia", Count: -1}},
+		},
+	}
+	_, err := client.ContainerCreate(context.Background(), &container.Config{}, hostConfig, nil, nil, "")
+	assert.ErrorContains(t, err, `"device requests" requires API version 1.40`)
+}
+
+func TestNetworkCreateLenientStripsUnsupportedFields(t *testing.T) {
+	var body []byte
+	client := captureBodyClient("1.24", true, &body)
+
+	_, err := client.NetworkCreate(context.Background(), "mynet", types.NetworkCreate{
+		Driver:     "overlay",
+		Attachable: true,
+		Ingress:    true,
+	})
+	assert.NilError(t, err)
+	assert.Check(t, !strings.Contains(string(body), `"Attachable":true`), "emitted JSON: %s", body)
+	assert.Check(t, !strings.Contains(string(body), `"Ingress":true`), "emitted JSON: %s", body)
+	assert.Check(t, strings.Contains(string(body), `"Driver":"overlay"`), "emitted JSON: %s", body)
+}
+
+func TestNetworkCreateStrictRejectsIngress(t *testing.T) {
+	var body []byte
+	client := captureBodyClient("1.28", false, &body)
+
+	_, err := client.NetworkCreate(context.Background(), "mynet", types.NetworkCreate{Ingress: true})
+	assert.ErrorContains(t, err, `"ingress network" requires API version 1.29`)
+}
+
+func TestVolumeCreateLenientStripsLabels(t *testing.T) {
+	var body []byte
+	client := captureBodyClient("1.22", true, &body)
+
+	_, err := client.VolumeCreate(context.Background(), volumetypes.VolumeCreateBody{
+		Name:   "myvolume",
+		Labels: map[string]string{"a": "b"},
+	})
+	assert.NilError(t, err)
+	assert.Check(t, !strings.Contains(string(body), `"a":"b"`), "emitted JSON: %s", body)
+	assert.Check(t, strings.Contains(string(body), `"Name":"myvolume"`), "emitted JSON: %s", body)
+}
+
+func TestDowngradeRequestIgnoresUnsetFields(t *testing.T) {
+	client := &Client{version: "1.24"}
+	err := client.downgradeRequest(versionedField{
+		name: "some field", version: "1.40",
+		set:   func() bool { return false },
+		clear: func() { t.Fatal("clear must not run for an unset field") },
+	})
+	assert.NilError(t, err)
+
+	// without a pinned version there is nothing to downgrade against
+	client = &Client{}
+	err = client.downgradeRequest(versionedField{
+		name: "some field", version: "1.40",
+		set:   func() bool { return true },
+		clear: func() { t.Fatal("clear must not run without a version") },
+	})
+	assert.NilError(t, err)
+}
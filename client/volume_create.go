@@ -11,6 +11,18 @@ import (
 // VolumeCreate creates a volume in the docker host.
 func (cli *Client) VolumeCreate(ctx context.Context, options volumetypes.VolumeCreateBody) (types.Volume, error) {
 	var volume types.Volume
+
+	err := cli.downgradeRequest(
+		versionedField{
+			name: "volume labels", version: "1.23",
+			set:   func() bool { return len(options.Labels) > 0 },
+			clear: func() { options.Labels = nil },
+		},
+	)
+	if err != nil {
+		return volume, err
+	}
+
 	resp, err := cli.post(ctx, "/volumes/create", nil, options, nil)
 	defer ensureReaderClosed(resp)
 	if err != nil {
@@ -1,18 +1,61 @@
 package stream // import "github.com/docker/docker/container/stream"
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
 
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/broadcaster"
+	"github.com/docker/docker/pkg/ioutils"
 	"github.com/docker/docker/pkg/pools"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/docker/pkg/stringid"
 	"github.com/moby/term"
 	"github.com/pkg/errors"
-	"github.com/sirupsen/logrus"
 	"golang.org/x/sync/errgroup"
+	"golang.org/x/time/rate"
 )
 
 var defaultEscapeSequence = []byte{16, 17} // ctrl-p, ctrl-q
 
+// maxDetachKeyLen is the longest detach key sequence accepted. The escape
+// proxy buffers up to a full sequence of partially-matched input across Read
+// boundaries, so the limit also bounds how much typed input can be held back
+// from the container while a match is in progress.
+const maxDetachKeyLen = 16
+
+// maxCopyBufferSize caps AttachConfig.BufferSize; larger requests are clamped
+// so a single attach cannot pin an arbitrary amount of memory per stream.
+const maxCopyBufferSize = 1 << 20 // 1MB
+
+// ValidateDetachKeys checks that a parsed detach key sequence can be matched
+// reliably by the escape proxy, naming the offending key in the returned
+// errdefs.InvalidParameter error. An empty sequence is valid; the default
+// ctrl-p ctrl-q applies.
+func ValidateDetachKeys(keys []byte) error {
+	if len(keys) > maxDetachKeyLen {
+		return errdefs.InvalidParameter(errors.Errorf("detach key sequence of %d keys exceeds the maximum of %d", len(keys), maxDetachKeyLen))
+	}
+	for i, k := range keys {
+		switch {
+		case k == 0:
+			return errdefs.InvalidParameter(errors.Errorf("detach key %d is NUL, which cannot be typed in raw mode", i+1))
+		case k >= utf8.RuneSelf:
+			// raw mode delivers multi-byte characters one byte at a time;
+			// a non-ASCII byte in the sequence cannot be told apart from
+			// the middle of ordinary input
+			return errdefs.InvalidParameter(errors.Errorf("detach key %d (%#x) is not a single-byte key", i+1, k))
+		}
+	}
+	return nil
+}
+
 // AttachConfig is the config struct used to attach a client to a stream's stdio
 type AttachConfig struct {
 	// Tells the attach copier that the stream's stdin is a TTY and to look for
@@ -23,15 +66,64 @@ type AttachConfig struct {
 	// Only useful when `TTY` is true
 	DetachKeys []byte
 
+	// DetachKeyTimeout bounds how long a partially-typed multi-byte detach
+	// sequence stays armed. When the next key of the sequence does not arrive
+	// within the window, the withheld prefix is flushed to the container and
+	// matching restarts, so a lone ctrl-p is not turned into a detach by an
+	// unrelated ctrl-q much later. Zero disables the timeout; the daemon
+	// passes DefaultDetachKeyTimeout.
+	DetachKeyTimeout time.Duration
+
+	// DetachPolicy selects what a typed detach sequence does: detach and be
+	// withheld from the container (DetachSwallow, the default), detach and
+	// be forwarded too (DetachForward), or forward without detaching when
+	// pressed twice quickly (DetachDoublePressForward).
+	DetachPolicy DetachPolicy
+
+	// DetachDoublePressTimeout is the window within which a second press of
+	// the sequence counts as a double press under DetachDoublePressForward.
+	// Zero applies a half-second default.
+	DetachDoublePressTimeout time.Duration
+
+	// DisableDetach turns escape-sequence detection off entirely for a TTY
+	// attach, so even the default ctrl-p ctrl-q reaches the application as
+	// literal bytes. The session then only ends through stream closure or
+	// context cancellation. The API layer maps detachKeys="none" to this.
+	DisableDetach bool
+
 	// CloseStdin signals that once done, stdin for the attached stream should be closed
 	// For example, this would close the attached container's stdin.
 	CloseStdin bool
 
+	// StdinRateLimit caps how fast client input is copied into the
+	// container, in bytes per second, so a huge paste cannot overwhelm the
+	// container's PTY or balloon the intermediate buffers. Zero means
+	// unlimited.
+	StdinRateLimit int
+	// StdinRateBurst is the token bucket's burst size in bytes. Zero
+	// derives a small burst from the rate.
+	StdinRateBurst int
+
+	// HalfCloseStdin makes end-of-input a half-close: when the client's
+	// Stdin reaches EOF only CStdin is closed, and the stdout/stderr
+	// copiers keep running until the container closes its side or the
+	// context ends. This supports `cat file | docker attach`-style usage
+	// where the client is done sending but still wants the output. It takes
+	// precedence over the CloseStdin/TTY teardown rules.
+	HalfCloseStdin bool
+
 	// UseStd* indicate whether the client has requested to be connected to the
 	// given stream or not.  These flags are used instead of checking Std* != nil
 	// at points before the client streams Std* are wired up.
 	UseStdin, UseStdout, UseStderr bool
 
+	// MergeStderr permits UseStderr together with TTY by aliasing stderr to
+	// the TTY's single output stream: the merged output is fanned out to both
+	// the Stdout and Stderr writers. Without it, AttachStreams rejects the
+	// combination, because a TTY has no separate stderr and a copier attached
+	// to one would never see data or close.
+	MergeStderr bool
+
 	// CStd* are the streams directly connected to the container
 	CStdin           io.WriteCloser
 	CStdout, CStderr io.ReadCloser
@@ -39,35 +131,382 @@ type AttachConfig struct {
 	// Provide client streams to wire up to
 	Stdin          io.ReadCloser
 	Stdout, Stderr io.Writer
+
+	// TransformStdout and TransformStderr, when set, wrap the client output
+	// path inside CopyStreams for platform-specific translation (such as
+	// ANSI to Windows console API, see NewConsoleOutputTransform). The
+	// transform receives the writer carrying translated output onward: what
+	// it writes is counted in CopyStats, resets the idle clock and, in mux
+	// mode, is framed by stdcopy — translation happens before framing and
+	// accounting.
+	TransformStdout, TransformStderr func(io.Writer) io.Writer
+
+	// TransformStdin, when set, wraps the client input stream. It reads the
+	// input after rate limiting is applied; with a TTY, the detach escape
+	// sequence is scanned on the transformed stream.
+	TransformStdin func(io.Reader) io.Reader
+
+	// MuxStreams tells CopyStreams to frame stdout and stderr itself into
+	// MuxOutput using the stdcopy header format, instead of copying into the
+	// separate Stdout/Stderr writers. Only meaningful for non-TTY attaches;
+	// a shared lock keeps concurrent frames from interleaving mid-frame.
+	MuxStreams bool
+	// MuxOutput is the single writer receiving the multiplexed stream when
+	// MuxStreams is set.
+	MuxOutput io.Writer
+
+	// Backpressure selects how this attacher is handled when it cannot keep
+	// up with the container's output. The default, BackpressureBlock,
+	// throttles the container — and with it every other attacher — until
+	// this one catches up.
+	Backpressure BackpressurePolicy
+
+	// BackpressureLimit is the per-attacher byte budget used by the
+	// BackpressureDrop and BackpressureBufferLimit policies. Zero applies
+	// defaultBackpressureLimit.
+	BackpressureLimit int
+
+	// ReplayOutput requests that the attach begins with the retained tail of
+	// the container's recent output — see Config.EnableOutputReplay — before
+	// live copying starts. It is a no-op when replay retention is not
+	// enabled. For TTY containers the tail is replayed into the single
+	// output stream.
+	ReplayOutput bool
+
+	// ReplayStdout and ReplayStderr are populated by AttachStreams when
+	// ReplayOutput is in effect. CopyStreams writes them to the client ahead
+	// of the live stream — with stdcopy framing when MuxStreams is set —
+	// without dropping or duplicating bytes around the handoff.
+	ReplayStdout, ReplayStderr []byte
+
+	// Handoff, when set, coordinates a logs-to-live handoff: the pipes wired
+	// by AttachStreams stay dormant until CopyStreams resumes the handoff,
+	// splicing in the output retained since Config.BeginOutputHandoff ahead
+	// of the live stream. HandoffStdoutSeq and HandoffStderrSeq say up to
+	// which sequence cursor the caller has already delivered output from the
+	// logs; live copy starts exactly there. Values below the handoff's pin
+	// are clamped to it, so zero means "everything since the pin".
+	Handoff                            *OutputHandoff
+	HandoffStdoutSeq, HandoffStderrSeq uint64
+
+	// DrainTimeout bounds a grace period applied when the context is
+	// canceled: stdin is closed immediately, but the stdout and stderr
+	// copiers are given up to this long to flush output the container had
+	// already written before the pipes are force-closed. Zero keeps the
+	// immediate teardown, at the risk of losing the container's final
+	// output.
+	DrainTimeout time.Duration
+
+	// IdleTimeout, when non-zero, bounds how long the attached streams may go
+	// with no bytes moving in either direction. When it expires, CopyStreams
+	// closes all pipes and delivers an IdleTimeoutError on the returned
+	// channel, so a dead client cannot hold the container's stream pipes open
+	// indefinitely. Zero disables the timeout.
+	IdleTimeout time.Duration
+
+	// BufferSize overrides the size of the buffers used by the stdin, stdout
+	// and stderr copiers. Zero (or negative) keeps the shared 32K default.
+	// Larger buffers favor throughput on bulk transfers; smaller ones reduce
+	// the latency and memory of chatty interactive streams. Values above
+	// maxCopyBufferSize are clamped.
+	BufferSize int
+
+	// DetachNotify, when set, is invoked once if the client detaches with the
+	// escape sequence. A detach is reported as a clean termination — the
+	// AttachResult carries ReasonDetach and a nil error — so most callers can
+	// rely on the result alone; the callback remains for those that need to
+	// react before the copiers have fully wound down.
+	DetachNotify func()
+
+	// StatsNotify, when set, is invoked once after all copiers have finished,
+	// just before the session's error is delivered, with the per-stream byte
+	// totals. The totals count what was actually written out, so they remain
+	// exact when a stream ends early on a detach or a canceled context.
+	StatsNotify func(CopyStats)
+}
+
+// CopyStats reports how many bytes moved on each attached stream over the
+// lifetime of a CopyStreams session.
+type CopyStats struct {
+	// StdinBytes is the number of bytes delivered to the container's stdin.
+	// With a TTY attached this excludes a detach escape sequence, unless the
+	// detach policy forwards it to the container.
+	StdinBytes int64
+	// StdoutBytes and StderrBytes are the number of bytes written to the
+	// client streams, before any stdcopy framing added by MuxStreams.
+	StdoutBytes, StderrBytes int64
+	// StdoutDropped and StderrDropped count the bytes discarded for this
+	// attacher by the BackpressureDrop policy.
+	StdoutDropped, StderrDropped int64
 }
 
-// AttachStreams attaches the container's streams to the AttachConfig
-func (c *Config) AttachStreams(cfg *AttachConfig) {
+// AttachStreams attaches the container's streams to the AttachConfig. It
+// returns an errdefs.InvalidParameter error when the config asks for a stream
+// combination the container cannot provide.
+func (c *Config) AttachStreams(cfg *AttachConfig) error {
+	if cfg.TTY && cfg.UseStderr {
+		// a TTY multiplexes stdout and stderr into a single stream, so there
+		// is no separate stderr pipe to attach
+		if !cfg.MergeStderr {
+			return errdefs.InvalidParameter(errors.New("cannot attach stderr separately to a TTY-enabled container"))
+		}
+		// the merged output flows through the stdout pipe; CopyStreams fans
+		// it out to the Stderr writer as well
+		cfg.UseStderr = false
+		cfg.UseStdout = true
+	}
+
 	if cfg.UseStdin {
 		cfg.CStdin = c.StdinPipe()
 	}
 
+	attachOutput := func(bc *broadcaster.Unbuffered, tail *replayBuffer) ([]byte, io.ReadWriteCloser) {
+		var pipe io.ReadWriteCloser = ioutils.NewBytesPipe()
+		if cfg.Backpressure != BackpressureBlock {
+			pipe = newBackpressurePipe(cfg.Backpressure, cfg.BackpressureLimit)
+		}
+		if cfg.Handoff != nil {
+			// registration is deferred until CopyStreams resumes the
+			// handoff, after the caller has read the log tail
+			return nil, pipe
+		}
+		if cfg.ReplayOutput && tail != nil {
+			return tail.tailAndFollow(pipe), pipe
+		}
+		bc.Add(pipe)
+		return nil, pipe
+	}
+
 	if cfg.UseStdout {
-		cfg.CStdout = c.StdoutPipe()
+		var pipe io.ReadWriteCloser
+		cfg.ReplayStdout, pipe = attachOutput(c.stdout, c.stdoutTail)
+		cfg.CStdout = pipe
+		if cfg.Handoff != nil {
+			cfg.Handoff.stdoutPipe = pipe
+		}
 	}
 
 	if cfg.UseStderr {
-		cfg.CStderr = c.StderrPipe()
+		var pipe io.ReadWriteCloser
+		cfg.ReplayStderr, pipe = attachOutput(c.stderr, c.stderrTail)
+		cfg.CStderr = pipe
+		if cfg.Handoff != nil {
+			cfg.Handoff.stderrPipe = pipe
+		}
+	}
+	return nil
+}
+
+// IdleTimeoutError is delivered by CopyStreams when AttachConfig.IdleTimeout
+// elapsed with no data moving on any of the attached streams.
+type IdleTimeoutError struct {
+	// Timeout is the idle timeout that expired.
+	Timeout time.Duration
+}
+
+func (e IdleTimeoutError) Error() string {
+	return fmt.Sprintf("attached streams idle for %v", e.Timeout)
+}
+
+// countingWriter accumulates the number of bytes written through it into the
+// session's CopyStats.
+type countingWriter struct {
+	w io.Writer
+	n *int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	atomic.AddInt64(cw.n, int64(n))
+	return n, err
+}
+
+// activityWriter stamps the shared last-activity clock whenever bytes move
+// through it.
+type activityWriter struct {
+	w    io.Writer
+	last *int64
+}
+
+func (aw *activityWriter) Write(p []byte) (int, error) {
+	n, err := aw.w.Write(p)
+	if n > 0 {
+		atomic.StoreInt64(aw.last, time.Now().UnixNano())
+	}
+	return n, err
+}
+
+// muxWriter serializes writes to the shared multiplexed output so a stdcopy
+// frame's header and payload stay contiguous when stdout and stderr are busy
+// at the same time.
+type muxWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (w *muxWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(p)
+}
+
+// TerminationReason says why a CopyStreams session ended.
+type TerminationReason int
+
+const (
+	// ReasonStreamsClosed means the copies ran to completion: the container
+	// (or the client) closed its side of the streams.
+	ReasonStreamsClosed TerminationReason = iota
+	// ReasonDetach means the client detached with the escape sequence.
+	ReasonDetach
+	// ReasonCanceled means the session context was canceled or timed out.
+	ReasonCanceled
+	// ReasonIdleTimeout means AttachConfig.IdleTimeout expired.
+	ReasonIdleTimeout
+	// ReasonError means one of the stream copies failed.
+	ReasonError
+)
+
+func (r TerminationReason) String() string {
+	switch r {
+	case ReasonStreamsClosed:
+		return "streams closed"
+	case ReasonDetach:
+		return "detach"
+	case ReasonCanceled:
+		return "canceled"
+	case ReasonIdleTimeout:
+		return "idle timeout"
+	case ReasonError:
+		return "error"
 	}
+	return "unknown"
 }
 
-// CopyStreams starts goroutines to copy data in and out to/from the container
+// AttachResult describes how an attach session ended.
+type AttachResult struct {
+	// SessionID is the per-attach correlation ID carried by every log line
+	// of the session.
+	SessionID string
+	// Reason classifies the termination.
+	Reason TerminationReason
+	// Err is the session's error; it carries the legacy CopyStreams error
+	// channel semantics and is nil for a clean termination.
+	Err error
+	// StdinErr, StdoutErr and StderrErr are the per-stream copy errors, so
+	// a failure can be attributed to the stream it happened on.
+	StdinErr, StdoutErr, StderrErr error
+	// Stats holds the per-stream byte counters for the session.
+	Stats CopyStats
+	// Duration is how long the session lasted.
+	Duration time.Duration
+}
+
+// CopyStreams starts goroutines to copy data in and out to/from the
+// container. It is the legacy form of CopyStreamsResult for callers that
+// only need the session error (AttachResult.Err).
 func (c *Config) CopyStreams(ctx context.Context, cfg *AttachConfig) <-chan error {
+	results := c.CopyStreamsResult(ctx, cfg)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- (<-results).Err
+	}()
+	return errs
+}
+
+// CopyStreamsResult starts goroutines to copy data in and out to/from the
+// container and delivers a single AttachResult once the session ends.
+func (c *Config) CopyStreamsResult(ctx context.Context, cfg *AttachConfig) <-chan AttachResult {
 	var group errgroup.Group
+	start := time.Now()
+
+	if cfg.Handoff != nil {
+		// the caller is done with the log tail; splice the retained output
+		// in at the requested cursors and go live
+		cfg.ReplayStdout, cfg.ReplayStderr = cfg.Handoff.resume(cfg.HandoffStdoutSeq, cfg.HandoffStderrSeq)
+	}
+
+	// every log line of this session shares the ID, which is also delivered
+	// in the AttachResult so API errors can reference it
+	sessionID := stringid.TruncateID(stringid.GenerateRandomID())
+	logger := loggerFromContext(ctx).WithField("session", sessionID)
+
+	// All three copiers share one pool; the stream with the odd size out can
+	// request its own AttachConfig.
+	copyFn := pools.Copy
+	if size := cfg.BufferSize; size > 0 {
+		if size > maxCopyBufferSize {
+			size = maxCopyBufferSize
+		}
+		copyFn = pools.NewBufferPoolWithSize(size).Copy
+	}
+
+	// track wraps every copy destination so any transferred byte, in either
+	// direction, resets the idle clock.
+	var lastActivity int64
+	track := func(w io.Writer) io.Writer { return w }
+	if cfg.IdleTimeout > 0 {
+		atomic.StoreInt64(&lastActivity, time.Now().UnixNano())
+		track = func(w io.Writer) io.Writer {
+			return &activityWriter{w: w, last: &lastActivity}
+		}
+	}
+
+	// count wraps each copy destination to total the bytes written per
+	// stream; the totals feed both StatsNotify and the AttachResult.
+	var stats CopyStats
+	count := func(w io.Writer, n *int64) io.Writer {
+		return &countingWriter{w: w, n: n}
+	}
+
+	// per-stream outcomes for the AttachResult; each is written by its copier
+	// goroutine and only read after group.Wait has returned
+	var stdinErr, stdoutErr, stderrErr error
+	var detached int32
+
+	stdout, stderr := cfg.Stdout, cfg.Stderr
+	if cfg.TTY && cfg.MergeStderr {
+		// the TTY's merged output reaches both client writers through the
+		// stdout copier; there is no separate stderr pipe (see AttachStreams)
+		switch {
+		case stdout == nil:
+			stdout = stderr
+		case stderr != nil:
+			stdout = io.MultiWriter(stdout, stderr)
+		}
+		stderr = nil
+	}
+	if cfg.MuxStreams && cfg.MuxOutput != nil {
+		mw := &muxWriter{w: cfg.MuxOutput}
+		if cfg.CStdout != nil {
+			stdout = stdcopy.NewStdWriter(mw, stdcopy.Stdout)
+		}
+		if cfg.CStderr != nil {
+			stderr = stdcopy.NewStdWriter(mw, stdcopy.Stderr)
+		}
+	}
 
 	// Connect stdin of container to the attach stdin stream.
 	if cfg.Stdin != nil {
 		group.Go(func() error {
-			logrus.Debug("attach: stdin: begin")
-			defer logrus.Debug("attach: stdin: end")
+			log := logger.WithField("stream", "stdin")
+			log.Debug("attach: begin")
+			defer log.Debug("attach: end")
+
+			stdin := cfg.Stdin
+			if cfg.StdinRateLimit > 0 {
+				stdin = newRateLimitedReader(ctx, stdin, cfg.StdinRateLimit, cfg.StdinRateBurst)
+			}
+			if cfg.TransformStdin != nil {
+				stdin = ioutils.NewReadCloserWrapper(cfg.TransformStdin(stdin), stdin.Close)
+			}
 
 			defer func() {
-				if cfg.CloseStdin && !cfg.TTY {
+				if cfg.HalfCloseStdin {
+					// done sending input; the output copiers run on until
+					// the container closes its side
+					cfg.CStdin.Close()
+				} else if cfg.CloseStdin && !cfg.TTY {
 					cfg.CStdin.Close()
 				} else {
 					// No matter what, when stdin is closed (io.Copy unblock), close stdout and stderr
@@ -81,25 +520,56 @@ func (c *Config) CopyStreams(ctx context.Context, cfg *AttachConfig) <-chan erro
 			}()
 
 			var err error
-			if cfg.TTY {
-				_, err = copyEscapable(cfg.CStdin, cfg.Stdin, cfg.DetachKeys)
+			dst := track(count(cfg.CStdin, &stats.StdinBytes))
+			if cfg.TTY && cfg.DisableDetach {
+				// no escape proxy: every byte, including the default escape
+				// sequence, goes to the application untouched
+				tagged := &stdinDst{w: dst}
+				var written int64
+				written, err = copyFn(tagged, stdin)
+				err = classifyStdinErr(written, err, tagged)
+				stdin.Close()
+			} else if cfg.TTY {
+				_, err = copyEscapable(dst, stdin, cfg, copyFn)
 			} else {
-				_, err = pools.Copy(cfg.CStdin, cfg.Stdin)
+				tagged := &stdinDst{w: dst}
+				var written int64
+				written, err = copyFn(tagged, stdin)
+				err = classifyStdinErr(written, err, tagged)
 			}
-			if err == io.ErrClosedPipe {
+			if errors.Is(err, io.ErrClosedPipe) {
+				err = nil
+			}
+			if errors.Is(err, context.Canceled) {
+				// the rate limiter gave up waiting for tokens because the
+				// session is being torn down; let the cancellation be
+				// reported, not the copy
+				err = nil
+			}
+			var eerr term.EscapeError
+			if errors.As(err, &eerr) {
+				// the client detached on purpose; the deferred closes above
+				// wind down the other copiers, and the result carries
+				// ReasonDetach rather than an error
+				atomic.StoreInt32(&detached, 1)
+				if cfg.DetachNotify != nil {
+					cfg.DetachNotify()
+				}
 				err = nil
 			}
 			if err != nil {
-				logrus.WithError(err).Debug("error on attach stdin")
-				return errors.Wrap(err, "error on attach stdin")
+				log.WithError(err).Debug("error on attach stdin")
+				stdinErr = errors.Wrap(err, "error on attach stdin")
+				return stdinErr
 			}
 			return nil
 		})
 	}
 
-	attachStream := func(name string, stream io.Writer, streamPipe io.ReadCloser) error {
-		logrus.Debugf("attach: %s: begin", name)
-		defer logrus.Debugf("attach: %s: end", name)
+	attachStream := func(name string, stream io.Writer, streamPipe io.ReadCloser, replay []byte) error {
+		log := logger.WithField("stream", name)
+		log.Debug("attach: begin")
+		defer log.Debug("attach: end")
 		defer func() {
 			// Make sure stdin gets closed
 			if cfg.Stdin != nil {
@@ -108,37 +578,98 @@ func (c *Config) CopyStreams(ctx context.Context, cfg *AttachConfig) <-chan erro
 			streamPipe.Close()
 		}()
 
-		_, err := pools.Copy(stream, streamPipe)
+		var src io.Reader = streamPipe
+		if len(replay) > 0 {
+			// the retained tail goes first; the follower pipe was registered
+			// under the same lock as the snapshot, so the live bytes line up
+			// exactly after it
+			src = io.MultiReader(bytes.NewReader(replay), streamPipe)
+		}
+		_, err := copyFn(stream, src)
 		if err == io.ErrClosedPipe {
 			err = nil
 		}
 		if err != nil {
-			logrus.WithError(err).Debugf("attach: %s", name)
+			log.WithError(err).Debug("attach: copy error")
 			return errors.Wrapf(err, "error attaching %s stream", name)
 		}
 		return nil
 	}
 
-	if cfg.Stdout != nil {
+	if stdout != nil {
 		group.Go(func() error {
-			return attachStream("stdout", cfg.Stdout, cfg.CStdout)
+			w := track(count(stdout, &stats.StdoutBytes))
+			if cfg.TransformStdout != nil {
+				w = cfg.TransformStdout(w)
+			}
+			stdoutErr = attachStream("stdout", w, cfg.CStdout, cfg.ReplayStdout)
+			return stdoutErr
 		})
 	}
-	if cfg.Stderr != nil {
+	if stderr != nil {
 		group.Go(func() error {
-			return attachStream("stderr", cfg.Stderr, cfg.CStderr)
+			w := track(count(stderr, &stats.StderrBytes))
+			if cfg.TransformStderr != nil {
+				w = cfg.TransformStderr(w)
+			}
+			stderrErr = attachStream("stderr", w, cfg.CStderr, cfg.ReplayStderr)
+			return stderrErr
 		})
 	}
 
-	errs := make(chan error, 1)
+	results := make(chan AttachResult, 1)
 	go func() {
-		defer logrus.Debug("attach done")
+		defer logger.Debug("attach done")
 		groupErr := make(chan error, 1)
 		go func() {
 			groupErr <- group.Wait()
 		}()
-		select {
-		case <-ctx.Done():
+
+		// finish assembles and delivers the session's result. It is only
+		// called once all copiers have returned, so the counters and the
+		// per-stream errors are settled.
+		finish := func(err error) {
+			res := AttachResult{
+				SessionID: sessionID,
+				Err:       err,
+				StdinErr:  stdinErr,
+				StdoutErr: stdoutErr,
+				StderrErr: stderrErr,
+				Duration:  time.Since(start),
+				Stats: CopyStats{
+					StdinBytes:  atomic.LoadInt64(&stats.StdinBytes),
+					StdoutBytes: atomic.LoadInt64(&stats.StdoutBytes),
+					StderrBytes: atomic.LoadInt64(&stats.StderrBytes),
+				},
+			}
+			if p, ok := cfg.CStdout.(*backpressurePipe); ok {
+				res.Stats.StdoutDropped = p.Dropped()
+			}
+			if p, ok := cfg.CStderr.(*backpressurePipe); ok {
+				res.Stats.StderrDropped = p.Dropped()
+			}
+
+			var ierr IdleTimeoutError
+			switch {
+			case atomic.LoadInt32(&detached) != 0:
+				res.Reason = ReasonDetach
+			case err == nil:
+				res.Reason = ReasonStreamsClosed
+			case errors.As(err, &ierr):
+				res.Reason = ReasonIdleTimeout
+			case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+				res.Reason = ReasonCanceled
+			default:
+				res.Reason = ReasonError
+			}
+
+			if cfg.StatsNotify != nil {
+				cfg.StatsNotify(res.Stats)
+			}
+			results <- res
+		}
+
+		closeStreams := func() {
 			// close all pipes
 			if cfg.CStdin != nil {
 				cfg.CStdin.Close()
@@ -149,27 +680,214 @@ func (c *Config) CopyStreams(ctx context.Context, cfg *AttachConfig) <-chan erro
 			if cfg.CStderr != nil {
 				cfg.CStderr.Close()
 			}
+		}
+
+		var idle <-chan time.Time
+		var idleTimer *time.Timer
+		if cfg.IdleTimeout > 0 {
+			idleTimer = time.NewTimer(cfg.IdleTimeout)
+			defer idleTimer.Stop()
+			idle = idleTimer.C
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				if cfg.DrainTimeout > 0 {
+					// stop accepting input right away, but leave the output
+					// pipes open so the copiers can flush what the container
+					// already wrote
+					if cfg.CStdin != nil {
+						cfg.CStdin.Close()
+					}
+					drain := time.NewTimer(cfg.DrainTimeout)
+					select {
+					case err := <-groupErr:
+						drain.Stop()
+						if err != nil {
+							finish(err)
+							return
+						}
+						finish(ctx.Err())
+						return
+					case <-drain.C:
+					}
+				}
+				closeStreams()
 
-			// Now with these closed, wait should return.
-			if err := group.Wait(); err != nil {
-				errs <- err
+				// Now with these closed, wait should return.
+				if err := group.Wait(); err != nil {
+					finish(err)
+					return
+				}
+				finish(ctx.Err())
+				return
+			case <-idle:
+				// data may have moved since the timer was armed; push the
+				// deadline out by however much of the timeout is left
+				remaining := cfg.IdleTimeout - time.Since(time.Unix(0, atomic.LoadInt64(&lastActivity)))
+				if remaining > 0 {
+					idleTimer.Reset(remaining)
+					continue
+				}
+				closeStreams()
+				// the copiers unblock on the closed pipes; the timeout is
+				// the interesting error, not whatever they report
+				group.Wait()
+				finish(IdleTimeoutError{Timeout: cfg.IdleTimeout})
+				return
+			case err := <-groupErr:
+				finish(err)
 				return
 			}
-			errs <- ctx.Err()
-		case err := <-groupErr:
-			errs <- err
 		}
 	}()
 
-	return errs
+	return results
 }
 
-func copyEscapable(dst io.Writer, src io.ReadCloser, keys []byte) (written int64, err error) {
+// StdinWriteError reports that input stopped because it could no longer be
+// delivered to the container — typically the container closed its stdin.
+type StdinWriteError struct {
+	// Written is how many bytes reached the container before the failure.
+	Written int64
+	Err     error
+}
+
+func (e StdinWriteError) Error() string {
+	return fmt.Sprintf("error writing to container stdin after %d bytes: %v", e.Written, e.Err)
+}
+
+// Unwrap makes the underlying write error visible to errors.Is/As.
+func (e StdinWriteError) Unwrap() error { return e.Err }
+
+// StdinReadError reports that input stopped because the client's stream
+// failed — typically the client hung up.
+type StdinReadError struct {
+	// Written is how many bytes had reached the container when the client's
+	// stream failed.
+	Written int64
+	Err     error
+}
+
+func (e StdinReadError) Error() string {
+	return fmt.Sprintf("error reading attach stdin after %d bytes written: %v", e.Written, e.Err)
+}
+
+// Unwrap makes the underlying read error visible to errors.Is/As.
+func (e StdinReadError) Unwrap() error { return e.Err }
+
+// stdinDst remembers the error (including a short write) raised by the
+// container-side writer, so a failed copy can be attributed to the right
+// side of the stream.
+type stdinDst struct {
+	w   io.Writer
+	err error
+}
+
+func (d *stdinDst) Write(p []byte) (int, error) {
+	n, err := d.w.Write(p)
+	if err != nil {
+		d.err = err
+	} else if n != len(p) {
+		d.err = io.ErrShortWrite
+	}
+	return n, err
+}
+
+// classifyStdinErr wraps a stdin copy failure as a StdinWriteError or a
+// StdinReadError, carrying how many bytes made it to the container.
+func classifyStdinErr(written int64, err error, dst *stdinDst) error {
+	if err == nil {
+		return nil
+	}
+	if dst.err != nil && err == dst.err {
+		return StdinWriteError{Written: written, Err: err}
+	}
+	return StdinReadError{Written: written, Err: err}
+}
+
+// rateLimitedReader throttles reads through a token bucket so the copy
+// converges on the configured rate. Waiting for tokens honors the session
+// context, so detach and teardown stay responsive while throttled.
+type rateLimitedReader struct {
+	ctx context.Context
+	rc  io.ReadCloser
+	lim *rate.Limiter
+}
+
+func newRateLimitedReader(ctx context.Context, rc io.ReadCloser, limit, burst int) io.ReadCloser {
+	if burst <= 0 {
+		if burst = limit / 10; burst < 512 {
+			burst = 512
+		}
+	}
+	return &rateLimitedReader{
+		ctx: ctx,
+		rc:  rc,
+		lim: rate.NewLimiter(rate.Limit(limit), burst),
+	}
+}
+
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	// never read more than can be paid for in one wait
+	if b := r.lim.Burst(); len(p) > b {
+		p = p[:b]
+	}
+	n, err := r.rc.Read(p)
+	if n > 0 {
+		if werr := r.lim.WaitN(r.ctx, n); werr != nil {
+			if cerr := r.ctx.Err(); cerr != nil {
+				werr = cerr
+			}
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+func (r *rateLimitedReader) Close() error {
+	return r.rc.Close()
+}
+
+func copyEscapable(dst io.Writer, src io.ReadCloser, cfg *AttachConfig, copyFn func(io.Writer, io.Reader) (int64, error)) (written int64, err error) {
+	keys := cfg.DetachKeys
 	if len(keys) == 0 {
 		keys = defaultEscapeSequence
+	} else if err := ValidateDetachKeys(keys); err != nil {
+		// callers validate at construction; this guards direct users of
+		// AttachConfig
+		src.Close()
+		return 0, err
+	}
+
+	// the double-press policy needs the pump-driven proxy even without an
+	// inter-key timeout, so its window can expire while no input arrives
+	var pr io.Reader
+	useTimed := cfg.DetachKeyTimeout > 0 || cfg.DetachPolicy == DetachDoublePressForward
+	if useTimed {
+		tp := newTimedEscapeProxy(src, keys, cfg.DetachKeyTimeout)
+		tp.policy = cfg.DetachPolicy
+		if cfg.DetachDoublePressTimeout > 0 {
+			tp.doublePressWindow = cfg.DetachDoublePressTimeout
+		}
+		pr = tp
+	} else {
+		pr = term.NewEscapeProxy(src, keys)
 	}
-	pr := term.NewEscapeProxy(src, keys)
 	defer src.Close()
 
-	return pools.Copy(dst, pr)
+	tagged := &stdinDst{w: dst}
+	written, err = copyFn(tagged, pr)
+	if !useTimed && cfg.DetachPolicy == DetachForward {
+		// the plain escape proxy withholds the sequence; hand it to the
+		// container here so the byte accounting stays correct
+		var eerr term.EscapeError
+		if errors.As(err, &eerr) {
+			if n, werr := tagged.Write(keys); werr == nil {
+				written += int64(n)
+			}
+		}
+	}
+	return written, classifyStdinErr(written, err, tagged)
 }
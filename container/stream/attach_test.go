@@ -0,0 +1,1531 @@
+package stream // import "github.com/docker/docker/container/stream"
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/pools"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/pkg/errors"
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+func TestCopyStreamsMuxOutput(t *testing.T) {
+	coutR, coutW := io.Pipe()
+	cerrR, cerrW := io.Pipe()
+	muxR, muxW := io.Pipe()
+
+	cfg := &AttachConfig{
+		UseStdout:  true,
+		UseStderr:  true,
+		CStdout:    coutR,
+		CStderr:    cerrR,
+		MuxStreams: true,
+		MuxOutput:  muxW,
+	}
+
+	c := NewConfig()
+	errs := c.CopyStreams(context.Background(), cfg)
+
+	var outBuf, errBuf bytes.Buffer
+	demuxDone := make(chan error, 1)
+	go func() {
+		_, err := stdcopy.StdCopy(&outBuf, &errBuf, muxR)
+		demuxDone <- err
+	}()
+
+	// pump concurrent bursts down both container pipes
+	const bursts = 200
+	var wantOut, wantErr bytes.Buffer
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		defer coutW.Close()
+		for i := 0; i < bursts; i++ {
+			b := []byte(fmt.Sprintf("out burst %d|", i))
+			wantOut.Write(b)
+			if _, err := coutW.Write(b); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		defer cerrW.Close()
+		for i := 0; i < bursts; i++ {
+			b := []byte(fmt.Sprintf("err burst %d|", i))
+			wantErr.Write(b)
+			if _, err := cerrW.Write(b); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	wg.Wait()
+
+	select {
+	case err := <-errs:
+		assert.NilError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the attach copiers")
+	}
+
+	// the copiers are done; end the multiplexed stream for the demuxer
+	muxW.Close()
+	select {
+	case err := <-demuxDone:
+		assert.NilError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for StdCopy")
+	}
+
+	assert.Check(t, is.Equal(wantOut.String(), outBuf.String()))
+	assert.Check(t, is.Equal(wantErr.String(), errBuf.String()))
+}
+
+func TestCopyStreamsSeparateWritersDefault(t *testing.T) {
+	coutR, coutW := io.Pipe()
+
+	var stdout bytes.Buffer
+	cfg := &AttachConfig{
+		UseStdout: true,
+		CStdout:   coutR,
+		Stdout:    &stdout,
+	}
+
+	c := NewConfig()
+	errs := c.CopyStreams(context.Background(), cfg)
+
+	_, err := coutW.Write([]byte("plain output"))
+	assert.NilError(t, err)
+	coutW.Close()
+
+	select {
+	case err := <-errs:
+		assert.NilError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the attach copier")
+	}
+	assert.Check(t, is.Equal("plain output", stdout.String()))
+}
+
+func TestCopyStreamsDetachNotify(t *testing.T) {
+	stdinR, stdinW := io.Pipe()
+	cstdinR, cstdinW := io.Pipe()
+	coutR, _ := io.Pipe()
+
+	var stdout bytes.Buffer
+	detached := make(chan struct{})
+	cfg := &AttachConfig{
+		TTY:          true,
+		UseStdin:     true,
+		UseStdout:    true,
+		CStdin:       cstdinW,
+		CStdout:      coutR,
+		Stdin:        stdinR,
+		Stdout:       &stdout,
+		DetachNotify: func() { close(detached) },
+	}
+
+	c := NewConfig()
+	errs := c.CopyStreams(context.Background(), cfg)
+
+	// drain whatever reaches the container's stdin
+	go io.Copy(ioutil.Discard, cstdinR)
+
+	// type something, then the default ctrl-p ctrl-q escape sequence
+	_, err := stdinW.Write([]byte{'l', 's', 16, 17})
+	assert.NilError(t, err)
+
+	select {
+	case <-detached:
+	case <-time.After(5 * time.Second):
+		t.Fatal("detach was not notified")
+	}
+
+	// a detach is a clean termination, and it winds down the other copiers
+	select {
+	case err := <-errs:
+		assert.NilError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("copiers did not shut down after detach")
+	}
+}
+
+func TestValidateDetachKeys(t *testing.T) {
+	assert.NilError(t, ValidateDetachKeys(nil))
+	assert.NilError(t, ValidateDetachKeys([]byte{16, 17}))
+	assert.NilError(t, ValidateDetachKeys([]byte("ctrlpq")))
+
+	err := ValidateDetachKeys(bytes.Repeat([]byte{1}, maxDetachKeyLen+1))
+	assert.ErrorContains(t, err, "exceeds the maximum")
+	assert.Check(t, errdefs.IsInvalidParameter(err))
+
+	err = ValidateDetachKeys([]byte{16, 0, 17})
+	assert.ErrorContains(t, err, "detach key 2")
+	assert.Check(t, errdefs.IsInvalidParameter(err))
+
+	err = ValidateDetachKeys([]byte{16, 0xc3})
+	assert.ErrorContains(t, err, "not a single-byte key")
+	assert.Check(t, errdefs.IsInvalidParameter(err))
+}
+
+func TestCopyStreamsDetachSplitAcrossReads(t *testing.T) {
+	stdinR, stdinW := io.Pipe()
+	cstdinR, cstdinW := io.Pipe()
+	coutR, _ := io.Pipe()
+
+	var detaches int32
+	cfg := &AttachConfig{
+		TTY:          true,
+		UseStdin:     true,
+		UseStdout:    true,
+		CStdin:       cstdinW,
+		CStdout:      coutR,
+		Stdin:        stdinR,
+		Stdout:       ioutil.Discard,
+		DetachKeys:   []byte{1, 2, 3}, // ctrl-a, ctrl-b, ctrl-c
+		DetachNotify: func() { atomic.AddInt32(&detaches, 1) },
+	}
+
+	c := NewConfig()
+	errs := c.CopyStreams(context.Background(), cfg)
+
+	// collect everything that reaches the container's stdin
+	var mu sync.Mutex
+	var received bytes.Buffer
+	go func() {
+		buf := make([]byte, 32)
+		for {
+			n, err := cstdinR.Read(buf)
+			mu.Lock()
+			received.Write(buf[:n])
+			mu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	// ordinary input, then the sequence one key per read
+	for _, chunk := range [][]byte{[]byte("x"), {1}, {2}, {3}} {
+		_, err := stdinW.Write(chunk)
+		assert.NilError(t, err)
+	}
+
+	select {
+	case err := <-errs:
+		assert.NilError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("copiers did not shut down after detach")
+	}
+
+	assert.Check(t, is.Equal(int32(1), atomic.LoadInt32(&detaches)))
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Check(t, is.Equal("x", received.String()), "escape sequence bytes leaked to the container")
+}
+
+// gatedWriter blocks every Write until the gate is released, simulating a
+// slow client, and signals when the first Write arrives.
+type gatedWriter struct {
+	entered chan struct{}
+	gate    chan struct{}
+	once    sync.Once
+	buf     bytes.Buffer
+}
+
+func (g *gatedWriter) Write(p []byte) (int, error) {
+	g.once.Do(func() { close(g.entered) })
+	<-g.gate
+	return g.buf.Write(p)
+}
+
+func TestCopyStreamsDisableDetach(t *testing.T) {
+	stdinR, stdinW := io.Pipe()
+	cstdinR, cstdinW := io.Pipe()
+	coutR, _ := io.Pipe()
+
+	cfg := &AttachConfig{
+		TTY:           true,
+		UseStdin:      true,
+		UseStdout:     true,
+		CStdin:        cstdinW,
+		CStdout:       coutR,
+		Stdin:         stdinR,
+		Stdout:        ioutil.Discard,
+		DisableDetach: true,
+		DetachNotify:  func() { t.Error("detach triggered with detach disabled") },
+	}
+
+	c := NewConfig()
+	errs := c.CopyStreams(context.Background(), cfg)
+
+	received := make(chan []byte, 1)
+	go func() {
+		b, _ := ioutil.ReadAll(cstdinR)
+		received <- b
+	}()
+
+	// the default escape sequence must arrive at the application verbatim
+	payload := []byte{'x', 16, 17, 'y'}
+	_, err := stdinW.Write(payload)
+	assert.NilError(t, err)
+
+	// the session must not end on the escape bytes; only closing the stream
+	// ends it
+	select {
+	case err := <-errs:
+		t.Fatalf("session ended before stdin was closed: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	stdinW.Close()
+
+	select {
+	case err := <-errs:
+		assert.NilError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the attach copiers")
+	}
+	cstdinW.Close()
+	assert.Check(t, is.DeepEqual(payload, <-received))
+}
+
+func TestCopyStreamsDisableDetachCancel(t *testing.T) {
+	stdinR, stdinW := io.Pipe()
+	defer stdinW.Close()
+	cstdinR, cstdinW := io.Pipe()
+	coutR, _ := io.Pipe()
+
+	cfg := &AttachConfig{
+		TTY:           true,
+		UseStdin:      true,
+		UseStdout:     true,
+		CStdin:        cstdinW,
+		CStdout:       coutR,
+		Stdin:         stdinR,
+		Stdout:        ioutil.Discard,
+		DisableDetach: true,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewConfig()
+	errs := c.CopyStreams(ctx, cfg)
+
+	go io.Copy(ioutil.Discard, cstdinR)
+	cancel()
+
+	select {
+	case err := <-errs:
+		assert.Check(t, is.Equal(context.Canceled, err))
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancellation did not end the session")
+	}
+}
+
+// failAfterWriter accepts limit bytes, then fails every write with err.
+type failAfterWriter struct {
+	limit int
+	n     int
+	err   error
+}
+
+func (w *failAfterWriter) Write(p []byte) (int, error) {
+	room := w.limit - w.n
+	if room <= 0 {
+		return 0, w.err
+	}
+	if len(p) <= room {
+		w.n += len(p)
+		return len(p), nil
+	}
+	w.n += room
+	return room, w.err
+}
+
+func (w *failAfterWriter) Close() error { return nil }
+
+func TestCopyStreamsStdinWriteError(t *testing.T) {
+	boom := fmt.Errorf("container stdin went away")
+	statsC := make(chan CopyStats, 1)
+	cfg := &AttachConfig{
+		UseStdin:    true,
+		CStdin:      &failAfterWriter{limit: 5, err: boom},
+		Stdin:       ioutil.NopCloser(bytes.NewReader(bytes.Repeat([]byte{'w'}, 100))),
+		CloseStdin:  true,
+		StatsNotify: func(s CopyStats) { statsC <- s },
+	}
+
+	c := NewConfig()
+	select {
+	case err := <-c.CopyStreams(context.Background(), cfg):
+		var werr StdinWriteError
+		assert.Check(t, errors.As(err, &werr), "expected StdinWriteError, got %v", err)
+		assert.Check(t, is.Equal(int64(5), werr.Written))
+		assert.Check(t, errors.Is(err, boom), "underlying write error not preserved")
+		var rerr StdinReadError
+		assert.Check(t, !errors.As(err, &rerr), "write failure misclassified as read failure")
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the stdin copier")
+	}
+
+	stats := <-statsC
+	assert.Check(t, is.Equal(int64(5), stats.StdinBytes))
+}
+
+func TestCopyStreamsStdinReadError(t *testing.T) {
+	boom := fmt.Errorf("client hung up")
+	cstdinR, cstdinW := io.Pipe()
+	go io.Copy(ioutil.Discard, cstdinR)
+
+	var fed bool
+	stdin := readerFunc(func(p []byte) (int, error) {
+		if !fed {
+			fed = true
+			return copy(p, "hi"), nil
+		}
+		return 0, boom
+	})
+	cfg := &AttachConfig{
+		UseStdin:   true,
+		CStdin:     cstdinW,
+		Stdin:      ioutil.NopCloser(stdin),
+		CloseStdin: true,
+	}
+
+	c := NewConfig()
+	select {
+	case err := <-c.CopyStreams(context.Background(), cfg):
+		var rerr StdinReadError
+		assert.Check(t, errors.As(err, &rerr), "expected StdinReadError, got %v", err)
+		assert.Check(t, is.Equal(int64(2), rerr.Written))
+		assert.Check(t, errors.Is(err, boom), "underlying read error not preserved")
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the stdin copier")
+	}
+}
+
+func TestCopyEscapableWriteError(t *testing.T) {
+	boom := fmt.Errorf("pty gone")
+	stdinR, stdinW := io.Pipe()
+	go func() {
+		stdinW.Write([]byte("abcdefgh"))
+		stdinW.Close()
+	}()
+
+	written, err := copyEscapable(&failAfterWriter{limit: 3, err: boom}, stdinR, &AttachConfig{}, pools.Copy)
+	assert.Check(t, is.Equal(int64(3), written))
+	var werr StdinWriteError
+	assert.Check(t, errors.As(err, &werr), "expected StdinWriteError, got %v", err)
+	assert.Check(t, is.Equal(int64(3), werr.Written))
+	assert.Check(t, errors.Is(err, boom))
+}
+
+// recordingTransform records everything passing through it and forwards an
+// upper-cased copy, so tests can see both what a transform reads and where
+// its output lands relative to the other layers.
+type recordingTransform struct {
+	w   io.Writer
+	mu  sync.Mutex
+	rec bytes.Buffer
+}
+
+func (r *recordingTransform) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	r.rec.Write(p)
+	r.mu.Unlock()
+	if _, err := r.w.Write(bytes.ToUpper(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (r *recordingTransform) recorded() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rec.String()
+}
+
+func TestCopyStreamsTransformBeforeMux(t *testing.T) {
+	coutR, coutW := io.Pipe()
+	muxR, muxW := io.Pipe()
+
+	var rt *recordingTransform
+	cfg := &AttachConfig{
+		UseStdout:  true,
+		CStdout:    coutR,
+		MuxStreams: true,
+		MuxOutput:  muxW,
+		TransformStdout: func(w io.Writer) io.Writer {
+			rt = &recordingTransform{w: w}
+			return rt
+		},
+	}
+
+	c := NewConfig()
+	errs := c.CopyStreams(context.Background(), cfg)
+
+	var outBuf, errBuf bytes.Buffer
+	demuxDone := make(chan error, 1)
+	go func() {
+		_, err := stdcopy.StdCopy(&outBuf, &errBuf, muxR)
+		demuxDone <- err
+	}()
+
+	_, err := coutW.Write([]byte("hello"))
+	assert.NilError(t, err)
+	coutW.Close()
+
+	select {
+	case err := <-errs:
+		assert.NilError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the attach copier")
+	}
+	muxW.Close()
+	select {
+	case err := <-demuxDone:
+		assert.NilError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for StdCopy")
+	}
+
+	// the transform read the raw container bytes, with no stdcopy header in
+	// sight; what it wrote came out framed on the stdout stream
+	assert.Check(t, is.Equal("hello", rt.recorded()))
+	assert.Check(t, is.Equal("HELLO", outBuf.String()))
+	assert.Check(t, is.Equal("", errBuf.String()))
+}
+
+func TestCopyStreamsTransformStdin(t *testing.T) {
+	cstdinR, cstdinW := io.Pipe()
+
+	cfg := &AttachConfig{
+		UseStdin:   true,
+		CStdin:     cstdinW,
+		Stdin:      ioutil.NopCloser(bytes.NewReader([]byte("quiet"))),
+		CloseStdin: true,
+		TransformStdin: func(r io.Reader) io.Reader {
+			return readerFunc(func(p []byte) (int, error) {
+				n, err := r.Read(p)
+				copy(p[:n], bytes.ToUpper(p[:n]))
+				return n, err
+			})
+		},
+	}
+
+	c := NewConfig()
+	errs := c.CopyStreams(context.Background(), cfg)
+
+	received := make(chan []byte, 1)
+	go func() {
+		b, _ := ioutil.ReadAll(cstdinR)
+		received <- b
+	}()
+
+	select {
+	case err := <-errs:
+		assert.NilError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the stdin copier")
+	}
+	assert.Check(t, is.Equal("QUIET", string(<-received)))
+}
+
+type readerFunc func([]byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) { return f(p) }
+
+func TestCopyStreamsStdinRateLimit(t *testing.T) {
+	payload := bytes.Repeat([]byte{'p'}, 32*1024)
+	cstdinR, cstdinW := io.Pipe()
+
+	cfg := &AttachConfig{
+		UseStdin:       true,
+		CStdin:         cstdinW,
+		Stdin:          ioutil.NopCloser(readerOnly{bytes.NewReader(payload)}),
+		CloseStdin:     true,
+		StdinRateLimit: 64 * 1024, // bytes/sec
+		StdinRateBurst: 1024,
+	}
+
+	c := NewConfig()
+	start := time.Now()
+	errs := c.CopyStreams(context.Background(), cfg)
+
+	received := make(chan []byte, 1)
+	go func() {
+		b, _ := ioutil.ReadAll(cstdinR)
+		received <- b
+	}()
+
+	select {
+	case err := <-errs:
+		assert.NilError(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for the throttled stdin copier")
+	}
+	elapsed := time.Since(start)
+
+	// 32KB at 64KB/s with a 1KB burst should take roughly half a second;
+	// well under that means the limit was not applied
+	assert.Check(t, elapsed > 300*time.Millisecond, "copy finished in %v, rate limit not applied", elapsed)
+	assert.Check(t, is.DeepEqual(payload, <-received))
+}
+
+func TestCopyStreamsStdinRateLimitCancel(t *testing.T) {
+	stdinR, stdinW := io.Pipe()
+	defer stdinW.Close()
+	cstdinR, cstdinW := io.Pipe()
+
+	cfg := &AttachConfig{
+		UseStdin:       true,
+		CStdin:         cstdinW,
+		Stdin:          stdinR,
+		CloseStdin:     true,
+		StdinRateLimit: 100, // bytes/sec: the copier will be stuck waiting
+		StdinRateBurst: 10,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewConfig()
+	errs := c.CopyStreams(ctx, cfg)
+
+	go io.Copy(ioutil.Discard, cstdinR)
+	go stdinW.Write(bytes.Repeat([]byte{'q'}, 1024))
+
+	// give the copier time to drain its burst and start waiting for tokens
+	time.Sleep(200 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-errs:
+		assert.Check(t, is.Equal(context.Canceled, err))
+	case <-time.After(2 * time.Second):
+		t.Fatal("cancellation did not interrupt the throttled stdin copy")
+	}
+}
+
+func TestCopyStreamsBackpressureBlock(t *testing.T) {
+	c := NewConfig()
+
+	var fastBuf bytes.Buffer
+	fast := &AttachConfig{UseStdout: true, Stdout: &fastBuf}
+	c.AttachStreams(fast)
+
+	slowClient := &gatedWriter{entered: make(chan struct{}), gate: make(chan struct{})}
+	slow := &AttachConfig{UseStdout: true, Stdout: slowClient}
+	c.AttachStreams(slow)
+
+	fastErrs := c.CopyStreams(context.Background(), fast)
+	slowErrs := c.CopyStreams(context.Background(), slow)
+
+	// 2MB is past the pipe's buffering; the stalled attacher must throttle
+	// the broadcast path under the default policy
+	chunk := bytes.Repeat([]byte{'b'}, 64*1024)
+	writeDone := make(chan struct{})
+	go func() {
+		defer close(writeDone)
+		for i := 0; i < 32; i++ {
+			c.Stdout().Write(chunk)
+		}
+	}()
+
+	select {
+	case <-writeDone:
+		t.Fatal("writes completed despite a stalled attacher under the block policy")
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	close(slowClient.gate)
+	select {
+	case <-writeDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("writes still blocked after the attacher was unstalled")
+	}
+
+	assert.NilError(t, c.CloseStreams())
+	for _, errs := range []<-chan error{fastErrs, slowErrs} {
+		select {
+		case err := <-errs:
+			assert.NilError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the attach copiers")
+		}
+	}
+	assert.Check(t, is.Equal(32*len(chunk), fastBuf.Len()))
+}
+
+func TestCopyStreamsBackpressureDrop(t *testing.T) {
+	c := NewConfig()
+
+	var fastBuf bytes.Buffer
+	fast := &AttachConfig{UseStdout: true, Stdout: &fastBuf}
+	c.AttachStreams(fast)
+
+	slowClient := &gatedWriter{entered: make(chan struct{}), gate: make(chan struct{})}
+	statsC := make(chan CopyStats, 1)
+	slow := &AttachConfig{
+		UseStdout:         true,
+		Stdout:            slowClient,
+		Backpressure:      BackpressureDrop,
+		BackpressureLimit: 64,
+		StatsNotify:       func(s CopyStats) { statsC <- s },
+	}
+	c.AttachStreams(slow)
+
+	fastErrs := c.CopyStreams(context.Background(), fast)
+	slowErrs := c.CopyStreams(context.Background(), slow)
+
+	chunk := bytes.Repeat([]byte{'d'}, 32)
+	const chunks = 100
+	writeDone := make(chan struct{})
+	go func() {
+		defer close(writeDone)
+		for i := 0; i < chunks; i++ {
+			c.Stdout().Write(chunk)
+		}
+	}()
+
+	// the stalled attacher must not hold up the broadcast path
+	select {
+	case <-writeDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stalled attacher blocked the broadcast path under the drop policy")
+	}
+
+	close(slowClient.gate)
+	assert.NilError(t, c.CloseStreams())
+
+	for _, errs := range []<-chan error{fastErrs, slowErrs} {
+		select {
+		case err := <-errs:
+			assert.NilError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the attach copiers")
+		}
+	}
+
+	// the fast attacher saw everything; the slow one missed data, and every
+	// byte is accounted for as either delivered or dropped
+	assert.Check(t, is.Equal(chunks*len(chunk), fastBuf.Len()))
+	select {
+	case stats := <-statsC:
+		assert.Check(t, stats.StdoutDropped > 0, "expected dropped bytes to be recorded")
+		assert.Check(t, is.Equal(int64(chunks*len(chunk)), stats.StdoutBytes+stats.StdoutDropped))
+	case <-time.After(5 * time.Second):
+		t.Fatal("stats were not reported")
+	}
+}
+
+func TestCopyStreamsBackpressureBufferLimit(t *testing.T) {
+	c := NewConfig()
+
+	var fastBuf bytes.Buffer
+	fast := &AttachConfig{UseStdout: true, Stdout: &fastBuf}
+	c.AttachStreams(fast)
+
+	slowClient := &gatedWriter{entered: make(chan struct{}), gate: make(chan struct{})}
+	slow := &AttachConfig{
+		UseStdout:         true,
+		Stdout:            slowClient,
+		Backpressure:      BackpressureBufferLimit,
+		BackpressureLimit: 64,
+	}
+	c.AttachStreams(slow)
+
+	fastErrs := c.CopyStreams(context.Background(), fast)
+	slowErrs := c.CopyStreams(context.Background(), slow)
+
+	chunk := bytes.Repeat([]byte{'l'}, 32)
+	const chunks = 10
+	writeDone := make(chan struct{})
+	go func() {
+		defer close(writeDone)
+		for i := 0; i < chunks; i++ {
+			c.Stdout().Write(chunk)
+		}
+	}()
+	select {
+	case <-writeDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stalled attacher blocked the broadcast path under the buffer-limit policy")
+	}
+
+	close(slowClient.gate)
+
+	// the slow attacher is disconnected with the typed error
+	select {
+	case err := <-slowErrs:
+		var bpe BackpressureExceededError
+		assert.Check(t, errors.As(err, &bpe), "expected BackpressureExceededError, got %v", err)
+		assert.Check(t, is.Equal(64, bpe.Limit))
+	case <-time.After(5 * time.Second):
+		t.Fatal("slow attacher was not disconnected")
+	}
+
+	// the fast attacher is unaffected
+	assert.NilError(t, c.CloseStreams())
+	select {
+	case err := <-fastErrs:
+		assert.NilError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the fast attach copier")
+	}
+	assert.Check(t, is.Equal(chunks*len(chunk), fastBuf.Len()))
+}
+
+func TestCopyStreamsDrainOnCancel(t *testing.T) {
+	run := func(t *testing.T, drain time.Duration) string {
+		coutR, coutW := io.Pipe()
+		client := &gatedWriter{
+			entered: make(chan struct{}),
+			gate:    make(chan struct{}),
+		}
+		cfg := &AttachConfig{
+			UseStdout:    true,
+			CStdout:      coutR,
+			Stdout:       client,
+			DrainTimeout: drain,
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		c := NewConfig()
+		errs := c.CopyStreams(ctx, cfg)
+
+		// the container's last gasp: a write the copier has picked up but
+		// not yet delivered, and one still sitting in the pipe
+		go func() {
+			coutW.Write([]byte("first"))
+			coutW.Write([]byte("final words"))
+			coutW.Close()
+		}()
+
+		<-client.entered
+		time.Sleep(50 * time.Millisecond) // let the second write queue up
+		cancel()
+		close(client.gate)
+
+		select {
+		case err := <-errs:
+			assert.Check(t, is.Equal(context.Canceled, err))
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the attach copier")
+		}
+		return client.buf.String()
+	}
+
+	t.Run("drain window", func(t *testing.T) {
+		got := run(t, 2*time.Second)
+		assert.Check(t, is.Equal("firstfinal words", got))
+	})
+	t.Run("no drain", func(t *testing.T) {
+		// without a drain window the force-close races the final output;
+		// the first chunk was already in flight and must still land
+		got := run(t, 0)
+		assert.Check(t, is.Contains(got, "first"))
+	})
+}
+
+func TestCopyStreamsHalfCloseStdin(t *testing.T) {
+	stdinR, stdinW := io.Pipe()
+	cstdinR, cstdinW := io.Pipe()
+	coutR, coutW := io.Pipe()
+
+	var stdout bytes.Buffer
+	cfg := &AttachConfig{
+		UseStdin:       true,
+		UseStdout:      true,
+		CStdin:         cstdinW,
+		CStdout:        coutR,
+		Stdin:          stdinR,
+		Stdout:         &stdout,
+		HalfCloseStdin: true,
+	}
+
+	c := NewConfig()
+	errs := c.CopyStreams(context.Background(), cfg)
+
+	// send some input and signal we are done with it
+	go func() {
+		stdinW.Write([]byte("input"))
+		stdinW.Close()
+	}()
+	received, err := ioutil.ReadAll(cstdinR)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("input", string(received)))
+
+	// the container's stdin is closed, but output must still flow
+	_, err = coutW.Write([]byte("after stdin EOF"))
+	assert.NilError(t, err)
+	coutW.Close()
+
+	select {
+	case err := <-errs:
+		assert.NilError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the attach copiers")
+	}
+	assert.Check(t, is.Equal("after stdin EOF", stdout.String()))
+}
+
+func TestCopyStreamsHalfCloseCancel(t *testing.T) {
+	stdinR, stdinW := io.Pipe()
+	cstdinR, cstdinW := io.Pipe()
+	coutR, coutW := io.Pipe()
+	defer coutW.Close()
+
+	cfg := &AttachConfig{
+		UseStdin:       true,
+		UseStdout:      true,
+		CStdin:         cstdinW,
+		CStdout:        coutR,
+		Stdin:          stdinR,
+		Stdout:         ioutil.Discard,
+		HalfCloseStdin: true,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewConfig()
+	errs := c.CopyStreams(ctx, cfg)
+
+	go io.Copy(ioutil.Discard, cstdinR)
+	stdinW.Close()
+
+	// stdin is half-closed; the output side stays up until the context ends
+	select {
+	case err := <-errs:
+		t.Fatalf("session ended on stdin EOF: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	cancel()
+	select {
+	case err := <-errs:
+		assert.Check(t, is.Equal(context.Canceled, err))
+	case <-time.After(5 * time.Second):
+		t.Fatal("copiers were not torn down on context cancellation")
+	}
+}
+
+func TestAttachReplayTail(t *testing.T) {
+	c := NewConfig()
+	c.EnableOutputReplay(8)
+
+	// output written long before the attach; only the tail is retained
+	_, err := c.Stdout().Write([]byte("0123456789abcdef"))
+	assert.NilError(t, err)
+
+	var stdout bytes.Buffer
+	cfg := &AttachConfig{
+		UseStdout:    true,
+		ReplayOutput: true,
+		Stdout:       &stdout,
+	}
+	c.AttachStreams(cfg)
+	errs := c.CopyStreams(context.Background(), cfg)
+
+	// some live output after the attach, then the container goes away
+	_, err = c.Stdout().Write([]byte("-live"))
+	assert.NilError(t, err)
+	assert.NilError(t, c.CloseStreams())
+
+	select {
+	case err := <-errs:
+		assert.NilError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the attach copier")
+	}
+	assert.Check(t, is.Equal("89abcdef-live", stdout.String()))
+}
+
+func TestAttachReplayMux(t *testing.T) {
+	c := NewConfig()
+	c.EnableOutputReplay(32)
+
+	_, err := c.Stdout().Write([]byte("old stdout"))
+	assert.NilError(t, err)
+	_, err = c.Stderr().Write([]byte("old stderr"))
+	assert.NilError(t, err)
+
+	muxR, muxW := io.Pipe()
+	cfg := &AttachConfig{
+		UseStdout:    true,
+		UseStderr:    true,
+		ReplayOutput: true,
+		MuxStreams:   true,
+		MuxOutput:    muxW,
+	}
+	c.AttachStreams(cfg)
+	errs := c.CopyStreams(context.Background(), cfg)
+
+	var outBuf, errBuf bytes.Buffer
+	demuxDone := make(chan error, 1)
+	go func() {
+		_, err := stdcopy.StdCopy(&outBuf, &errBuf, muxR)
+		demuxDone <- err
+	}()
+
+	assert.NilError(t, c.CloseStreams())
+	select {
+	case err := <-errs:
+		assert.NilError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the attach copiers")
+	}
+	muxW.Close()
+	select {
+	case err := <-demuxDone:
+		assert.NilError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for StdCopy")
+	}
+
+	// the replayed tails arrive on their own streams, correctly framed
+	assert.Check(t, is.Equal("old stdout", outBuf.String()))
+	assert.Check(t, is.Equal("old stderr", errBuf.String()))
+}
+
+func TestAttachReplayHandoffRace(t *testing.T) {
+	c := NewConfig()
+	c.EnableOutputReplay(1024)
+
+	// write continuously while the attach happens, so the snapshot/live
+	// handoff lands mid-stream
+	var want bytes.Buffer
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		for i := 0; i < 2000; i++ {
+			b := []byte(fmt.Sprintf("%06d\n", i))
+			want.Write(b)
+			if _, err := c.Stdout().Write(b); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+
+	time.Sleep(5 * time.Millisecond) // let some output accumulate first
+
+	var stdout bytes.Buffer
+	cfg := &AttachConfig{
+		UseStdout:    true,
+		ReplayOutput: true,
+		Stdout:       &stdout,
+	}
+	c.AttachStreams(cfg)
+	errs := c.CopyStreams(context.Background(), cfg)
+
+	<-written
+	assert.NilError(t, c.CloseStreams())
+
+	select {
+	case err := <-errs:
+		assert.NilError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the attach copier")
+	}
+
+	// the client must see a contiguous suffix of everything written: any
+	// dropped or duplicated byte around the handoff breaks this
+	received := stdout.Bytes()
+	assert.Check(t, len(received) >= 1024, "expected at least the retained tail, got %d bytes", len(received))
+	assert.Check(t, bytes.HasSuffix(want.Bytes(), received),
+		"received output is not a suffix of what was written")
+}
+
+func TestCopyStreamsStats(t *testing.T) {
+	stdinR, stdinW := io.Pipe()
+	cstdinR, cstdinW := io.Pipe()
+	coutR, coutW := io.Pipe()
+	cerrR, cerrW := io.Pipe()
+
+	var stdout, stderr bytes.Buffer
+	statsC := make(chan CopyStats, 1)
+	cfg := &AttachConfig{
+		UseStdin:    true,
+		UseStdout:   true,
+		UseStderr:   true,
+		CStdin:      cstdinW,
+		CStdout:     coutR,
+		CStderr:     cerrR,
+		Stdin:       stdinR,
+		Stdout:      &stdout,
+		Stderr:      &stderr,
+		CloseStdin:  true,
+		StatsNotify: func(s CopyStats) { statsC <- s },
+	}
+
+	c := NewConfig()
+	errs := c.CopyStreams(context.Background(), cfg)
+
+	go io.Copy(ioutil.Discard, cstdinR)
+
+	const (
+		stdinLen  = 12
+		stdoutLen = 1000
+		stderrLen = 77
+	)
+	_, err := stdinW.Write(bytes.Repeat([]byte{'i'}, stdinLen))
+	assert.NilError(t, err)
+	stdinW.Close()
+	_, err = coutW.Write(bytes.Repeat([]byte{'o'}, stdoutLen))
+	assert.NilError(t, err)
+	coutW.Close()
+	_, err = cerrW.Write(bytes.Repeat([]byte{'e'}, stderrLen))
+	assert.NilError(t, err)
+	cerrW.Close()
+
+	select {
+	case err := <-errs:
+		assert.NilError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the attach copiers")
+	}
+
+	select {
+	case stats := <-statsC:
+		assert.Check(t, is.Equal(int64(stdinLen), stats.StdinBytes))
+		assert.Check(t, is.Equal(int64(stdoutLen), stats.StdoutBytes))
+		assert.Check(t, is.Equal(int64(stderrLen), stats.StderrBytes))
+	case <-time.After(5 * time.Second):
+		t.Fatal("stats were not reported")
+	}
+}
+
+func TestCopyStreamsStatsOnDetach(t *testing.T) {
+	stdinR, stdinW := io.Pipe()
+	cstdinR, cstdinW := io.Pipe()
+	coutR, _ := io.Pipe()
+
+	statsC := make(chan CopyStats, 1)
+	cfg := &AttachConfig{
+		TTY:          true,
+		UseStdin:     true,
+		UseStdout:    true,
+		CStdin:       cstdinW,
+		CStdout:      coutR,
+		Stdin:        stdinR,
+		Stdout:       ioutil.Discard,
+		DetachNotify: func() {},
+		StatsNotify:  func(s CopyStats) { statsC <- s },
+	}
+
+	c := NewConfig()
+	errs := c.CopyStreams(context.Background(), cfg)
+
+	go io.Copy(ioutil.Discard, cstdinR)
+
+	// three real keys, then the escape sequence, which must not be counted
+	_, err := stdinW.Write([]byte{'a', 'b', 'c', 16, 17})
+	assert.NilError(t, err)
+
+	select {
+	case err := <-errs:
+		assert.NilError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("copiers did not shut down after detach")
+	}
+
+	select {
+	case stats := <-statsC:
+		assert.Check(t, is.Equal(int64(3), stats.StdinBytes))
+	case <-time.After(5 * time.Second):
+		t.Fatal("stats were not reported")
+	}
+}
+
+func TestCopyStreamsIdleTimeout(t *testing.T) {
+	// a silent container: pipes are open but nothing ever moves
+	coutR, coutW := io.Pipe()
+	defer coutW.Close()
+	cstdinR, cstdinW := io.Pipe()
+	defer cstdinR.Close()
+	stdinR, stdinW := io.Pipe()
+	defer stdinW.Close()
+
+	cfg := &AttachConfig{
+		UseStdin:    true,
+		UseStdout:   true,
+		CStdin:      cstdinW,
+		CStdout:     coutR,
+		Stdin:       stdinR,
+		Stdout:      ioutil.Discard,
+		IdleTimeout: 50 * time.Millisecond,
+	}
+
+	c := NewConfig()
+	errs := c.CopyStreams(context.Background(), cfg)
+
+	select {
+	case err := <-errs:
+		var ierr IdleTimeoutError
+		assert.Check(t, errors.As(err, &ierr), "expected IdleTimeoutError, got %v", err)
+		assert.Check(t, is.Equal(cfg.IdleTimeout, ierr.Timeout))
+		assert.Check(t, !errors.Is(err, context.Canceled), "idle timeout must not look like a canceled context")
+	case <-time.After(5 * time.Second):
+		t.Fatal("copiers did not terminate on idle timeout")
+	}
+}
+
+func TestCopyStreamsIdleTimeoutResetByActivity(t *testing.T) {
+	coutR, coutW := io.Pipe()
+	cstdinR, cstdinW := io.Pipe()
+	defer cstdinR.Close()
+	stdinR, stdinW := io.Pipe()
+	defer stdinW.Close()
+
+	cfg := &AttachConfig{
+		UseStdin:    true,
+		UseStdout:   true,
+		CStdin:      cstdinW,
+		CStdout:     coutR,
+		Stdin:       stdinR,
+		Stdout:      ioutil.Discard,
+		IdleTimeout: 250 * time.Millisecond,
+	}
+
+	c := NewConfig()
+	errs := c.CopyStreams(context.Background(), cfg)
+
+	// keep the stream busy well past the timeout; the deadline must keep
+	// sliding as long as bytes move
+	for i := 0; i < 10; i++ {
+		if _, err := coutW.Write([]byte("tick")); err != nil {
+			t.Fatalf("stream torn down while active: %v", err)
+		}
+		select {
+		case err := <-errs:
+			t.Fatalf("idle timeout fired while the stream was active: %v", err)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	// now go silent
+	select {
+	case err := <-errs:
+		var ierr IdleTimeoutError
+		assert.Check(t, errors.As(err, &ierr), "expected IdleTimeoutError, got %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("copiers did not terminate after the stream went silent")
+	}
+}
+
+// writerOnly and readerOnly hide the optional io.ReaderFrom/io.WriterTo fast
+// paths so that io.CopyBuffer actually copies through the configured buffer.
+type writerOnly struct{ io.Writer }
+type readerOnly struct{ io.Reader }
+
+func TestCopyStreamsBufferSizes(t *testing.T) {
+	payload := make([]byte, 64*1024+13) // deliberately not buffer-aligned
+	for i := range payload {
+		payload[i] = byte(i % 251)
+	}
+
+	// 1 byte and non-power-of-two sizes must not corrupt or truncate the
+	// stream; oversized requests are clamped rather than rejected.
+	for _, size := range []int{1, 7, 1000, maxCopyBufferSize + 1} {
+		t.Run(fmt.Sprintf("size=%d", size), func(t *testing.T) {
+			coutR, coutW := io.Pipe()
+
+			var stdout bytes.Buffer
+			cfg := &AttachConfig{
+				UseStdout:  true,
+				CStdout:    coutR,
+				Stdout:     writerOnly{&stdout},
+				BufferSize: size,
+			}
+
+			c := NewConfig()
+			errs := c.CopyStreams(context.Background(), cfg)
+
+			go func() {
+				coutW.Write(payload)
+				coutW.Close()
+			}()
+
+			select {
+			case err := <-errs:
+				assert.NilError(t, err)
+			case <-time.After(10 * time.Second):
+				t.Fatal("timed out waiting for the attach copier")
+			}
+			assert.Check(t, is.DeepEqual(payload, stdout.Bytes()))
+		})
+	}
+}
+
+func BenchmarkCopyStreamsThroughput(b *testing.B) {
+	payload := make([]byte, 16*1024*1024)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+
+	for _, bench := range []struct {
+		name string
+		size int
+	}{
+		{"default", 0},
+		{"1K", 1 << 10},
+		{"4K", 1 << 12},
+		{"64K", 1 << 16},
+		{"1M", 1 << 20},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			b.SetBytes(int64(len(payload)))
+			for i := 0; i < b.N; i++ {
+				cfg := &AttachConfig{
+					UseStdout:  true,
+					CStdout:    ioutil.NopCloser(readerOnly{bytes.NewReader(payload)}),
+					Stdout:     writerOnly{ioutil.Discard},
+					BufferSize: bench.size,
+				}
+				c := NewConfig()
+				if err := <-c.CopyStreams(context.Background(), cfg); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestCopyStreamsInvalidDetachKeys(t *testing.T) {
+	stdinR, stdinW := io.Pipe()
+	defer stdinW.Close()
+	cstdinR, cstdinW := io.Pipe()
+	defer cstdinR.Close()
+
+	cfg := &AttachConfig{
+		TTY:        true,
+		UseStdin:   true,
+		CStdin:     cstdinW,
+		Stdin:      stdinR,
+		DetachKeys: bytes.Repeat([]byte{1}, maxDetachKeyLen+1),
+	}
+
+	c := NewConfig()
+	select {
+	case err := <-c.CopyStreams(context.Background(), cfg):
+		assert.ErrorContains(t, err, "exceeds the maximum")
+	case <-time.After(5 * time.Second):
+		t.Fatal("invalid detach keys were not rejected")
+	}
+}
+
+func waitAttachResult(t *testing.T, results <-chan AttachResult) AttachResult {
+	t.Helper()
+	select {
+	case res := <-results:
+		return res
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the attach result")
+		return AttachResult{}
+	}
+}
+
+func TestCopyStreamsResultStreamsClosed(t *testing.T) {
+	coutR, coutW := io.Pipe()
+
+	var stdout bytes.Buffer
+	cfg := &AttachConfig{
+		UseStdout: true,
+		CStdout:   coutR,
+		Stdout:    &stdout,
+	}
+
+	c := NewConfig()
+	results := c.CopyStreamsResult(context.Background(), cfg)
+
+	_, err := coutW.Write([]byte("hello"))
+	assert.NilError(t, err)
+	coutW.Close()
+
+	res := waitAttachResult(t, results)
+	assert.Check(t, is.Equal(ReasonStreamsClosed, res.Reason))
+	assert.NilError(t, res.Err)
+	assert.NilError(t, res.StdoutErr)
+	assert.Check(t, is.Equal(int64(5), res.Stats.StdoutBytes))
+	assert.Check(t, res.Duration > 0)
+}
+
+func TestCopyStreamsResultDetach(t *testing.T) {
+	stdinR, stdinW := io.Pipe()
+	cstdinR, cstdinW := io.Pipe()
+	coutR, _ := io.Pipe()
+
+	cfg := &AttachConfig{
+		TTY:       true,
+		UseStdin:  true,
+		UseStdout: true,
+		CStdin:    cstdinW,
+		CStdout:   coutR,
+		Stdin:     stdinR,
+		Stdout:    ioutil.Discard,
+	}
+
+	c := NewConfig()
+	results := c.CopyStreamsResult(context.Background(), cfg)
+
+	go io.Copy(ioutil.Discard, cstdinR)
+
+	_, err := stdinW.Write([]byte{16, 17}) // default ctrl-p,ctrl-q
+	assert.NilError(t, err)
+
+	res := waitAttachResult(t, results)
+	assert.Check(t, is.Equal(ReasonDetach, res.Reason))
+	assert.NilError(t, res.Err)
+}
+
+func TestCopyStreamsResultCanceled(t *testing.T) {
+	coutR, coutW := io.Pipe()
+	defer coutW.Close()
+
+	cfg := &AttachConfig{
+		UseStdout: true,
+		CStdout:   coutR,
+		Stdout:    ioutil.Discard,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := NewConfig()
+	results := c.CopyStreamsResult(ctx, cfg)
+	cancel()
+
+	res := waitAttachResult(t, results)
+	assert.Check(t, is.Equal(ReasonCanceled, res.Reason))
+	assert.Check(t, errors.Is(res.Err, context.Canceled), "expected context.Canceled, got %v", res.Err)
+}
+
+func TestCopyStreamsResultIdleTimeout(t *testing.T) {
+	coutR, coutW := io.Pipe()
+	defer coutW.Close()
+
+	cfg := &AttachConfig{
+		UseStdout:   true,
+		CStdout:     coutR,
+		Stdout:      ioutil.Discard,
+		IdleTimeout: 50 * time.Millisecond,
+	}
+
+	c := NewConfig()
+	results := c.CopyStreamsResult(context.Background(), cfg)
+
+	res := waitAttachResult(t, results)
+	assert.Check(t, is.Equal(ReasonIdleTimeout, res.Reason))
+	var ierr IdleTimeoutError
+	assert.Check(t, errors.As(res.Err, &ierr), "expected IdleTimeoutError, got %v", res.Err)
+}
+
+func TestCopyStreamsResultError(t *testing.T) {
+	coutR, coutW := io.Pipe()
+	defer coutW.Close()
+
+	werr := errors.New("client gone")
+	cfg := &AttachConfig{
+		UseStdout: true,
+		CStdout:   coutR,
+		Stdout:    &failAfterWriter{limit: 0, err: werr},
+	}
+
+	c := NewConfig()
+	results := c.CopyStreamsResult(context.Background(), cfg)
+
+	go coutW.Write([]byte("output"))
+
+	res := waitAttachResult(t, results)
+	assert.Check(t, is.Equal(ReasonError, res.Reason))
+	assert.Check(t, errors.Is(res.Err, werr), "expected the stdout write error, got %v", res.Err)
+	assert.Check(t, errors.Is(res.StdoutErr, werr), "expected StdoutErr to carry the write error, got %v", res.StdoutErr)
+}
+
+func TestAttachStreamsTTYStderrRejected(t *testing.T) {
+	c := NewConfig()
+	cfg := &AttachConfig{
+		TTY:       true,
+		UseStdout: true,
+		UseStderr: true,
+	}
+
+	err := c.AttachStreams(cfg)
+	assert.Check(t, errdefs.IsInvalidParameter(err), "expected an invalid parameter error, got %v", err)
+	assert.Check(t, is.Nil(cfg.CStdout), "no pipe may be wired up on rejection")
+	assert.Check(t, is.Nil(cfg.CStderr), "no pipe may be wired up on rejection")
+}
+
+func TestCopyStreamsMergeStderr(t *testing.T) {
+	c := NewConfig()
+
+	var stdout, stderr bytes.Buffer
+	cfg := &AttachConfig{
+		TTY:         true,
+		UseStdout:   true,
+		UseStderr:   true,
+		MergeStderr: true,
+		Stdout:      &stdout,
+		Stderr:      &stderr,
+	}
+
+	assert.NilError(t, c.AttachStreams(cfg))
+	assert.Check(t, is.Nil(cfg.CStderr), "a TTY has no separate stderr pipe to attach")
+
+	errs := c.CopyStreams(context.Background(), cfg)
+
+	_, err := c.Stdout().Write([]byte("merged output"))
+	assert.NilError(t, err)
+	assert.NilError(t, c.CloseStreams())
+
+	select {
+	case err := <-errs:
+		assert.NilError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the attach copiers")
+	}
+
+	assert.Check(t, is.Equal("merged output", stdout.String()))
+	assert.Check(t, is.Equal("merged output", stderr.String()))
+}
+
+func TestCopyStreamsMergeStderrOnly(t *testing.T) {
+	c := NewConfig()
+
+	var stderr bytes.Buffer
+	cfg := &AttachConfig{
+		TTY:         true,
+		UseStderr:   true,
+		MergeStderr: true,
+		Stderr:      &stderr,
+	}
+
+	assert.NilError(t, c.AttachStreams(cfg))
+
+	errs := c.CopyStreams(context.Background(), cfg)
+
+	_, err := c.Stdout().Write([]byte("tty output"))
+	assert.NilError(t, err)
+	assert.NilError(t, c.CloseStreams())
+
+	select {
+	case err := <-errs:
+		assert.NilError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the attach copiers")
+	}
+
+	assert.Check(t, is.Equal("tty output", stderr.String()))
+}
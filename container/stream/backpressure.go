@@ -0,0 +1,121 @@
+package stream // import "github.com/docker/docker/container/stream"
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// BackpressurePolicy selects how an attacher that cannot keep up with the
+// container's output is handled when several clients share one Config.
+type BackpressurePolicy int
+
+const (
+	// BackpressureBlock throttles the container's output until the attacher
+	// catches up. Because the broadcast write path is shared, this also
+	// stalls every other attacher. This is the historical behavior and the
+	// default.
+	BackpressureBlock BackpressurePolicy = iota
+	// BackpressureDrop discards output that exceeds the attacher's budget.
+	// The attacher misses data but nobody else is slowed down; CopyStats
+	// records how many bytes were dropped.
+	BackpressureDrop
+	// BackpressureBufferLimit buffers up to the attacher's budget and then
+	// disconnects it with a BackpressureExceededError.
+	BackpressureBufferLimit
+)
+
+// defaultBackpressureLimit is the per-attacher byte budget applied when
+// AttachConfig.BackpressureLimit is zero.
+const defaultBackpressureLimit = 1 << 20 // 1MB
+
+// BackpressureExceededError ends an attach under BackpressureBufferLimit
+// when the attacher falls further behind than its buffer budget.
+type BackpressureExceededError struct {
+	// Limit is the byte budget that was exceeded.
+	Limit int
+}
+
+func (e BackpressureExceededError) Error() string {
+	return fmt.Sprintf("attached client fell more than %d bytes behind the container's output", e.Limit)
+}
+
+// backpressurePipe is the per-attacher pipe between the output broadcaster
+// and the attach copier for the non-blocking policies. Unlike a BytesPipe it
+// never blocks the writer: output beyond the budget is either dropped or
+// ends the pipe with a typed error, per the policy.
+type backpressurePipe struct {
+	policy BackpressurePolicy
+	limit  int
+
+	mu       sync.Mutex
+	wait     *sync.Cond
+	buf      bytes.Buffer
+	closeErr error
+	dropped  int64
+}
+
+func newBackpressurePipe(policy BackpressurePolicy, limit int) *backpressurePipe {
+	if limit <= 0 {
+		limit = defaultBackpressureLimit
+	}
+	p := &backpressurePipe{policy: policy, limit: limit}
+	p.wait = sync.NewCond(&p.mu)
+	return p
+}
+
+func (p *backpressurePipe) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	if p.closeErr != nil {
+		p.mu.Unlock()
+		return 0, io.ErrClosedPipe
+	}
+	if p.buf.Len()+len(b) > p.limit {
+		if p.policy == BackpressureDrop {
+			p.dropped += int64(len(b))
+			p.mu.Unlock()
+			return len(b), nil
+		}
+		err := BackpressureExceededError{Limit: p.limit}
+		// the copier drains what is buffered, then reports the typed error
+		p.closeErr = err
+		p.wait.Broadcast()
+		p.mu.Unlock()
+		return 0, err
+	}
+	p.buf.Write(b)
+	p.wait.Broadcast()
+	p.mu.Unlock()
+	return len(b), nil
+}
+
+func (p *backpressurePipe) Read(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for p.buf.Len() == 0 {
+		if p.closeErr != nil {
+			return 0, p.closeErr
+		}
+		p.wait.Wait()
+	}
+	n, _ := p.buf.Read(b)
+	return n, nil
+}
+
+func (p *backpressurePipe) Close() error {
+	p.mu.Lock()
+	if p.closeErr == nil {
+		p.closeErr = io.EOF
+	}
+	p.wait.Broadcast()
+	p.mu.Unlock()
+	return nil
+}
+
+// Dropped returns how many bytes the drop policy has discarded so far.
+func (p *backpressurePipe) Dropped() int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.dropped
+}
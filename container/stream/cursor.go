@@ -0,0 +1,160 @@
+package stream // import "github.com/docker/docker/container/stream"
+
+import (
+	"io"
+	"sync"
+)
+
+// streamCursor numbers every byte written to one of a container's output
+// streams with a monotonic sequence, so that a logs reader and a live attach
+// can agree on an exact splice point. It also retains output on behalf of
+// pinned handoffs and forwards live writes to followers registered by
+// resume; retention, registration and the sequence all move under one lock,
+// so a follower sees every byte from its splice point exactly once.
+type streamCursor struct {
+	mu        sync.Mutex
+	seq       uint64
+	pins      []*cursorPin
+	followers []io.WriteCloser
+	closed    bool
+}
+
+// cursorPin retains all output written since it was taken, starting at
+// sequence seq, until the handoff that owns it resumes or is canceled.
+type cursorPin struct {
+	seq uint64
+	buf []byte
+}
+
+// Write advances the sequence, retains p for the active pins and forwards it
+// to the followers. Failed followers are evicted, mirroring
+// broadcaster.Unbuffered.
+func (c *streamCursor) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return 0, io.ErrClosedPipe
+	}
+	c.seq += uint64(len(p))
+
+	for _, pin := range c.pins {
+		pin.buf = append(pin.buf, p...)
+	}
+
+	var evict []int
+	for i, w := range c.followers {
+		if n, err := w.Write(p); err != nil || n != len(p) {
+			evict = append(evict, i)
+		}
+	}
+	for n, i := range evict {
+		c.followers = append(c.followers[:i-n], c.followers[i-n+1:]...)
+	}
+	return len(p), nil
+}
+
+// pin starts retaining output and returns the pin, whose seq is the cursor
+// position retention begins at.
+func (c *streamCursor) pin() *cursorPin {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p := &cursorPin{seq: c.seq}
+	c.pins = append(c.pins, p)
+	return p
+}
+
+func (c *streamCursor) unpin(p *cursorPin) {
+	for i, cur := range c.pins {
+		if cur == p {
+			c.pins = append(c.pins[:i], c.pins[i+1:]...)
+			return
+		}
+	}
+}
+
+// resume releases pin and registers w to go live at sequence from: the
+// retained bytes at or past from are returned for replay and everything
+// later arrives through w. A from below the pin is clamped to the pin, and
+// one past the current sequence starts immediately. When the cursor is
+// already closed, w is closed so the reader sees the replay's end as the end
+// of the stream.
+func (c *streamCursor) resume(p *cursorPin, w io.WriteCloser, from uint64) []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.unpin(p)
+	if w == nil {
+		return nil
+	}
+
+	skip := uint64(0)
+	if from > p.seq {
+		skip = from - p.seq
+	}
+	if max := uint64(len(p.buf)); skip > max {
+		skip = max
+	}
+	replay := append([]byte(nil), p.buf[skip:]...)
+
+	if c.closed {
+		w.Close()
+	} else {
+		c.followers = append(c.followers, w)
+	}
+	return replay
+}
+
+// Sequence returns the cursor's current position.
+func (c *streamCursor) Sequence() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.seq
+}
+
+// Close ends the live stream for all followers and drops any retained
+// output.
+func (c *streamCursor) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	for _, w := range c.followers {
+		w.Close()
+	}
+	c.followers = nil
+	c.pins = nil
+	return nil
+}
+
+// OutputHandoff coordinates a gapless switch from reading a container's logs
+// to live-attached streaming. Taking the handoff (Config.BeginOutputHandoff)
+// pins the output streams so everything written from that moment on is
+// retained; the caller then reads the log tail at its own pace and attaches
+// with AttachConfig.Handoff, at which point the retained output is spliced
+// in ahead of the live stream. A handoff that will not be attached must be
+// canceled to release the retained output.
+type OutputHandoff struct {
+	// StdoutSeq and StderrSeq are the sequence cursors at which retention
+	// began. Output before them is only available from the logs.
+	StdoutSeq, StderrSeq uint64
+
+	stdout, stderr         *streamCursor
+	stdoutPin, stderrPin   *cursorPin
+	stdoutPipe, stderrPipe io.ReadWriteCloser
+}
+
+// resume goes live on both streams, splicing at the given cursors, and
+// returns the retained bytes to replay ahead of each live stream.
+func (h *OutputHandoff) resume(stdoutFrom, stderrFrom uint64) (replayStdout, replayStderr []byte) {
+	replayStdout = h.stdout.resume(h.stdoutPin, h.stdoutPipe, stdoutFrom)
+	replayStderr = h.stderr.resume(h.stderrPin, h.stderrPipe, stderrFrom)
+	return replayStdout, replayStderr
+}
+
+// Cancel releases the retained output without attaching.
+func (h *OutputHandoff) Cancel() {
+	h.stdout.mu.Lock()
+	h.stdout.unpin(h.stdoutPin)
+	h.stdout.mu.Unlock()
+	h.stderr.mu.Lock()
+	h.stderr.unpin(h.stderrPin)
+	h.stderr.mu.Unlock()
+}
@@ -0,0 +1,152 @@
+package stream // import "github.com/docker/docker/container/stream"
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+// startContinuousWriter keeps writing numbered chunks to the config's stdout
+// until stop is closed, recording every byte before it is written so the
+// recorded stream is always at or ahead of the cursor.
+func startContinuousWriter(c *Config, mu *sync.Mutex, written *bytes.Buffer, stop chan struct{}) chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for seq := 0; ; seq++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			chunk := []byte(fmt.Sprintf("chunk-%04d;", seq))
+			mu.Lock()
+			written.Write(chunk)
+			mu.Unlock()
+			c.Stdout().Write(chunk)
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	return done
+}
+
+func TestOutputHandoffContinuity(t *testing.T) {
+	c := NewConfig()
+
+	var mu sync.Mutex
+	var written bytes.Buffer
+	stop := make(chan struct{})
+	writerDone := startContinuousWriter(c, &mu, &written, stop)
+
+	// let output accumulate before the handoff is taken
+	time.Sleep(20 * time.Millisecond)
+	h := c.BeginOutputHandoff()
+	assert.Check(t, h.StdoutSeq > 0, "the writer should have moved the cursor")
+
+	// "read the logs": everything up to the handoff's pin
+	mu.Lock()
+	logs := append([]byte(nil), written.Bytes()[:h.StdoutSeq]...)
+	mu.Unlock()
+
+	// the writer keeps going while the log tail is being delivered
+	time.Sleep(20 * time.Millisecond)
+
+	var live bytes.Buffer
+	cfg := &AttachConfig{UseStdout: true, Stdout: &live, Handoff: h}
+	assert.NilError(t, c.AttachStreams(cfg))
+	results := c.CopyStreamsResult(context.Background(), cfg)
+
+	time.Sleep(20 * time.Millisecond)
+	close(stop)
+	<-writerDone
+	assert.NilError(t, c.CloseStreams())
+
+	res := waitAttachResult(t, results)
+	assert.NilError(t, res.Err)
+
+	mu.Lock()
+	all := append([]byte(nil), written.Bytes()...)
+	mu.Unlock()
+	got := append(logs, live.Bytes()...)
+	assert.Check(t, is.DeepEqual(all, got), "logs followed by the live stream must be byte-exact")
+}
+
+func TestOutputHandoffExplicitCursor(t *testing.T) {
+	c := NewConfig()
+
+	var mu sync.Mutex
+	var written bytes.Buffer
+	stop := make(chan struct{})
+	writerDone := startContinuousWriter(c, &mu, &written, stop)
+
+	time.Sleep(20 * time.Millisecond)
+	h := c.BeginOutputHandoff()
+
+	// the log reader kept up past the pin; splice at its final cursor so the
+	// retained bytes it already delivered are skipped, not duplicated
+	time.Sleep(20 * time.Millisecond)
+	logSeq, _ := c.OutputCursor()
+	mu.Lock()
+	logs := append([]byte(nil), written.Bytes()[:logSeq]...)
+	mu.Unlock()
+
+	var live bytes.Buffer
+	cfg := &AttachConfig{
+		UseStdout:        true,
+		Stdout:           &live,
+		Handoff:          h,
+		HandoffStdoutSeq: logSeq,
+	}
+	assert.NilError(t, c.AttachStreams(cfg))
+	results := c.CopyStreamsResult(context.Background(), cfg)
+
+	time.Sleep(20 * time.Millisecond)
+	close(stop)
+	<-writerDone
+	assert.NilError(t, c.CloseStreams())
+
+	res := waitAttachResult(t, results)
+	assert.NilError(t, res.Err)
+
+	mu.Lock()
+	all := append([]byte(nil), written.Bytes()...)
+	mu.Unlock()
+	got := append(logs, live.Bytes()...)
+	assert.Check(t, is.DeepEqual(all, got), "splicing at the log reader's cursor must not duplicate output")
+}
+
+func TestOutputHandoffCancel(t *testing.T) {
+	c := NewConfig()
+
+	h := c.BeginOutputHandoff()
+	_, err := c.Stdout().Write([]byte("retained while pinned"))
+	assert.NilError(t, err)
+	h.Cancel()
+
+	c.stdoutCursor.mu.Lock()
+	pins := len(c.stdoutCursor.pins)
+	c.stdoutCursor.mu.Unlock()
+	assert.Check(t, is.Equal(0, pins), "cancel must release the retained output")
+
+	// canceling again (or after a resume) is a no-op
+	h.Cancel()
+}
+
+func TestOutputCursorCounts(t *testing.T) {
+	c := NewConfig()
+
+	_, err := c.Stdout().Write([]byte("12345"))
+	assert.NilError(t, err)
+	_, err = c.Stderr().Write([]byte("123"))
+	assert.NilError(t, err)
+
+	stdoutSeq, stderrSeq := c.OutputCursor()
+	assert.Check(t, is.Equal(uint64(5), stdoutSeq))
+	assert.Check(t, is.Equal(uint64(3), stderrSeq))
+}
@@ -0,0 +1,220 @@
+package stream // import "github.com/docker/docker/container/stream"
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/moby/term"
+)
+
+// DefaultDetachKeyTimeout is the inter-key window the daemon applies to
+// multi-byte detach sequences. It is generous enough for deliberate typing
+// while keeping a lone ctrl-p (readline previous-history) from arming the
+// detach matcher indefinitely.
+const DefaultDetachKeyTimeout = 2 * time.Second
+
+// defaultDoublePressTimeout is the window within which a second press of the
+// detach sequence counts as a double press under DetachDoublePressForward.
+const defaultDoublePressTimeout = 500 * time.Millisecond
+
+// DetachPolicy says what CopyStreams does with a typed detach sequence.
+type DetachPolicy int
+
+const (
+	// DetachSwallow detaches the client and withholds the sequence from the
+	// container. This is the default.
+	DetachSwallow DetachPolicy = iota
+	// DetachForward detaches the client and also forwards the sequence to
+	// the container, for applications that bind the same keys.
+	DetachForward
+	// DetachDoublePressForward forwards one press of the sequence to the
+	// container — without detaching — when it is pressed twice within
+	// AttachConfig.DetachDoublePressTimeout. A single press still detaches,
+	// though only once the window has passed.
+	DetachDoublePressForward
+)
+
+// readResult carries one source read from the pump goroutine to Read.
+type readResult struct {
+	data []byte
+	err  error
+}
+
+// timedEscapeProxy scans stdin for the detach escape sequence the way
+// term.NewEscapeProxy does, but bounds the time allowed between the keys of a
+// multi-byte sequence. When the next key does not arrive within the window,
+// the withheld prefix is flushed to the container and matching restarts, so
+// ctrl-p followed by an unrelated ctrl-q an hour later is ordinary input, not
+// a detach. A full match is reported as term.EscapeError.
+//
+// Reads from the source happen on a dedicated pump goroutine so a pending
+// partial match can expire while no input arrives. The goroutine exits when
+// the source read fails, which the session teardown guarantees by closing
+// stdin.
+type timedEscapeProxy struct {
+	escapeKeys        []byte
+	timeout           time.Duration
+	policy            DetachPolicy
+	doublePressWindow time.Duration
+	after             func(time.Duration) <-chan time.Time
+
+	src   io.Reader
+	once  sync.Once
+	reads chan readResult
+
+	pos           int              // keys of the sequence matched so far
+	expire        <-chan time.Time // armed while pos > 0
+	pendingDetach bool             // sequence typed; awaiting a double press
+	pendingExpire <-chan time.Time // armed while pendingDetach
+	out           []byte           // scanned bytes ready to hand to the caller
+	err           error            // delivered once out is drained
+	matched       bool             // detach decided; report EscapeError
+}
+
+func newTimedEscapeProxy(r io.Reader, escapeKeys []byte, timeout time.Duration) *timedEscapeProxy {
+	return &timedEscapeProxy{
+		escapeKeys:        escapeKeys,
+		timeout:           timeout,
+		doublePressWindow: defaultDoublePressTimeout,
+		after:             time.After,
+		src:               r,
+		reads:             make(chan readResult),
+	}
+}
+
+func (p *timedEscapeProxy) pump() {
+	for {
+		buf := make([]byte, 256)
+		n, err := p.src.Read(buf)
+		p.reads <- readResult{data: buf[:n], err: err}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// flush releases a withheld partial sequence to the caller and restarts
+// matching.
+func (p *timedEscapeProxy) flush() {
+	p.out = append(p.out, p.escapeKeys[:p.pos]...)
+	p.pos = 0
+	p.expire = nil
+}
+
+// armKeyTimer gives the next key of the sequence a fresh window, when
+// inter-key timing is enabled.
+func (p *timedEscapeProxy) armKeyTimer() {
+	if p.timeout > 0 {
+		p.expire = p.after(p.timeout)
+	}
+}
+
+// sequenceTyped handles a fully typed escape sequence according to the
+// detach policy.
+func (p *timedEscapeProxy) sequenceTyped() {
+	p.pos = 0
+	p.expire = nil
+	switch p.policy {
+	case DetachForward:
+		// the container gets the sequence too
+		p.out = append(p.out, p.escapeKeys...)
+		p.matched = true
+	case DetachDoublePressForward:
+		// hold the detach until the double-press window has passed
+		p.pendingDetach = true
+		p.pendingExpire = p.after(p.doublePressWindow)
+	default:
+		p.matched = true
+	}
+}
+
+// confirmDetach settles a pending detach.
+func (p *timedEscapeProxy) confirmDetach() {
+	p.matched = true
+	p.pendingDetach = false
+	p.pendingExpire = nil
+	p.expire = nil
+}
+
+// scan feeds freshly read bytes through the sequence matcher, appending
+// everything that is not part of a pending match to p.out.
+func (p *timedEscapeProxy) scan(data []byte) {
+	for _, b := range data {
+		if p.pendingDetach {
+			if b == p.escapeKeys[p.pos] {
+				p.pos++
+				if p.pos == len(p.escapeKeys) {
+					// a second press within the window: pass one press
+					// through to the container instead of detaching
+					p.out = append(p.out, p.escapeKeys...)
+					p.pendingDetach = false
+					p.pendingExpire = nil
+					p.pos = 0
+				}
+				continue
+			}
+			// any other key confirms the detach
+			p.confirmDetach()
+			return
+		}
+		if b == p.escapeKeys[p.pos] {
+			p.pos++
+			if p.pos == len(p.escapeKeys) {
+				p.sequenceTyped()
+				if p.matched {
+					return
+				}
+				continue
+			}
+			p.armKeyTimer()
+			continue
+		}
+		if p.pos > 0 {
+			p.flush()
+			// the byte that broke the match may itself start a new one
+			if b == p.escapeKeys[0] {
+				p.pos = 1
+				p.armKeyTimer()
+				continue
+			}
+		}
+		p.out = append(p.out, b)
+	}
+}
+
+func (p *timedEscapeProxy) Read(buf []byte) (int, error) {
+	p.once.Do(func() { go p.pump() })
+
+	for len(p.out) == 0 {
+		if p.matched {
+			return 0, term.EscapeError{}
+		}
+		if p.err != nil {
+			return 0, p.err
+		}
+		select {
+		case res := <-p.reads:
+			p.scan(res.data)
+			if res.err != nil {
+				if p.pendingDetach {
+					// input ended with the detach still pending: deliver it
+					p.confirmDetach()
+				}
+				// deliver the withheld prefix before the error
+				p.flush()
+				p.err = res.err
+			}
+		case <-p.expire:
+			// the rest of the sequence never came; this was ordinary input
+			p.flush()
+		case <-p.pendingExpire:
+			// no second press: this was a detach after all
+			p.confirmDetach()
+		}
+	}
+
+	n := copy(buf, p.out)
+	p.out = p.out[n:]
+	return n, nil
+}
@@ -0,0 +1,296 @@
+package stream // import "github.com/docker/docker/container/stream"
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/moby/term"
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+// newTestEscapeProxy returns a timed escape proxy whose inter-key timer is
+// driven by the returned channel instead of the wall clock.
+func newTestEscapeProxy(r io.Reader, keys []byte) (*timedEscapeProxy, chan time.Time) {
+	tick := make(chan time.Time)
+	p := newTimedEscapeProxy(r, keys, time.Minute)
+	p.after = func(time.Duration) <-chan time.Time { return tick }
+	return p, tick
+}
+
+func TestTimedEscapeProxyFlushesStalePrefix(t *testing.T) {
+	srcR, srcW := io.Pipe()
+	defer srcW.Close()
+	p, tick := newTestEscapeProxy(srcR, []byte{1, 2, 3})
+
+	go srcW.Write([]byte{1})
+	go func() { tick <- time.Now() }()
+
+	buf := make([]byte, 32)
+	n, err := p.Read(buf)
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual([]byte{1}, buf[:n]), "the stale prefix must reach the container")
+
+	// matching restarted: the key that would have completed the sequence is
+	// now ordinary input
+	go srcW.Write([]byte{2, 3})
+	n, err = p.Read(buf)
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual([]byte{2, 3}, buf[:n]))
+}
+
+func TestTimedEscapeProxyDetachWithinWindow(t *testing.T) {
+	srcR, srcW := io.Pipe()
+	defer srcW.Close()
+	p, _ := newTestEscapeProxy(srcR, []byte{1, 2, 3})
+
+	// one key per read, with no timer expiry in between
+	go func() {
+		srcW.Write([]byte{1})
+		srcW.Write([]byte{2})
+		srcW.Write([]byte{3})
+	}()
+
+	buf := make([]byte, 32)
+	n, err := p.Read(buf)
+	assert.Check(t, is.Equal(0, n), "escape sequence bytes leaked through")
+	assert.ErrorType(t, err, term.EscapeError{})
+}
+
+func TestTimedEscapeProxyRestartAfterFlush(t *testing.T) {
+	srcR, srcW := io.Pipe()
+	defer srcW.Close()
+	p, tick := newTestEscapeProxy(srcR, []byte{1, 2})
+
+	go srcW.Write([]byte{1})
+	go func() { tick <- time.Now() }()
+
+	buf := make([]byte, 32)
+	n, err := p.Read(buf)
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual([]byte{1}, buf[:n]))
+
+	// a fresh, timely sequence still detaches
+	go srcW.Write([]byte{1, 2})
+	n, err = p.Read(buf)
+	assert.Check(t, is.Equal(0, n))
+	assert.ErrorType(t, err, term.EscapeError{})
+}
+
+func TestTimedEscapeProxyMismatchMidSequence(t *testing.T) {
+	srcR, srcW := io.Pipe()
+	defer srcW.Close()
+	p, _ := newTestEscapeProxy(srcR, []byte{1, 2})
+
+	go srcW.Write([]byte{1, 'x', 1, 1, 2})
+
+	// {1, 'x'} is flushed by the mismatch; the second 1 is flushed when the
+	// third starts a new match that the trailing 2 completes
+	var got []byte
+	buf := make([]byte, 32)
+	for len(got) < 3 {
+		n, err := p.Read(buf)
+		assert.NilError(t, err)
+		got = append(got, buf[:n]...)
+	}
+	assert.Check(t, is.DeepEqual([]byte{1, 'x', 1}, got))
+
+	n, err := p.Read(buf)
+	assert.Check(t, is.Equal(0, n))
+	assert.ErrorType(t, err, term.EscapeError{})
+}
+
+func TestTimedEscapeProxyPrefixFlushedAtEOF(t *testing.T) {
+	srcR, srcW := io.Pipe()
+	p, _ := newTestEscapeProxy(srcR, []byte{1, 2})
+
+	go func() {
+		srcW.Write([]byte{1})
+		srcW.Close()
+	}()
+
+	buf := make([]byte, 32)
+	n, err := p.Read(buf)
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual([]byte{1}, buf[:n]), "the withheld prefix must not be lost at EOF")
+
+	_, err = p.Read(buf)
+	assert.Check(t, is.Equal(io.EOF, err))
+}
+
+func TestCopyStreamsDetachKeyTimeout(t *testing.T) {
+	stdinR, stdinW := io.Pipe()
+	cstdinR, cstdinW := io.Pipe()
+	coutR, coutW := io.Pipe()
+	defer coutW.Close()
+
+	cfg := &AttachConfig{
+		TTY:              true,
+		UseStdin:         true,
+		UseStdout:        true,
+		CStdin:           cstdinW,
+		CStdout:          coutR,
+		Stdin:            stdinR,
+		Stdout:           ioutil.Discard,
+		DetachKeyTimeout: 100 * time.Millisecond,
+	}
+
+	c := NewConfig()
+	results := c.CopyStreamsResult(context.Background(), cfg)
+
+	received := make(chan []byte, 1)
+	go func() {
+		b, _ := ioutil.ReadAll(cstdinR)
+		received <- b
+	}()
+
+	// ctrl-p, then silence past the window: the prefix must reach the
+	// container instead of arming a detach
+	_, err := stdinW.Write([]byte{16})
+	assert.NilError(t, err)
+	time.Sleep(300 * time.Millisecond)
+	_, err = stdinW.Write([]byte{17})
+	assert.NilError(t, err)
+
+	// a timely sequence still detaches
+	_, err = stdinW.Write([]byte{16, 17})
+	assert.NilError(t, err)
+
+	res := waitAttachResult(t, results)
+	assert.Check(t, is.Equal(ReasonDetach, res.Reason))
+	assert.NilError(t, res.Err)
+
+	cstdinW.Close()
+	select {
+	case b := <-received:
+		assert.Check(t, is.DeepEqual([]byte{16, 17}, b), "the expired prefix and the late key must reach the container")
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the container side of stdin")
+	}
+}
+
+func TestTimedEscapeProxyForwardPolicy(t *testing.T) {
+	srcR, srcW := io.Pipe()
+	defer srcW.Close()
+	p, _ := newTestEscapeProxy(srcR, []byte{1, 2})
+	p.policy = DetachForward
+
+	go srcW.Write([]byte{'a', 1, 2})
+
+	var got []byte
+	buf := make([]byte, 32)
+	for len(got) < 3 {
+		n, err := p.Read(buf)
+		assert.NilError(t, err)
+		got = append(got, buf[:n]...)
+	}
+	assert.Check(t, is.DeepEqual([]byte{'a', 1, 2}, got), "the sequence must be forwarded to the container")
+
+	n, err := p.Read(buf)
+	assert.Check(t, is.Equal(0, n))
+	assert.ErrorType(t, err, term.EscapeError{}, "forwarding must still detach")
+}
+
+func TestTimedEscapeProxyDoublePressForwards(t *testing.T) {
+	srcR, srcW := io.Pipe()
+	defer srcW.Close()
+	p, _ := newTestEscapeProxy(srcR, []byte{1, 2})
+	p.policy = DetachDoublePressForward
+
+	// two presses with no window expiry in between
+	go srcW.Write([]byte{1, 2, 1, 2})
+
+	buf := make([]byte, 32)
+	n, err := p.Read(buf)
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual([]byte{1, 2}, buf[:n]), "a double press must forward one press")
+
+	// the session goes on: ordinary input still flows
+	go srcW.Write([]byte{'x'})
+	n, err = p.Read(buf)
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual([]byte{'x'}, buf[:n]))
+}
+
+func TestTimedEscapeProxyDoublePressWindowExpires(t *testing.T) {
+	srcR, srcW := io.Pipe()
+	defer srcW.Close()
+	p, tick := newTestEscapeProxy(srcR, []byte{1, 2})
+	p.policy = DetachDoublePressForward
+
+	go srcW.Write([]byte{1, 2})
+	go func() { tick <- time.Now() }()
+
+	buf := make([]byte, 32)
+	n, err := p.Read(buf)
+	assert.Check(t, is.Equal(0, n), "an expired single press must not leak the sequence")
+	assert.ErrorType(t, err, term.EscapeError{})
+}
+
+func TestTimedEscapeProxyDoublePressInterrupted(t *testing.T) {
+	srcR, srcW := io.Pipe()
+	defer srcW.Close()
+	p, _ := newTestEscapeProxy(srcR, []byte{1, 2})
+	p.policy = DetachDoublePressForward
+
+	// a different key while the detach is pending confirms it
+	go srcW.Write([]byte{1, 2, 'x'})
+
+	buf := make([]byte, 32)
+	n, err := p.Read(buf)
+	assert.Check(t, is.Equal(0, n))
+	assert.ErrorType(t, err, term.EscapeError{})
+}
+
+func TestCopyStreamsDetachForward(t *testing.T) {
+	stdinR, stdinW := io.Pipe()
+	cstdinR, cstdinW := io.Pipe()
+	coutR, _ := io.Pipe()
+
+	statsC := make(chan CopyStats, 1)
+	cfg := &AttachConfig{
+		TTY:          true,
+		UseStdin:     true,
+		UseStdout:    true,
+		CStdin:       cstdinW,
+		CStdout:      coutR,
+		Stdin:        stdinR,
+		Stdout:       ioutil.Discard,
+		DetachPolicy: DetachForward,
+		StatsNotify:  func(s CopyStats) { statsC <- s },
+	}
+
+	c := NewConfig()
+	results := c.CopyStreamsResult(context.Background(), cfg)
+
+	received := make(chan []byte, 1)
+	go func() {
+		b, _ := ioutil.ReadAll(cstdinR)
+		received <- b
+	}()
+
+	_, err := stdinW.Write([]byte{'a', 16, 17})
+	assert.NilError(t, err)
+
+	res := waitAttachResult(t, results)
+	assert.Check(t, is.Equal(ReasonDetach, res.Reason))
+	assert.NilError(t, res.Err)
+
+	cstdinW.Close()
+	select {
+	case b := <-received:
+		assert.Check(t, is.DeepEqual([]byte{'a', 16, 17}, b), "the sequence must reach the container under DetachForward")
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the container side of stdin")
+	}
+
+	select {
+	case stats := <-statsC:
+		assert.Check(t, is.Equal(int64(3), stats.StdinBytes), "forwarded sequence bytes must be counted")
+	case <-time.After(5 * time.Second):
+		t.Fatal("stats were not reported")
+	}
+}
@@ -0,0 +1,27 @@
+package stream // import "github.com/docker/docker/container/stream"
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+)
+
+type loggerKey struct{}
+
+// WithLogger returns a context carrying the logger CopyStreams should use
+// for its per-stream debug lines. The daemon sets the container and exec
+// fields here, so a line on a busy daemon can be tied back to the API
+// request it belongs to; CopyStreams adds the per-session ID and the stream
+// name itself.
+func WithLogger(ctx context.Context, logger *logrus.Entry) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// loggerFromContext returns the logger set by WithLogger, or an entry on the
+// standard logger when none is set.
+func loggerFromContext(ctx context.Context) *logrus.Entry {
+	if logger, ok := ctx.Value(loggerKey{}).(*logrus.Entry); ok {
+		return logger
+	}
+	return logrus.NewEntry(logrus.StandardLogger())
+}
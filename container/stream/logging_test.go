@@ -0,0 +1,123 @@
+package stream // import "github.com/docker/docker/container/stream"
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+// capturingHook records every entry logged through it so tests can assert on
+// the fields a session attaches to its lines.
+type capturingHook struct {
+	mu      sync.Mutex
+	entries []logrus.Entry
+}
+
+func (h *capturingHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *capturingHook) Fire(entry *logrus.Entry) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	data := logrus.Fields{}
+	for k, v := range entry.Data {
+		data[k] = v
+	}
+	h.entries = append(h.entries, logrus.Entry{Level: entry.Level, Message: entry.Message, Data: data})
+	return nil
+}
+
+// find returns the recorded entries with the given message.
+func (h *capturingHook) find(message string) []logrus.Entry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var out []logrus.Entry
+	for _, e := range h.entries {
+		if e.Message == message {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// newCapturingLogger returns a debug-level logger entry that only records to
+// the hook, plus the hook itself.
+func newCapturingLogger() (*logrus.Entry, *capturingHook) {
+	hook := &capturingHook{}
+	logger := logrus.New()
+	logger.SetLevel(logrus.DebugLevel)
+	logger.SetOutput(ioutil.Discard)
+	logger.AddHook(hook)
+	return logrus.NewEntry(logger), hook
+}
+
+func TestCopyStreamsLoggingFields(t *testing.T) {
+	coutR, coutW := io.Pipe()
+
+	var stdout bytes.Buffer
+	cfg := &AttachConfig{
+		UseStdout: true,
+		CStdout:   coutR,
+		Stdout:    &stdout,
+	}
+
+	entry, hook := newCapturingLogger()
+	ctx := WithLogger(context.Background(), entry.WithField("container", "abc123"))
+
+	c := NewConfig()
+	results := c.CopyStreamsResult(ctx, cfg)
+
+	_, err := coutW.Write([]byte("hello"))
+	assert.NilError(t, err)
+	coutW.Close()
+
+	res := waitAttachResult(t, results)
+	assert.NilError(t, res.Err)
+	assert.Check(t, res.SessionID != "", "the result must carry a session ID")
+
+	for _, message := range []string{"attach: begin", "attach: end"} {
+		entries := hook.find(message)
+		assert.Assert(t, is.Len(entries, 1), "expected exactly one %q line", message)
+		assert.Check(t, is.Equal("abc123", entries[0].Data["container"]), "%q must carry the caller's fields", message)
+		assert.Check(t, is.Equal("stdout", entries[0].Data["stream"]))
+		assert.Check(t, is.Equal(res.SessionID, entries[0].Data["session"]), "%q must carry the session ID from the result", message)
+	}
+}
+
+func TestCopyStreamsLoggingErrorFields(t *testing.T) {
+	coutR, coutW := io.Pipe()
+	defer coutW.Close()
+
+	werr := errors.New("client gone")
+	cfg := &AttachConfig{
+		UseStdout: true,
+		CStdout:   coutR,
+		Stdout:    &failAfterWriter{limit: 0, err: werr},
+	}
+
+	entry, hook := newCapturingLogger()
+	ctx := WithLogger(context.Background(), entry.WithField("container", "abc123"))
+
+	c := NewConfig()
+	results := c.CopyStreamsResult(ctx, cfg)
+
+	go coutW.Write([]byte("output"))
+
+	res := waitAttachResult(t, results)
+	assert.Check(t, is.Equal(ReasonError, res.Reason))
+
+	entries := hook.find("attach: copy error")
+	assert.Assert(t, is.Len(entries, 1))
+	assert.Check(t, is.Equal("abc123", entries[0].Data["container"]))
+	assert.Check(t, is.Equal("stdout", entries[0].Data["stream"]))
+	assert.Check(t, is.Equal(res.SessionID, entries[0].Data["session"]))
+}
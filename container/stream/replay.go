@@ -0,0 +1,87 @@
+package stream // import "github.com/docker/docker/container/stream"
+
+import (
+	"io"
+	"sync"
+)
+
+// replayBuffer retains the most recent bytes written to one of a container's
+// output streams so that a later attach can replay them, like a tail of the
+// output. It also forwards live writes to follower pipes registered by
+// tailAndFollow; the snapshot and the registration happen under the same
+// lock, so a follower sees every byte written after its snapshot exactly
+// once.
+type replayBuffer struct {
+	mu        sync.Mutex
+	buf       []byte
+	size      int
+	followers []io.WriteCloser
+	closed    bool
+}
+
+func newReplayBuffer(size int) *replayBuffer {
+	return &replayBuffer{
+		buf:  make([]byte, 0, size),
+		size: size,
+	}
+}
+
+// Write retains the tail of p and forwards it to the followers. Failed
+// followers are evicted, mirroring broadcaster.Unbuffered.
+func (b *replayBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return 0, io.ErrClosedPipe
+	}
+
+	if len(p) >= b.size {
+		b.buf = append(b.buf[:0], p[len(p)-b.size:]...)
+	} else {
+		b.buf = append(b.buf, p...)
+		if excess := len(b.buf) - b.size; excess > 0 {
+			copy(b.buf, b.buf[excess:])
+			b.buf = b.buf[:b.size]
+		}
+	}
+
+	var evict []int
+	for i, w := range b.followers {
+		if n, err := w.Write(p); err != nil || n != len(p) {
+			evict = append(evict, i)
+		}
+	}
+	for n, i := range evict {
+		b.followers = append(b.followers[:i-n], b.followers[i-n+1:]...)
+	}
+	return len(p), nil
+}
+
+// tailAndFollow returns a copy of the retained tail and registers w to
+// receive everything written from this moment on. When the buffer is
+// already closed, w is closed instead, so a reader sees the tail's end as
+// the end of the stream.
+func (b *replayBuffer) tailAndFollow(w io.WriteCloser) []byte {
+	b.mu.Lock()
+	tail := append([]byte(nil), b.buf...)
+	if b.closed {
+		w.Close()
+	} else {
+		b.followers = append(b.followers, w)
+	}
+	b.mu.Unlock()
+	return tail
+}
+
+// Close ends the live stream for all followers. The retained tail stays
+// available for replay.
+func (b *replayBuffer) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for _, w := range b.followers {
+		w.Close()
+	}
+	b.followers = nil
+	return nil
+}
@@ -0,0 +1,117 @@
+package stream // import "github.com/docker/docker/container/stream"
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// boundedPipe is a stdin pipe with a cap on the amount of buffered data.
+// Writers block once the cap is reached, instead of growing daemon memory,
+// and resume as the reader drains. Closing either end unblocks both sides,
+// which is how the attach copiers interrupt a stalled write when their
+// context ends.
+type boundedPipe struct {
+	mu   sync.Mutex
+	wait *sync.Cond
+
+	buf       bytes.Buffer
+	limit     int
+	highWater int64
+	rclosed   bool
+	wclosed   bool
+}
+
+func newBoundedPipe(limit int) *boundedPipe {
+	p := &boundedPipe{limit: limit}
+	p.wait = sync.NewCond(&p.mu)
+	return p
+}
+
+func (p *boundedPipe) Read(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for {
+		if p.buf.Len() > 0 {
+			n, _ := p.buf.Read(b)
+			p.wait.Broadcast()
+			return n, nil
+		}
+		if p.wclosed {
+			return 0, io.EOF
+		}
+		if p.rclosed {
+			return 0, io.ErrClosedPipe
+		}
+		p.wait.Wait()
+	}
+}
+
+func (p *boundedPipe) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var written int
+	for len(b) > 0 {
+		if p.rclosed || p.wclosed {
+			return written, io.ErrClosedPipe
+		}
+		room := p.limit - p.buf.Len()
+		if room <= 0 {
+			p.wait.Wait()
+			continue
+		}
+		if room > len(b) {
+			room = len(b)
+		}
+		n, _ := p.buf.Write(b[:room])
+		written += n
+		b = b[n:]
+		if hw := int64(p.buf.Len()); hw > p.highWater {
+			p.highWater = hw
+		}
+		p.wait.Broadcast()
+	}
+	return written, nil
+}
+
+// HighWater reports the most bytes the pipe has held at once.
+func (p *boundedPipe) HighWater() int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.highWater
+}
+
+func (p *boundedPipe) closeRead() {
+	p.mu.Lock()
+	p.rclosed = true
+	p.wait.Broadcast()
+	p.mu.Unlock()
+}
+
+func (p *boundedPipe) closeWrite() {
+	p.mu.Lock()
+	p.wclosed = true
+	p.wait.Broadcast()
+	p.mu.Unlock()
+}
+
+// boundedPipeReader and boundedPipeWriter are the two ends handed out as
+// Config.Stdin and Config.StdinPipe; each Close only closes its own side,
+// matching io.Pipe's semantics.
+type boundedPipeReader struct{ p *boundedPipe }
+
+func (r *boundedPipeReader) Read(b []byte) (int, error) { return r.p.Read(b) }
+
+func (r *boundedPipeReader) Close() error {
+	r.p.closeRead()
+	return nil
+}
+
+type boundedPipeWriter struct{ p *boundedPipe }
+
+func (w *boundedPipeWriter) Write(b []byte) (int, error) { return w.p.Write(b) }
+
+func (w *boundedPipeWriter) Close() error {
+	w.p.closeWrite()
+	return nil
+}
@@ -0,0 +1,76 @@
+package stream // import "github.com/docker/docker/container/stream"
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+func TestBoundedStdinPipeBlocksAtCap(t *testing.T) {
+	c := NewConfig()
+	c.SetStdinBufferLimit(64)
+	c.NewInputPipes()
+
+	payload := bytes.Repeat([]byte{'s'}, 256)
+	writeDone := make(chan error, 1)
+	go func() {
+		_, err := c.StdinPipe().Write(payload)
+		c.StdinPipe().Close()
+		writeDone <- err
+	}()
+
+	// nobody is reading; the write must park at the cap instead of buffering
+	// the full payload
+	select {
+	case err := <-writeDone:
+		t.Fatalf("write past the cap completed with no consumer (err=%v)", err)
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	got, err := ioutil.ReadAll(c.Stdin())
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(payload, got), "draining the consumer must deliver everything")
+
+	select {
+	case err := <-writeDone:
+		assert.NilError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("write did not finish after the consumer drained")
+	}
+
+	assert.Check(t, is.Equal(int64(64), c.StdinHighWater()))
+}
+
+func TestBoundedStdinPipeCloseUnblocksWriter(t *testing.T) {
+	c := NewConfig()
+	c.SetStdinBufferLimit(8)
+	c.NewInputPipes()
+
+	writeDone := make(chan error, 1)
+	go func() {
+		_, err := c.StdinPipe().Write(bytes.Repeat([]byte{'s'}, 64))
+		writeDone <- err
+	}()
+
+	// give the writer time to hit the cap, then tear down the read side the
+	// way the copier does when its context ends
+	time.Sleep(100 * time.Millisecond)
+	assert.NilError(t, c.Stdin().Close())
+
+	select {
+	case err := <-writeDone:
+		assert.ErrorContains(t, err, "closed pipe")
+	case <-time.After(5 * time.Second):
+		t.Fatal("blocked write was not unblocked by closing the pipe")
+	}
+}
+
+func TestStdinHighWaterUnbounded(t *testing.T) {
+	c := NewConfig()
+	c.NewInputPipes()
+	assert.Check(t, is.Equal(int64(0), c.StdinHighWater()))
+}
@@ -25,21 +25,32 @@ import (
 // copied and delivered to all StdoutPipe and StderrPipe consumers, using
 // a kind of "broadcaster".
 type Config struct {
-	wg        sync.WaitGroup
-	stdout    *broadcaster.Unbuffered
-	stderr    *broadcaster.Unbuffered
-	stdin     io.ReadCloser
-	stdinPipe io.WriteCloser
-	dio       *cio.DirectIO
+	wg           sync.WaitGroup
+	stdout       *broadcaster.Unbuffered
+	stderr       *broadcaster.Unbuffered
+	stdin        io.ReadCloser
+	stdinPipe    io.WriteCloser
+	stdinLimit   int
+	stdinBounded *boundedPipe
+	dio          *cio.DirectIO
+	stdoutTail   *replayBuffer
+	stderrTail   *replayBuffer
+	stdoutCursor *streamCursor
+	stderrCursor *streamCursor
 }
 
 // NewConfig creates a stream config and initializes
 // the standard err and standard out to new unbuffered broadcasters.
 func NewConfig() *Config {
-	return &Config{
-		stderr: new(broadcaster.Unbuffered),
-		stdout: new(broadcaster.Unbuffered),
+	c := &Config{
+		stderr:       new(broadcaster.Unbuffered),
+		stdout:       new(broadcaster.Unbuffered),
+		stdoutCursor: new(streamCursor),
+		stderrCursor: new(streamCursor),
 	}
+	c.stdout.Add(c.stdoutCursor)
+	c.stderr.Add(c.stderrCursor)
+	return c
 }
 
 // Stdout returns the standard output in the configuration.
@@ -80,8 +91,69 @@ func (c *Config) StderrPipe() io.ReadCloser {
 	return bytesPipe
 }
 
+// EnableOutputReplay starts retaining the last size bytes written to each of
+// the stdout and stderr streams, so that later attaches can request a replay
+// of recent output via AttachConfig.ReplayOutput. It should be called before
+// output starts flowing; calling it again replaces the retained tails.
+func (c *Config) EnableOutputReplay(size int) {
+	c.stdoutTail = newReplayBuffer(size)
+	c.stderrTail = newReplayBuffer(size)
+	c.stdout.Add(c.stdoutTail)
+	c.stderr.Add(c.stderrTail)
+}
+
+// SetStdinBufferLimit caps how much stdin data the pipes created by
+// NewInputPipes may buffer. Writes past the cap block until the container
+// side drains, instead of growing daemon memory; a stalled write is
+// interrupted by closing either end of the pipe. The limit sticks across the
+// pipe re-creation that happens when a container restarts. Zero (the
+// default) keeps the synchronous unbuffered pipe.
+func (c *Config) SetStdinBufferLimit(limit int) {
+	c.stdinLimit = limit
+}
+
+// StdinHighWater reports the most bytes the bounded stdin pipe has held at
+// once, for diagnostics. It is zero when no buffer limit is set.
+func (c *Config) StdinHighWater() int64 {
+	if c.stdinBounded == nil {
+		return 0
+	}
+	return c.stdinBounded.HighWater()
+}
+
+// OutputCursor returns the sequence positions of the stdout and stderr
+// streams: the total number of bytes each has carried so far. The cursors
+// give a logs reader and a live attach a common coordinate to splice at, see
+// BeginOutputHandoff.
+func (c *Config) OutputCursor() (stdoutSeq, stderrSeq uint64) {
+	return c.stdoutCursor.Sequence(), c.stderrCursor.Sequence()
+}
+
+// BeginOutputHandoff starts retaining all output written from this moment
+// on, so a caller can read the log tail and then attach — with
+// AttachConfig.Handoff — from an exact cursor with no gap. The handoff must
+// either be attached or canceled, or the retained output is held for the
+// container's lifetime.
+func (c *Config) BeginOutputHandoff() *OutputHandoff {
+	h := &OutputHandoff{
+		stdout: c.stdoutCursor,
+		stderr: c.stderrCursor,
+	}
+	h.stdoutPin = c.stdoutCursor.pin()
+	h.stderrPin = c.stderrCursor.pin()
+	h.StdoutSeq = h.stdoutPin.seq
+	h.StderrSeq = h.stderrPin.seq
+	return h
+}
+
 // NewInputPipes creates new pipes for both standard inputs, Stdin and StdinPipe.
 func (c *Config) NewInputPipes() {
+	if c.stdinLimit > 0 {
+		c.stdinBounded = newBoundedPipe(c.stdinLimit)
+		c.stdin = &boundedPipeReader{p: c.stdinBounded}
+		c.stdinPipe = &boundedPipeWriter{p: c.stdinBounded}
+		return
+	}
 	c.stdin, c.stdinPipe = io.Pipe()
 }
 
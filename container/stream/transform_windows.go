@@ -0,0 +1,30 @@
+package stream // import "github.com/docker/docker/container/stream"
+
+import (
+	"io"
+	"os"
+
+	windowsconsole "github.com/moby/term/windows"
+)
+
+// NewConsoleOutputTransform is an AttachConfig.TransformStdout/TransformStderr
+// implementation for clients attached through a Windows console: when the
+// destination turns out to be a console handle, output is routed through the
+// ANSI emulation layer, which translates VT sequences into console API calls.
+// Any other destination is passed through untouched.
+func NewConsoleOutputTransform(w io.Writer) io.Writer {
+	if f, ok := w.(*os.File); ok && windowsconsole.IsConsole(f.Fd()) {
+		return windowsconsole.NewAnsiWriter(int(f.Fd()))
+	}
+	return w
+}
+
+// NewConsoleInputTransform is the AttachConfig.TransformStdin counterpart:
+// input read from a console handle is translated from console input records
+// into VT sequences for the container.
+func NewConsoleInputTransform(r io.Reader) io.Reader {
+	if f, ok := r.(*os.File); ok && windowsconsole.IsConsole(f.Fd()) {
+		return windowsconsole.NewAnsiReader(int(f.Fd()))
+	}
+	return r
+}
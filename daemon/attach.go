@@ -19,12 +19,19 @@ import (
 // ContainerAttach attaches to logs according to the config passed in. See ContainerAttachConfig.
 func (daemon *Daemon) ContainerAttach(prefixOrName string, c *backend.ContainerAttachConfig) error {
 	keys := []byte{}
+	disableDetach := false
 	var err error
-	if c.DetachKeys != "" {
+	if c.DetachKeys == "none" {
+		// the literal escape bytes must reach the application
+		disableDetach = true
+	} else if c.DetachKeys != "" {
 		keys, err = term.ToBytes(c.DetachKeys)
 		if err != nil {
 			return errdefs.InvalidParameter(errors.Errorf("Invalid detach keys (%s) provided", c.DetachKeys))
 		}
+		if err := stream.ValidateDetachKeys(keys); err != nil {
+			return err
+		}
 	}
 
 	ctr, err := daemon.GetContainer(prefixOrName)
@@ -41,14 +48,33 @@ func (daemon *Daemon) ContainerAttach(prefixOrName string, c *backend.ContainerA
 	}
 
 	cfg := stream.AttachConfig{
-		UseStdin:   c.UseStdin,
-		UseStdout:  c.UseStdout,
-		UseStderr:  c.UseStderr,
-		TTY:        ctr.Config.Tty,
-		CloseStdin: ctr.Config.StdinOnce,
-		DetachKeys: keys,
+		UseStdin:         c.UseStdin,
+		UseStdout:        c.UseStdout,
+		UseStderr:        c.UseStderr,
+		TTY:              ctr.Config.Tty,
+		CloseStdin:       ctr.Config.StdinOnce,
+		DetachKeys:       keys,
+		DetachKeyTimeout: stream.DefaultDetachKeyTimeout,
+		DisableDetach:    disableDetach,
+	}
+	if ctr.Config.Tty {
+		// a TTY merges stdout and stderr into a single stream; drop the
+		// stderr attach rather than park a copier on a pipe that never
+		// produces data
+		cfg.UseStderr = false
+	}
+	if c.Logs && c.Stream {
+		// retain output written while the log tail is read, so the live copy
+		// resumes exactly where retention began instead of dropping whatever
+		// the container wrote in between
+		cfg.Handoff = ctr.StreamConfig.BeginOutputHandoff()
+	}
+	if err := ctr.StreamConfig.AttachStreams(&cfg); err != nil {
+		if cfg.Handoff != nil {
+			cfg.Handoff.Cancel()
+		}
+		return err
 	}
-	ctr.StreamConfig.AttachStreams(&cfg)
 
 	inStream, outStream, errStream, err := c.GetStreams()
 	if err != nil {
@@ -86,11 +112,13 @@ func (daemon *Daemon) ContainerAttachRaw(prefixOrName string, stdin io.ReadClose
 	cfg := stream.AttachConfig{
 		UseStdin:   stdin != nil,
 		UseStdout:  stdout != nil,
-		UseStderr:  stderr != nil,
+		UseStderr:  stderr != nil && !ctr.Config.Tty,
 		TTY:        ctr.Config.Tty,
 		CloseStdin: ctr.Config.StdinOnce,
 	}
-	ctr.StreamConfig.AttachStreams(&cfg)
+	if err := ctr.StreamConfig.AttachStreams(&cfg); err != nil {
+		return err
+	}
 	close(attached)
 	if cfg.UseStdin {
 		cfg.Stdin = stdin
@@ -106,6 +134,11 @@ func (daemon *Daemon) ContainerAttachRaw(prefixOrName string, stdin io.ReadClose
 }
 
 func (daemon *Daemon) containerAttach(c *container.Container, cfg *stream.AttachConfig, logs, doStream bool) error {
+	if cfg.Handoff != nil {
+		// release the retained output if we bail out before the live copy
+		// resumes the handoff; Cancel after a resume is a no-op
+		defer cfg.Handoff.Cancel()
+	}
 	if logs {
 		logDriver, logCreated, err := daemon.getLogger(c)
 		if err != nil {
@@ -173,15 +206,13 @@ func (daemon *Daemon) containerAttach(c *container.Container, cfg *stream.Attach
 		}()
 	}
 
-	ctx := c.InitAttachContext()
-	err := <-c.StreamConfig.CopyStreams(ctx, cfg)
-	if err != nil {
-		var ierr term.EscapeError
-		if errors.Is(err, context.Canceled) || errors.As(err, &ierr) {
-			daemon.LogContainerEvent(c, "detach")
-		} else {
-			logrus.Errorf("attach failed with error: %v", err)
-		}
+	ctx := stream.WithLogger(c.InitAttachContext(), logrus.WithField("container", c.ID))
+	res := <-c.StreamConfig.CopyStreamsResult(ctx, cfg)
+	switch res.Reason {
+	case stream.ReasonDetach, stream.ReasonCanceled:
+		daemon.LogContainerEvent(c, "detach")
+	case stream.ReasonError:
+		logrus.WithField("session", res.SessionID).Errorf("attach failed with error: %v", res.Err)
 	}
 
 	return nil
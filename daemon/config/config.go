@@ -159,6 +159,10 @@ type CommonConfig struct {
 	SocketGroup           string                    `json:"group,omitempty"`
 	CorsHeaders           string                    `json:"api-cors-header,omitempty"`
 
+	// VolumeRoots is an allowlist of additional base paths under which local
+	// volumes may be placed with the "rootdir" creation option.
+	VolumeRoots []string `json:"volume-roots,omitempty"`
+
 	// TrustKeyPath is used to generate the daemon ID and for signing schema 1 manifests
 	// when pushing to a registry which does not support schema 2. This field is marked as
 	// deprecated because schema 1 manifests are deprecated in favor of schema 2 and the
@@ -202,6 +206,12 @@ type CommonConfig struct {
 	// to stop when daemon is being shutdown
 	ShutdownTimeout int `json:"shutdown-timeout,omitempty"`
 
+	// StdinBufferSize caps, in bytes, how much stdin data the daemon buffers
+	// per attach between the client and the container process. Writes past
+	// the cap block until the process catches up. Zero keeps the synchronous
+	// unbuffered pipe.
+	StdinBufferSize int `json:"stdin-buffer-size,omitempty"`
+
 	Debug     bool     `json:"debug,omitempty"`
 	Hosts     []string `json:"hosts,omitempty"`
 	LogLevel  string   `json:"log-level,omitempty"`
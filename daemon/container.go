@@ -110,6 +110,7 @@ func (daemon *Daemon) load(id string) (*container.Container, error) {
 func (daemon *Daemon) Register(c *container.Container) error {
 	// Attach to stdout and stderr
 	if c.Config.OpenStdin {
+		c.StreamConfig.SetStdinBufferLimit(daemon.configStore.StdinBufferSize)
 		c.StreamConfig.NewInputPipes()
 	} else {
 		c.StreamConfig.NewNopInputPipe()
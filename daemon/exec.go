@@ -104,12 +104,19 @@ func (daemon *Daemon) ContainerExecCreate(name string, config *types.ExecConfig)
 	entrypoint, args := daemon.getEntrypointAndArgs(strslice.StrSlice{}, cmd)
 
 	keys := []byte{}
-	if config.DetachKeys != "" {
+	disableDetach := false
+	if config.DetachKeys == "none" {
+		// the literal escape bytes must reach the exec'd process
+		disableDetach = true
+	} else if config.DetachKeys != "" {
 		keys, err = term.ToBytes(config.DetachKeys)
 		if err != nil {
 			err = fmt.Errorf("Invalid escape keys (%s) provided", config.DetachKeys)
 			return "", err
 		}
+		if err := stream.ValidateDetachKeys(keys); err != nil {
+			return "", err
+		}
 	}
 
 	execConfig := exec.NewConfig()
@@ -118,6 +125,7 @@ func (daemon *Daemon) ContainerExecCreate(name string, config *types.ExecConfig)
 	execConfig.OpenStderr = config.AttachStderr
 	execConfig.ContainerID = cntr.ID
 	execConfig.DetachKeys = keys
+	execConfig.DisableDetach = disableDetach
 	execConfig.Entrypoint = entrypoint
 	execConfig.Args = args
 	execConfig.Tty = config.Tty
@@ -211,11 +219,13 @@ func (daemon *Daemon) ContainerExecStart(ctx context.Context, name string, stdin
 	if ec.OpenStdout {
 		cStdout = stdout
 	}
-	if ec.OpenStderr {
+	if ec.OpenStderr && !ec.Tty {
+		// a TTY merges stderr into the single output stream
 		cStderr = stderr
 	}
 
 	if ec.OpenStdin {
+		ec.StreamConfig.SetStdinBufferLimit(daemon.configStore.StdinBufferSize)
 		ec.StreamConfig.NewInputPipes()
 	} else {
 		ec.StreamConfig.NewNopInputPipe()
@@ -247,18 +257,26 @@ func (daemon *Daemon) ContainerExecStart(ctx context.Context, name string, stdin
 	}
 
 	attachConfig := stream.AttachConfig{
-		TTY:        ec.Tty,
-		UseStdin:   cStdin != nil,
-		UseStdout:  cStdout != nil,
-		UseStderr:  cStderr != nil,
-		Stdin:      cStdin,
-		Stdout:     cStdout,
-		Stderr:     cStderr,
-		DetachKeys: ec.DetachKeys,
-		CloseStdin: true,
-	}
-	ec.StreamConfig.AttachStreams(&attachConfig)
-	attachErr := ec.StreamConfig.CopyStreams(ctx, &attachConfig)
+		TTY:              ec.Tty,
+		UseStdin:         cStdin != nil,
+		UseStdout:        cStdout != nil,
+		UseStderr:        cStderr != nil,
+		Stdin:            cStdin,
+		Stdout:           cStdout,
+		Stderr:           cStderr,
+		DetachKeys:       ec.DetachKeys,
+		DetachKeyTimeout: stream.DefaultDetachKeyTimeout,
+		DisableDetach:    ec.DisableDetach,
+		CloseStdin:       true,
+	}
+	if err := ec.StreamConfig.AttachStreams(&attachConfig); err != nil {
+		return err
+	}
+	attachCtx := stream.WithLogger(ctx, logrus.WithFields(logrus.Fields{
+		"container": c.ID,
+		"exec":      ec.ID,
+	}))
+	attachRes := ec.StreamConfig.CopyStreamsResult(attachCtx, &attachConfig)
 
 	// Synchronize with libcontainerd event loop
 	ec.Lock()
@@ -288,19 +306,18 @@ func (daemon *Daemon) ContainerExecStart(ctx context.Context, name string, stdin
 		case <-timeout.C:
 			logrus.Infof("Container %v, process %v failed to exit within %v of signal TERM - using the force", c.ID, name, termProcessTimeout)
 			daemon.containerd.SignalProcess(ctx, c.ID, name, int(signal.SignalMap["KILL"]))
-		case <-attachErr:
+		case <-attachRes:
 			// TERM signal worked
 		}
 		return ctx.Err()
-	case err := <-attachErr:
-		if err != nil {
-			if _, ok := err.(term.EscapeError); !ok {
-				return errdefs.System(errors.Wrap(err, "exec attach failed"))
-			}
-			attributes := map[string]string{
+	case res := <-attachRes:
+		switch res.Reason {
+		case stream.ReasonDetach:
+			daemon.LogContainerEventWithAttributes(c, "exec_detach", map[string]string{
 				"execID": ec.ID,
-			}
-			daemon.LogContainerEventWithAttributes(c, "exec_detach", attributes)
+			})
+		case stream.ReasonError:
+			return errdefs.System(errors.Wrapf(res.Err, "exec attach failed (session %s)", res.SessionID))
 		}
 	}
 	return nil
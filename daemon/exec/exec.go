@@ -16,25 +16,26 @@ import (
 // examined both during and after completion.
 type Config struct {
 	sync.Mutex
-	Started      chan struct{}
-	StreamConfig *stream.Config
-	ID           string
-	Running      bool
-	ExitCode     *int
-	OpenStdin    bool
-	OpenStderr   bool
-	OpenStdout   bool
-	CanRemove    bool
-	ContainerID  string
-	DetachKeys   []byte
-	Entrypoint   string
-	Args         []string
-	Tty          bool
-	Privileged   bool
-	User         string
-	WorkingDir   string
-	Env          []string
-	Pid          int
+	Started       chan struct{}
+	StreamConfig  *stream.Config
+	ID            string
+	Running       bool
+	ExitCode      *int
+	OpenStdin     bool
+	OpenStderr    bool
+	OpenStdout    bool
+	CanRemove     bool
+	ContainerID   string
+	DetachKeys    []byte
+	DisableDetach bool
+	Entrypoint    string
+	Args          []string
+	Tty           bool
+	Privileged    bool
+	User          string
+	WorkingDir    string
+	Env           []string
+	Pid           int
 }
 
 // NewConfig initializes the a new exec configuration
@@ -21,6 +21,7 @@ import (
 	"github.com/docker/docker/pkg/sysinfo"
 	"github.com/docker/docker/pkg/system"
 	"github.com/docker/docker/registry"
+	"github.com/docker/docker/volume"
 	metrics "github.com/docker/go-metrics"
 	"github.com/opencontainers/selinux/go-selinux"
 	"github.com/sirupsen/logrus"
@@ -166,8 +167,16 @@ func (daemon *Daemon) fillDriverInfo(v *types.Info) {
 }
 
 func (daemon *Daemon) fillPluginsInfo(v *types.Info) {
+	volumeDrivers := daemon.volumes.GetDriverList()
+	for i, name := range volumeDrivers {
+		// annotate cluster-wide drivers; the scope comes from the driver
+		// store's capabilities cache, so this doesn't hit the plugins.
+		if caps, err := daemon.volumes.DriverCapabilities(name); err == nil && caps.Scope == volume.GlobalScope {
+			volumeDrivers[i] = name + " (global)"
+		}
+	}
 	v.Plugins = types.PluginsInfo{
-		Volume:  daemon.volumes.GetDriverList(),
+		Volume:  volumeDrivers,
 		Network: daemon.GetNetworkDriverList(),
 
 		// The authorization plugins are returned in the order they are
@@ -1,7 +1,9 @@
 package daemon // import "github.com/docker/docker/daemon"
 
 import (
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/docker/docker/errdefs"
 	"github.com/docker/docker/pkg/plugingetter"
@@ -24,6 +26,10 @@ var (
 	healthChecksCounter       metrics.Counter
 	healthChecksFailedCounter metrics.Counter
 
+	pluginCalls      metrics.LabeledTimer
+	pluginCallErrors metrics.LabeledCounter
+	pluginFirstByte  metrics.LabeledTimer
+
 	stateCtr *stateCounter
 )
 
@@ -58,6 +64,10 @@ func init() {
 	healthChecksCounter = ns.NewCounter("health_checks", "The total number of health checks")
 	healthChecksFailedCounter = ns.NewCounter("health_checks_failed", "The total number of failed health checks")
 
+	pluginCalls = ns.NewLabeledTimer("plugin_calls", "The number of seconds it takes a plugin to answer each call attempt", "plugin", "method")
+	pluginCallErrors = ns.NewLabeledCounter("plugin_call_errors", "The total number of plugin call attempts which returned an error", "plugin", "method", "status")
+	pluginFirstByte = ns.NewLabeledTimer("plugin_first_byte", "The number of seconds until the first byte of a streamed plugin response is read", "plugin", "method")
+
 	stateCtr = newStateCounter(ns.NewDesc("container_states", "The count of containers in various states", metrics.Unit("containers"), "state"))
 	ns.Add(stateCtr)
 
@@ -142,6 +152,21 @@ func (daemon *Daemon) cleanupMetricsPlugins() {
 	}
 }
 
+// pluginMetricsObserver feeds plugin client observations into the engine's
+// metrics registry. It satisfies plugins.ClientObserver.
+type pluginMetricsObserver struct{}
+
+func (pluginMetricsObserver) ObserveAttempt(label string, obs plugins.AttemptObservation) {
+	pluginCalls.WithValues(label, obs.Method).Update(obs.Duration)
+	if obs.Err != nil {
+		pluginCallErrors.WithValues(label, obs.Method, strconv.Itoa(obs.Status)).Inc()
+	}
+}
+
+func (pluginMetricsObserver) ObserveFirstByte(label, method string, d time.Duration) {
+	pluginFirstByte.WithValues(label, method).Update(d)
+}
+
 type metricsPlugin interface {
 	StartMetrics() error
 	StopMetrics() error
@@ -162,7 +187,10 @@ func makePluginAdapter(p plugingetter.CompatPlugin) (metricsPlugin, error) {
 	}
 
 	addr := pa.Addr()
-	client, err := plugins.NewClientWithTimeout(addr.Network()+"://"+addr.String(), nil, pa.Timeout())
+	client, err := plugins.NewClientWithOptions(addr.Network()+"://"+addr.String(), nil,
+		plugins.WithHTTPTimeout(pa.Timeout()),
+		plugins.WithObserver(p.Name(), pluginMetricsObserver{}),
+	)
 	if err != nil {
 		return nil, errors.Wrap(err, "error creating metrics plugin client")
 	}
@@ -48,6 +48,13 @@ func (daemon *Daemon) removeMountPoints(container *container.Container, rm bool)
 		}
 	}
 
+	// Catch any references not covered by the container's mount points, e.g.
+	// when an abnormal removal left the container's config out of sync with
+	// the volume store.
+	if _, err := daemon.volumes.ReleaseByPrefix(context.TODO(), container.ID); err != nil {
+		rmErrors = append(rmErrors, err.Error())
+	}
+
 	if len(rmErrors) > 0 {
 		return fmt.Errorf("Error removing volumes:\n%v", strings.Join(rmErrors, "\n"))
 	}
@@ -6,6 +6,7 @@ import (
 	"sync"
 
 	"github.com/docker/docker/pkg/plugingetter"
+	"github.com/docker/docker/pkg/plugins"
 	"github.com/sirupsen/logrus"
 )
 
@@ -20,6 +21,9 @@ type Middleware struct {
 // with a slice of plugins names.
 func NewMiddleware(names []string, pg plugingetter.PluginGetter) *Middleware {
 	SetPluginGetter(pg)
+	// the configured chain order is the administrator's preference; publish
+	// it so capability-based lookups agree with the middleware.
+	plugins.SetCapabilityOrder(AuthZApiImplements, names)
 	return &Middleware{
 		plugins: newPlugins(names),
 	}
@@ -33,6 +37,7 @@ func (m *Middleware) getAuthzPlugins() []Plugin {
 
 // SetPlugins sets the plugin used for authorization
 func (m *Middleware) SetPlugins(names []string) {
+	plugins.SetCapabilityOrder(AuthZApiImplements, names)
 	m.mu.Lock()
 	m.plugins = newPlugins(names)
 	m.mu.Unlock()
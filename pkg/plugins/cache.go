@@ -0,0 +1,127 @@
+package plugins // import "github.com/docker/docker/pkg/plugins"
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// defaultCacheEntries is the entry cap used for the response cache of clients
+// constructed during plugin activation.
+const defaultCacheEntries = 32
+
+// responseCache holds raw response bodies of idempotent calls, keyed by
+// method and request body hash. Entries expire after the TTL the caller
+// asked for; hits are decoded afresh into the caller's output, so cached
+// data is never aliased between callers.
+type responseCache struct {
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	max     int
+}
+
+type cacheEntry struct {
+	method  string
+	body    []byte
+	expires time.Time
+}
+
+func newResponseCache(maxEntries int) *responseCache {
+	return &responseCache{entries: make(map[string]cacheEntry), max: maxEntries}
+}
+
+func cacheKey(serviceMethod string, body []byte) string {
+	sum := sha256.Sum256(body)
+	return serviceMethod + ":" + hex.EncodeToString(sum[:])
+}
+
+func (rc *responseCache) get(key string) ([]byte, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	e, ok := rc.entries[key]
+	if !ok || time.Now().After(e.expires) {
+		delete(rc.entries, key)
+		return nil, false
+	}
+	return e.body, true
+}
+
+func (rc *responseCache) put(key, method string, body []byte, ttl time.Duration) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if len(rc.entries) >= rc.max {
+		rc.evictLocked()
+	}
+	rc.entries[key] = cacheEntry{method: method, body: body, expires: time.Now().Add(ttl)}
+}
+
+// evictLocked drops expired entries, and failing that the soonest-expiring
+// one, to make room for an insert.
+func (rc *responseCache) evictLocked() {
+	now := time.Now()
+	var soonestKey string
+	var soonest time.Time
+	for k, e := range rc.entries {
+		if now.After(e.expires) {
+			delete(rc.entries, k)
+			continue
+		}
+		if soonestKey == "" || e.expires.Before(soonest) {
+			soonestKey, soonest = k, e.expires
+		}
+	}
+	if len(rc.entries) >= rc.max && soonestKey != "" {
+		delete(rc.entries, soonestKey)
+	}
+}
+
+func (rc *responseCache) invalidate(serviceMethod string) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	for k, e := range rc.entries {
+		if e.method == serviceMethod {
+			delete(rc.entries, k)
+		}
+	}
+}
+
+func (rc *responseCache) flush() {
+	rc.mu.Lock()
+	rc.entries = make(map[string]cacheEntry)
+	rc.mu.Unlock()
+}
+
+// WithResponseCache returns a ClientOpt which equips the client with a
+// response cache of at most maxEntries entries. The cache only serves calls
+// the caller explicitly marks cacheable via WithRequestCacheable.
+func WithResponseCache(maxEntries int) ClientOpt {
+	return func(c *Client) {
+		c.cache = newResponseCache(maxEntries)
+	}
+}
+
+// WithRequestCacheable marks this call as safe to serve from the client's
+// response cache for ttl after a successful response. It only applies to
+// read-only, idempotent calls, and only on clients carrying a cache (see
+// WithResponseCache); elsewhere it is a no-op.
+func WithRequestCacheable(ttl time.Duration) func(*RequestOpts) {
+	return func(o *RequestOpts) {
+		o.CacheTTL = ttl
+	}
+}
+
+// InvalidateCache drops any cached responses for serviceMethod, e.g. after a
+// mutating call which made them stale.
+func (c *Client) InvalidateCache(serviceMethod string) {
+	if c.cache != nil {
+		c.cache.invalidate(serviceMethod)
+	}
+}
+
+// FlushCache drops all cached responses.
+func (c *Client) FlushCache() {
+	if c.cache != nil {
+		c.cache.flush()
+	}
+}
@@ -0,0 +1,101 @@
+package plugins // import "github.com/docker/docker/pkg/plugins"
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+func TestResponseCacheHitMiss(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	var requests int
+	mux.HandleFunc("/Test.Capabilities", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprintf(w, `{"Scope":"answer %d"}`, requests)
+	})
+
+	c, err := NewClientWithOpts(addr, WithResponseCache(8))
+	assert.NilError(t, err)
+
+	var out struct{ Scope string }
+	assert.NilError(t, c.CallWithOptions("Test.Capabilities", nil, &out, WithRequestCacheable(time.Minute)))
+	assert.Check(t, is.Equal("answer 1", out.Scope))
+
+	// identical call is a hit; the mutation of out must not leak into the cache
+	out.Scope = "mutated"
+	assert.NilError(t, c.CallWithOptions("Test.Capabilities", nil, &out, WithRequestCacheable(time.Minute)))
+	assert.Check(t, is.Equal("answer 1", out.Scope))
+	assert.Check(t, is.Equal(1, requests))
+
+	// different arguments miss
+	assert.NilError(t, c.CallWithOptions("Test.Capabilities", map[string]string{"Name": "v"}, &out, WithRequestCacheable(time.Minute)))
+	assert.Check(t, is.Equal("answer 2", out.Scope))
+	assert.Check(t, is.Equal(2, requests))
+}
+
+func TestResponseCacheTTLExpiry(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	var requests int
+	mux.HandleFunc("/Test.Capabilities", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte("{}"))
+	})
+
+	c, err := NewClientWithOpts(addr, WithResponseCache(8))
+	assert.NilError(t, err)
+
+	assert.NilError(t, c.CallWithOptions("Test.Capabilities", nil, nil, WithRequestCacheable(50*time.Millisecond)))
+	assert.NilError(t, c.CallWithOptions("Test.Capabilities", nil, nil, WithRequestCacheable(50*time.Millisecond)))
+	assert.Check(t, is.Equal(1, requests))
+
+	time.Sleep(100 * time.Millisecond)
+	assert.NilError(t, c.CallWithOptions("Test.Capabilities", nil, nil, WithRequestCacheable(50*time.Millisecond)))
+	assert.Check(t, is.Equal(2, requests))
+}
+
+func TestResponseCacheOnlyCacheable(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	var requests int
+	mux.HandleFunc("/Test.Mount", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte("{}"))
+	})
+
+	c, err := NewClientWithOpts(addr, WithResponseCache(8))
+	assert.NilError(t, err)
+
+	// a plain call must neither populate nor consult the cache
+	assert.NilError(t, c.Call("Test.Mount", nil, nil))
+	assert.Check(t, is.Len(c.cache.entries, 0))
+	assert.NilError(t, c.Call("Test.Mount", nil, nil))
+	assert.Check(t, is.Equal(2, requests))
+}
+
+func TestResponseCacheInvalidate(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	var requests int
+	mux.HandleFunc("/Test.Capabilities", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte("{}"))
+	})
+
+	c, err := NewClientWithOpts(addr, WithResponseCache(8))
+	assert.NilError(t, err)
+
+	assert.NilError(t, c.CallWithOptions("Test.Capabilities", nil, nil, WithRequestCacheable(time.Minute)))
+	c.InvalidateCache("Test.Capabilities")
+	assert.NilError(t, c.CallWithOptions("Test.Capabilities", nil, nil, WithRequestCacheable(time.Minute)))
+	assert.Check(t, is.Equal(2, requests))
+}
@@ -2,79 +2,438 @@ package plugins // import "github.com/docker/docker/pkg/plugins"
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"io"
 	"io/ioutil"
+	"math/rand"
+	"net"
 	"net/http"
 	"net/url"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
+	"github.com/docker/docker/dockerversion"
 	"github.com/docker/docker/pkg/ioutils"
 	"github.com/docker/docker/pkg/plugins/transport"
+	"github.com/docker/docker/pkg/requestid"
+	"github.com/docker/docker/pkg/stringid"
 	"github.com/docker/go-connections/sockets"
 	"github.com/docker/go-connections/tlsconfig"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
 
 const (
 	defaultTimeOut = 30
+
+	// defaultDialTimeout bounds how long establishing a connection to the
+	// plugin may take, independently of the per-attempt request timeout. A
+	// dead endpoint should fail fast rather than consume the whole request
+	// timeout before the first byte is sent.
+	defaultDialTimeout = 5 * time.Second
 )
 
-func newTransport(addr string, tlsConfig *tlsconfig.Options) (transport.Transport, error) {
+// defaultUserAgent identifies the calling daemon version to plugins when no
+// explicit User-Agent is configured via WithUserAgent.
+var defaultUserAgent = "docker/" + dockerversion.Version
+
+// requestUserAgent returns the User-Agent to send on requests to the plugin.
+func (c *Client) requestUserAgent() string {
+	if c.userAgent != "" {
+		return c.userAgent
+	}
+	return defaultUserAgent
+}
+
+func (c *Client) newTransport(addr string) (transport.Transport, *http.Transport, error) {
 	tr := &http.Transport{}
 
-	if tlsConfig != nil {
-		c, err := tlsconfig.Client(*tlsConfig)
+	if c.tlsConfig != nil {
+		cfg, err := tlsconfig.Client(*c.tlsConfig)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
-		tr.TLSClientConfig = c
+		tr.TLSClientConfig = cfg
+	}
+	if c.certProvider != nil {
+		if tr.TLSClientConfig == nil {
+			tr.TLSClientConfig = tlsconfig.ClientDefault()
+		}
+		tr.TLSClientConfig.GetClientCertificate = c.certProvider
 	}
 
 	u, err := url.Parse(addr)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	socket := u.Host
 	if socket == "" {
 		// valid local socket addresses have the host empty.
 		socket = u.Path
 	}
-	if err := sockets.ConfigureTransport(tr, u.Scheme, socket); err != nil {
-		return nil, err
+	if u.Scheme == "npipe" {
+		// The canonical form is npipe:////./pipe/name; translate the URL
+		// back into the native \\.\pipe\name path for the pipe dialer.
+		socket = strings.Replace(u.Host+u.Path, "/", `\`, -1)
+	}
+
+	tt := defaultTransportTuning(u.Scheme)
+	tt.merge(c.tuning)
+	tr.MaxIdleConns = tt.MaxIdleConns
+	tr.MaxIdleConnsPerHost = tt.MaxIdleConnsPerHost
+	tr.IdleConnTimeout = tt.IdleConnTimeout
+	tr.DisableKeepAlives = tt.DisableKeepAlives
+
+	dialTimeout := c.dialTimeout
+	if dialTimeout <= 0 {
+		dialTimeout = defaultDialTimeout
+	}
+
+	if u.Scheme == "unix" && c.peerCred != nil {
+		if err := configurePeerCredTransport(tr, socket, c.peerCred, dialTimeout); err != nil {
+			return nil, nil, err
+		}
+	} else if err := sockets.ConfigureTransport(tr, u.Scheme, socket); err != nil {
+		// on platforms without named pipe support this surfaces
+		// sockets.ErrProtocolNotAvailable for npipe addresses.
+		return nil, nil, errors.Wrapf(err, "plugin address %s", addr)
+	}
+	switch u.Scheme {
+	case "unix":
+		if c.peerCred == nil {
+			// DialContext takes precedence over the Dial func installed by
+			// ConfigureTransport, so this bounds connection establishment
+			// without disturbing the rest of the unix socket setup.
+			tr.DialContext = unixDialContext(socket, dialTimeout)
+		}
+	case "npipe":
+		// the pipe dialer installed by ConfigureTransport carries its own
+		// timeout.
+	default:
+		dialer, err := sockets.DialerFromEnvironment(&net.Dialer{
+			Timeout:   dialTimeout,
+			KeepAlive: tt.KeepAlive,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		tr.Dial = dialer.Dial
 	}
 	scheme := httpScheme(u)
 
-	return transport.NewHTTPTransport(tr, scheme, socket), nil
+	return transport.NewHTTPTransportWithHeaders(tr, scheme, socket, c.staticHeaders), tr, nil
+}
+
+// unixDialContext returns a DialContext func which connects to the unix
+// socket at socket, giving up after timeout.
+func unixDialContext(socket string, timeout time.Duration) func(ctx context.Context, _, _ string) (net.Conn, error) {
+	return func(ctx context.Context, _, _ string) (net.Conn, error) {
+		d := net.Dialer{Timeout: timeout}
+		return d.DialContext(ctx, "unix", socket)
+	}
+}
+
+// TransportTuning configures connection pooling and keepalive behavior for a
+// plugin client. Zero-valued fields use scheme-specific defaults; negative
+// values disable the corresponding limit.
+type TransportTuning struct {
+	// MaxIdleConns caps the total number of idle connections kept for reuse.
+	MaxIdleConns int
+	// MaxIdleConnsPerHost caps the idle connections kept per plugin endpoint.
+	MaxIdleConnsPerHost int
+	// IdleConnTimeout is how long an idle connection is kept around before
+	// being closed.
+	IdleConnTimeout time.Duration
+	// KeepAlive is the TCP keepalive interval used for TCP plugin
+	// connections. It has no effect on local sockets.
+	KeepAlive time.Duration
+	// DisableKeepAlives disables HTTP keep-alives entirely; every call opens
+	// a fresh connection.
+	DisableKeepAlives bool
+}
+
+// defaultTransportTuning returns the connection tuning defaults for the given
+// address scheme.
+func defaultTransportTuning(scheme string) TransportTuning {
+	switch scheme {
+	case "unix", "npipe":
+		// Local connections are cheap to set up and there is nothing between
+		// us and the plugin to time them out, so keep them around.
+		return TransportTuning{
+			MaxIdleConns:        16,
+			MaxIdleConnsPerHost: 16,
+		}
+	default:
+		// TCP plugins often sit behind load balancers which silently drop
+		// idle connections; reap idle connections before that typically
+		// happens and keep the kernel probing the live ones.
+		return TransportTuning{
+			MaxIdleConns:        16,
+			MaxIdleConnsPerHost: 4,
+			IdleConnTimeout:     90 * time.Second,
+			KeepAlive:           30 * time.Second,
+		}
+	}
+}
+
+// merge overlays the caller-provided tuning over the defaults.
+func (tt *TransportTuning) merge(o *TransportTuning) {
+	if o == nil {
+		return
+	}
+	if o.MaxIdleConns != 0 {
+		tt.MaxIdleConns = o.MaxIdleConns
+	}
+	if o.MaxIdleConnsPerHost != 0 {
+		tt.MaxIdleConnsPerHost = o.MaxIdleConnsPerHost
+	}
+	if o.IdleConnTimeout != 0 {
+		tt.IdleConnTimeout = o.IdleConnTimeout
+	}
+	if o.KeepAlive != 0 {
+		tt.KeepAlive = o.KeepAlive
+	}
+	tt.DisableKeepAlives = o.DisableKeepAlives
+	if tt.MaxIdleConns < 0 {
+		tt.MaxIdleConns = 0
+	}
+	if tt.MaxIdleConnsPerHost < 0 {
+		tt.MaxIdleConnsPerHost = 0
+	}
+	if tt.IdleConnTimeout < 0 {
+		tt.IdleConnTimeout = 0
+	}
+	if tt.KeepAlive < 0 {
+		tt.KeepAlive = 0
+	}
+}
+
+// WithTransportTuning returns a ClientOpt which overrides the client's
+// connection pooling and keepalive defaults.
+func WithTransportTuning(tt TransportTuning) ClientOpt {
+	return func(c *Client) {
+		c.tuning = &tt
+	}
 }
 
 // NewClient creates a new plugin client (http).
 func NewClient(addr string, tlsConfig *tlsconfig.Options) (*Client, error) {
-	clientTransport, err := newTransport(addr, tlsConfig)
-	if err != nil {
-		return nil, err
-	}
-	return newClientWithTransport(clientTransport, 0), nil
+	return NewClientWithOptions(addr, tlsConfig)
 }
 
 // NewClientWithTimeout creates a new plugin client (http).
 func NewClientWithTimeout(addr string, tlsConfig *tlsconfig.Options, timeout time.Duration) (*Client, error) {
-	clientTransport, err := newTransport(addr, tlsConfig)
+	return NewClientWithOptions(addr, tlsConfig, WithHTTPTimeout(timeout))
+}
+
+// NewClientWithOptions creates a new plugin client (http) with the given
+// options applied.
+func NewClientWithOptions(addr string, tlsConfig *tlsconfig.Options, opts ...ClientOpt) (*Client, error) {
+	return NewClientWithOpts(addr, append([]ClientOpt{WithTLSConfig(tlsConfig)}, opts...)...)
+}
+
+// NewClientWithOpts creates a new plugin client for the plugin at addr,
+// configured entirely through functional options.
+func NewClientWithOpts(addr string, opts ...ClientOpt) (*Client, error) {
+	c := &Client{
+		http:  &http.Client{},
+		retry: defaultRetryPolicy(),
+	}
+	for _, o := range opts {
+		o(c)
+	}
+	if c.requestFactory != nil {
+		// the transport was supplied by the caller (e.g. tests); addr is
+		// not dialed at all.
+		c.http.Transport = chainMiddleware(c.http.Transport, c.middlewares)
+		return c, nil
+	}
+	clientTransport, base, err := c.newTransport(addr)
 	if err != nil {
 		return nil, err
 	}
-	return newClientWithTransport(clientTransport, timeout), nil
+	st := &switchableTransport{t: clientTransport, base: base}
+	c.addr = addr
+	c.http.Transport = chainMiddleware(st, c.middlewares)
+	c.requestFactory = st
+	c.transport = st
+	return c, nil
+}
+
+// switchableTransport is the transport installed on a constructed client;
+// ReloadTLS swaps the underlying transport without disturbing requests
+// already in flight.
+type switchableTransport struct {
+	mu   sync.RWMutex
+	t    transport.Transport
+	base *http.Transport
+}
+
+func (s *switchableTransport) current() (transport.Transport, *http.Transport) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.t, s.base
+}
+
+func (s *switchableTransport) swap(t transport.Transport, base *http.Transport) *http.Transport {
+	s.mu.Lock()
+	old := s.base
+	s.t, s.base = t, base
+	s.mu.Unlock()
+	return old
+}
+
+func (s *switchableTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t, _ := s.current()
+	return t.RoundTrip(req)
+}
+
+func (s *switchableTransport) NewRequest(path string, data io.Reader) (*http.Request, error) {
+	t, _ := s.current()
+	return t.NewRequest(path, data)
+}
+
+// ReloadTLS rebuilds the client's transport with the given TLS options, e.g.
+// after the CA bundle changed, and swaps it in atomically. Requests already
+// in flight finish on the old transport; subsequent requests use the new
+// configuration. Idle connections established with the old configuration are
+// closed.
+func (c *Client) ReloadTLS(tlsConfig *tlsconfig.Options) error {
+	if c.transport == nil {
+		return errors.New("plugins: client transport was supplied by the caller and cannot be reloaded")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tlsConfig = tlsConfig
+	clientTransport, base, err := c.newTransport(c.addr)
+	if err != nil {
+		return err
+	}
+	if old := c.transport.swap(clientTransport, base); old != nil {
+		old.CloseIdleConnections()
+	}
+	return nil
+}
+
+// CloseIdleConnections closes any connections the client is keeping idle for
+// reuse, e.g. when the plugin it talks to is being disabled.
+func (c *Client) CloseIdleConnections() {
+	if c.transport == nil {
+		return
+	}
+	if _, base := c.transport.current(); base != nil {
+		base.CloseIdleConnections()
+	}
+}
+
+// ClientOpt is used to configure a plugin client.
+type ClientOpt func(*Client)
+
+// WithHTTPTimeout returns a ClientOpt which sets an overall timeout on the
+// client's HTTP requests.
+func WithHTTPTimeout(timeout time.Duration) ClientOpt {
+	return func(c *Client) {
+		c.http.Timeout = timeout
+	}
+}
+
+// WithDialTimeout returns a ClientOpt which bounds how long establishing a
+// connection to the plugin may take, separately from the per-attempt request
+// timeout. The default is defaultDialTimeout. Dial failures surface as a
+// *net.OpError with Op "dial", so a custom retry classifier can tell a dead
+// endpoint apart from a live plugin answering slowly.
+func WithDialTimeout(d time.Duration) ClientOpt {
+	return func(c *Client) {
+		c.dialTimeout = d
+	}
+}
+
+// WithRetryPolicy returns a ClientOpt which sets the backoff schedule used
+// when calls to the plugin fail.
+func WithRetryPolicy(p RetryPolicy) ClientOpt {
+	return func(c *Client) {
+		c.retry = p
+	}
+}
+
+// WithRetryWindow returns a ClientOpt which bounds the overall time spent
+// retrying failed calls, overriding the retry policy's MaxElapsedTime while
+// leaving the backoff schedule untouched.
+func WithRetryWindow(d time.Duration) ClientOpt {
+	return func(c *Client) {
+		c.retry.MaxElapsedTime = d
+	}
+}
+
+// WithTLSConfig returns a ClientOpt which sets the TLS options used when
+// connecting to the plugin. A nil config is ignored.
+func WithTLSConfig(tlsConfig *tlsconfig.Options) ClientOpt {
+	return func(c *Client) {
+		c.tlsConfig = tlsConfig
+	}
+}
+
+// WithTransport returns a ClientOpt which makes the client use t instead of
+// constructing a transport from the plugin's address. Intended for tests.
+func WithTransport(t transport.Transport) ClientOpt {
+	return func(c *Client) {
+		c.http.Transport = t
+		c.requestFactory = t
+	}
+}
+
+// WithTLSClientCertificate returns a ClientOpt which makes the client obtain
+// its TLS client certificate from provider on every handshake, so rotated
+// certificates (e.g. short-lived ones from an internal CA) are picked up
+// without rebuilding the client.
+func WithTLSClientCertificate(provider func(*tls.CertificateRequestInfo) (*tls.Certificate, error)) ClientOpt {
+	return func(c *Client) {
+		c.certProvider = provider
+	}
+}
+
+// WithUserAgent returns a ClientOpt which sets the User-Agent header sent on
+// every request to the plugin, replacing the "docker/<version>" default.
+func WithUserAgent(ua string) ClientOpt {
+	return func(c *Client) {
+		c.userAgent = ua
+	}
+}
+
+// WithStaticHeaders returns a ClientOpt which sets the given headers on every
+// request to the plugin, e.g. a static auth header required by a proxy in
+// front of a remote plugin. Headers set per call via WithRequestHeaders
+// override them.
+func WithStaticHeaders(h http.Header) ClientOpt {
+	return func(c *Client) {
+		c.staticHeaders = h
+	}
+}
+
+// WithActivationGrace returns a ClientOpt for plugins behind socket
+// activation (e.g. systemd): the socket accepts connections immediately,
+// but the first response only arrives once the service behind it has
+// started. Until the client has seen a response, per-attempt timeouts are
+// stretched to at least d and the startup delay is not counted against the
+// retry budget, so a lazy start is not misread as a chain of failures.
+func WithActivationGrace(d time.Duration) ClientOpt {
+	return func(c *Client) {
+		c.activationGrace = d
+	}
 }
 
-// newClientWithTransport creates a new plugin client with a given transport.
-func newClientWithTransport(tr transport.Transport, timeout time.Duration) *Client {
-	return &Client{
-		http: &http.Client{
-			Transport: tr,
-			Timeout:   timeout,
-		},
-		requestFactory: tr,
+// WithGzipRequests returns a ClientOpt which makes the client gzip-compress
+// request bodies. Only enable this for plugins which are known to handle
+// compressed requests, e.g. because they advertise it in their manifest;
+// responses are negotiated per request and need no option.
+func WithGzipRequests() ClientOpt {
+	return func(c *Client) {
+		c.gzipRequests = true
 	}
 }
 
@@ -82,11 +441,106 @@ func newClientWithTransport(tr transport.Transport, timeout time.Duration) *Clie
 type Client struct {
 	http           *http.Client // http client to use
 	requestFactory transport.RequestFactory
+	retry          RetryPolicy
+	observer       ClientObserver
+	observerLabel  string
+	peerCred       *PeerCredPolicy
+	tuning         *TransportTuning
+	tlsConfig      *tlsconfig.Options
+	certProvider   func(*tls.CertificateRequestInfo) (*tls.Certificate, error)
+	userAgent      string
+	addr           string
+	transport      *switchableTransport // nil when the transport was supplied via WithTransport
+	middlewares    []Middleware
+	gzipRequests   bool
+	limiter         *concurrencyLimiter
+	cache           *responseCache
+	signing         *SigningPolicy
+	activationGrace time.Duration
+	dialTimeout     time.Duration
+	staticHeaders   http.Header
+
+	mu                sync.Mutex // protects negotiatedVersion, handshake, and activated
+	negotiatedVersion string
+	handshake         *ExtendedManifest
+	activated         bool
+}
+
+// NegotiatedVersion returns the plugin protocol version mimetype the plugin
+// most recently answered with, or an empty string before any call completed
+// (or when the plugin never declared one). Driver proxies can use it to gate
+// request fields only newer protocol versions understand.
+func (c *Client) NegotiatedVersion() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.negotiatedVersion
+}
+
+func (c *Client) setNegotiatedVersion(version string) {
+	c.mu.Lock()
+	c.negotiatedVersion = version
+	c.mu.Unlock()
+}
+
+func (c *Client) isActivated() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.activated
+}
+
+func (c *Client) markActivated() {
+	c.mu.Lock()
+	c.activated = true
+	c.mu.Unlock()
 }
 
 // RequestOpts is the set of options that can be passed into a request
 type RequestOpts struct {
+	// Timeout bounds each individual attempt at the request.
 	Timeout time.Duration
+
+	// Context bounds the whole interaction with the plugin, including
+	// retries. Its deadline, when present, takes the place of the retry
+	// policy's MaxElapsedTime as the overall budget; Timeout still applies
+	// per attempt within it.
+	Context context.Context
+
+	// RetryPolicy overrides the client's retry policy for this call only.
+	RetryPolicy *RetryPolicy
+
+	// RetryWindow overrides the overall time budget for retrying this call
+	// only, leaving the rest of the retry policy untouched.
+	RetryWindow time.Duration
+
+	// Rewind is called before every attempt to obtain a fresh copy of the
+	// request body. Supplying it makes a request with an otherwise
+	// non-replayable body safe to retry.
+	Rewind func() (io.Reader, error)
+
+	// RetryClassifier overrides which failures are retried for this call
+	// only, for plugins with unusual failure modes.
+	RetryClassifier RetryClassifier
+
+	// Progress, when set, is invoked periodically with the number of body
+	// bytes sent so far. The count restarts for each attempt.
+	Progress func(written int64)
+
+	// HedgeDelay, when positive, launches an extra copy of the request each
+	// time this delay passes without a response, up to HedgeMax extra
+	// copies. See WithHedging.
+	HedgeDelay time.Duration
+	HedgeMax   int
+
+	// LimitExempt lets the request bypass the client's concurrency limit.
+	LimitExempt bool
+
+	// CacheTTL, when positive, allows the call to be served from the
+	// client's response cache. See WithRequestCacheable.
+	CacheTTL time.Duration
+
+	// Headers are set on the request for this call only, overriding any
+	// static headers the client was configured with.
+	Headers http.Header
 }
 
 // WithRequestTimeout sets a timeout duration for plugin requests
@@ -96,12 +550,272 @@ func WithRequestTimeout(t time.Duration) func(*RequestOpts) {
 	}
 }
 
+// WithRequestContext bounds the whole interaction with the plugin, including
+// retries, by ctx.
+func WithRequestContext(ctx context.Context) func(*RequestOpts) {
+	return func(o *RequestOpts) {
+		o.Context = ctx
+	}
+}
+
+// WithRequestRetryPolicy sets the backoff schedule used when this request
+// fails, overriding the client's policy.
+func WithRequestRetryPolicy(p RetryPolicy) func(*RequestOpts) {
+	return func(o *RequestOpts) {
+		o.RetryPolicy = &p
+	}
+}
+
+// WithRequestRetryWindow bounds the overall time spent retrying this call,
+// overriding the retry policy's MaxElapsedTime. Interactive operations can
+// set a short window without having to restate the backoff schedule.
+func WithRequestRetryWindow(d time.Duration) func(*RequestOpts) {
+	return func(o *RequestOpts) {
+		o.RetryWindow = d
+	}
+}
+
+// RetryClassifier decides whether a failed attempt should be retried. status
+// is the HTTP status code the plugin responded with, or zero when the request
+// did not complete, in which case err is the transport-level error.
+type RetryClassifier func(status int, err error) bool
+
+// defaultRetryClassifier retries transient failures only: timeouts,
+// connections refused, reset, or dropped while the plugin (re)starts, and the
+// gateway statuses a proxy in front of a plugin returns while it is away.
+// Deterministic failures such as a 400 are surfaced immediately instead of
+// burning the whole backoff window repeating them.
+func defaultRetryClassifier(status int, err error) bool {
+	if err == nil {
+		switch status {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	var nerr net.Error
+	if errors.As(err, &nerr) && nerr.Timeout() {
+		return true
+	}
+	// the plugin dropped the connection mid-request, e.g. while restarting
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		switch errno {
+		case syscall.ECONNREFUSED, syscall.ECONNRESET, syscall.ENOENT:
+			// the plugin's socket is not there (yet), or it is not
+			// accepting connections
+			return true
+		}
+		return false
+	}
+	var oerr *net.OpError
+	return errors.As(err, &oerr)
+}
+
+// WithRequestRetryClassifier overrides the decision of which failures are
+// retried for this request only.
+func WithRequestRetryClassifier(classify RetryClassifier) func(*RequestOpts) {
+	return func(o *RequestOpts) {
+		o.RetryClassifier = classify
+	}
+}
+
+// WithRequestHeaders sets the given headers on this request only. They are
+// applied last, so they override the client's static headers on key
+// collisions.
+func WithRequestHeaders(h http.Header) func(*RequestOpts) {
+	return func(o *RequestOpts) {
+		o.Headers = h
+	}
+}
+
+// WithRequestProgress registers fn to be invoked with the running count of
+// body bytes sent while the request body is streamed to the plugin.
+func WithRequestProgress(fn func(written int64)) func(*RequestOpts) {
+	return func(o *RequestOpts) {
+		o.Progress = fn
+	}
+}
+
+// WithRequestRewind supplies a function returning a fresh copy of the request
+// body, allowing a request which would otherwise not be retryable to be
+// replayed safely.
+func WithRequestRewind(rewind func() (io.Reader, error)) func(*RequestOpts) {
+	return func(o *RequestOpts) {
+		o.Rewind = rewind
+	}
+}
+
+// WithHedging launches up to maxExtra additional copies of the request, one
+// each time delay passes without a response, and takes whichever response
+// arrives first, cancelling the losers. This cuts tail latency against a
+// plugin with the occasional slow replica, at the cost of the plugin
+// sometimes observing the request more than once, so it must only be used
+// for idempotent calls. Hedged copies do not count as retries; if the
+// winning response is a retryable failure the normal retry schedule still
+// applies. Hedging needs a replayable body (see WithRequestRewind) and is
+// ignored without one.
+func WithHedging(delay time.Duration, maxExtra int) func(*RequestOpts) {
+	return func(o *RequestOpts) {
+		o.HedgeDelay = delay
+		o.HedgeMax = maxExtra
+	}
+}
+
+// replayableBody returns a function yielding the request body for each
+// attempt, and whether the body can be replayed for a retry. A nil body, a
+// bytes or strings reader, or a caller-provided rewind function are
+// replayable; any other reader may already have been partially consumed by a
+// failed attempt, and retrying would send a truncated body to the plugin.
+func replayableBody(data io.Reader, rewind func() (io.Reader, error)) (func() (io.Reader, error), bool) {
+	if rewind != nil {
+		return rewind, true
+	}
+	switch d := data.(type) {
+	case nil:
+		return func() (io.Reader, error) { return nil, nil }, true
+	case *bytes.Buffer:
+		// snapshot the contents so every attempt sends the full body
+		b := d.Bytes()
+		return func() (io.Reader, error) { return bytes.NewReader(b), nil }, true
+	case *bytes.Reader:
+		return func() (io.Reader, error) {
+			if _, err := d.Seek(0, io.SeekStart); err != nil {
+				return nil, err
+			}
+			return d, nil
+		}, true
+	case *strings.Reader:
+		return func() (io.Reader, error) {
+			if _, err := d.Seek(0, io.SeekStart); err != nil {
+				return nil, err
+			}
+			return d, nil
+		}, true
+	default:
+		return func() (io.Reader, error) { return data, nil }, false
+	}
+}
+
+// progressInterval is how many body bytes are sent between progress
+// callbacks.
+const progressInterval = 512 * 1024
+
+// progressReader invokes fn with the running byte count as the body is
+// consumed by the transport, at most once per progressInterval bytes and once
+// more when the body is exhausted.
+type progressReader struct {
+	r        io.Reader
+	fn       func(written int64)
+	written  int64
+	reported int64
+}
+
+func (pr *progressReader) Read(p []byte) (int, error) {
+	n, err := pr.r.Read(p)
+	pr.written += int64(n)
+	if pr.written-pr.reported >= progressInterval || (err == io.EOF && pr.written != pr.reported) {
+		pr.reported = pr.written
+		pr.fn(pr.written)
+	}
+	return n, err
+}
+
+// gzipCompress buffers the compressed contents of r so the body can be sent
+// (and, being a bytes reader, resent on retry) with a known length.
+func gzipCompress(r io.Reader) (io.Reader, error) {
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	if _, err := io.Copy(gzw, r); err != nil {
+		return nil, err
+	}
+	if err := gzw.Close(); err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(buf.Bytes()), nil
+}
+
+// TrailerPluginError is the HTTP trailer a plugin can set to report an error
+// which occurred after the response status was already sent, e.g. halfway
+// through producing a stream.
+const TrailerPluginError = "Docker-Plugin-Error"
+
+// streamErrorReader converts a terminal error reported by the plugin in the
+// response trailers into an error surfaced from the final Read and from
+// Close, so callers consuming a stream learn why it ended early. Responses
+// without the trailer behave exactly as before.
+type streamErrorReader struct {
+	io.ReadCloser
+	resp    *http.Response
+	method  string
+	checked bool
+	err     error
+}
+
+func (r *streamErrorReader) trailerErr() error {
+	if !r.checked {
+		r.checked = true
+		if msg := r.resp.Trailer.Get(TrailerPluginError); msg != "" {
+			r.err = errors.Errorf("%s: plugin error during stream: %s", r.method, msg)
+		}
+	}
+	return r.err
+}
+
+func (r *streamErrorReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if err == io.EOF {
+		// trailers are only populated once the body has been fully read
+		if terr := r.trailerErr(); terr != nil {
+			return n, terr
+		}
+	}
+	return n, err
+}
+
+func (r *streamErrorReader) Close() error {
+	err := r.ReadCloser.Close()
+	if terr := r.trailerErr(); terr != nil {
+		return terr
+	}
+	return err
+}
+
+// decompressedBody returns a reader over the response body, transparently
+// decompressing it if the plugin responded with gzip. The transport's own
+// decompression is bypassed because we set Accept-Encoding explicitly.
+func decompressedBody(resp *http.Response) (io.ReadCloser, error) {
+	if resp.Header.Get("Content-Encoding") != "gzip" {
+		return resp.Body, nil
+	}
+	gzr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return ioutils.NewReadCloserWrapper(gzr, func() error {
+		err := gzr.Close()
+		if cErr := resp.Body.Close(); err == nil {
+			err = cErr
+		}
+		return err
+	}), nil
+}
+
 // Call calls the specified method with the specified arguments for the plugin.
 // It will retry for 30 seconds if a failure occurs when calling.
 func (c *Client) Call(serviceMethod string, args, ret interface{}) error {
 	return c.CallWithOptions(serviceMethod, args, ret)
 }
 
+// CallWithContext is just like Call, except the whole interaction with the
+// plugin, including retries, is bounded by ctx.
+func (c *Client) CallWithContext(ctx context.Context, serviceMethod string, args, ret interface{}, opts ...func(*RequestOpts)) error {
+	return c.CallWithOptions(serviceMethod, args, ret, append(opts, WithRequestContext(ctx))...)
+}
+
 // CallWithOptions is just like call except it takes options
 func (c *Client) CallWithOptions(serviceMethod string, args interface{}, ret interface{}, opts ...func(*RequestOpts)) error {
 	var buf bytes.Buffer
@@ -110,11 +824,45 @@ func (c *Client) CallWithOptions(serviceMethod string, args interface{}, ret int
 			return err
 		}
 	}
+
+	var ropts RequestOpts
+	for _, o := range opts {
+		o(&ropts)
+	}
+	var key string
+	if c.cache != nil && ropts.CacheTTL > 0 {
+		key = cacheKey(serviceMethod, buf.Bytes())
+		if cached, ok := c.cache.get(key); ok {
+			if ret == nil {
+				return nil
+			}
+			// decoding the cached bytes afresh deep-copies them into the
+			// caller's output
+			return json.Unmarshal(cached, ret)
+		}
+	}
+
 	body, err := c.callWithRetry(serviceMethod, &buf, true, opts...)
 	if err != nil {
 		return err
 	}
 	defer body.Close()
+	if key != "" {
+		b, err := ioutil.ReadAll(body)
+		if err != nil {
+			logrus.Errorf("%s: error reading plugin resp: %v", serviceMethod, err)
+			return err
+		}
+		c.cache.put(key, serviceMethod, b, ropts.CacheTTL)
+		if ret == nil {
+			return nil
+		}
+		if err := json.Unmarshal(b, ret); err != nil {
+			logrus.Errorf("%s: error reading plugin resp: %v", serviceMethod, err)
+			return err
+		}
+		return nil
+	}
 	if ret != nil {
 		if err := json.NewDecoder(body).Decode(&ret); err != nil {
 			logrus.Errorf("%s: error reading plugin resp: %v", serviceMethod, err)
@@ -126,16 +874,216 @@ func (c *Client) CallWithOptions(serviceMethod string, args interface{}, ret int
 
 // Stream calls the specified method with the specified arguments for the plugin and returns the response body
 func (c *Client) Stream(serviceMethod string, args interface{}) (io.ReadCloser, error) {
+	return c.StreamWithOptions(serviceMethod, args)
+}
+
+// StreamWithContext is like Stream with the returned stream wired to ctx:
+// cancellation closes the response body and the underlying connection to the
+// plugin, and subsequent Reads report the context error. Long-lived streams
+// such as event feeds should use this so an abandoned consumer does not leak
+// the connection.
+func (c *Client) StreamWithContext(ctx context.Context, serviceMethod string, args interface{}, opts ...func(*RequestOpts)) (io.ReadCloser, error) {
+	body, err := c.StreamWithOptions(serviceMethod, args, append([]func(*RequestOpts){WithRequestContext(ctx)}, opts...)...)
+	if err != nil {
+		return nil, err
+	}
+	return newCtxReadCloser(ctx, body), nil
+}
+
+// StreamWithOptions is just like Stream except it takes options, e.g.
+// WithRequestTimeout to bound the initial exchange with the plugin.
+func (c *Client) StreamWithOptions(serviceMethod string, args interface{}, opts ...func(*RequestOpts)) (io.ReadCloser, error) {
 	var buf bytes.Buffer
 	if err := json.NewEncoder(&buf).Encode(args); err != nil {
 		return nil, err
 	}
-	return c.callWithRetry(serviceMethod, &buf, true)
+	start := time.Now()
+	body, err := c.callWithRetry(serviceMethod, &buf, true, append([]func(*RequestOpts){WithRequestLimitExempt()}, opts...)...)
+	if err != nil {
+		return nil, err
+	}
+	if c.observer != nil {
+		body = &firstByteReader{
+			ReadCloser: body,
+			method:     serviceMethod,
+			start:      start,
+			label:      c.observerLabel,
+			observer:   c.observer,
+		}
+	}
+	return body, nil
+}
+
+// ctxReadCloser ties a response stream to a context. Cancellation closes the
+// underlying body, which makes the HTTP transport tear down the connection
+// rather than try to reuse it, and later Reads report the context error
+// instead of the read failure the teardown provokes.
+type ctxReadCloser struct {
+	rc        io.ReadCloser
+	ctx       context.Context
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newCtxReadCloser(ctx context.Context, rc io.ReadCloser) io.ReadCloser {
+	r := &ctxReadCloser{rc: rc, ctx: ctx, done: make(chan struct{})}
+	go func() {
+		select {
+		case <-ctx.Done():
+			r.Close()
+		case <-r.done:
+		}
+	}()
+	return r
+}
+
+func (r *ctxReadCloser) Read(p []byte) (int, error) {
+	n, err := r.rc.Read(p)
+	if err != nil && r.ctx.Err() != nil {
+		return n, r.ctx.Err()
+	}
+	return n, err
+}
+
+func (r *ctxReadCloser) Close() error {
+	var err error
+	r.closeOnce.Do(func() {
+		close(r.done)
+		err = r.rc.Close()
+	})
+	return err
+}
+
+// defaultPingTimeout bounds a health probe when the caller's context carries
+// no deadline of its own.
+const defaultPingTimeout = 5 * time.Second
+
+// PingResult describes the outcome of a health probe against a plugin.
+type PingResult struct {
+	// Latency is the time the plugin took to answer the probe.
+	Latency time.Duration
+	// Implements is the subsystem list from the plugin's handshake response.
+	// It is only populated when the probe fell back to the activation
+	// endpoint; plugins serving the dedicated probe path answer with an
+	// empty body.
+	Implements []string
+}
+
+// Ping probes the plugin with a single request to check that it is
+// responsive. It bypasses the retry machinery entirely: one failed or slow
+// answer is exactly the signal a health check is after. If ctx carries no
+// deadline, defaultPingTimeout is applied.
+//
+// Plugins may serve a dedicated lightweight "Plugin.Ping" path; for those
+// which do not, the probe falls back to the activation handshake and reports
+// the subsystems from its response.
+func (c *Client) Ping(ctx context.Context) (PingResult, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultPingTimeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	path := "Plugin.Ping"
+	resp, err := c.probe(ctx, path)
+	if err != nil {
+		return PingResult{}, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		// older plugins do not serve a probe path; the handshake endpoint is
+		// the next cheapest thing they all answer.
+		resp.Body.Close()
+		path = "Plugin.Activate"
+		if resp, err = c.probe(ctx, path); err != nil {
+			return PingResult{}, err
+		}
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return PingResult{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return PingResult{}, newPluginError(path, resp.StatusCode, requestid.FromContext(ctx), b)
+	}
+
+	res := PingResult{Latency: time.Since(start)}
+	var m Manifest
+	if err := json.Unmarshal(b, &m); err == nil {
+		res.Implements = m.Implements
+	}
+	return res, nil
+}
+
+// ExtendedManifest is the reply to the extended handshake: the legacy
+// manifest plus the optional protocol extensions the plugin advertises for
+// each implemented interface. Legacy plugins simply leave Extensions empty.
+type ExtendedManifest struct {
+	Manifest
+	// Extensions maps an implemented interface to the protocol extensions
+	// the plugin supports for it, e.g. "VolumeDriver" -> ["mountID"].
+	Extensions map[string][]string
+}
+
+// Handshake posts to the activation endpoint and parses the plugin's reply
+// as an extended manifest. The result is cached on the client, so driver
+// proxies can branch on the advertised capabilities through Supports
+// instead of probing with trial-and-error requests.
+func (c *Client) Handshake(ctx context.Context) (*ExtendedManifest, error) {
+	var m ExtendedManifest
+	if err := c.CallWithContext(ctx, "Plugin.Activate", nil, &m); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.handshake = &m
+	c.mu.Unlock()
+	return &m, nil
+}
+
+// Supports reports whether the plugin advertised the named protocol
+// extension for the given interface in its handshake. It reports false
+// before Handshake has completed, and always for legacy plugins which only
+// return the plain manifest.
+func (c *Client) Supports(iface, extension string) bool {
+	c.mu.Lock()
+	m := c.handshake
+	c.mu.Unlock()
+	if m == nil {
+		return false
+	}
+	for _, e := range m.Extensions[iface] {
+		if e == extension {
+			return true
+		}
+	}
+	return false
+}
+
+// probe performs a single request to serviceMethod with no body, no retries
+// and no response decompression.
+func (c *Client) probe(ctx context.Context, serviceMethod string) (*http.Response, error) {
+	req, err := c.requestFactory.NewRequest(serviceMethod, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", c.requestUserAgent())
+	return c.http.Do(req.WithContext(ctx))
 }
 
-// SendFile calls the specified method, and passes through the IO stream
+// SendFile calls the specified method, and passes through the IO stream. The
+// body is streamed to the plugin rather than buffered, so the call is only
+// retried when a rewind function is supplied via WithRequestRewind.
 func (c *Client) SendFile(serviceMethod string, data io.Reader, ret interface{}) error {
-	body, err := c.callWithRetry(serviceMethod, data, true)
+	return c.SendFileWithOptions(serviceMethod, data, ret)
+}
+
+// SendFileWithOptions is just like SendFile except it takes options, e.g.
+// WithRequestContext to allow cancelling the transfer mid-stream or
+// WithRequestProgress to observe how much of the body has been sent.
+func (c *Client) SendFileWithOptions(serviceMethod string, data io.Reader, ret interface{}, opts ...func(*RequestOpts)) error {
+	body, err := c.callWithRetry(serviceMethod, data, true, append([]func(*RequestOpts){WithRequestLimitExempt()}, opts...)...)
 	if err != nil {
 		return err
 	}
@@ -148,6 +1096,36 @@ func (c *Client) SendFile(serviceMethod string, data io.Reader, ret interface{})
 }
 
 func (c *Client) callWithRetry(serviceMethod string, data io.Reader, retry bool, reqOpts ...func(*RequestOpts)) (io.ReadCloser, error) {
+	var opts RequestOpts
+	for _, o := range reqOpts {
+		o(&opts)
+	}
+	if c.limiter == nil || opts.LimitExempt {
+		return c.doWithRetry(serviceMethod, data, retry, reqOpts...)
+	}
+
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := c.limiter.acquire(ctx); err != nil {
+		return nil, err
+	}
+	body, err := c.doWithRetry(serviceMethod, data, retry, reqOpts...)
+	if err != nil {
+		c.limiter.release()
+		return nil, err
+	}
+	// the slot stays occupied until the response has been consumed; the
+	// plugin is still doing work for us as long as the body is open
+	return ioutils.NewReadCloserWrapper(body, func() error {
+		err := body.Close()
+		c.limiter.release()
+		return err
+	}), nil
+}
+
+func (c *Client) doWithRetry(serviceMethod string, data io.Reader, retry bool, reqOpts ...func(*RequestOpts)) (io.ReadCloser, error) {
 	var retries int
 	start := time.Now()
 
@@ -156,81 +1134,409 @@ func (c *Client) callWithRetry(serviceMethod string, data io.Reader, retry bool,
 		o(&opts)
 	}
 
-	for {
-		req, err := c.requestFactory.NewRequest(serviceMethod, data)
+	policy := c.retry
+	if opts.RetryPolicy != nil {
+		policy = *opts.RetryPolicy
+	}
+	if opts.RetryWindow > 0 {
+		policy.MaxElapsedTime = opts.RetryWindow
+	}
+
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	// a socket-activated plugin accepts connections at once but answers its
+	// first request only when the service behind it has started; give that
+	// first interaction a separate grace period which does not eat into the
+	// retry budget
+	var grace time.Duration
+	if c.activationGrace > 0 && !c.isActivated() {
+		grace = c.activationGrace
+	}
+	budgetStart := start.Add(grace)
+
+	// with a deadline on the context, that is the overall budget for the
+	// interaction and the policy's MaxElapsedTime does not apply
+	deadline, hasDeadline := ctx.Deadline()
+	abortRetry := func(timeOff time.Duration) bool {
+		if hasDeadline {
+			return time.Now().Add(timeOff).After(deadline)
+		}
+		return policy.abort(budgetStart, timeOff)
+	}
+
+	newBody, replayable := replayableBody(data, opts.Rewind)
+
+	classify := RetryClassifier(defaultRetryClassifier)
+	if opts.RetryClassifier != nil {
+		classify = opts.RetryClassifier
+	}
+
+	// take the request ID from the API request this call is made on behalf
+	// of, or generate one; either way it is fixed here so every retry of
+	// this logical call carries the same ID
+	reqID := requestid.FromContext(ctx)
+	if reqID == "" {
+		reqID = stringid.TruncateID(stringid.GenerateRandomID())
+	}
+
+	// keep a bounded history of attempts so the final error tells the whole
+	// story, not just the last failure
+	var history []Attempt
+	observe := func(attemptStart time.Time, status int, final bool, err error) {
+		a := Attempt{Time: attemptStart, Duration: time.Since(attemptStart), Status: status}
+		if err != nil {
+			a.Err = err.Error()
+		}
+		if len(history) == maxAttemptHistory {
+			copy(history, history[1:])
+			history[len(history)-1] = a
+		} else {
+			history = append(history, a)
+		}
+		c.observeAttempt(serviceMethod, attemptStart, status, retries, final, err)
+	}
+	fail := func(err error) error {
+		return &CallError{method: serviceMethod, Err: err, history: history}
+	}
+
+	buildReq := func() (*http.Request, error) {
+		body, err := newBody()
+		if err != nil {
+			return nil, err
+		}
+		if opts.Progress != nil && body != nil {
+			body = &progressReader{r: body, fn: opts.Progress}
+		}
+		// non-replayable bodies are streamed straight through; compressing
+		// them would mean buffering the whole payload in memory
+		if c.gzipRequests && body != nil && replayable {
+			if body, err = gzipCompress(body); err != nil {
+				return nil, err
+			}
+		}
+		req, err := c.requestFactory.NewRequest(serviceMethod, body)
 		if err != nil {
 			return nil, err
 		}
+		req.Header.Set("User-Agent", c.requestUserAgent())
+		req.Header.Set(requestid.HeaderKey, reqID)
+		if tc := traceContextFrom(ctx); tc.Traceparent != "" {
+			req.Header.Set(TraceparentHeader, tc.Traceparent)
+			if tc.Tracestate != "" {
+				req.Header.Set(TracestateHeader, tc.Tracestate)
+			}
+		}
+		if c.signing != nil {
+			// the signature covers the uncompressed body; streamed bodies
+			// cannot be hashed before they are sent
+			bodyHash := transport.UnsignedPayload
+			if replayable {
+				fresh, err := newBody()
+				if err != nil {
+					return nil, err
+				}
+				var raw []byte
+				if fresh != nil {
+					if raw, err = ioutil.ReadAll(fresh); err != nil {
+						return nil, err
+					}
+				}
+				bodyHash = transport.BodyHash(raw)
+			}
+			c.signing.signRequest(req, bodyHash)
+		}
+		// advertise that we can handle compressed responses; plugins which
+		// ignore the header keep responding uncompressed
+		req.Header.Set("Accept-Encoding", "gzip")
+		if c.gzipRequests && body != nil && replayable {
+			req.Header.Set("Content-Encoding", "gzip")
+		}
+		// per-call headers win over everything set above
+		for k, vs := range opts.Headers {
+			req.Header[http.CanonicalHeaderKey(k)] = append([]string(nil), vs...)
+		}
+		return req, nil
+	}
 
-		cancelRequest := func() {}
-		if opts.Timeout > 0 {
-			var ctx context.Context
-			ctx, cancelRequest = context.WithTimeout(req.Context(), opts.Timeout)
-			req = req.WithContext(ctx)
+	for {
+		req, err := buildReq()
+		if err != nil {
+			return nil, err
 		}
 
-		resp, err := c.http.Do(req)
+		attemptStart := time.Now()
+		var resp *http.Response
+		var cancelRequest func()
+		if opts.HedgeDelay > 0 && opts.HedgeMax > 0 && replayable {
+			resp, cancelRequest, err = c.hedgedDo(ctx, req, buildReq, &opts)
+		} else {
+			reqCtx := ctx
+			cancelRequest = func() {}
+			if opts.Timeout > 0 {
+				attemptTimeout := opts.Timeout
+				if grace > attemptTimeout {
+					attemptTimeout = grace
+				}
+				reqCtx, cancelRequest = context.WithTimeout(ctx, attemptTimeout)
+			}
+			resp, err = c.http.Do(req.WithContext(reqCtx))
+		}
 		if err != nil {
 			cancelRequest()
-			if !retry {
-				return nil, err
+			if !retry || !classify(0, err) {
+				observe(attemptStart, 0, true, err)
+				return nil, fail(err)
+			}
+			if !replayable {
+				observe(attemptStart, 0, true, err)
+				return nil, fail(errors.Wrap(err, "not retryable: body already consumed"))
 			}
 
-			timeOff := backoff(retries)
-			if abort(start, timeOff) {
-				return nil, err
+			timeOff := policy.backoff(retries)
+			if abortRetry(timeOff) {
+				err = &RetriesExhaustedError{Attempts: retries + 1, Elapsed: time.Since(start), Err: err}
+				observe(attemptStart, 0, true, err)
+				return nil, fail(err)
 			}
+			observe(attemptStart, 0, false, err)
 			retries++
-			logrus.Warnf("Unable to connect to plugin: %s%s: %v, retrying in %v", req.URL.Host, req.URL.Path, err, timeOff)
-			time.Sleep(timeOff)
+			logrus.WithField("request-id", reqID).Warnf("Unable to connect to plugin: %s%s: %v, retrying in %v", req.URL.Host, req.URL.Path, err, timeOff)
+			select {
+			case <-ctx.Done():
+				return nil, fail(ctx.Err())
+			case <-time.After(timeOff):
+			}
 			continue
 		}
 
+		// any response at all means the service behind the socket is up
+		c.markActivated()
+
 		if resp.StatusCode != http.StatusOK {
-			b, err := ioutil.ReadAll(resp.Body)
-			resp.Body.Close()
+			timeOff := policy.backoff(retries)
+			retryable := retry && replayable && classify(resp.StatusCode, nil)
+			// a retryable status after the retry window has closed is an
+			// exhaustion; surface it as such so callers can tell it apart
+			// from a deterministic failure
+			exhaust := func(e error) error {
+				if !retryable {
+					return e
+				}
+				return &RetriesExhaustedError{Attempts: retries + 1, Elapsed: time.Since(start), Err: e}
+			}
+			if retryable && !abortRetry(timeOff) {
+				// transient server-side failure; drain the body so the
+				// connection can be reused and try again
+				io.Copy(ioutil.Discard, resp.Body)
+				resp.Body.Close()
+				cancelRequest()
+				observe(attemptStart, resp.StatusCode, false, nil)
+				retries++
+				logrus.WithField("request-id", reqID).Warnf("Plugin %s%s returned status %d, retrying in %v", req.URL.Host, req.URL.Path, resp.StatusCode, timeOff)
+				select {
+				case <-ctx.Done():
+					return nil, fail(ctx.Err())
+				case <-time.After(timeOff):
+				}
+				continue
+			}
+			respBody, dErr := decompressedBody(resp)
+			if dErr != nil {
+				resp.Body.Close()
+				cancelRequest()
+				stErr := exhaust(newPluginError(serviceMethod, resp.StatusCode, reqID, []byte(dErr.Error())))
+				observe(attemptStart, resp.StatusCode, true, stErr)
+				return nil, fail(stErr)
+			}
+			b, err := ioutil.ReadAll(respBody)
+			respBody.Close()
 			cancelRequest()
 			if err != nil {
-				return nil, &statusError{resp.StatusCode, serviceMethod, err.Error()}
+				stErr := exhaust(newPluginError(serviceMethod, resp.StatusCode, reqID, []byte(err.Error())))
+				observe(attemptStart, resp.StatusCode, true, stErr)
+				return nil, fail(stErr)
 			}
-
-			// Plugins' Response(s) should have an Err field indicating what went
-			// wrong. Try to unmarshal into ResponseErr. Otherwise fallback to just
-			// return the string(body)
-			type responseErr struct {
-				Err string
-			}
-			remoteErr := responseErr{}
-			if err := json.Unmarshal(b, &remoteErr); err == nil {
-				if remoteErr.Err != "" {
-					return nil, &statusError{resp.StatusCode, serviceMethod, remoteErr.Err}
-				}
+			stErr := exhaust(newPluginError(serviceMethod, resp.StatusCode, reqID, b))
+			observe(attemptStart, resp.StatusCode, true, stErr)
+			return nil, fail(stErr)
+		}
+		version, vErr := transport.ResponseVersion(resp)
+		if vErr != nil {
+			resp.Body.Close()
+			cancelRequest()
+			observe(attemptStart, resp.StatusCode, true, vErr)
+			return nil, fail(vErr)
+		}
+		if version != "" {
+			c.setNegotiatedVersion(version)
+		}
+		respBody, dErr := decompressedBody(resp)
+		if dErr != nil {
+			resp.Body.Close()
+			cancelRequest()
+			observe(attemptStart, resp.StatusCode, true, dErr)
+			return nil, fail(dErr)
+		}
+		if c.signing != nil && c.signing.Strict {
+			verified, vErr := c.signing.verifyResponse(req.URL.Path, resp, respBody)
+			if vErr != nil {
+				cancelRequest()
+				observe(attemptStart, resp.StatusCode, true, vErr)
+				return nil, fail(vErr)
 			}
-			// old way...
-			return nil, &statusError{resp.StatusCode, serviceMethod, string(b)}
+			respBody = verified
 		}
-		return ioutils.NewReadCloserWrapper(resp.Body, func() error {
-			err := resp.Body.Close()
+		observe(attemptStart, resp.StatusCode, true, nil)
+		respBody = &streamErrorReader{ReadCloser: respBody, resp: resp, method: serviceMethod}
+		return ioutils.NewReadCloserWrapper(respBody, func() error {
+			err := respBody.Close()
 			cancelRequest()
 			return err
 		}), nil
 	}
 }
 
-func backoff(retries int) time.Duration {
-	b, max := 1, defaultTimeOut
+type hedgeResult struct {
+	idx  int
+	resp *http.Response
+	err  error
+}
+
+// hedgedDo issues req and, each time opts.HedgeDelay passes without a
+// response, launches another copy built by buildReq, up to opts.HedgeMax
+// extra copies. The first successful response wins and the remaining
+// in-flight copies are cancelled immediately; an error is only returned once
+// every launched copy has failed, and it is the first one observed. The
+// returned cancel function releases the winning attempt's context and must be
+// called once its body has been consumed.
+func (c *Client) hedgedDo(ctx context.Context, req *http.Request, buildReq func() (*http.Request, error), opts *RequestOpts) (*http.Response, func(), error) {
+	results := make(chan hedgeResult, opts.HedgeMax+1)
+	var cancels []func()
+	launch := func(req *http.Request) {
+		attemptCtx, cancel := context.WithCancel(ctx)
+		if opts.Timeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		}
+		cancels = append(cancels, cancel)
+		idx := len(cancels) - 1
+		req = req.WithContext(attemptCtx)
+		go func() {
+			resp, err := c.http.Do(req)
+			results <- hedgeResult{idx: idx, resp: resp, err: err}
+		}()
+	}
+	launch(req)
+	outstanding := 1
+
+	timer := time.NewTimer(opts.HedgeDelay)
+	defer timer.Stop()
+
+	var firstErr error
+	var stopHedging bool
+	for {
+		select {
+		case r := <-results:
+			outstanding--
+			if r.err != nil {
+				cancels[r.idx]()
+				if firstErr == nil {
+					firstErr = r.err
+				}
+				if outstanding == 0 {
+					return nil, func() {}, firstErr
+				}
+				continue
+			}
+			for i, cancel := range cancels {
+				if i != r.idx {
+					cancel()
+				}
+			}
+			if outstanding > 0 {
+				// reap the cancelled losers in the background
+				go func(n int) {
+					for ; n > 0; n-- {
+						if lr := <-results; lr.resp != nil {
+							lr.resp.Body.Close()
+						}
+					}
+				}(outstanding)
+			}
+			return r.resp, cancels[r.idx], nil
+		case <-timer.C:
+			if firstErr == nil && !stopHedging && len(cancels) < opts.HedgeMax+1 {
+				extra, err := buildReq()
+				if err != nil {
+					// earlier copies are still in flight; just stop hedging
+					stopHedging = true
+					continue
+				}
+				launch(extra)
+				outstanding++
+				timer.Reset(opts.HedgeDelay)
+			}
+		}
+	}
+}
+
+// RetryPolicy configures the backoff schedule used when calls to a plugin
+// fail. The zero value disables waiting entirely; use defaultRetryPolicy for
+// the historical schedule.
+type RetryPolicy struct {
+	// InitialDelay is the delay before the first retry.
+	InitialDelay time.Duration
+	// Multiplier is the factor the delay grows by after each retry.
+	Multiplier float64
+	// MaxDelay caps the delay between consecutive retries.
+	MaxDelay time.Duration
+	// MaxElapsedTime is the overall window after which retrying is abandoned
+	// and the last error is returned.
+	MaxElapsedTime time.Duration
+	// Jitter is the maximum fraction of the computed delay (between 0 and 1)
+	// which is randomly added to or subtracted from it, to avoid thundering
+	// herds of retries against a recovering plugin.
+	Jitter float64
+}
+
+func defaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		InitialDelay:   1 * time.Second,
+		Multiplier:     2,
+		MaxDelay:       defaultTimeOut * time.Second,
+		MaxElapsedTime: defaultTimeOut * time.Second,
+	}
+}
+
+// backoff returns the time to wait before the given (zero-indexed) retry.
+func (p RetryPolicy) backoff(retries int) time.Duration {
+	b, max := float64(p.InitialDelay), float64(p.MaxDelay)
 	for b < max && retries > 0 {
-		b *= 2
+		b *= p.Multiplier
 		retries--
 	}
 	if b > max {
 		b = max
 	}
-	return time.Duration(b) * time.Second
+	if p.Jitter > 0 {
+		b += (rand.Float64()*2 - 1) * p.Jitter * b
+	}
+	return time.Duration(b)
+}
+
+// abort reports whether waiting timeOff for the next retry would exceed the
+// policy's overall window.
+func (p RetryPolicy) abort(start time.Time, timeOff time.Duration) bool {
+	return timeOff+time.Since(start) >= p.MaxElapsedTime
+}
+
+func backoff(retries int) time.Duration {
+	return defaultRetryPolicy().backoff(retries)
 }
 
 func abort(start time.Time, timeOff time.Duration) bool {
-	return timeOff+time.Since(start) >= time.Duration(defaultTimeOut)*time.Second
+	return defaultRetryPolicy().abort(start, timeOff)
 }
 
 func httpScheme(u *url.URL) string {
@@ -0,0 +1,15 @@
+// +build !windows
+
+package plugins // import "github.com/docker/docker/pkg/plugins"
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestNpipeUnsupported(t *testing.T) {
+	_, err := NewClientWithOpts("npipe:////./pipe/docker_test_plugin")
+	assert.ErrorContains(t, err, "protocol not available")
+	assert.ErrorContains(t, err, "npipe:////./pipe/docker_test_plugin")
+}
@@ -0,0 +1,32 @@
+package plugins // import "github.com/docker/docker/pkg/plugins"
+
+import (
+	"net/http"
+	"testing"
+
+	winio "github.com/Microsoft/go-winio"
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+func TestNpipeClient(t *testing.T) {
+	pipeName := `\\.\pipe\docker-plugin-test`
+	l, err := winio.ListenPipe(pipeName, nil)
+	assert.NilError(t, err)
+	defer l.Close()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/Test.Echo", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"Name":"plugin"}`))
+	})
+	server := &http.Server{Handler: mux}
+	go server.Serve(l)
+	defer server.Close()
+
+	c, err := NewClientWithOpts("npipe:////./pipe/docker-plugin-test")
+	assert.NilError(t, err)
+
+	var out struct{ Name string }
+	assert.NilError(t, c.Call("Test.Echo", nil, &out))
+	assert.Check(t, is.Equal("plugin", out.Name))
+}
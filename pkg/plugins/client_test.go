@@ -2,16 +2,33 @@ package plugins // import "github.com/docker/docker/pkg/plugins"
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/json"
+	"fmt"
 	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/docker/docker/dockerversion"
+	"github.com/docker/docker/errdefs"
 	"github.com/docker/docker/pkg/plugins/transport"
+	"github.com/docker/docker/pkg/requestid"
 	"github.com/docker/go-connections/tlsconfig"
 	"github.com/pkg/errors"
 	"gotest.tools/v3/assert"
@@ -135,6 +152,389 @@ func TestAbortRetry(t *testing.T) {
 	}
 }
 
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{
+		InitialDelay:   100 * time.Millisecond,
+		Multiplier:     3,
+		MaxDelay:       time.Second,
+		MaxElapsedTime: 2 * time.Second,
+	}
+
+	cases := []struct {
+		retries    int
+		expTimeOff time.Duration
+	}{
+		{0, 100 * time.Millisecond},
+		{1, 300 * time.Millisecond},
+		{2, 900 * time.Millisecond},
+		{3, time.Second},
+		{10, time.Second},
+	}
+
+	for _, c := range cases {
+		if d := p.backoff(c.retries); d != c.expTimeOff {
+			t.Fatalf("Retry %v, expected %v, was %v\n", c.retries, c.expTimeOff, d)
+		}
+	}
+}
+
+func TestRetryPolicyAbort(t *testing.T) {
+	p := RetryPolicy{
+		InitialDelay:   100 * time.Millisecond,
+		Multiplier:     2,
+		MaxDelay:       time.Second,
+		MaxElapsedTime: 2 * time.Second,
+	}
+
+	cases := []struct {
+		timeOff  time.Duration
+		expAbort bool
+	}{
+		{100 * time.Millisecond, false},
+		{time.Second, false},
+		{2 * time.Second, true},
+		{3 * time.Second, true},
+	}
+
+	for _, c := range cases {
+		if a := p.abort(time.Now(), c.timeOff); a != c.expAbort {
+			t.Fatalf("Duration %v, expected %v, was %v\n", c.timeOff, c.expAbort, a)
+		}
+	}
+}
+
+func TestRetryPolicyJitter(t *testing.T) {
+	p := RetryPolicy{
+		InitialDelay:   time.Second,
+		Multiplier:     2,
+		MaxDelay:       30 * time.Second,
+		MaxElapsedTime: 30 * time.Second,
+		Jitter:         0.5,
+	}
+
+	base := 4 * time.Second
+	min, max := time.Duration(float64(base)*0.5), time.Duration(float64(base)*1.5)
+	for i := 0; i < 1000; i++ {
+		if d := p.backoff(2); d < min || d > max {
+			t.Fatalf("backoff with jitter out of bounds: %v not in [%v, %v]", d, min, max)
+		}
+	}
+}
+
+type recordingObserver struct {
+	mu       sync.Mutex
+	labels   []string
+	attempts []AttemptObservation
+	ttfb     []time.Duration
+}
+
+func (o *recordingObserver) ObserveAttempt(label string, obs AttemptObservation) {
+	o.mu.Lock()
+	o.labels = append(o.labels, label)
+	o.attempts = append(o.attempts, obs)
+	o.mu.Unlock()
+}
+
+func (o *recordingObserver) ObserveFirstByte(label, method string, d time.Duration) {
+	o.mu.Lock()
+	o.ttfb = append(o.ttfb, d)
+	o.mu.Unlock()
+}
+
+func TestClientObserverSuccess(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	mux.HandleFunc("/Test.Echo", func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(w, r.Body)
+	})
+
+	obs := &recordingObserver{}
+	c, err := NewClientWithOptions(addr, &tlsconfig.Options{InsecureSkipVerify: true}, WithObserver("p1", obs))
+	assert.NilError(t, err)
+
+	m := Manifest{[]string{"VolumeDriver", "NetworkDriver"}}
+	var output Manifest
+	assert.NilError(t, c.Call("Test.Echo", m, &output))
+
+	assert.Assert(t, is.Len(obs.attempts, 1))
+	a := obs.attempts[0]
+	assert.Check(t, is.Equal(obs.labels[0], "p1"))
+	assert.Check(t, is.Equal(a.Method, "Test.Echo"))
+	assert.Check(t, is.Equal(a.Status, http.StatusOK))
+	assert.Check(t, is.Equal(a.Retry, 0))
+	assert.Check(t, a.Final)
+	assert.Check(t, a.Err == nil)
+
+	// streaming calls additionally report time-to-first-byte
+	body, err := c.Stream("Test.Echo", m)
+	assert.NilError(t, err)
+	var buf bytes.Buffer
+	_, err = io.Copy(&buf, body)
+	assert.NilError(t, err)
+	assert.NilError(t, body.Close())
+	assert.Check(t, is.Len(obs.ttfb, 1))
+	assert.Assert(t, is.Len(obs.attempts, 2))
+}
+
+func TestClientObserverRetryThenSuccess(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "plugin.sock")
+
+	obs := &recordingObserver{}
+	c, err := NewClientWithOptions("unix://"+sock, nil,
+		WithObserver("p1", obs),
+		WithRetryPolicy(RetryPolicy{
+			InitialDelay:   10 * time.Millisecond,
+			Multiplier:     1,
+			MaxDelay:       10 * time.Millisecond,
+			MaxElapsedTime: 10 * time.Second,
+		}),
+	)
+	assert.NilError(t, err)
+
+	// bring the plugin up only after the first attempts have failed
+	srvMux := http.NewServeMux()
+	srvMux.HandleFunc("/Test.Echo", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	})
+	var l net.Listener
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		var err error
+		l, err = net.Listen("unix", sock)
+		if err != nil {
+			return
+		}
+		http.Serve(l, srvMux)
+	}()
+	defer func() {
+		if l != nil {
+			l.Close()
+		}
+	}()
+
+	assert.NilError(t, c.Call("Test.Echo", nil, nil))
+
+	assert.Assert(t, len(obs.attempts) >= 2)
+	last := obs.attempts[len(obs.attempts)-1]
+	assert.Check(t, last.Final)
+	assert.Check(t, is.Equal(last.Status, http.StatusOK))
+	assert.Check(t, last.Err == nil)
+	for i, a := range obs.attempts[:len(obs.attempts)-1] {
+		assert.Check(t, !a.Final)
+		assert.Check(t, a.Err != nil)
+		assert.Check(t, is.Equal(a.Status, 0))
+		assert.Check(t, is.Equal(a.Retry, i))
+	}
+}
+
+func TestClientObserverFailure(t *testing.T) {
+	obs := &recordingObserver{}
+	c, err := NewClientWithOptions("tcp://127.0.0.1:1", &tlsconfig.Options{InsecureSkipVerify: true},
+		WithObserver("p1", obs),
+		WithRetryPolicy(RetryPolicy{
+			InitialDelay:   10 * time.Millisecond,
+			Multiplier:     1,
+			MaxDelay:       10 * time.Millisecond,
+			MaxElapsedTime: 50 * time.Millisecond,
+		}),
+	)
+	assert.NilError(t, err)
+
+	_, err = c.callWithRetry("Test.Method", nil, true)
+	assert.Assert(t, err != nil)
+
+	assert.Assert(t, len(obs.attempts) >= 1)
+	last := obs.attempts[len(obs.attempts)-1]
+	assert.Check(t, last.Final)
+	assert.Check(t, last.Err != nil)
+	assert.Check(t, is.Equal(last.Status, 0))
+	for _, a := range obs.attempts[:len(obs.attempts)-1] {
+		assert.Check(t, !a.Final)
+	}
+}
+
+// startDelayedServer serves handler on a unix socket at sock after delay,
+// so that a client's first attempts fail and force a retry.
+func startDelayedServer(t *testing.T, sock string, delay time.Duration, handler http.Handler) {
+	t.Helper()
+	var l net.Listener
+	go func() {
+		time.Sleep(delay)
+		var err error
+		l, err = net.Listen("unix", sock)
+		if err != nil {
+			return
+		}
+		http.Serve(l, handler)
+	}()
+	t.Cleanup(func() {
+		if l != nil {
+			l.Close()
+		}
+	})
+}
+
+func fastRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		InitialDelay:   10 * time.Millisecond,
+		Multiplier:     1,
+		MaxDelay:       10 * time.Millisecond,
+		MaxElapsedTime: 10 * time.Second,
+	}
+}
+
+func TestRetryReplayableBody(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "plugin.sock")
+	payload := `{"name":"v1"}`
+
+	var (
+		mu     sync.Mutex
+		bodies []string
+	)
+	h := http.NewServeMux()
+	h.HandleFunc("/Test.Create", func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		io.Copy(&buf, r.Body)
+		mu.Lock()
+		bodies = append(bodies, buf.String())
+		mu.Unlock()
+		w.Write([]byte("{}"))
+	})
+	startDelayedServer(t, sock, 100*time.Millisecond, h)
+
+	c, err := NewClientWithOptions("unix://"+sock, nil, WithRetryPolicy(fastRetryPolicy()))
+	assert.NilError(t, err)
+
+	body, err := c.callWithRetry("Test.Create", bytes.NewReader([]byte(payload)), true)
+	assert.NilError(t, err)
+	assert.NilError(t, body.Close())
+
+	// the attempt which got through must have seen the complete body
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Assert(t, is.Len(bodies, 1))
+	assert.Check(t, is.Equal(bodies[0], payload))
+}
+
+func TestRetryNonReplayableBody(t *testing.T) {
+	p := fastRetryPolicy()
+	p.MaxElapsedTime = 50 * time.Millisecond
+	c, err := NewClientWithOptions("tcp://127.0.0.1:1", &tlsconfig.Options{InsecureSkipVerify: true}, WithRetryPolicy(p))
+	assert.NilError(t, err)
+
+	// wrap the reader so the replayable fast path does not recognize it
+	reader := struct{ io.Reader }{strings.NewReader(`{"name":"v1"}`)}
+	_, err = c.callWithRetry("Test.Create", reader, true)
+	assert.ErrorContains(t, err, "not retryable: body already consumed")
+}
+
+func TestRetryWithRewind(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "plugin.sock")
+	payload := `{"name":"v1"}`
+
+	var (
+		mu     sync.Mutex
+		bodies []string
+	)
+	h := http.NewServeMux()
+	h.HandleFunc("/Test.Create", func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		io.Copy(&buf, r.Body)
+		mu.Lock()
+		bodies = append(bodies, buf.String())
+		mu.Unlock()
+		w.Write([]byte("{}"))
+	})
+	startDelayedServer(t, sock, 100*time.Millisecond, h)
+
+	c, err := NewClientWithOptions("unix://"+sock, nil, WithRetryPolicy(fastRetryPolicy()))
+	assert.NilError(t, err)
+
+	// a rewind function makes an otherwise non-replayable body retryable
+	reader := struct{ io.Reader }{strings.NewReader(payload)}
+	body, err := c.callWithRetry("Test.Create", reader, true, WithRequestRewind(func() (io.Reader, error) {
+		return strings.NewReader(payload), nil
+	}))
+	assert.NilError(t, err)
+	assert.NilError(t, body.Close())
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Assert(t, is.Len(bodies, 1))
+	assert.Check(t, is.Equal(bodies[0], payload))
+}
+
+// countingListener counts the connections accepted from a plugin client so
+// tests can tell connection reuse from fresh dials.
+type countingListener struct {
+	net.Listener
+	mu       sync.Mutex
+	accepted int
+}
+
+func (l *countingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil {
+		l.mu.Lock()
+		l.accepted++
+		l.mu.Unlock()
+	}
+	return conn, err
+}
+
+func (l *countingListener) count() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.accepted
+}
+
+func startCountingServer(t *testing.T) (*countingListener, string) {
+	t.Helper()
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	l := &countingListener{Listener: inner}
+	h := http.NewServeMux()
+	h.HandleFunc("/Test.Echo", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	})
+	go http.Serve(l, h)
+	t.Cleanup(func() { l.Close() })
+	return l, "tcp://" + inner.Addr().String()
+}
+
+func TestConnectionReuse(t *testing.T) {
+	l, addr := startCountingServer(t)
+
+	c, err := NewClientWithOptions(addr, &tlsconfig.Options{InsecureSkipVerify: true})
+	assert.NilError(t, err)
+
+	var out struct{}
+	for i := 0; i < 3; i++ {
+		assert.NilError(t, c.Call("Test.Echo", nil, &out))
+	}
+	assert.Check(t, is.Equal(l.count(), 1))
+
+	// flushing the pool forces the next call onto a fresh connection
+	c.CloseIdleConnections()
+	assert.NilError(t, c.Call("Test.Echo", nil, &out))
+	assert.Check(t, is.Equal(l.count(), 2))
+}
+
+func TestConnectionNoReuse(t *testing.T) {
+	l, addr := startCountingServer(t)
+
+	c, err := NewClientWithOptions(addr, &tlsconfig.Options{InsecureSkipVerify: true},
+		WithTransportTuning(TransportTuning{DisableKeepAlives: true}))
+	assert.NilError(t, err)
+
+	var out struct{}
+	for i := 0; i < 3; i++ {
+		assert.NilError(t, c.Call("Test.Echo", nil, &out))
+	}
+	assert.Check(t, is.Equal(l.count(), 3))
+}
+
 func TestClientScheme(t *testing.T) {
 	cases := map[string]string{
 		"tcp://127.0.0.1:8080":          "http",
@@ -274,3 +674,1072 @@ func (w *testRequestWrapper) NewRequest(path string, data io.Reader) (*http.Requ
 	req.URL = u
 	return req, nil
 }
+
+func TestGzipResponse(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	m := Manifest{[]string{"VolumeDriver"}}
+
+	mux.HandleFunc("/Test.GzipEcho", func(w http.ResponseWriter, r *http.Request) {
+		assert.Check(t, is.Equal("gzip", r.Header.Get("Accept-Encoding")))
+
+		header := w.Header()
+		header.Set("Content-Type", transport.VersionMimetype)
+		header.Set("Content-Encoding", "gzip")
+
+		gzw := gzip.NewWriter(w)
+		io.Copy(gzw, r.Body)
+		gzw.Close()
+	})
+
+	c, _ := NewClient(addr, &tlsconfig.Options{InsecureSkipVerify: true})
+	var output Manifest
+	assert.NilError(t, c.Call("Test.GzipEcho", m, &output))
+	assert.Check(t, is.DeepEqual(m, output))
+}
+
+func TestGzipRequest(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	m := Manifest{[]string{"VolumeDriver"}}
+
+	mux.HandleFunc("/Test.GzipEcho", func(w http.ResponseWriter, r *http.Request) {
+		assert.Check(t, is.Equal("gzip", r.Header.Get("Content-Encoding")))
+
+		gzr, err := gzip.NewReader(r.Body)
+		assert.NilError(t, err)
+		defer gzr.Close()
+
+		w.Header().Set("Content-Type", transport.VersionMimetype)
+		io.Copy(w, gzr)
+	})
+
+	c, err := NewClientWithOptions(addr, &tlsconfig.Options{InsecureSkipVerify: true}, WithGzipRequests())
+	assert.NilError(t, err)
+	var output Manifest
+	assert.NilError(t, c.Call("Test.GzipEcho", m, &output))
+	assert.Check(t, is.DeepEqual(m, output))
+}
+
+func TestGzipRequestRetry(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "plugin.sock")
+	startDelayedServer(t, sock, 100*time.Millisecond, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gzr, err := gzip.NewReader(r.Body)
+		assert.NilError(t, err)
+		defer gzr.Close()
+		io.Copy(w, gzr)
+	}))
+
+	c, err := NewClientWithOptions("unix://"+sock, nil, WithGzipRequests(), WithRetryPolicy(fastRetryPolicy()))
+	assert.NilError(t, err)
+
+	body, err := c.callWithRetry("Test.Echo", strings.NewReader("echo this"), true)
+	assert.NilError(t, err)
+	defer body.Close()
+	b, err := ioutil.ReadAll(body)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("echo this", string(b)))
+}
+
+func TestPluginError(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	mux.HandleFunc("/Test.NotFound", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"Err": "no such volume"}`))
+	})
+	mux.HandleFunc("/Test.Boom", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("something went bang"))
+	})
+
+	c, _ := NewClient(addr, &tlsconfig.Options{InsecureSkipVerify: true})
+
+	err := c.Call("Test.NotFound", nil, nil)
+	assert.Assert(t, err != nil)
+	var pe *PluginError
+	assert.Assert(t, errors.As(err, &pe))
+	assert.Check(t, is.Equal(http.StatusNotFound, pe.StatusCode))
+	assert.Check(t, is.Equal("Test.NotFound", pe.Path))
+	assert.Check(t, is.Equal("no such volume", pe.Err))
+	assert.Check(t, IsNotFound(err))
+	assert.Check(t, errdefs.IsNotFound(err))
+
+	err = c.Call("Test.Boom", nil, nil)
+	assert.Assert(t, errors.As(err, &pe))
+	assert.Check(t, is.Equal(http.StatusInternalServerError, pe.StatusCode))
+	assert.Check(t, is.Equal("something went bang", pe.Err))
+	assert.Check(t, is.Equal("something went bang", string(pe.Body)))
+	assert.Check(t, errdefs.IsSystem(err))
+	assert.Check(t, !IsNotFound(err))
+}
+
+func TestPing(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	mux.HandleFunc("/Plugin.Ping", func(w http.ResponseWriter, r *http.Request) {})
+
+	c, _ := NewClient(addr, &tlsconfig.Options{InsecureSkipVerify: true})
+	res, err := c.Ping(context.Background())
+	assert.NilError(t, err)
+	assert.Check(t, res.Latency > 0)
+	assert.Check(t, is.Len(res.Implements, 0))
+}
+
+func TestPingFallbackToActivate(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	// the plugin answers the handshake but not the probe path
+	mux.HandleFunc("/Plugin.Activate", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", transport.VersionMimetype)
+		json.NewEncoder(w).Encode(Manifest{Implements: []string{"VolumeDriver"}})
+	})
+
+	c, _ := NewClient(addr, &tlsconfig.Options{InsecureSkipVerify: true})
+	res, err := c.Ping(context.Background())
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual([]string{"VolumeDriver"}, res.Implements))
+}
+
+func TestPingTimeout(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	block := make(chan struct{})
+	defer close(block)
+	mux.HandleFunc("/Plugin.Ping", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-block:
+		case <-r.Context().Done():
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	c, _ := NewClient(addr, &tlsconfig.Options{InsecureSkipVerify: true})
+	_, err := c.Ping(ctx)
+	assert.Assert(t, err != nil)
+	assert.Check(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestNewClientWithOpts(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	mux.HandleFunc("/Test.Echo", func(w http.ResponseWriter, r *http.Request) {
+		assert.Check(t, is.Equal("engine-test", r.Header.Get("User-Agent")))
+		io.Copy(w, r.Body)
+	})
+
+	c, err := NewClientWithOpts(addr,
+		WithTLSConfig(&tlsconfig.Options{InsecureSkipVerify: true}),
+		WithUserAgent("engine-test"),
+		WithRetryPolicy(fastRetryPolicy()),
+	)
+	assert.NilError(t, err)
+
+	m := Manifest{[]string{"VolumeDriver"}}
+	var output Manifest
+	assert.NilError(t, c.Call("Test.Echo", m, &output))
+	assert.Check(t, is.DeepEqual(m, output))
+}
+
+func TestNewClientWithOptsTransport(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	mux.HandleFunc("/Test.Echo", func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(w, r.Body)
+	})
+
+	u, err := url.Parse(addr)
+	assert.NilError(t, err)
+
+	// the supplied transport wins; the address is never dialed
+	c, err := NewClientWithOpts("unix:///this/path/does/not/exist.sock",
+		WithTransport(transport.NewHTTPTransport(&http.Transport{}, "http", u.Host)))
+	assert.NilError(t, err)
+
+	m := Manifest{[]string{"VolumeDriver"}}
+	var output Manifest
+	assert.NilError(t, c.Call("Test.Echo", m, &output))
+	assert.Check(t, is.DeepEqual(m, output))
+}
+
+func TestNoRetryOnBadRequest(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	var requests int
+	mux.HandleFunc("/Test.BadParam", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"Err": "invalid parameter"}`))
+	})
+
+	c, _ := NewClientWithOpts(addr, WithRetryPolicy(fastRetryPolicy()))
+	err := c.Call("Test.BadParam", nil, nil)
+	assert.Assert(t, err != nil)
+	assert.Check(t, errdefs.IsInvalidParameter(err))
+	assert.Check(t, is.Equal(1, requests), "a deterministic failure must not be retried")
+}
+
+func TestRetryOnServiceUnavailable(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	var requests int
+	mux.HandleFunc("/Test.Flaky", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		io.Copy(w, r.Body)
+	})
+
+	c, _ := NewClientWithOpts(addr, WithRetryPolicy(fastRetryPolicy()))
+	m := Manifest{[]string{"VolumeDriver"}}
+	var output Manifest
+	assert.NilError(t, c.Call("Test.Flaky", m, &output))
+	assert.Check(t, is.DeepEqual(m, output))
+	assert.Check(t, is.Equal(2, requests))
+}
+
+func TestRequestRetryClassifier(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	var requests int
+	mux.HandleFunc("/Test.Teapot", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusTeapot)
+			return
+		}
+		w.Write([]byte("{}"))
+	})
+
+	c, _ := NewClientWithOpts(addr, WithRetryPolicy(fastRetryPolicy()))
+	err := c.CallWithOptions("Test.Teapot", nil, nil, WithRequestRetryClassifier(func(status int, err error) bool {
+		return status == http.StatusTeapot
+	}))
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(2, requests))
+}
+
+func TestCallWithContextDeadline(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	block := make(chan struct{})
+	defer close(block)
+	mux.HandleFunc("/Test.Block", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-block:
+		case <-r.Context().Done():
+		}
+	})
+
+	// a long per-attempt timeout does not stretch the overall ctx budget
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	c, _ := NewClientWithOpts(addr, WithRetryPolicy(fastRetryPolicy()))
+	start := time.Now()
+	err := c.CallWithContext(ctx, "Test.Block", nil, nil, WithRequestTimeout(10*time.Second))
+	assert.Assert(t, err != nil)
+	assert.Check(t, time.Since(start) < 5*time.Second)
+
+	var tErr interface{ Timeout() bool }
+	assert.Assert(t, errors.As(err, &tErr))
+	assert.Assert(t, tErr.Timeout())
+}
+
+func TestCallWithContextAttemptTimeout(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	var requests int
+	mux.HandleFunc("/Test.SlowOnce", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			// blow the per-attempt budget, but not the overall one
+			select {
+			case <-time.After(10 * time.Second):
+			case <-r.Context().Done():
+			}
+			return
+		}
+		w.Write([]byte("{}"))
+	})
+
+	// a generous overall budget with a short per-attempt timeout recovers
+	// from a single slow attempt by retrying
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	c, _ := NewClientWithOpts(addr, WithRetryPolicy(fastRetryPolicy()))
+	err := c.CallWithContext(ctx, "Test.SlowOnce", nil, nil, WithRequestTimeout(50*time.Millisecond))
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(2, requests))
+}
+
+func TestNegotiatedVersion(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	serveWithContentType := func(ct string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", ct)
+			w.Write([]byte("{}"))
+		}
+	}
+	mux.HandleFunc("/Test.Current", serveWithContentType(transport.VersionMimetype))
+	mux.HandleFunc("/Test.Old", serveWithContentType("application/vnd.docker.plugins.v1+json"))
+	mux.HandleFunc("/Test.Generic", serveWithContentType("application/json"))
+	mux.HandleFunc("/Test.Garbage", serveWithContentType("application/vnd.docker.plugins.v9000+json"))
+
+	c, _ := NewClient(addr, &tlsconfig.Options{InsecureSkipVerify: true})
+	assert.Check(t, is.Equal("", c.NegotiatedVersion()))
+
+	assert.NilError(t, c.Call("Test.Current", nil, nil))
+	assert.Check(t, is.Equal(transport.VersionMimetype, c.NegotiatedVersion()))
+
+	assert.NilError(t, c.Call("Test.Old", nil, nil))
+	assert.Check(t, is.Equal("application/vnd.docker.plugins.v1+json", c.NegotiatedVersion()))
+
+	// a generic content type carries no version and keeps the last one
+	assert.NilError(t, c.Call("Test.Generic", nil, nil))
+	assert.Check(t, is.Equal("application/vnd.docker.plugins.v1+json", c.NegotiatedVersion()))
+
+	err := c.Call("Test.Garbage", nil, nil)
+	assert.Assert(t, err != nil)
+	var vErr transport.UnknownVersionError
+	assert.Assert(t, errors.As(err, &vErr))
+	assert.Check(t, is.Equal("application/vnd.docker.plugins.v9000+json", vErr.Version))
+}
+
+func TestSendFileProgress(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	const payloadSize = 4 * 1024 * 1024
+	var received int64
+	mux.HandleFunc("/Test.Consume", func(w http.ResponseWriter, r *http.Request) {
+		n, _ := io.Copy(ioutil.Discard, r.Body)
+		received = n
+		w.Write([]byte("{}"))
+	})
+
+	var progress []int64
+	payload := bytes.NewReader(make([]byte, payloadSize))
+	c, _ := NewClientWithOpts(addr)
+	err := c.SendFileWithOptions("Test.Consume", io.MultiReader(payload), nil,
+		WithRequestProgress(func(written int64) {
+			progress = append(progress, written)
+		}))
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(int64(payloadSize), received))
+	assert.Assert(t, len(progress) > 1, "expected multiple progress callbacks")
+	assert.Check(t, is.Equal(int64(payloadSize), progress[len(progress)-1]))
+}
+
+func TestSendFileCancellation(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	mux.HandleFunc("/Test.Consume", func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(ioutil.Discard, r.Body)
+		w.Write([]byte("{}"))
+	})
+
+	pr, pw := io.Pipe()
+	defer pw.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c, _ := NewClientWithOpts(addr)
+	done := make(chan error, 1)
+	go func() {
+		done <- c.SendFileWithOptions("Test.Consume", pr, nil, WithRequestContext(ctx))
+	}()
+
+	_, err := pw.Write(make([]byte, 1024))
+	assert.NilError(t, err)
+	cancel()
+
+	select {
+	case err := <-done:
+		assert.Assert(t, err != nil)
+	case <-time.After(5 * time.Second):
+		t.Fatal("transfer did not stop after cancellation")
+	}
+}
+
+func TestReloadTLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	}))
+	defer srv.Close()
+
+	c, err := NewClientWithOpts(srv.URL, WithTLSConfig(&tlsconfig.Options{InsecureSkipVerify: true}))
+	assert.NilError(t, err)
+	assert.NilError(t, c.Call("Test.Echo", nil, nil))
+
+	// with verification enabled the self-signed server cert is rejected
+	assert.NilError(t, c.ReloadTLS(&tlsconfig.Options{}))
+	err = c.CallWithOptions("Test.Echo", nil, nil, WithRequestRetryPolicy(RetryPolicy{}))
+	assert.Assert(t, err != nil)
+
+	assert.NilError(t, c.ReloadTLS(&tlsconfig.Options{InsecureSkipVerify: true}))
+	assert.NilError(t, c.Call("Test.Echo", nil, nil))
+}
+
+// genClientCert generates a self-signed client certificate with the given
+// common name.
+func genClientCert(t *testing.T, cn string) *tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NilError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.NilError(t, err)
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestTLSClientCertificateProvider(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"CN": %q}`, r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	srv.StartTLS()
+	defer srv.Close()
+
+	var (
+		mu      sync.Mutex
+		current = genClientCert(t, "before")
+	)
+	c, err := NewClientWithOpts(srv.URL,
+		WithTLSConfig(&tlsconfig.Options{InsecureSkipVerify: true}),
+		WithTLSClientCertificate(func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			return current, nil
+		}),
+		// force a fresh handshake for every call so rotation is visible
+		WithTransportTuning(TransportTuning{DisableKeepAlives: true}),
+	)
+	assert.NilError(t, err)
+
+	var ret struct{ CN string }
+	assert.NilError(t, c.Call("Test.WhoAmI", nil, &ret))
+	assert.Check(t, is.Equal("before", ret.CN))
+
+	mu.Lock()
+	current = genClientCert(t, "after")
+	mu.Unlock()
+
+	assert.NilError(t, c.Call("Test.WhoAmI", nil, &ret))
+	assert.Check(t, is.Equal("after", ret.CN))
+}
+
+func TestStreamCleanEOF(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	mux.HandleFunc("/Test.Stream", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("some stream data"))
+	})
+
+	c, _ := NewClientWithOpts(addr)
+	body, err := c.Stream("Test.Stream", nil)
+	assert.NilError(t, err)
+	b, err := ioutil.ReadAll(body)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal("some stream data", string(b)))
+	assert.NilError(t, body.Close())
+}
+
+func TestStreamTrailerError(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	mux.HandleFunc("/Test.Stream", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", TrailerPluginError)
+		w.Write([]byte("partial data"))
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		w.Header().Set(TrailerPluginError, "backend exploded")
+	})
+
+	c, _ := NewClientWithOpts(addr)
+	body, err := c.Stream("Test.Stream", nil)
+	assert.NilError(t, err)
+	_, err = ioutil.ReadAll(body)
+	assert.Assert(t, err != nil)
+	assert.Check(t, is.ErrorContains(err, "plugin error during stream"))
+	assert.Check(t, is.ErrorContains(err, "backend exploded"))
+	err = body.Close()
+	assert.Check(t, is.ErrorContains(err, "backend exploded"))
+}
+
+func TestStreamConnectionDrop(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	mux.HandleFunc("/Test.Stream", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial data"))
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		panic(http.ErrAbortHandler)
+	})
+
+	c, _ := NewClientWithOpts(addr)
+	body, err := c.Stream("Test.Stream", nil)
+	assert.NilError(t, err)
+	_, err = ioutil.ReadAll(body)
+	assert.Assert(t, err != nil)
+	assert.Check(t, !strings.Contains(err.Error(), "plugin error during stream"))
+	body.Close()
+}
+
+func TestHedgedRequest(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	var mu sync.Mutex
+	var requests int
+	slowCancelled := make(chan struct{})
+	mux.HandleFunc("/Test.Hedge", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		n := requests
+		mu.Unlock()
+		if n == 1 {
+			// the slow replica: wait to be cancelled by the winner
+			select {
+			case <-r.Context().Done():
+				close(slowCancelled)
+			case <-time.After(10 * time.Second):
+			}
+			return
+		}
+		w.Write([]byte(`{"Addr":"fast"}`))
+	})
+
+	c, err := NewClientWithOpts(addr)
+	assert.NilError(t, err)
+
+	var out struct{ Addr string }
+	start := time.Now()
+	assert.NilError(t, c.CallWithOptions("Test.Hedge", nil, &out, WithHedging(20*time.Millisecond, 1)))
+	assert.Check(t, is.Equal("fast", out.Addr))
+	assert.Check(t, time.Since(start) < 5*time.Second, "hedged call waited for the slow attempt")
+
+	select {
+	case <-slowCancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("losing attempt was not cancelled")
+	}
+}
+
+func TestHedgedRequestFastPath(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	var mu sync.Mutex
+	var requests int
+	mux.HandleFunc("/Test.Hedge", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		w.Write([]byte("{}"))
+	})
+
+	c, err := NewClientWithOpts(addr)
+	assert.NilError(t, err)
+
+	assert.NilError(t, c.CallWithOptions("Test.Hedge", nil, nil, WithHedging(10*time.Second, 3)))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Check(t, is.Equal(1, requests), "no hedge should be launched when the first attempt answers in time")
+}
+
+func TestHedgedRequestAllFail(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+	// no handler: every attempt 404s, which is not retryable
+
+	c, err := NewClientWithOpts(addr, WithRetryPolicy(fastRetryPolicy()))
+	assert.NilError(t, err)
+
+	err = c.CallWithOptions("Test.Missing", nil, nil, WithHedging(10*time.Millisecond, 2))
+	assert.Check(t, err != nil)
+	assert.Check(t, errdefs.IsNotFound(err))
+}
+
+func TestHandshakeExtended(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	mux.HandleFunc("/Plugin.Activate", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", transport.VersionMimetype)
+		w.Write([]byte(`{"Implements":["VolumeDriver"],"Extensions":{"VolumeDriver":["mountID"]}}`))
+	})
+
+	c, err := NewClientWithOpts(addr)
+	assert.NilError(t, err)
+
+	m, err := c.Handshake(context.Background())
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual([]string{"VolumeDriver"}, m.Implements))
+
+	assert.Check(t, c.Supports("VolumeDriver", "mountID"))
+	assert.Check(t, !c.Supports("VolumeDriver", "checkpoint"))
+	assert.Check(t, !c.Supports("NetworkDriver", "mountID"))
+}
+
+func TestHandshakeLegacy(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	mux.HandleFunc("/Plugin.Activate", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", transport.VersionMimetype)
+		w.Write([]byte(`{"Implements":["NetworkDriver"]}`))
+	})
+
+	c, err := NewClientWithOpts(addr)
+	assert.NilError(t, err)
+
+	m, err := c.Handshake(context.Background())
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual([]string{"NetworkDriver"}, m.Implements))
+	assert.Check(t, is.Len(m.Extensions, 0))
+	assert.Check(t, !c.Supports("NetworkDriver", "anything"))
+}
+
+func TestRetryWindowExhausted(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	mux.HandleFunc("/Test.Unavailable", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	c, err := NewClientWithOpts(addr,
+		WithRetryPolicy(RetryPolicy{InitialDelay: 200 * time.Millisecond, Multiplier: 1, MaxDelay: 200 * time.Millisecond}),
+		WithRetryWindow(2*time.Second),
+	)
+	assert.NilError(t, err)
+
+	start := time.Now()
+	err = c.Call("Test.Unavailable", nil, nil)
+	elapsed := time.Since(start)
+
+	var rex *RetriesExhaustedError
+	assert.Assert(t, errors.As(err, &rex), "expected RetriesExhaustedError, got %T: %v", err, err)
+	assert.Check(t, rex.Attempts > 1, "expected more than one attempt, got %d", rex.Attempts)
+	assert.ErrorContains(t, err, fmt.Sprintf("gave up after %d attempts", rex.Attempts))
+
+	// the window bounds the waiting, not the number of retries
+	assert.Check(t, elapsed < 10*time.Second, "gave up after %v instead of the 2s window", elapsed)
+
+	// the underlying plugin error stays reachable through the wrapper
+	var pe *PluginError
+	assert.Check(t, errors.As(err, &pe))
+	assert.Check(t, is.Equal(http.StatusServiceUnavailable, pe.StatusCode))
+}
+
+func TestRequestRetryWindow(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	mux.HandleFunc("/Test.Unavailable", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	c, err := NewClientWithOpts(addr, WithRetryPolicy(fastRetryPolicy()))
+	assert.NilError(t, err)
+
+	start := time.Now()
+	err = c.CallWithOptions("Test.Unavailable", nil, nil, WithRequestRetryWindow(300*time.Millisecond))
+	elapsed := time.Since(start)
+
+	var rex *RetriesExhaustedError
+	assert.Assert(t, errors.As(err, &rex))
+	assert.Check(t, elapsed < 5*time.Second, "per-call window was not honored, took %v", elapsed)
+}
+
+func TestRequestIDStableAcrossRetries(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	var ids []string
+	mux.HandleFunc("/Test.Flaky", func(w http.ResponseWriter, r *http.Request) {
+		ids = append(ids, r.Header.Get(requestid.HeaderKey))
+		if len(ids) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("{}"))
+	})
+
+	c, err := NewClientWithOpts(addr, WithRetryPolicy(fastRetryPolicy()))
+	assert.NilError(t, err)
+	assert.NilError(t, c.Call("Test.Flaky", nil, nil))
+
+	assert.Assert(t, is.Len(ids, 2))
+	assert.Check(t, ids[0] != "", "request must carry an X-Request-ID")
+	assert.Check(t, is.Equal(ids[0], ids[1]), "retries of the same logical call must reuse the ID")
+}
+
+func TestRequestIDFromContext(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	mux.HandleFunc("/Test.Echo", func(w http.ResponseWriter, r *http.Request) {
+		assert.Check(t, is.Equal("api-request-1", r.Header.Get(requestid.HeaderKey)))
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"Err":"no such thing"}`))
+	})
+
+	c, err := NewClientWithOpts(addr)
+	assert.NilError(t, err)
+
+	ctx := requestid.WithID(context.Background(), "api-request-1")
+	err = c.CallWithContext(ctx, "Test.Echo", nil, nil)
+
+	var pe *PluginError
+	assert.Assert(t, errors.As(err, &pe))
+	assert.Check(t, is.Equal("api-request-1", pe.RequestID))
+	assert.ErrorContains(t, err, "request-id api-request-1")
+}
+
+func TestTraceContextForwarded(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	const parent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	var headers []string
+	mux.HandleFunc("/Test.Flaky", func(w http.ResponseWriter, r *http.Request) {
+		headers = append(headers, r.Header.Get(TraceparentHeader))
+		assert.Check(t, is.Equal("vendor=1", r.Header.Get(TracestateHeader)))
+		if len(headers) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("{}"))
+	})
+
+	c, err := NewClientWithOpts(addr, WithRetryPolicy(fastRetryPolicy()))
+	assert.NilError(t, err)
+
+	ctx := WithTraceContext(context.Background(), TraceContext{Traceparent: parent, Tracestate: "vendor=1"})
+	assert.NilError(t, c.CallWithContext(ctx, "Test.Flaky", nil, nil))
+
+	assert.Assert(t, is.Len(headers, 2))
+	assert.Check(t, is.Equal(parent, headers[0]))
+	assert.Check(t, is.Equal(parent, headers[1]), "retries must carry the trace context too")
+}
+
+func TestActivationGrace(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	var requests int
+	mux.HandleFunc("/Test.Echo", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			// the service behind the socket is still starting
+			time.Sleep(300 * time.Millisecond)
+		}
+		w.Write([]byte("{}"))
+	})
+
+	c, err := NewClientWithOpts(addr, WithActivationGrace(5*time.Second))
+	assert.NilError(t, err)
+
+	// well under the first-response delay, but the grace stretches it
+	err = c.CallWithOptions("Test.Echo", nil, nil, WithRequestTimeout(50*time.Millisecond))
+	assert.NilError(t, err, "first call should ride out the activation delay")
+	assert.Check(t, is.Equal(1, requests), "the slow first response must not be retried")
+
+	// once activated the configured per-attempt timeout is back in force
+	mux.HandleFunc("/Test.Slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+		w.Write([]byte("{}"))
+	})
+	err = c.CallWithOptions("Test.Slow", nil, nil, WithRequestTimeout(50*time.Millisecond), WithRequestRetryPolicy(RetryPolicy{}))
+	assert.Check(t, err != nil, "per-attempt timeout should apply after activation")
+}
+
+func TestCallErrorAttemptHistory(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	var calls int
+	mux.HandleFunc("/Test.Echo", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			http.Error(w, "warming up", http.StatusServiceUnavailable)
+			return
+		}
+		http.Error(w, "bad request", http.StatusBadRequest)
+	})
+
+	c, err := NewClientWithOpts(addr, WithRetryPolicy(fastRetryPolicy()))
+	assert.NilError(t, err)
+
+	err = c.Call("Test.Echo", nil, nil)
+	assert.Assert(t, err != nil)
+
+	var ce *CallError
+	assert.Assert(t, errors.As(err, &ce))
+	attempts := ce.Attempts()
+	assert.Assert(t, is.Len(attempts, 2))
+	assert.Check(t, is.Equal(http.StatusServiceUnavailable, attempts[0].Status))
+	assert.Check(t, is.Equal(http.StatusBadRequest, attempts[1].Status))
+	assert.Check(t, attempts[0].Err == "")
+	assert.Check(t, attempts[1].Err != "")
+	assert.Check(t, !attempts[1].Time.Before(attempts[0].Time))
+	assert.Check(t, ce.String() != "")
+
+	// the wrapper stays transparent to existing error classification
+	var pe *PluginError
+	assert.Assert(t, errors.As(err, &pe))
+	assert.Check(t, is.Equal(http.StatusBadRequest, pe.StatusCode))
+}
+
+func TestCallErrorAttemptHistoryTruncated(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	var calls int
+	mux.HandleFunc("/Test.Echo", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls <= maxAttemptHistory+4 {
+			http.Error(w, "warming up", http.StatusServiceUnavailable)
+			return
+		}
+		http.Error(w, "bad request", http.StatusBadRequest)
+	})
+
+	c, err := NewClientWithOpts(addr, WithRetryPolicy(RetryPolicy{
+		InitialDelay:   time.Millisecond,
+		Multiplier:     1,
+		MaxDelay:       time.Millisecond,
+		MaxElapsedTime: 10 * time.Second,
+	}))
+	assert.NilError(t, err)
+
+	err = c.Call("Test.Echo", nil, nil)
+	assert.Assert(t, err != nil)
+
+	var ce *CallError
+	assert.Assert(t, errors.As(err, &ce))
+	attempts := ce.Attempts()
+	assert.Assert(t, is.Len(attempts, maxAttemptHistory))
+	for _, a := range attempts[:len(attempts)-1] {
+		assert.Check(t, is.Equal(http.StatusServiceUnavailable, a.Status))
+	}
+	assert.Check(t, is.Equal(http.StatusBadRequest, attempts[len(attempts)-1].Status))
+}
+
+func TestDialTimeoutDeadEndpoint(t *testing.T) {
+	// reserved TEST-NET-1 address; nothing answers there, so the dial either
+	// times out or is rejected outright
+	c, err := NewClientWithOpts("tcp://192.0.2.1:32456", WithDialTimeout(100*time.Millisecond))
+	assert.NilError(t, err)
+
+	start := time.Now()
+	err = c.CallWithOptions("Test.Echo", nil, nil,
+		WithRequestRetryClassifier(func(status int, err error) bool { return false }))
+	assert.Assert(t, err != nil)
+	assert.Assert(t, time.Since(start) < 3*time.Second, "dial was not bounded by the dial timeout")
+
+	var oerr *net.OpError
+	assert.Assert(t, errors.As(err, &oerr))
+	assert.Check(t, is.Equal("dial", oerr.Op))
+}
+
+func TestDialTimeoutSlowResponse(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	mux.HandleFunc("/Test.Echo", func(w http.ResponseWriter, r *http.Request) {
+		// slower than the dial timeout; only connection establishment is
+		// bounded by it
+		time.Sleep(150 * time.Millisecond)
+		io.Copy(w, r.Body)
+	})
+
+	c, err := NewClientWithOpts(addr, WithDialTimeout(20*time.Millisecond))
+	assert.NilError(t, err)
+	assert.NilError(t, c.Call("Test.Echo", nil, nil))
+}
+
+func TestStaticHeaders(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	var (
+		mu   sync.Mutex
+		seen []string
+	)
+	mux.HandleFunc("/Test.Echo", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen = append(seen, r.Header.Get("X-Proxy-Auth"))
+		mu.Unlock()
+		io.Copy(w, r.Body)
+	})
+
+	c, err := NewClientWithOpts(addr, WithStaticHeaders(http.Header{"X-Proxy-Auth": []string{"secret"}}))
+	assert.NilError(t, err)
+
+	assert.NilError(t, c.Call("Test.Echo", nil, nil))
+
+	body, err := c.Stream("Test.Echo", nil)
+	assert.NilError(t, err)
+	body.Close()
+
+	assert.NilError(t, c.SendFile("Test.Echo", strings.NewReader("{}"), nil))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Check(t, is.DeepEqual([]string{"secret", "secret", "secret"}, seen))
+}
+
+func TestStaticHeadersResentOnRetry(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	var (
+		mu   sync.Mutex
+		seen []string
+	)
+	mux.HandleFunc("/Test.Echo", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen = append(seen, r.Header.Get("X-Proxy-Auth"))
+		n := len(seen)
+		mu.Unlock()
+		if n == 1 {
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+			return
+		}
+		io.Copy(w, r.Body)
+	})
+
+	c, err := NewClientWithOpts(addr,
+		WithStaticHeaders(http.Header{"X-Proxy-Auth": []string{"secret"}}),
+		WithRetryPolicy(fastRetryPolicy()))
+	assert.NilError(t, err)
+
+	assert.NilError(t, c.Call("Test.Echo", nil, nil))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Check(t, is.DeepEqual([]string{"secret", "secret"}, seen))
+}
+
+func TestRequestHeadersOverrideStatic(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	var got string
+	mux.HandleFunc("/Test.Echo", func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("X-Proxy-Auth")
+		io.Copy(w, r.Body)
+	})
+
+	c, err := NewClientWithOpts(addr, WithStaticHeaders(http.Header{"X-Proxy-Auth": []string{"static"}}))
+	assert.NilError(t, err)
+
+	assert.NilError(t, c.CallWithOptions("Test.Echo", nil, nil,
+		WithRequestHeaders(http.Header{"X-Proxy-Auth": []string{"per-call"}})))
+	assert.Check(t, is.Equal("per-call", got))
+}
+
+func TestDefaultUserAgent(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	var got string
+	mux.HandleFunc("/Test.Echo", func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("User-Agent")
+		io.Copy(w, r.Body)
+	})
+
+	c, err := NewClientWithOpts(addr)
+	assert.NilError(t, err)
+
+	assert.NilError(t, c.Call("Test.Echo", nil, nil))
+	assert.Check(t, is.Equal("docker/"+dockerversion.Version, got))
+}
+
+func TestStreamWithContextCancel(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	serverDone := make(chan struct{})
+	mux.HandleFunc("/Test.Stream", func(w http.ResponseWriter, r *http.Request) {
+		defer close(serverDone)
+		f := w.(http.Flusher)
+		w.WriteHeader(http.StatusOK)
+		f.Flush()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(10 * time.Millisecond):
+				if _, err := w.Write([]byte("tick\n")); err != nil {
+					return
+				}
+				f.Flush()
+			}
+		}
+	})
+
+	c, err := NewClientWithOpts(addr)
+	assert.NilError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	body, err := c.StreamWithContext(ctx, "Test.Stream", nil)
+	assert.NilError(t, err)
+	defer body.Close()
+
+	buf := make([]byte, 16)
+	_, err = body.Read(buf)
+	assert.NilError(t, err)
+
+	cancel()
+	select {
+	case <-serverDone:
+	case <-time.After(2 * time.Second):
+		t.Fatal("server did not observe the disconnect after cancellation")
+	}
+
+	_, err = body.Read(buf)
+	assert.Assert(t, errors.Is(err, context.Canceled), "expected context.Canceled, got %v", err)
+}
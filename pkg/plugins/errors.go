@@ -1,33 +1,194 @@
 package plugins // import "github.com/docker/docker/pkg/plugins"
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/errdefs"
 )
 
-type statusError struct {
-	status int
+// maxErrorBody caps how much of a plugin's error response body is retained on
+// a PluginError, to keep misbehaving plugins from bloating error values.
+const maxErrorBody = 1024
+
+// PluginError is the error returned when a plugin answers a call with a
+// non-200 status. It retains the HTTP status code and response body so that
+// callers can map plugin failures onto the right API error class.
+type PluginError struct {
+	// StatusCode is the HTTP status code the plugin responded with.
+	StatusCode int
+	// Path is the RPC-style path of the call that failed, e.g.
+	// "VolumeDriver.Mount".
+	Path string
+	// Err is the message from the plugin's standard {"Err": "..."} error
+	// response, or the raw body when the response was not in that shape.
+	Err string
+	// Body is the raw response body, truncated to maxErrorBody bytes.
+	Body []byte
+	// RequestID is the X-Request-ID the failing request was sent with, for
+	// correlation with the plugin's own logs.
+	RequestID string
+}
+
+// Error returns a formatted string for this error type
+func (e *PluginError) Error() string {
+	if e.RequestID != "" {
+		return fmt.Sprintf("%s: %v (request-id %s)", e.Path, e.Err, e.RequestID)
+	}
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+// newPluginError builds a PluginError from a plugin's error response body and
+// wraps it with the errdefs class matching the HTTP status, so that both
+// errors.As and errdefs.Is* checks work on the result.
+func newPluginError(path string, status int, reqID string, body []byte) error {
+	pe := &PluginError{
+		StatusCode: status,
+		Path:       path,
+		Body:       body,
+		RequestID:  reqID,
+	}
+	if len(pe.Body) > maxErrorBody {
+		pe.Body = pe.Body[:maxErrorBody]
+	}
+
+	// Plugins' Response(s) should have an Err field indicating what went
+	// wrong. Try to unmarshal into ResponseErr. Otherwise fallback to just
+	// return the string(body)
+	type responseErr struct {
+		Err string
+	}
+	remoteErr := responseErr{}
+	if err := json.Unmarshal(body, &remoteErr); err == nil && remoteErr.Err != "" {
+		pe.Err = remoteErr.Err
+	} else {
+		// old way...
+		pe.Err = string(body)
+	}
+	return errdefs.FromStatusCode(pe, status)
+}
+
+// RetriesExhaustedError is returned when a call is given up on because the
+// overall retry window elapsed. It wraps the error from the last attempt, so
+// errors.As and errdefs checks against the underlying failure keep working.
+type RetriesExhaustedError struct {
+	// Attempts is the number of attempts made, including the first one.
+	Attempts int
+	// Elapsed is the total time spent on the call, including backoff.
+	Elapsed time.Duration
+	// Err is the error from the last attempt.
+	Err error
+}
+
+// Error returns a formatted string for this error type
+func (e *RetriesExhaustedError) Error() string {
+	return fmt.Sprintf("%v (gave up after %d attempts over %v)", e.Err, e.Attempts, e.Elapsed.Truncate(time.Millisecond))
+}
+
+// Unwrap returns the error from the last attempt.
+func (e *RetriesExhaustedError) Unwrap() error { return e.Err }
+
+// Cause returns the error from the last attempt.
+func (e *RetriesExhaustedError) Cause() error { return e.Err }
+
+// maxAttemptHistory bounds how many attempts a CallError retains; the
+// oldest attempts are dropped first.
+const maxAttemptHistory = 8
+
+// Attempt is one try at a plugin call, as recorded in a CallError.
+type Attempt struct {
+	// Time is when the attempt started.
+	Time time.Time
+	// Duration is how long the attempt took.
+	Duration time.Duration
+	// Status is the HTTP status the plugin responded with, or zero when the
+	// request did not complete.
+	Status int
+	// Err summarizes the attempt's failure; it is empty for attempts which
+	// got a response.
+	Err string
+}
+
+// CallError is the error returned when a plugin call ultimately fails. It
+// defers to the final underlying error — Error, Unwrap, and Cause are all
+// transparent — and retains a bounded history of the attempts made, so a
+// bug report can show whether the plugin was down the whole time or
+// flapping.
+type CallError struct {
 	method string
-	err    string
+	// Err is the error from the final attempt.
+	Err     error
+	history []Attempt
+}
+
+// Error returns the final underlying error's message.
+func (e *CallError) Error() string { return e.Err.Error() }
+
+// Unwrap returns the final underlying error.
+func (e *CallError) Unwrap() error { return e.Err }
+
+// Cause returns the final underlying error.
+func (e *CallError) Cause() error { return e.Err }
+
+// Attempts returns the recorded attempts, oldest first. At most
+// maxAttemptHistory attempts are retained.
+func (e *CallError) Attempts() []Attempt { return e.history }
+
+// String renders the attempt history compactly, for inclusion in bug
+// reports.
+func (e *CallError) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %d recorded attempt(s):", e.method, len(e.history))
+	for i, a := range e.history {
+		fmt.Fprintf(&b, " [%d] %s %v status=%d", i, a.Time.Format(time.RFC3339Nano), a.Duration.Truncate(time.Millisecond), a.Status)
+		if a.Err != "" {
+			fmt.Fprintf(&b, " err=%q", a.Err)
+		}
+	}
+	return b.String()
+}
+
+// QueueTimeoutError is returned when a request could not start before its
+// context expired because the client's concurrency limit kept it queued.
+type QueueTimeoutError struct {
+	// Waited is how long the request sat in the queue.
+	Waited time.Duration
+	// Limit is the concurrency limit that was in force.
+	Limit int
+}
+
+// Error returns a formatted string for this error type
+func (e *QueueTimeoutError) Error() string {
+	return fmt.Sprintf("plugin request timed out after queueing for %v behind %d in-flight requests", e.Waited.Truncate(time.Millisecond), e.Limit)
+}
+
+// peerCredError is the error returned when a unix socket plugin connection is
+// rejected because the peer's credentials are not allowed.
+type peerCredError struct {
+	uid int
 }
 
 // Error returns a formatted string for this error type
-func (e *statusError) Error() string {
-	return fmt.Sprintf("%s: %v", e.method, e.err)
+func (e *peerCredError) Error() string {
+	return fmt.Sprintf("plugin unix socket peer has unauthorized uid %d", e.uid)
 }
 
+// Unauthorized marks the error as an authorization failure
+func (e *peerCredError) Unauthorized() {}
+
 // IsNotFound indicates if the passed in error is from an http.StatusNotFound from the plugin
 func IsNotFound(err error) bool {
 	return isStatusError(err, http.StatusNotFound)
 }
 
 func isStatusError(err error, status int) bool {
-	if err == nil {
-		return false
-	}
-	e, ok := err.(*statusError)
-	if !ok {
+	var pe *PluginError
+	if !errors.As(err, &pe) {
 		return false
 	}
-	return e.status == status
+	return pe.StatusCode == status
 }
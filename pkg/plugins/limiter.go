@@ -0,0 +1,87 @@
+package plugins // import "github.com/docker/docker/pkg/plugins"
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// concurrencyLimiter caps how many requests a client has in flight at once,
+// queueing the rest, so a burst of calls does not overwhelm a plugin which
+// serves them one at a time.
+type concurrencyLimiter struct {
+	slots chan struct{}
+
+	mu       sync.Mutex
+	inFlight int
+	queued   int
+}
+
+func newConcurrencyLimiter(max int) *concurrencyLimiter {
+	return &concurrencyLimiter{slots: make(chan struct{}, max)}
+}
+
+// acquire blocks until a slot is free or ctx expires, in which case a
+// QueueTimeoutError is returned.
+func (l *concurrencyLimiter) acquire(ctx context.Context) error {
+	l.mu.Lock()
+	l.queued++
+	l.mu.Unlock()
+	start := time.Now()
+	defer func() {
+		l.mu.Lock()
+		l.queued--
+		l.mu.Unlock()
+	}()
+
+	select {
+	case l.slots <- struct{}{}:
+		l.mu.Lock()
+		l.inFlight++
+		l.mu.Unlock()
+		return nil
+	case <-ctx.Done():
+		return &QueueTimeoutError{Waited: time.Since(start), Limit: cap(l.slots)}
+	}
+}
+
+func (l *concurrencyLimiter) release() {
+	<-l.slots
+	l.mu.Lock()
+	l.inFlight--
+	l.mu.Unlock()
+}
+
+func (l *concurrencyLimiter) stats() (inFlight, queued int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inFlight, l.queued
+}
+
+// WithConcurrencyLimit returns a ClientOpt which caps the number of requests
+// the client has in flight at once; further calls queue until a slot frees
+// up, respecting their context deadline. Streaming calls (Stream, SendFile)
+// are exempt, as they hold their slot for as long as the stream stays open.
+func WithConcurrencyLimit(max int) ClientOpt {
+	return func(c *Client) {
+		c.limiter = newConcurrencyLimiter(max)
+	}
+}
+
+// WithRequestLimitExempt lets this request bypass the client's concurrency
+// limit, e.g. for a call which must go out while slots are tied up.
+func WithRequestLimitExempt() func(*RequestOpts) {
+	return func(o *RequestOpts) {
+		o.LimitExempt = true
+	}
+}
+
+// ConcurrencyStats returns how many requests are currently in flight and how
+// many are queued waiting for a slot. Both are zero when no concurrency
+// limit is configured.
+func (c *Client) ConcurrencyStats() (inFlight, queued int) {
+	if c.limiter == nil {
+		return 0, 0
+	}
+	return c.limiter.stats()
+}
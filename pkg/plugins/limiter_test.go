@@ -0,0 +1,127 @@
+package plugins // import "github.com/docker/docker/pkg/plugins"
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+func TestConcurrencyLimit(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	var mu sync.Mutex
+	var inFlight, maxInFlight int
+	mux.HandleFunc("/Test.Slow", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+
+		time.Sleep(50 * time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		w.Write([]byte("{}"))
+	})
+
+	c, err := NewClientWithOpts(addr, WithConcurrencyLimit(2))
+	assert.NilError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Check(t, c.Call("Test.Slow", nil, nil))
+		}()
+	}
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Check(t, maxInFlight <= 2, "concurrency ceiling exceeded: saw %d in-flight requests", maxInFlight)
+}
+
+func TestConcurrencyLimitQueueTimeout(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	release := make(chan struct{})
+	mux.HandleFunc("/Test.Block", func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Write([]byte("{}"))
+	})
+
+	c, err := NewClientWithOpts(addr, WithConcurrencyLimit(1))
+	assert.NilError(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Call("Test.Block", nil, nil)
+	}()
+
+	// wait for the first call to occupy the only slot
+	waitForCondition(t, func() bool {
+		inFlight, _ := c.ConcurrencyStats()
+		return inFlight == 1
+	}, "first call never took the slot")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err = c.CallWithContext(ctx, "Test.Block", nil, nil)
+
+	var qErr *QueueTimeoutError
+	assert.Assert(t, errors.As(err, &qErr), "expected QueueTimeoutError, got %T: %v", err, err)
+	assert.Check(t, is.Equal(1, qErr.Limit))
+
+	close(release)
+	assert.NilError(t, <-done)
+
+	inFlight, queued := c.ConcurrencyStats()
+	assert.Check(t, is.Equal(0, inFlight))
+	assert.Check(t, is.Equal(0, queued))
+}
+
+func TestConcurrencyLimitExemptStream(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	release := make(chan struct{})
+	mux.HandleFunc("/Test.Block", func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Write([]byte("{}"))
+	})
+	mux.HandleFunc("/Test.Stream", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("streamed"))
+	})
+
+	c, err := NewClientWithOpts(addr, WithConcurrencyLimit(1))
+	assert.NilError(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Call("Test.Block", nil, nil)
+	}()
+	waitForCondition(t, func() bool {
+		inFlight, _ := c.ConcurrencyStats()
+		return inFlight == 1
+	}, "first call never took the slot")
+
+	// the stream goes out even though the only slot is occupied
+	body, err := c.Stream("Test.Stream", nil)
+	assert.NilError(t, err)
+	assert.NilError(t, body.Close())
+
+	close(release)
+	assert.NilError(t, <-done)
+}
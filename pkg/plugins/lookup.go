@@ -0,0 +1,139 @@
+package plugins // import "github.com/docker/docker/pkg/plugins"
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// capabilityOrder holds the administrator's explicit plugin ordering per
+// capability, as set through SetCapabilityOrder.
+var capabilityOrder = struct {
+	sync.Mutex
+	order map[string][]string
+}{order: make(map[string][]string)}
+
+// SetCapabilityOrder records an explicit priority order for plugins
+// implementing capability, typically from daemon configuration. Plugins named
+// here sort before all others in GetAllOrdered, in the given order; names not
+// currently registered are remembered so the order applies when they appear.
+func SetCapabilityOrder(capability string, names []string) {
+	capabilityOrder.Lock()
+	if len(names) == 0 {
+		delete(capabilityOrder.order, capability)
+	} else {
+		capabilityOrder.order[capability] = append([]string(nil), names...)
+	}
+	capabilityOrder.Unlock()
+}
+
+// capabilityRank returns the explicit ordering for capability as a name to
+// position map.
+func capabilityRank(capability string) map[string]int {
+	capabilityOrder.Lock()
+	defer capabilityOrder.Unlock()
+	rank := make(map[string]int, len(capabilityOrder.order[capability]))
+	for i, name := range capabilityOrder.order[capability] {
+		if _, ok := rank[name]; !ok {
+			rank[name] = i
+		}
+	}
+	return rank
+}
+
+// GetAllOrdered returns the plugins implementing the specified capability in a
+// stable priority order: names listed via SetCapabilityOrder first, in that
+// order, then the remaining plugins by registration order, then by name.
+// Callers that pick "any plugin implementing X" should use this rather than
+// GetAll, whose order follows map iteration.
+func GetAllOrdered(imp string) ([]*Plugin, error) {
+	pls, err := GetAll(imp)
+	if err != nil {
+		return nil, err
+	}
+	rank := capabilityRank(imp)
+	sort.SliceStable(pls, func(i, j int) bool {
+		ri, iOrdered := rank[pls[i].name]
+		rj, jOrdered := rank[pls[j].name]
+		if iOrdered != jOrdered {
+			return iOrdered
+		}
+		if iOrdered && ri != rj {
+			return ri < rj
+		}
+		if pls[i].regSeq != pls[j].regSeq {
+			return pls[i].regSeq < pls[j].regSeq
+		}
+		return pls[i].name < pls[j].name
+	})
+	return pls, nil
+}
+
+// subscribers receive a WatchEvent whenever a plugin is registered with or
+// removed from the registry, whichever way the change came about (lookup by
+// name, GetAll scan, or a directory watcher).
+var subscribers = struct {
+	sync.Mutex
+	next int
+	subs map[int]chan WatchEvent
+}{subs: make(map[int]chan WatchEvent)}
+
+// Subscribe returns a channel delivering an event for every change to the set
+// of registered plugins, and a cancel function releasing the subscription.
+// Events are dropped rather than delivered late when the subscriber falls
+// behind, so consumers should re-query the registry on receipt instead of
+// replaying events.
+func Subscribe() (<-chan WatchEvent, func()) {
+	ch := make(chan WatchEvent, watchEventBacklog)
+	subscribers.Lock()
+	id := subscribers.next
+	subscribers.next++
+	subscribers.subs[id] = ch
+	subscribers.Unlock()
+	return ch, func() {
+		subscribers.Lock()
+		delete(subscribers.subs, id)
+		subscribers.Unlock()
+	}
+}
+
+func notifySubscribers(ev WatchEvent) {
+	subscribers.Lock()
+	for _, ch := range subscribers.subs {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+	subscribers.Unlock()
+}
+
+// register stores pl under name unless a plugin by that name already exists,
+// in which case the existing one is returned with false. A fresh registration
+// is stamped with its registration order and announced to subscribers.
+func (s *plugins) register(name string, pl *Plugin) (*Plugin, bool) {
+	s.Lock()
+	if existing, exists := s.plugins[name]; exists {
+		s.Unlock()
+		return existing, false
+	}
+	s.seq++
+	pl.regSeq = s.seq
+	s.plugins[name] = pl
+	s.Unlock()
+	notifySubscribers(WatchEvent{Name: name, Addr: pl.Addr, Action: WatchActionAdded, Time: time.Now()})
+	return pl, true
+}
+
+// deregister removes the named plugin from the registry, announcing the
+// removal to subscribers. It reports whether the plugin was registered.
+func (s *plugins) deregister(name string) bool {
+	s.Lock()
+	_, exists := s.plugins[name]
+	delete(s.plugins, name)
+	s.Unlock()
+	if exists {
+		notifySubscribers(WatchEvent{Name: name, Action: WatchActionRemoved, Time: time.Now()})
+	}
+	return exists
+}
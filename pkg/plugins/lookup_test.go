@@ -0,0 +1,91 @@
+package plugins // import "github.com/docker/docker/pkg/plugins"
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+func registerFakePlugin(t *testing.T, tmpdir, name, capability string) {
+	spec := fmt.Sprintf(`{"Name": %q, "Addr": "https://example.com/docker/%s"}`, name, name)
+	assert.NilError(t, ioutil.WriteFile(filepath.Join(tmpdir, name+".json"), []byte(spec), 0644))
+
+	r := newLocalRegistry()
+	pl, err := r.Plugin(name)
+	assert.NilError(t, err)
+	pl.Manifest = &Manifest{Implements: []string{capability}}
+	_, fresh := storage.register(name, pl)
+	assert.Assert(t, fresh)
+}
+
+func pluginNames(pls []*Plugin) []string {
+	names := make([]string, len(pls))
+	for i, pl := range pls {
+		names[i] = pl.Name()
+	}
+	return names
+}
+
+func TestGetAllOrdered(t *testing.T) {
+	tmpdir, unregister := Setup(t)
+	defer unregister()
+
+	names := []string{"cherry", "apple", "banana"}
+	for _, name := range names {
+		registerFakePlugin(t, tmpdir, name, "fruitcap")
+	}
+	defer func() {
+		for _, name := range names {
+			storage.deregister(name)
+		}
+	}()
+
+	// without explicit configuration registration order wins
+	pls, err := GetAllOrdered("fruitcap")
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual([]string{"cherry", "apple", "banana"}, pluginNames(pls)))
+
+	// an explicitly ordered plugin sorts before the rest
+	SetCapabilityOrder("fruitcap", []string{"banana"})
+	defer SetCapabilityOrder("fruitcap", nil)
+
+	pls, err = GetAllOrdered("fruitcap")
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual([]string{"banana", "cherry", "apple"}, pluginNames(pls)))
+}
+
+func TestSubscribeRegistryChanges(t *testing.T) {
+	ch, cancel := Subscribe()
+	defer cancel()
+
+	recv := func() WatchEvent {
+		select {
+		case ev := <-ch:
+			return ev
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for registry change event")
+			return WatchEvent{}
+		}
+	}
+
+	pl := &Plugin{name: "subtest", Addr: "unix:///run/docker/plugins/subtest.sock", activateWait: sync.NewCond(&sync.Mutex{})}
+	_, fresh := storage.register("subtest", pl)
+	assert.Assert(t, fresh)
+
+	ev := recv()
+	assert.Check(t, is.Equal(WatchActionAdded, ev.Action))
+	assert.Check(t, is.Equal("subtest", ev.Name))
+	assert.Check(t, is.Equal(pl.Addr, ev.Addr))
+
+	assert.Assert(t, storage.deregister("subtest"))
+
+	ev = recv()
+	assert.Check(t, is.Equal(WatchActionRemoved, ev.Action))
+	assert.Check(t, is.Equal("subtest", ev.Name))
+}
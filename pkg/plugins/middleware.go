@@ -0,0 +1,74 @@
+package plugins // import "github.com/docker/docker/pkg/plugins"
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/docker/docker/pkg/requestid"
+	"github.com/sirupsen/logrus"
+)
+
+// Middleware wraps the round tripper used for plugin calls, allowing
+// cross-cutting concerns such as auth headers, request logging, or fault
+// injection in tests to be layered onto a client without forking it. The
+// chain is invoked once per attempt, so middlewares see each retry
+// individually.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// WithMiddleware returns a ClientOpt which registers the given middlewares
+// on the client. They are applied in registration order, the first one
+// outermost.
+func WithMiddleware(mw ...Middleware) ClientOpt {
+	return func(c *Client) {
+		c.middlewares = append(c.middlewares, mw...)
+	}
+}
+
+// chainMiddleware applies the middlewares around rt, first one outermost.
+func chainMiddleware(rt http.RoundTripper, mws []Middleware) http.RoundTripper {
+	for i := len(mws) - 1; i >= 0; i-- {
+		rt = mws[i](rt)
+	}
+	return rt
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// HeaderMiddleware returns a Middleware which sets the given header on every
+// attempt, e.g. an authorization header for remote plugins.
+func HeaderMiddleware(key, value string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			req.Header.Set(key, value)
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// LoggingMiddleware returns a Middleware which logs every attempt and its
+// outcome at debug level, tagged with the given plugin label.
+func LoggingMiddleware(label string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+			logger := logrus.WithFields(logrus.Fields{
+				"plugin":     label,
+				"path":       req.URL.Path,
+				"duration":   time.Since(start),
+				"request-id": req.Header.Get(requestid.HeaderKey),
+			})
+			if err != nil {
+				logger.WithError(err).Debug("plugin request failed")
+				return nil, err
+			}
+			logger.WithField("status", resp.StatusCode).Debug("plugin request")
+			return resp, nil
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package plugins // import "github.com/docker/docker/pkg/plugins"
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+// recordMiddleware appends name to calls around every attempt.
+func recordMiddleware(name string, calls *[]string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			*calls = append(*calls, name)
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+func TestMiddlewareOrderingAndRetries(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	var requests int
+	mux.HandleFunc("/Test.Flaky", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("{}"))
+	})
+
+	var calls []string
+	c, err := NewClientWithOpts(addr,
+		WithRetryPolicy(fastRetryPolicy()),
+		WithMiddleware(recordMiddleware("outer", &calls), recordMiddleware("inner", &calls)),
+	)
+	assert.NilError(t, err)
+
+	assert.NilError(t, c.Call("Test.Flaky", nil, nil))
+	// first registered runs outermost, and every attempt goes through the
+	// whole chain
+	assert.Check(t, is.DeepEqual([]string{"outer", "inner", "outer", "inner"}, calls))
+}
+
+func TestMiddlewareShortCircuit(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	var reached bool
+	mux.HandleFunc("/Test.Echo", func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	})
+
+	shortCircuit := func(next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{},
+				Body:       ioutil.NopCloser(strings.NewReader("{}")),
+			}, nil
+		})
+	}
+
+	c, err := NewClientWithOpts(addr, WithMiddleware(shortCircuit))
+	assert.NilError(t, err)
+	assert.NilError(t, c.Call("Test.Echo", nil, nil))
+	assert.Check(t, !reached, "short-circuiting middleware must not reach the plugin")
+}
+
+func TestHeaderMiddleware(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	mux.HandleFunc("/Test.Echo", func(w http.ResponseWriter, r *http.Request) {
+		assert.Check(t, is.Equal("Bearer secret", r.Header.Get("Authorization")))
+		w.Write([]byte("{}"))
+	})
+
+	c, err := NewClientWithOpts(addr, WithMiddleware(HeaderMiddleware("Authorization", "Bearer secret")))
+	assert.NilError(t, err)
+	assert.NilError(t, c.Call("Test.Echo", nil, nil))
+}
@@ -0,0 +1,80 @@
+package plugins // import "github.com/docker/docker/pkg/plugins"
+
+import (
+	"io"
+	"time"
+)
+
+// AttemptObservation describes a single HTTP attempt made by a Client.
+type AttemptObservation struct {
+	// Method is the service method path, e.g. "VolumeDriver.Mount".
+	Method string
+	// Duration is how long the attempt took.
+	Duration time.Duration
+	// Status is the HTTP status code of the response, or 0 if the attempt
+	// failed before a response was received.
+	Status int
+	// Retry is the zero-based index of the attempt; 0 is the initial call.
+	Retry int
+	// Final is set when no further retries will be made, making this
+	// attempt's outcome the outcome of the whole call.
+	Final bool
+	// Err is the error the attempt returned, if any.
+	Err error
+}
+
+// ClientObserver receives an observation for every attempt a Client makes
+// against its plugin. Implementations must be safe for concurrent use and
+// should not block, as they are called on the request path.
+type ClientObserver interface {
+	// ObserveAttempt is called after every individual HTTP attempt. label is
+	// the value the client was configured with, typically the plugin name.
+	ObserveAttempt(label string, obs AttemptObservation)
+	// ObserveFirstByte is called for streaming calls when the first byte of
+	// the response body is read.
+	ObserveFirstByte(label, method string, d time.Duration)
+}
+
+// WithObserver returns a ClientOpt which installs an observer on the client.
+// The label is attached to every observation; callers which know it should
+// pass the plugin name.
+func WithObserver(label string, obs ClientObserver) ClientOpt {
+	return func(c *Client) {
+		c.observerLabel = label
+		c.observer = obs
+	}
+}
+
+func (c *Client) observeAttempt(method string, start time.Time, status, retry int, final bool, err error) {
+	if c.observer == nil {
+		return
+	}
+	c.observer.ObserveAttempt(c.observerLabel, AttemptObservation{
+		Method:   method,
+		Duration: time.Since(start),
+		Status:   status,
+		Retry:    retry,
+		Final:    final,
+		Err:      err,
+	})
+}
+
+// firstByteReader reports the time from the start of a streaming call to the
+// first byte of the response body being read.
+type firstByteReader struct {
+	io.ReadCloser
+	method   string
+	start    time.Time
+	label    string
+	observer ClientObserver
+	observed bool
+}
+
+func (r *firstByteReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if !r.observed && n > 0 {
+		r.observed = true
+		r.observer.ObserveFirstByte(r.label, r.method, time.Since(r.start))
+	}
+	return n, err
+}
@@ -0,0 +1,33 @@
+package plugins // import "github.com/docker/docker/pkg/plugins"
+
+// PeerCredPolicy configures verification of the identity of a local plugin
+// reached over a unix socket. The connection is rejected unless the process
+// listening on the socket runs as root or as one of the allowed UIDs.
+type PeerCredPolicy struct {
+	// AllowedUIDs is the set of peer UIDs accepted in addition to root.
+	AllowedUIDs []int
+}
+
+// allows reports whether a peer with the given UID is acceptable.
+func (p *PeerCredPolicy) allows(uid int) bool {
+	if uid == 0 {
+		return true
+	}
+	for _, allowed := range p.AllowedUIDs {
+		if uid == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// WithPeerCredCheck returns a ClientOpt which makes the client verify the
+// peer's credentials after dialing a unix socket, rejecting the connection
+// with an authorization error unless the peer runs as root or as one of the
+// given UIDs. It has no effect on non-unix transports, and on platforms
+// without SO_PEERCRED support the check is skipped.
+func WithPeerCredCheck(allowedUIDs ...int) ClientOpt {
+	return func(c *Client) {
+		c.peerCred = &PeerCredPolicy{AllowedUIDs: allowedUIDs}
+	}
+}
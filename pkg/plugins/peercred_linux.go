@@ -0,0 +1,56 @@
+package plugins // import "github.com/docker/docker/pkg/plugins"
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// configurePeerCredTransport is like sockets.ConfigureTransport for unix
+// sockets, but verifies the peer's credentials via SO_PEERCRED on every new
+// connection. The check needs access to the raw connection, hence the custom
+// DialContext.
+func configurePeerCredTransport(tr *http.Transport, socket string, policy *PeerCredPolicy, dialTimeout time.Duration) error {
+	// No need for compression in local communications.
+	tr.DisableCompression = true
+	tr.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+		d := net.Dialer{Timeout: dialTimeout}
+		conn, err := d.DialContext(ctx, "unix", socket)
+		if err != nil {
+			return nil, err
+		}
+		uc, ok := conn.(*net.UnixConn)
+		if !ok {
+			conn.Close()
+			return nil, fmt.Errorf("unexpected connection type %T dialing plugin socket %s", conn, socket)
+		}
+		raw, err := uc.SyscallConn()
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		var (
+			cred    *syscall.Ucred
+			credErr error
+		)
+		if err := raw.Control(func(fd uintptr) {
+			cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+		}); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		if credErr != nil {
+			conn.Close()
+			return nil, credErr
+		}
+		if !policy.allows(int(cred.Uid)) {
+			conn.Close()
+			return nil, &peerCredError{uid: int(cred.Uid)}
+		}
+		return conn, nil
+	}
+	return nil
+}
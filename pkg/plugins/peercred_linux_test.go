@@ -0,0 +1,61 @@
+package plugins // import "github.com/docker/docker/pkg/plugins"
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+func TestPeerCredPolicyAllows(t *testing.T) {
+	p := &PeerCredPolicy{AllowedUIDs: []int{1000}}
+	assert.Check(t, p.allows(0), "root must always be allowed")
+	assert.Check(t, p.allows(1000))
+	assert.Check(t, !p.allows(1001))
+
+	empty := &PeerCredPolicy{}
+	assert.Check(t, empty.allows(0))
+	assert.Check(t, !empty.allows(1000))
+}
+
+// servePluginSocket starts an HTTP server answering Plugin.Hello on a unix
+// socket at the returned path, owned by the test's UID.
+func servePluginSocket(t *testing.T) string {
+	t.Helper()
+	sock := filepath.Join(t.TempDir(), "plugin.sock")
+	l, err := net.Listen("unix", sock)
+	assert.NilError(t, err)
+	h := http.NewServeMux()
+	h.HandleFunc("/Plugin.Hello", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	})
+	go http.Serve(l, h)
+	t.Cleanup(func() { l.Close() })
+	return sock
+}
+
+func TestPeerCredCheckAccept(t *testing.T) {
+	sock := servePluginSocket(t)
+
+	c, err := NewClientWithOptions("unix://"+sock, nil, WithPeerCredCheck(os.Getuid()))
+	assert.NilError(t, err)
+	assert.NilError(t, c.Call("Plugin.Hello", nil, nil))
+}
+
+func TestPeerCredCheckReject(t *testing.T) {
+	if os.Getuid() == 0 {
+		t.Skip("the peer is root, which is always allowed")
+	}
+	sock := servePluginSocket(t)
+
+	// the policy allows neither root (we are not root) nor our UID
+	c, err := NewClientWithOptions("unix://"+sock, nil, WithPeerCredCheck(os.Getuid()+1))
+	assert.NilError(t, err)
+	err = c.CallWithOptions("Plugin.Hello", nil, nil, WithRequestRetryPolicy(RetryPolicy{}))
+	assert.Assert(t, err != nil)
+	assert.Check(t, is.ErrorContains(err, "unauthorized uid"))
+}
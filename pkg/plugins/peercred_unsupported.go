@@ -0,0 +1,21 @@
+// +build !linux
+
+package plugins // import "github.com/docker/docker/pkg/plugins"
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/docker/go-connections/sockets"
+)
+
+// configurePeerCredTransport falls back to the standard unix transport on
+// platforms without SO_PEERCRED support; the peer credential check is
+// skipped.
+func configurePeerCredTransport(tr *http.Transport, socket string, _ *PeerCredPolicy, dialTimeout time.Duration) error {
+	if err := sockets.ConfigureTransport(tr, "unix", socket); err != nil {
+		return err
+	}
+	tr.DialContext = unixDialContext(socket, dialTimeout)
+	return nil
+}
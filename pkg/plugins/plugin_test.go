@@ -152,3 +152,98 @@ func TestGetAll(t *testing.T) {
 		t.Fatalf("Expected to get plugin with name %s", plugin.Name())
 	}
 }
+
+// activationCounter serves Plugin.Activate with the manifest returned by
+// manifest(call), counting calls.
+func activationCounter(t *testing.T, manifest func(call int) (Manifest, bool)) *int {
+	var calls int
+	mux.HandleFunc("/Plugin.Activate", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		m, ok := manifest(calls)
+		if !ok {
+			http.Error(w, "plugin broken", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", transport.VersionMimetype)
+		if err := json.NewEncoder(w).Encode(m); err != nil {
+			t.Fatal(err)
+		}
+	})
+	return &calls
+}
+
+func TestActivationTTLExpiry(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	calls := activationCounter(t, func(call int) (Manifest, bool) {
+		if call == 1 {
+			return Manifest{Implements: []string{"apple"}}, true
+		}
+		return Manifest{Implements: []string{"apple", "banana"}}, true
+	})
+
+	SetActivationTTL(30 * time.Millisecond)
+	defer SetActivationTTL(defaultActivationTTL)
+
+	p := NewLocalPlugin("ttl-fruit", addr)
+	assert.NilError(t, p.activate())
+	assert.Assert(t, p.implements("apple"))
+	assert.Assert(t, !p.implements("banana"))
+
+	// still fresh: served from cache
+	assert.NilError(t, p.activate())
+	assert.Equal(t, 1, *calls)
+
+	time.Sleep(50 * time.Millisecond)
+	assert.NilError(t, p.activate())
+	assert.Equal(t, 2, *calls)
+	assert.Assert(t, p.implements("banana"))
+}
+
+func TestActivationInvalidate(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	calls := activationCounter(t, func(call int) (Manifest, bool) {
+		if call == 1 {
+			return Manifest{Implements: []string{"apple"}}, true
+		}
+		return Manifest{Implements: []string{"apple", "banana"}}, true
+	})
+
+	p := NewLocalPlugin("invalidate-fruit", addr)
+	_, fresh := storage.register("invalidate-fruit", p)
+	assert.Assert(t, fresh)
+	defer storage.deregister("invalidate-fruit")
+
+	assert.NilError(t, p.activate())
+	assert.Assert(t, !p.implements("banana"))
+
+	Invalidate("invalidate-fruit")
+	assert.NilError(t, p.activate())
+	assert.Equal(t, 2, *calls)
+	assert.Assert(t, p.implements("banana"))
+}
+
+func TestActivationStaleOnError(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	calls := activationCounter(t, func(call int) (Manifest, bool) {
+		return Manifest{Implements: []string{"apple"}}, call == 1
+	})
+
+	p := NewLocalPlugin("stale-fruit", addr)
+	assert.NilError(t, p.activate())
+
+	p.invalidate()
+	// re-activation fails; the cached manifest keeps being served
+	assert.NilError(t, p.activate())
+	assert.Equal(t, 2, *calls)
+	assert.Assert(t, p.implements("apple"))
+
+	// and the failure does not leave the plugin permanently stale
+	assert.NilError(t, p.activate())
+	assert.Equal(t, 2, *calls)
+}
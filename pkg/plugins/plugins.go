@@ -23,8 +23,10 @@
 package plugins // import "github.com/docker/docker/pkg/plugins"
 
 import (
+	"context"
 	"errors"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/docker/go-connections/tlsconfig"
@@ -42,6 +44,9 @@ var (
 type plugins struct {
 	sync.Mutex
 	plugins map[string]*Plugin
+	// seq stamps registrations so lookups can order plugins by when they
+	// were first registered; see GetAllOrdered.
+	seq uint64
 }
 
 type extpointHandlers struct {
@@ -54,6 +59,43 @@ var (
 	handlers = extpointHandlers{extpointHandlers: make(map[string][]func(string, *Client))}
 )
 
+// CapabilityGzip is advertised in a plugin's manifest when it accepts
+// gzip-compressed request bodies.
+const CapabilityGzip = "gzip"
+
+// defaultActivationTTL is how long a successful activation result (the
+// plugin's manifest) is trusted before the handshake is redone on next use,
+// so a plugin upgraded in place to add a capability is eventually recognized
+// without a daemon restart.
+const defaultActivationTTL = time.Hour
+
+// activationTTLNs holds the activation TTL in nanoseconds; accessed
+// atomically. Zero disables expiry.
+var activationTTLNs = int64(defaultActivationTTL)
+
+// SetActivationTTL changes how long cached activation results are trusted.
+// Zero or negative disables expiry, restoring the historical cache-forever
+// behavior.
+func SetActivationTTL(d time.Duration) {
+	atomic.StoreInt64(&activationTTLNs, int64(d))
+}
+
+func activationTTL() time.Duration {
+	return time.Duration(atomic.LoadInt64(&activationTTLNs))
+}
+
+// Invalidate discards the cached activation result of the named plugin, if
+// registered, so its handshake is redone on next use. Plugin upgrade paths
+// and the directory watcher call this when a plugin changes in place.
+func Invalidate(name string) {
+	storage.Lock()
+	p, ok := storage.plugins[name]
+	storage.Unlock()
+	if ok {
+		p.invalidate()
+	}
+}
+
 // Manifest lists what a plugin implements.
 type Manifest struct {
 	// List of subsystem the plugin implements.
@@ -79,6 +121,13 @@ type Plugin struct {
 	activateErr error
 	// keeps track of callback handlers run against this plugin
 	handlersRun bool
+	// registration order stamp, assigned when the plugin enters the registry
+	regSeq uint64
+	// when the manifest was last obtained from the plugin; zero for
+	// manifests installed directly, which never go stale by age
+	activatedAt time.Time
+	// set by Invalidate to force the next use to redo the handshake
+	invalidated bool
 }
 
 // Name returns the name of the plugin.
@@ -91,6 +140,15 @@ func (p *Plugin) Client() *Client {
 	return p.client
 }
 
+// Ping probes the plugin to check that it is responsive. The plugin must
+// have been activated.
+func (p *Plugin) Ping(ctx context.Context) (PingResult, error) {
+	if p.client == nil {
+		return PingResult{}, errors.New("plugin not activated")
+	}
+	return p.client.Ping(ctx)
+}
+
 // Protocol returns the protocol name/version used for plugins in this package.
 func (p *Plugin) Protocol() string {
 	return ProtocolSchemeHTTPV1
@@ -115,13 +173,39 @@ func NewLocalPlugin(name, addr string) *Plugin {
 func (p *Plugin) activate() error {
 	p.activateWait.L.Lock()
 
-	if p.activated() {
+	// the activation lock also deduplicates concurrent re-activation: the
+	// first caller through redoes the handshake, later ones see a fresh
+	// result here and return immediately.
+	if p.activated() && !p.activationStale() {
 		p.runHandlers()
 		p.activateWait.L.Unlock()
 		return p.activateErr
 	}
 
-	p.activateErr = p.activateWithLock()
+	refresh := p.activated()
+	prevManifest, prevClient := p.Manifest, p.client
+	err := p.activateWithLock()
+	switch {
+	case err != nil && refresh:
+		// the plugin answered this handshake before; keep serving the
+		// cached manifest rather than breaking its existing users, and
+		// back off a full TTL before trying again
+		logrus.WithError(err).WithField("plugin", p.name).Warn("error re-activating plugin, keeping cached manifest")
+		p.Manifest, p.client = prevManifest, prevClient
+		p.activatedAt = time.Now()
+		p.invalidated = false
+		p.activateErr = nil
+	case err == nil:
+		p.activatedAt = time.Now()
+		p.invalidated = false
+		p.activateErr = nil
+		if refresh {
+			// the manifest may list new capabilities; let their handlers run
+			p.handlersRun = false
+		}
+	default:
+		p.activateErr = err
+	}
 
 	p.runHandlers()
 	p.activateWait.L.Unlock()
@@ -159,19 +243,45 @@ func (p *Plugin) activated() bool {
 	return p.Manifest != nil
 }
 
+// activationStale reports whether the cached activation result should no
+// longer be trusted.
+// This should only be called with the activation lock held
+func (p *Plugin) activationStale() bool {
+	if p.invalidated {
+		return true
+	}
+	ttl := activationTTL()
+	return ttl > 0 && !p.activatedAt.IsZero() && time.Since(p.activatedAt) > ttl
+}
+
+// invalidate marks the cached activation result stale so the next use redoes
+// the handshake.
+func (p *Plugin) invalidate() {
+	p.activateWait.L.Lock()
+	p.invalidated = true
+	p.activateWait.L.Unlock()
+}
+
 func (p *Plugin) activateWithLock() error {
-	c, err := NewClient(p.Addr, p.TLSConfig)
+	c, err := NewClientWithOptions(p.Addr, p.TLSConfig, append(defaultClientOpts(p.Addr), WithResponseCache(defaultCacheEntries))...)
 	if err != nil {
 		return err
 	}
 	p.client = c
 
-	m := new(Manifest)
-	if err = p.client.Call("Plugin.Activate", nil, m); err != nil {
+	m, err := p.client.Handshake(context.Background())
+	if err != nil {
 		return err
 	}
 
-	p.Manifest = m
+	p.Manifest = &m.Manifest
+	for _, iface := range m.Implements {
+		if iface == CapabilityGzip {
+			// the plugin told us it handles compressed requests
+			p.client.gzipRequests = true
+			break
+		}
+	}
 	return nil
 }
 
@@ -222,20 +332,15 @@ func loadWithRetry(name string, retry bool) (*Plugin, error) {
 			continue
 		}
 
-		storage.Lock()
-		if pl, exists := storage.plugins[name]; exists {
-			storage.Unlock()
-			return pl, pl.activate()
+		stored, fresh := storage.register(name, pl)
+		if !fresh {
+			return stored, stored.activate()
 		}
-		storage.plugins[name] = pl
-		storage.Unlock()
 
 		err = pl.activate()
 
 		if err != nil {
-			storage.Lock()
-			delete(storage.plugins, name)
-			storage.Unlock()
+			storage.deregister(name)
 		}
 
 		return pl, err
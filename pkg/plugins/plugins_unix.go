@@ -2,8 +2,38 @@
 
 package plugins // import "github.com/docker/docker/pkg/plugins"
 
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
 // ScopedPath returns the path scoped to the plugin's rootfs.
 // For v1 plugins, this always returns the path unchanged as v1 plugins run directly on the host.
 func (p *Plugin) ScopedPath(s string) string {
 	return s
 }
+
+// defaultClientOpts returns the default client options for a plugin at addr.
+// Unix socket plugins living under a root-owned directory get peer credential
+// verification by default, so an unprivileged process which manages to create
+// a socket at a watched path cannot impersonate a plugin.
+func defaultClientOpts(addr string) []ClientOpt {
+	u, err := url.Parse(addr)
+	if err != nil || u.Scheme != "unix" {
+		return nil
+	}
+	socket := u.Host
+	if socket == "" {
+		socket = u.Path
+	}
+	fi, err := os.Stat(filepath.Dir(socket))
+	if err != nil {
+		return nil
+	}
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok && st.Uid == 0 {
+		return []ClientOpt{WithPeerCredCheck()}
+	}
+	return nil
+}
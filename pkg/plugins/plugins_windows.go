@@ -5,3 +5,9 @@ package plugins // import "github.com/docker/docker/pkg/plugins"
 func (p *Plugin) ScopedPath(s string) string {
 	return s
 }
+
+// defaultClientOpts returns the default client options for a plugin at addr.
+// Peer credential verification does not apply on Windows.
+func defaultClientOpts(addr string) []ClientOpt {
+	return nil
+}
@@ -0,0 +1,67 @@
+package plugins // import "github.com/docker/docker/pkg/plugins"
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// CapabilityShutdown is advertised in a plugin's manifest when it wants to be
+// notified before the daemon goes away, e.g. to flush state or stop accepting
+// new work.
+const CapabilityShutdown = "shutdown"
+
+// shutdownCallTimeout bounds the shutdown notification to a single plugin; a
+// plugin that cannot acknowledge within this window is not worth delaying
+// daemon shutdown for.
+const shutdownCallTimeout = 2 * time.Second
+
+// NotifyShutdown posts a Plugin.Shutdown notification to every active plugin
+// which advertised CapabilityShutdown, in parallel and without retries. It is
+// strictly best-effort: failures are logged and otherwise ignored, and the
+// whole broadcast is abandoned once ctx expires so dead plugins cannot hold
+// up daemon shutdown.
+func NotifyShutdown(ctx context.Context) {
+	storage.Lock()
+	pls := make([]*Plugin, 0, len(storage.plugins))
+	for _, p := range storage.plugins {
+		pls = append(pls, p)
+	}
+	storage.Unlock()
+
+	var wg sync.WaitGroup
+	for _, p := range pls {
+		p.activateWait.L.Lock()
+		capable := p.activated() && p.implements(CapabilityShutdown) && p.client != nil
+		p.activateWait.L.Unlock()
+		if !capable {
+			continue
+		}
+
+		wg.Add(1)
+		go func(p *Plugin) {
+			defer wg.Done()
+			err := p.client.CallWithOptions("Plugin.Shutdown", nil, nil,
+				WithRequestContext(ctx),
+				WithRequestTimeout(shutdownCallTimeout),
+				WithRequestRetryClassifier(func(int, error) bool { return false }))
+			if err != nil {
+				logrus.WithError(err).WithField("plugin", p.name).Warn("error notifying plugin of shutdown")
+			}
+		}(p)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		// stragglers keep running until their own timeout; their results
+		// are logged by the goroutines above
+	}
+}
@@ -0,0 +1,76 @@
+package plugins // import "github.com/docker/docker/pkg/plugins"
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+// shutdownTestPlugin registers an activated plugin backed by its own test
+// server and returns a counter of Plugin.Shutdown calls it received.
+func shutdownTestPlugin(t *testing.T, name string, implements []string, block <-chan struct{}) *int32 {
+	var (
+		mu    sync.Mutex
+		calls int32
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/Plugin.Shutdown" {
+			http.NotFound(w, r)
+			return
+		}
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		if block != nil {
+			<-block
+		}
+		w.Write([]byte("{}"))
+	}))
+	t.Cleanup(srv.Close)
+
+	c, err := NewClientWithOpts(srv.URL)
+	assert.NilError(t, err)
+
+	p := &Plugin{
+		name:         name,
+		Addr:         srv.URL,
+		client:       c,
+		Manifest:     &Manifest{Implements: implements},
+		activateWait: sync.NewCond(&sync.Mutex{}),
+	}
+	_, fresh := storage.register(name, p)
+	assert.Assert(t, fresh)
+	t.Cleanup(func() { storage.deregister(name) })
+	return &calls
+}
+
+func TestNotifyShutdown(t *testing.T) {
+	capable := shutdownTestPlugin(t, "shutdown-capable", []string{"apple", CapabilityShutdown}, nil)
+	oblivious := shutdownTestPlugin(t, "shutdown-oblivious", []string{"apple"}, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	NotifyShutdown(ctx)
+
+	assert.Check(t, is.Equal(int32(1), *capable))
+	assert.Check(t, is.Equal(int32(0), *oblivious))
+}
+
+func TestNotifyShutdownDeadline(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	shutdownTestPlugin(t, "shutdown-stuck", []string{CapabilityShutdown}, block)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	NotifyShutdown(ctx)
+	assert.Assert(t, time.Since(start) < time.Second, "shutdown broadcast not bounded by the context deadline")
+}
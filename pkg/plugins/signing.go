@@ -0,0 +1,72 @@
+package plugins // import "github.com/docker/docker/pkg/plugins"
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/pkg/plugins/transport"
+	"github.com/pkg/errors"
+)
+
+// defaultSigningSkew is the clock-skew tolerance applied when a
+// SigningPolicy does not set one.
+const defaultSigningSkew = 2 * time.Minute
+
+// SigningPolicy configures HMAC request signing for plugins reached over
+// TCP, as an application-level shared-secret check on top of (or instead
+// of) TLS. The headers and canonical payload are specified in the transport
+// package.
+type SigningPolicy struct {
+	// Key is the shared secret the signatures are computed with.
+	Key []byte
+	// Strict, when set, rejects responses which do not carry a valid
+	// signature. Verification buffers the response body in memory, so
+	// strict mode is meant for control-plane calls, not large streams.
+	Strict bool
+	// Skew is the clock-skew tolerance for response timestamps. Zero means
+	// defaultSigningSkew.
+	Skew time.Duration
+}
+
+// WithRequestSigning returns a ClientOpt which makes the client sign every
+// request under the given policy.
+func WithRequestSigning(p SigningPolicy) ClientOpt {
+	return func(c *Client) {
+		if p.Skew == 0 {
+			p.Skew = defaultSigningSkew
+		}
+		c.signing = &p
+	}
+}
+
+// signRequest attaches signature headers to req. bodyHash is the hex
+// SHA-256 of the uncompressed request body, or transport.UnsignedPayload
+// for streamed bodies.
+func (p *SigningPolicy) signRequest(req *http.Request, bodyHash string) {
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set(transport.SignatureTimestampHeader, ts)
+	req.Header.Set(transport.SignatureHeader, transport.Sign(p.Key, req.URL.Path, ts, bodyHash))
+}
+
+// verifyResponse checks the signature headers of resp over the decoded body,
+// consuming it; the returned reader serves the verified bytes in its place.
+func (p *SigningPolicy) verifyResponse(path string, resp *http.Response, body io.ReadCloser) (io.ReadCloser, error) {
+	b, err := ioutil.ReadAll(body)
+	body.Close()
+	if err != nil {
+		return nil, err
+	}
+	sig := resp.Header.Get(transport.SignatureHeader)
+	if sig == "" {
+		return nil, errors.New("plugin response is not signed")
+	}
+	ts := resp.Header.Get(transport.SignatureTimestampHeader)
+	if err := transport.Verify(p.Key, path, ts, transport.BodyHash(b), sig, p.Skew, time.Now()); err != nil {
+		return nil, errors.Wrap(err, "plugin response signature")
+	}
+	return ioutil.NopCloser(bytes.NewReader(b)), nil
+}
@@ -0,0 +1,108 @@
+package plugins // import "github.com/docker/docker/pkg/plugins"
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/pkg/plugins/transport"
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+// signingHandler verifies the request signature with key and, on success,
+// hands off to next with a function for writing a signed response.
+func signingHandler(t *testing.T, key []byte, next func(w http.ResponseWriter, body []byte, sign func([]byte))) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		assert.NilError(t, err)
+
+		err = transport.Verify(key, r.URL.Path,
+			r.Header.Get(transport.SignatureTimestampHeader),
+			transport.BodyHash(body),
+			r.Header.Get(transport.SignatureHeader),
+			time.Minute, time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+
+		next(w, body, func(respBody []byte) {
+			ts := strconv.FormatInt(time.Now().Unix(), 10)
+			w.Header().Set(transport.SignatureTimestampHeader, ts)
+			w.Header().Set(transport.SignatureHeader, transport.Sign(key, r.URL.Path, ts, transport.BodyHash(respBody)))
+			w.Write(respBody)
+		})
+	}
+}
+
+func TestSignedRequestRoundTrip(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	key := []byte("shared-secret")
+	mux.HandleFunc("/Test.Echo", signingHandler(t, key, func(w http.ResponseWriter, body []byte, sign func([]byte)) {
+		sign([]byte(`{"Name":"signed"}`))
+	}))
+
+	c, err := NewClientWithOpts(addr, WithRequestSigning(SigningPolicy{Key: key, Strict: true}))
+	assert.NilError(t, err)
+
+	var out struct{ Name string }
+	assert.NilError(t, c.Call("Test.Echo", map[string]string{"Name": "in"}, &out))
+	assert.Check(t, is.Equal("signed", out.Name))
+}
+
+func TestSignedRequestWrongKey(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	key := []byte("shared-secret")
+	mux.HandleFunc("/Test.Echo", signingHandler(t, key, func(w http.ResponseWriter, body []byte, sign func([]byte)) {
+		sign([]byte("{}"))
+	}))
+
+	c, err := NewClientWithOpts(addr, WithRequestSigning(SigningPolicy{Key: []byte("not-the-secret")}))
+	assert.NilError(t, err)
+
+	err = c.Call("Test.Echo", nil, nil)
+	assert.ErrorContains(t, err, "signature mismatch")
+}
+
+func TestSignedResponseTampered(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	key := []byte("shared-secret")
+	mux.HandleFunc("/Test.Echo", signingHandler(t, key, func(w http.ResponseWriter, body []byte, sign func([]byte)) {
+		// a valid signature over a different body than the one sent
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		w.Header().Set(transport.SignatureTimestampHeader, ts)
+		w.Header().Set(transport.SignatureHeader, transport.Sign(key, "/Test.Echo", ts, transport.BodyHash([]byte("{}"))))
+		w.Write([]byte(`{"Name":"tampered"}`))
+	}))
+
+	c, err := NewClientWithOpts(addr, WithRequestSigning(SigningPolicy{Key: key, Strict: true}))
+	assert.NilError(t, err)
+
+	err = c.Call("Test.Echo", nil, nil)
+	assert.ErrorContains(t, err, "plugin response signature")
+}
+
+func TestSignedResponseMissing(t *testing.T) {
+	addr := setupRemotePluginServer()
+	defer teardownRemotePluginServer()
+
+	key := []byte("shared-secret")
+	mux.HandleFunc("/Test.Echo", signingHandler(t, key, func(w http.ResponseWriter, body []byte, sign func([]byte)) {
+		w.Write([]byte("{}"))
+	}))
+
+	c, err := NewClientWithOpts(addr, WithRequestSigning(SigningPolicy{Key: key, Strict: true}))
+	assert.NilError(t, err)
+
+	err = c.Call("Test.Echo", nil, nil)
+	assert.ErrorContains(t, err, "not signed")
+}
@@ -0,0 +1,48 @@
+// +build !windows
+
+package plugins // import "github.com/docker/docker/pkg/plugins"
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+
+	"github.com/pkg/errors"
+)
+
+// filer is implemented by listeners whose file descriptor can be duplicated
+// for inheritance, such as *net.UnixListener and *net.TCPListener.
+type filer interface {
+	File() (*os.File, error)
+}
+
+// SocketActivate arranges for cmd to inherit l following the LISTEN_FDS
+// convention of systemd socket activation: the listener is appended to the
+// file descriptors passed to the child, starting at fd 3, and LISTEN_FDS
+// names how many were passed. The daemon can thus bind a plugin's socket
+// itself, launch the plugin lazily, and connect before the plugin has
+// finished starting (see WithActivationGrace for the client side).
+//
+// LISTEN_PID is set to 0 because a Go parent cannot learn the child's pid
+// before exec; plugins launched this way should accept that value in place
+// of their own pid.
+func SocketActivate(cmd *exec.Cmd, l net.Listener) error {
+	fl, ok := l.(filer)
+	if !ok {
+		return errors.Errorf("listener of type %T cannot be passed to a plugin", l)
+	}
+	f, err := fl.File()
+	if err != nil {
+		return errors.Wrap(err, "error duplicating listener fd")
+	}
+	cmd.ExtraFiles = append(cmd.ExtraFiles, f)
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env,
+		fmt.Sprintf("LISTEN_FDS=%d", len(cmd.ExtraFiles)),
+		"LISTEN_PID=0",
+	)
+	return nil
+}
@@ -0,0 +1,32 @@
+// +build !windows
+
+package plugins // import "github.com/docker/docker/pkg/plugins"
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+func TestSocketActivate(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "docker-test")
+	assert.NilError(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	l, err := net.Listen("unix", filepath.Join(tmpdir, "plugin.sock"))
+	assert.NilError(t, err)
+	defer l.Close()
+
+	cmd := exec.Command("/bin/true")
+	assert.NilError(t, SocketActivate(cmd, l))
+
+	assert.Check(t, is.Len(cmd.ExtraFiles, 1))
+	env := cmd.Env[len(cmd.Env)-2:]
+	assert.Check(t, is.DeepEqual([]string{"LISTEN_FDS=1", "LISTEN_PID=0"}, env))
+}
@@ -0,0 +1,38 @@
+package plugins // import "github.com/docker/docker/pkg/plugins"
+
+import "context"
+
+// W3C trace context headers, as defined by
+// https://www.w3.org/TR/trace-context/.
+const (
+	TraceparentHeader = "Traceparent"
+	TracestateHeader  = "Tracestate"
+)
+
+// TraceContext carries the W3C trace context of the operation a plugin call
+// is made on behalf of. The tracing SDK itself is not a dependency of this
+// package; callers that trace their requests put the serialized headers on
+// the call's context with WithTraceContext, and the client forwards them on
+// every request (including retries and hedges) so plugin-side tracing can
+// join the trace.
+type TraceContext struct {
+	// Traceparent is the serialized traceparent header value.
+	Traceparent string
+	// Tracestate is the serialized tracestate header value, if any.
+	Tracestate string
+}
+
+type traceCtxKey struct{}
+
+// WithTraceContext returns a context carrying tc. Use it with
+// CallWithContext (or WithRequestContext) to have the trace context
+// forwarded to the plugin.
+func WithTraceContext(ctx context.Context, tc TraceContext) context.Context {
+	return context.WithValue(ctx, traceCtxKey{}, tc)
+}
+
+// traceContextFrom returns the trace context carried by ctx, if any.
+func traceContextFrom(ctx context.Context) TraceContext {
+	tc, _ := ctx.Value(traceCtxKey{}).(TraceContext)
+	return tc
+}
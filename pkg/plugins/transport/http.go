@@ -10,16 +10,25 @@ import (
 // sends request to.
 type httpTransport struct {
 	http.RoundTripper
-	scheme string
-	addr   string
+	scheme  string
+	addr    string
+	headers http.Header
 }
 
 // NewHTTPTransport creates a new httpTransport.
 func NewHTTPTransport(r http.RoundTripper, scheme, addr string) Transport {
+	return NewHTTPTransportWithHeaders(r, scheme, addr, nil)
+}
+
+// NewHTTPTransportWithHeaders creates a new httpTransport which additionally
+// sets the given headers on every request it creates, e.g. a static auth
+// header required by a proxy in front of a remote plugin.
+func NewHTTPTransportWithHeaders(r http.RoundTripper, scheme, addr string, headers http.Header) Transport {
 	return httpTransport{
 		RoundTripper: r,
 		scheme:       scheme,
 		addr:         addr,
+		headers:      headers,
 	}
 }
 
@@ -32,5 +41,8 @@ func (t httpTransport) NewRequest(path string, data io.Reader) (*http.Request, e
 	}
 	req.URL.Scheme = t.scheme
 	req.URL.Host = t.addr
+	for k, vs := range t.headers {
+		req.Header[http.CanonicalHeaderKey(k)] = append([]string(nil), vs...)
+	}
 	return req, nil
 }
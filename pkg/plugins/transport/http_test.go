@@ -19,3 +19,37 @@ func TestHTTPTransport(t *testing.T) {
 	}
 	assert.Check(t, is.Equal(http.MethodPost, request.Method))
 }
+
+func TestRequestAcceptHeader(t *testing.T) {
+	newTransport := NewHTTPTransport(&http.Transport{}, "http", "0.0.0.0")
+	request, err := newTransport.NewRequest("Test.Echo", nil)
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(supportedVersions, request.Header.Values("Accept")))
+}
+
+func TestResponseVersion(t *testing.T) {
+	for _, tc := range []struct {
+		contentType string
+		version     string
+		expErr      bool
+	}{
+		{contentType: VersionMimetype, version: VersionMimetype},
+		{contentType: VersionMimetype + "; charset=utf-8", version: VersionMimetype},
+		{contentType: "application/vnd.docker.plugins.v1+json", version: "application/vnd.docker.plugins.v1+json"},
+		{contentType: "application/json"},
+		{contentType: ""},
+		{contentType: "application/vnd.docker.plugins.v9000+json", expErr: true},
+	} {
+		resp := &http.Response{Header: http.Header{}}
+		if tc.contentType != "" {
+			resp.Header.Set("Content-Type", tc.contentType)
+		}
+		v, err := ResponseVersion(resp)
+		if tc.expErr {
+			assert.Check(t, is.ErrorContains(err, "unknown plugin protocol version"))
+			continue
+		}
+		assert.NilError(t, err)
+		assert.Check(t, is.Equal(tc.version, v))
+	}
+}
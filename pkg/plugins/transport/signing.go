@@ -0,0 +1,65 @@
+package transport // import "github.com/docker/docker/pkg/plugins/transport"
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Header names for the optional HMAC signing scheme. A signed message
+// carries both headers; the signature is the hex HMAC-SHA256, under the
+// shared key, of the canonical payload built by Sign from the request path,
+// the timestamp header value, and the hex SHA-256 of the message body after
+// any Content-Encoding has been decoded. Plugin SDKs implement verification
+// with Verify and sign their responses the same way.
+const (
+	// SignatureHeader carries the hex HMAC-SHA256 signature.
+	SignatureHeader = "Docker-Plugin-Signature"
+	// SignatureTimestampHeader carries the Unix-seconds timestamp the
+	// signature covers.
+	SignatureTimestampHeader = "Docker-Plugin-Timestamp"
+	// UnsignedPayload takes the place of the body hash when the body is
+	// streamed and cannot be hashed before it is sent.
+	UnsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// BodyHash returns the hex SHA-256 of a message body, as used in the signed
+// payload.
+func BodyHash(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// Sign returns the hex HMAC-SHA256 signature of the canonical payload: the
+// request path, the timestamp, and the body hash (or UnsignedPayload),
+// newline-separated.
+func Sign(key []byte, path, timestamp, bodyHash string) string {
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n", path, timestamp, bodyHash)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks sig against the signature expected for the given payload,
+// in constant time, and that timestamp is within skew of now, rejecting
+// replayed messages.
+func Verify(key []byte, path, timestamp, bodyHash, sig string, skew time.Duration, now time.Time) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid signature timestamp %q", timestamp)
+	}
+	d := now.Sub(time.Unix(ts, 0))
+	if d < 0 {
+		d = -d
+	}
+	if d > skew {
+		return fmt.Errorf("signature timestamp %v outside clock-skew tolerance %v", d.Truncate(time.Second), skew)
+	}
+	if !hmac.Equal([]byte(Sign(key, path, timestamp, bodyHash)), []byte(sig)) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package transport // import "github.com/docker/docker/pkg/plugins/transport"
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestSignVerify(t *testing.T) {
+	key := []byte("secret")
+	now := time.Now()
+	ts := strconv.FormatInt(now.Unix(), 10)
+	bodyHash := BodyHash([]byte(`{"Name":"v"}`))
+
+	sig := Sign(key, "/VolumeDriver.Get", ts, bodyHash)
+	assert.NilError(t, Verify(key, "/VolumeDriver.Get", ts, bodyHash, sig, time.Minute, now))
+
+	// tampered body
+	err := Verify(key, "/VolumeDriver.Get", ts, BodyHash([]byte(`{"Name":"w"}`)), sig, time.Minute, now)
+	assert.ErrorContains(t, err, "signature mismatch")
+
+	// tampered path
+	err = Verify(key, "/VolumeDriver.Remove", ts, bodyHash, sig, time.Minute, now)
+	assert.ErrorContains(t, err, "signature mismatch")
+
+	// wrong key
+	err = Verify([]byte("other"), "/VolumeDriver.Get", ts, bodyHash, sig, time.Minute, now)
+	assert.ErrorContains(t, err, "signature mismatch")
+}
+
+func TestVerifyStaleTimestamp(t *testing.T) {
+	key := []byte("secret")
+	now := time.Now()
+	ts := strconv.FormatInt(now.Add(-10*time.Minute).Unix(), 10)
+	bodyHash := BodyHash(nil)
+
+	sig := Sign(key, "/Plugin.Activate", ts, bodyHash)
+	err := Verify(key, "/Plugin.Activate", ts, bodyHash, sig, time.Minute, now)
+	assert.ErrorContains(t, err, "clock-skew")
+
+	// the same message is fine with a larger tolerance
+	assert.NilError(t, Verify(key, "/Plugin.Activate", ts, bodyHash, sig, 15*time.Minute, now))
+
+	err = Verify(key, "/Plugin.Activate", "garbage", bodyHash, sig, time.Minute, now)
+	assert.ErrorContains(t, err, "invalid signature timestamp")
+}
@@ -1,7 +1,9 @@
 package transport // import "github.com/docker/docker/pkg/plugins/transport"
 
 import (
+	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"strings"
 )
@@ -9,6 +11,49 @@ import (
 // VersionMimetype is the Content-Type the engine sends to plugins.
 const VersionMimetype = "application/vnd.docker.plugins.v1.2+json"
 
+// versionMimetypePrefix is common to all plugin protocol version mimetypes.
+const versionMimetypePrefix = "application/vnd.docker.plugins."
+
+// supportedVersions lists the plugin protocol versions this engine speaks, in
+// preference order. All of them are offered to the plugin in the Accept
+// header of every request.
+var supportedVersions = []string{
+	VersionMimetype,
+	"application/vnd.docker.plugins.v1.1+json",
+	"application/vnd.docker.plugins.v1+json",
+}
+
+// UnknownVersionError is returned when a plugin answers with a protocol
+// version mimetype this engine does not speak.
+type UnknownVersionError struct {
+	Version string
+}
+
+func (e UnknownVersionError) Error() string {
+	return fmt.Sprintf("unknown plugin protocol version mimetype %q", e.Version)
+}
+
+// ResponseVersion returns the plugin protocol version mimetype the plugin
+// answered resp with, or an empty string when it did not declare one. Older
+// plugins responding with a generic content type such as application/json are
+// not an error; a plugin mimetype outside the supported versions is, so
+// callers get a clear failure instead of a decode error further on.
+func ResponseVersion(resp *http.Response) (string, error) {
+	ct := resp.Header.Get("Content-Type")
+	if mt, _, err := mime.ParseMediaType(ct); err == nil {
+		ct = mt
+	}
+	if !strings.HasPrefix(ct, versionMimetypePrefix) {
+		return "", nil
+	}
+	for _, v := range supportedVersions {
+		if ct == v {
+			return v, nil
+		}
+	}
+	return "", UnknownVersionError{Version: ct}
+}
+
 // RequestFactory defines an interface that
 // transports can implement to create new requests.
 type RequestFactory interface {
@@ -31,6 +76,8 @@ func newHTTPRequest(path string, data io.Reader) (*http.Request, error) {
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Add("Accept", VersionMimetype)
+	for _, v := range supportedVersions {
+		req.Header.Add("Accept", v)
+	}
 	return req, nil
 }
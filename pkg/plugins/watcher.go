@@ -0,0 +1,190 @@
+package plugins // import "github.com/docker/docker/pkg/plugins"
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+)
+
+// Actions recorded in a WatchEvent.
+const (
+	WatchActionAdded   = "added"
+	WatchActionRemoved = "removed"
+)
+
+// watchEventBacklog caps how many events a Watcher keeps for inspection.
+const watchEventBacklog = 64
+
+// WatchEvent records a plugin appearing in or disappearing from the
+// discovery directories.
+type WatchEvent struct {
+	// Name of the plugin.
+	Name string
+	// Addr the plugin was registered with; empty for removals.
+	Addr string
+	// Action is WatchActionAdded or WatchActionRemoved.
+	Action string
+	// Time the event was observed.
+	Time time.Time
+}
+
+// Watcher observes the plugin discovery directories and keeps the registry in
+// sync without waiting for a lookup by name: a spec or socket appearing
+// registers the plugin, and one disappearing removes it so the getters stop
+// returning it. Activation still happens lazily on first use.
+type Watcher struct {
+	fsw *fsnotify.Watcher
+
+	mu     sync.Mutex
+	events []WatchEvent
+}
+
+// NewWatcher starts watching the well-known plugin directories. Directories
+// which do not exist yet are skipped; they can be picked up by a later
+// Watcher once created.
+func NewWatcher() (*Watcher, error) {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	w := &Watcher{fsw: fsw}
+
+	for _, dir := range append([]string{socketsPath}, specsPaths...) {
+		w.watchDir(dir)
+		// discovery also looks one level down, in <dir>/<name>/<name>.<ext>
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				w.watchDir(filepath.Join(dir, entry.Name()))
+			}
+		}
+	}
+
+	go w.run()
+	return w, nil
+}
+
+func (w *Watcher) watchDir(dir string) {
+	if err := w.fsw.Add(dir); err != nil && !os.IsNotExist(err) {
+		logrus.WithError(err).WithField("dir", dir).Debug("cannot watch plugin directory")
+	}
+}
+
+// Events returns the most recent watch events, oldest first, for surfacing
+// through the plugin subsystem.
+func (w *Watcher) Events() []WatchEvent {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	out := make([]WatchEvent, len(w.events))
+	copy(out, w.events)
+	return out
+}
+
+// Close stops watching the plugin directories.
+func (w *Watcher) Close() error {
+	return w.fsw.Close()
+}
+
+func (w *Watcher) run() {
+	for {
+		select {
+		case ev, ok := <-w.fsw.Events:
+			if !ok {
+				return
+			}
+			switch {
+			case ev.Op&(fsnotify.Create|fsnotify.Write) != 0:
+				w.handleCreate(ev.Name)
+			case ev.Op&(fsnotify.Remove|fsnotify.Rename) != 0:
+				w.handleRemove(ev.Name)
+			}
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return
+			}
+			logrus.WithError(err).Error("error watching plugin directories")
+		}
+	}
+}
+
+func (w *Watcher) handleCreate(path string) {
+	if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+		// a plugin may be installed as <dir>/<name>/<name>.<ext>
+		w.watchDir(path)
+		return
+	}
+	name := pluginNameFromPath(path)
+	if name == "" {
+		return
+	}
+
+	// run the file through the regular discovery code so a malformed spec is
+	// rejected the same way a lookup would reject it
+	registry := newLocalRegistry()
+	pl, err := registry.Plugin(name)
+	if err != nil {
+		logrus.WithError(err).WithField("path", path).Debug("ignoring plugin candidate")
+		return
+	}
+
+	stored, fresh := storage.register(name, pl)
+	if !fresh {
+		// the plugin was rewritten in place; redo the handshake on next use
+		// so e.g. a freshly added capability is recognized
+		stored.invalidate()
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{"plugin": name, "addr": pl.Addr}).Info("plugin discovered")
+	w.record(WatchEvent{Name: name, Addr: pl.Addr, Action: WatchActionAdded, Time: time.Now()})
+}
+
+func (w *Watcher) handleRemove(path string) {
+	name := pluginNameFromPath(path)
+	if name == "" {
+		return
+	}
+
+	// the same plugin may be advertised through several paths; only drop it
+	// once none of them resolves anymore
+	registry := newLocalRegistry()
+	if _, err := registry.Plugin(name); err == nil {
+		return
+	}
+
+	if !storage.deregister(name) {
+		return
+	}
+
+	logrus.WithField("plugin", name).Info("plugin removed")
+	w.record(WatchEvent{Name: name, Action: WatchActionRemoved, Time: time.Now()})
+}
+
+func (w *Watcher) record(ev WatchEvent) {
+	w.mu.Lock()
+	w.events = append(w.events, ev)
+	if len(w.events) > watchEventBacklog {
+		w.events = w.events[len(w.events)-watchEventBacklog:]
+	}
+	w.mu.Unlock()
+}
+
+// pluginNameFromPath returns the plugin name a discovery file refers to, or
+// an empty string for files discovery would not consider.
+func pluginNameFromPath(path string) string {
+	ext := filepath.Ext(path)
+	switch ext {
+	case ".sock", ".spec", ".json":
+		return strings.TrimSuffix(filepath.Base(path), ext)
+	}
+	return ""
+}
@@ -0,0 +1,74 @@
+package plugins // import "github.com/docker/docker/pkg/plugins"
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+func waitForCondition(t *testing.T, pred func() bool, msg string) {
+	t.Helper()
+	for i := 0; i < 500; i++ {
+		if pred() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal(msg)
+}
+
+func TestWatcherAddRemove(t *testing.T) {
+	tmpdir, unregister := Setup(t)
+	defer unregister()
+
+	w, err := NewWatcher()
+	assert.NilError(t, err)
+	defer w.Close()
+
+	registered := func() bool {
+		storage.Lock()
+		defer storage.Unlock()
+		_, ok := storage.plugins["watched"]
+		return ok
+	}
+
+	spec := filepath.Join(tmpdir, "watched.spec")
+	assert.NilError(t, ioutil.WriteFile(spec, []byte("unix://"+filepath.Join(tmpdir, "watched.sock")), 0644))
+	waitForCondition(t, registered, "plugin was not registered when its spec appeared")
+
+	assert.NilError(t, os.Remove(spec))
+	waitForCondition(t, func() bool { return !registered() }, "plugin was not dropped when its spec disappeared")
+
+	events := w.Events()
+	assert.Assert(t, len(events) >= 2)
+	assert.Check(t, is.Equal("watched", events[0].Name))
+	assert.Check(t, is.Equal(WatchActionAdded, events[0].Action))
+	last := events[len(events)-1]
+	assert.Check(t, is.Equal("watched", last.Name))
+	assert.Check(t, is.Equal(WatchActionRemoved, last.Action))
+}
+
+func TestWatcherIgnoresMalformedSpec(t *testing.T) {
+	tmpdir, unregister := Setup(t)
+	defer unregister()
+
+	w, err := NewWatcher()
+	assert.NilError(t, err)
+	defer w.Close()
+
+	spec := filepath.Join(tmpdir, "broken.spec")
+	assert.NilError(t, ioutil.WriteFile(spec, []byte("://missing-scheme"), 0644))
+
+	// give the watcher a chance to (wrongly) register it
+	time.Sleep(100 * time.Millisecond)
+	storage.Lock()
+	_, ok := storage.plugins["broken"]
+	storage.Unlock()
+	assert.Check(t, !ok, "malformed spec must not be registered")
+	assert.Check(t, is.Len(w.Events(), 0))
+}
@@ -24,7 +24,7 @@ var (
 	BufioReader32KPool = newBufioReaderPoolWithSize(buffer32K)
 	// BufioWriter32KPool is a pool which returns bufio.Writer with a 32K buffer.
 	BufioWriter32KPool = newBufioWriterPoolWithSize(buffer32K)
-	buffer32KPool      = newBufferPoolWithSize(buffer32K)
+	buffer32KPool      = NewBufferPoolWithSize(buffer32K)
 )
 
 // BufioReaderPool is a bufio reader that uses sync.Pool.
@@ -55,34 +55,46 @@ func (bufPool *BufioReaderPool) Put(b *bufio.Reader) {
 	bufPool.pool.Put(b)
 }
 
-type bufferPool struct {
+// BufferPool is a pool of byte slices of a fixed size.
+type BufferPool struct {
 	pool sync.Pool
 }
 
-func newBufferPoolWithSize(size int) *bufferPool {
-	return &bufferPool{
+// NewBufferPoolWithSize returns a pool of byte slices of the given size.
+// Callers whose buffer size is fixed should prefer the shared 32K pools in
+// this package; sized pools are for copies whose throughput or latency needs
+// are tuned per stream.
+func NewBufferPoolWithSize(size int) *BufferPool {
+	return &BufferPool{
 		pool: sync.Pool{
 			New: func() interface{} { s := make([]byte, size); return &s },
 		},
 	}
 }
 
-func (bp *bufferPool) Get() *[]byte {
+// Get returns a buffer from the pool. The buffer size is that of the pool.
+func (bp *BufferPool) Get() *[]byte {
 	return bp.pool.Get().(*[]byte)
 }
 
-func (bp *bufferPool) Put(b *[]byte) {
+// Put puts the buffer back into the pool.
+func (bp *BufferPool) Put(b *[]byte) {
 	bp.pool.Put(b)
 }
 
-// Copy is a convenience wrapper which uses a buffer to avoid allocation in io.Copy.
-func Copy(dst io.Writer, src io.Reader) (written int64, err error) {
-	buf := buffer32KPool.Get()
+// Copy is like the package-level Copy, but draws its buffer from this pool.
+func (bp *BufferPool) Copy(dst io.Writer, src io.Reader) (written int64, err error) {
+	buf := bp.Get()
 	written, err = io.CopyBuffer(dst, src, *buf)
-	buffer32KPool.Put(buf)
+	bp.Put(buf)
 	return
 }
 
+// Copy is a convenience wrapper which uses a buffer to avoid allocation in io.Copy.
+func Copy(dst io.Writer, src io.Reader) (written int64, err error) {
+	return buffer32KPool.Copy(dst, src)
+}
+
 // NewReadCloserWrapper returns a wrapper which puts the bufio.Reader back
 // into the pool and closes the reader if it's an io.ReadCloser.
 func (bufPool *BufioReaderPool) NewReadCloserWrapper(buf *bufio.Reader, r io.Reader) io.ReadCloser {
@@ -0,0 +1,25 @@
+// Package requestid carries an identifier for an API request through
+// context, so that log lines and downstream calls made on behalf of the
+// request can be correlated. The plugins client forwards the ID to plugins
+// in the HeaderKey header; plugin authors are encouraged to log it.
+package requestid // import "github.com/docker/docker/pkg/requestid"
+
+import "context"
+
+// HeaderKey is the canonical header used to pass the request ID on to
+// downstream services such as plugins.
+const HeaderKey = "X-Request-ID"
+
+type ctxKey struct{}
+
+// WithID returns a context carrying id as the request ID.
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext returns the request ID carried by ctx, or an empty string
+// when there is none.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
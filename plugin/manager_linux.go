@@ -190,6 +190,9 @@ func (pm *Manager) disable(p *v2.Plugin, c *controller) error {
 
 	c.restart = false
 	shutdownPlugin(p, c.exitChan, pm.executor)
+	if pc := p.Client(); pc != nil {
+		pc.CloseIdleConnections()
+	}
 	pm.config.Store.SetState(p, false)
 	return pm.save(p)
 }
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/docker/docker/volume"
+	metrics "github.com/docker/go-metrics"
 	"github.com/sirupsen/logrus"
 )
 
@@ -25,6 +26,7 @@ func (a *volumeDriverAdapter) Name() string {
 }
 
 func (a *volumeDriverAdapter) Create(name string, opts map[string]string) (volume.Volume, error) {
+	defer metrics.StartTimer(driverCalls.WithValues(a.name, "create"))()
 	if err := a.proxy.Create(name, opts); err != nil {
 		return nil, err
 	}
@@ -37,10 +39,12 @@ func (a *volumeDriverAdapter) Create(name string, opts map[string]string) (volum
 }
 
 func (a *volumeDriverAdapter) Remove(v volume.Volume) error {
+	defer metrics.StartTimer(driverCalls.WithValues(a.name, "remove"))()
 	return a.proxy.Remove(v.Name())
 }
 
 func (a *volumeDriverAdapter) List() ([]volume.Volume, error) {
+	defer metrics.StartTimer(driverCalls.WithValues(a.name, "list"))()
 	ls, err := a.proxy.List()
 	if err != nil {
 		return nil, err
@@ -60,6 +64,7 @@ func (a *volumeDriverAdapter) List() ([]volume.Volume, error) {
 }
 
 func (a *volumeDriverAdapter) Get(name string) (volume.Volume, error) {
+	defer metrics.StartTimer(driverCalls.WithValues(a.name, "get"))()
 	v, err := a.proxy.Get(name)
 	if err != nil {
 		return nil, err
@@ -151,12 +156,14 @@ func (a *volumeAdapter) CachedPath() string {
 }
 
 func (a *volumeAdapter) Mount(id string) (string, error) {
+	defer metrics.StartTimer(driverCalls.WithValues(a.driverName, "mount"))()
 	mountpoint, err := a.proxy.Mount(a.name, id)
 	a.eMount = a.scopePath(mountpoint)
 	return a.eMount, err
 }
 
 func (a *volumeAdapter) Unmount(id string) error {
+	defer metrics.StartTimer(driverCalls.WithValues(a.driverName, "unmount"))()
 	err := a.proxy.Unmount(a.name, id)
 	if err == nil {
 		a.eMount = ""
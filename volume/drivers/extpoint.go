@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/docker/docker/errdefs"
 	getter "github.com/docker/docker/pkg/plugingetter"
@@ -18,6 +19,11 @@ import (
 
 const extName = "VolumeDriver"
 
+// defaultLookupTimeout bounds how long a lazy driver lookup may block on the
+// plugin subsystem. A missing plugin can otherwise hold up lookups for the
+// plugin subsystem's full retry window.
+const defaultLookupTimeout = 30 * time.Second
+
 // volumeDriver defines the available functions that volume plugins must implement.
 // This interface is only defined to generate the proxy objects.
 // It's not intended to be public or reused.
@@ -44,20 +50,34 @@ type volumeDriver interface {
 // Store is an in-memory store for volume drivers
 type Store struct {
 	extensions   map[string]volume.Driver
+	capabilities map[string]volume.Capability
 	mu           sync.Mutex
 	driverLock   *locker.Locker
 	pluginGetter getter.PluginGetter
+	// lookupTimeout bounds how long a lazy driver lookup may block on the
+	// plugin getter. Zero means no timeout.
+	lookupTimeout time.Duration
 }
 
 // NewStore creates a new volume driver store
 func NewStore(pg getter.PluginGetter) *Store {
 	return &Store{
-		extensions:   make(map[string]volume.Driver),
-		driverLock:   locker.New(),
-		pluginGetter: pg,
+		extensions:    make(map[string]volume.Driver),
+		capabilities:  make(map[string]volume.Capability),
+		driverLock:    locker.New(),
+		pluginGetter:  pg,
+		lookupTimeout: defaultLookupTimeout,
 	}
 }
 
+// SetLookupTimeout configures how long a lazy driver lookup may block on the
+// plugin getter before it is abandoned. Zero disables the timeout.
+func (s *Store) SetLookupTimeout(t time.Duration) {
+	s.mu.Lock()
+	s.lookupTimeout = t
+	s.mu.Unlock()
+}
+
 type driverNotFoundError string
 
 func (e driverNotFoundError) Error() string {
@@ -83,7 +103,7 @@ func (s *Store) lookup(name string, mode int) (volume.Driver, error) {
 		return ext, nil
 	}
 	if s.pluginGetter != nil {
-		p, err := s.pluginGetter.Get(name, extName, mode)
+		p, err := s.getPlugin(name, mode)
 		if err != nil {
 			return nil, errors.Wrap(err, "error looking up volume plugin "+name)
 		}
@@ -112,6 +132,51 @@ func (s *Store) lookup(name string, mode int) (volume.Driver, error) {
 	return nil, driverNotFoundError(name)
 }
 
+// getPlugin resolves a plugin through the plugin getter, bounded by the
+// store's lookup timeout.
+// The plugin getter has no way to cancel a lookup, so on timeout the pending
+// lookup is left to finish in the background and any reference it acquires is
+// released.
+func (s *Store) getPlugin(name string, mode int) (getter.CompatPlugin, error) {
+	s.mu.Lock()
+	timeout := s.lookupTimeout
+	s.mu.Unlock()
+
+	if timeout <= 0 {
+		return s.pluginGetter.Get(name, extName, mode)
+	}
+
+	type lookupResult struct {
+		p   getter.CompatPlugin
+		err error
+	}
+	chRes := make(chan lookupResult, 1)
+	go func() {
+		p, err := s.pluginGetter.Get(name, extName, mode)
+		chRes <- lookupResult{p: p, err: err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case res := <-chRes:
+		return res.p, res.err
+	case <-timer.C:
+		go func() {
+			res := <-chRes
+			if res.err == nil && mode > 0 {
+				// the lookup finished after we gave up on it, undo the
+				// reference count change
+				if _, err := s.pluginGetter.Get(name, extName, mode*-1); err != nil {
+					logrus.WithError(err).WithField("action", "abandon-lookup").WithField("plugin", name).Error("error releasing reference to plugin")
+				}
+			}
+		}()
+		return nil, errdefs.Deadline(errors.Errorf("timeout waiting for volume plugin %s", name))
+	}
+}
+
 func validateDriver(vd volume.Driver) error {
 	scope := vd.Scope()
 	if scope != volume.LocalScope && scope != volume.GlobalScope {
@@ -142,6 +207,60 @@ func (s *Store) Register(d volume.Driver, name string) bool {
 	return true
 }
 
+// Deregister removes the driver associated with the given name from the
+// store. It returns false if no driver was registered under that name.
+// This is intended to be called when the plugin backing a driver is disabled
+// or removed, so stale entries don't cause hangs on the next lookup.
+func (s *Store) Deregister(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	s.driverLock.Lock(name)
+	defer s.driverLock.Unlock(name)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.extensions[name]; !exists {
+		return false
+	}
+	delete(s.extensions, name)
+	delete(s.capabilities, name)
+	return true
+}
+
+// GetDriverCapabilities returns the capabilities of the driver registered
+// under the given name.
+// Querying a plugin's Capabilities endpoint is a remote call, so the result
+// is cached on first use; the cache entry is dropped when the driver is
+// deregistered (e.g. because the backing plugin was disabled), so a
+// re-enabled plugin is queried afresh.
+func (s *Store) GetDriverCapabilities(name string) (volume.Capability, error) {
+	s.mu.Lock()
+	c, ok := s.capabilities[name]
+	s.mu.Unlock()
+	if ok {
+		return c, nil
+	}
+
+	d, err := s.lookup(name, getter.Lookup)
+	if err != nil {
+		return volume.Capability{}, err
+	}
+
+	if a, ok := d.(*volumeDriverAdapter); ok {
+		c = a.getCapabilities()
+	} else {
+		c = volume.Capability{Scope: d.Scope()}
+	}
+
+	s.mu.Lock()
+	s.capabilities[name] = c
+	s.mu.Unlock()
+	return c, nil
+}
+
 // GetDriver returns a volume driver by its name.
 // If the driver is empty, it looks for the local driver.
 func (s *Store) GetDriver(name string) (volume.Driver, error) {
@@ -2,7 +2,11 @@ package drivers // import "github.com/docker/docker/volume/drivers"
 
 import (
 	"testing"
+	"time"
 
+	"github.com/docker/docker/errdefs"
+	getter "github.com/docker/docker/pkg/plugingetter"
+	"github.com/docker/docker/pkg/plugins"
 	volumetestutils "github.com/docker/docker/volume/testutils"
 )
 
@@ -22,3 +26,104 @@ func TestGetDriver(t *testing.T) {
 		t.Fatalf("Expected fake driver, got %s\n", d.Name())
 	}
 }
+
+func TestDeregister(t *testing.T) {
+	s := NewStore(nil)
+	if s.Deregister("missing") {
+		t.Fatal("Expected Deregister of a missing driver to return false")
+	}
+
+	s.Register(volumetestutils.NewFakeDriver("fake"), "fake")
+	if !s.Deregister("fake") {
+		t.Fatal("Expected Deregister of a registered driver to return true")
+	}
+	if _, err := s.GetDriver("fake"); err == nil {
+		t.Fatal("Expected error looking up a deregistered driver, was nil")
+	}
+}
+
+type blockingPluginGetter struct {
+	ch chan struct{}
+}
+
+func (g *blockingPluginGetter) Get(name, capability string, mode int) (getter.CompatPlugin, error) {
+	<-g.ch
+	return nil, errNoSuchVolume
+}
+
+func (g *blockingPluginGetter) GetAllByCap(capability string) ([]getter.CompatPlugin, error) {
+	return nil, nil
+}
+
+func (g *blockingPluginGetter) GetAllManagedPluginsByCap(capability string) []getter.CompatPlugin {
+	return nil
+}
+
+func (g *blockingPluginGetter) Handle(capability string, callback func(string, *plugins.Client)) {
+}
+
+func TestLookupTimeout(t *testing.T) {
+	pg := &blockingPluginGetter{ch: make(chan struct{})}
+	defer close(pg.ch)
+
+	s := NewStore(pg)
+	s.SetLookupTimeout(10 * time.Millisecond)
+
+	_, err := s.GetDriver("slow")
+	if err == nil {
+		t.Fatal("Expected error from a lookup exceeding the timeout, was nil")
+	}
+	if !errdefs.IsDeadline(err) {
+		t.Fatalf("Expected a deadline-exceeded error, got %v", err)
+	}
+}
+
+type scopedFakeDriver struct {
+	*volumetestutils.FakeDriver
+	scope      string
+	scopeCalls int
+}
+
+func (d *scopedFakeDriver) Scope() string {
+	d.scopeCalls++
+	return d.scope
+}
+
+func TestGetDriverCapabilities(t *testing.T) {
+	s := NewStore(nil)
+	if _, err := s.GetDriverCapabilities("missing"); err == nil {
+		t.Fatal("Expected error looking up capabilities of a missing driver, was nil")
+	}
+
+	d := &scopedFakeDriver{FakeDriver: volumetestutils.NewFakeDriver("fake"), scope: "global"}
+	s.Register(d, "fake")
+	calls := d.scopeCalls
+
+	caps, err := s.GetDriverCapabilities("fake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if caps.Scope != "global" {
+		t.Fatalf("Expected global scope, got %q", caps.Scope)
+	}
+	calls = d.scopeCalls
+	if _, err := s.GetDriverCapabilities("fake"); err != nil {
+		t.Fatal(err)
+	}
+	if d.scopeCalls != calls {
+		t.Fatal("Expected capabilities to be served from the cache")
+	}
+
+	// deregistering invalidates the cache so a re-registered driver is
+	// queried afresh
+	s.Deregister("fake")
+	d.scope = "local"
+	s.Register(d, "fake")
+	caps, err = s.GetDriverCapabilities("fake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if caps.Scope != "local" {
+		t.Fatalf("Expected local scope after re-registering, got %q", caps.Scope)
+	}
+}
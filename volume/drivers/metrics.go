@@ -0,0 +1,16 @@
+package drivers // import "github.com/docker/docker/volume/drivers"
+
+import (
+	metrics "github.com/docker/go-metrics"
+)
+
+// driverCalls times the individual calls made to volume drivers, labeled by
+// driver name and call. For plugin drivers each call is a round trip to the
+// plugin, so this is where slow plugins show up.
+var driverCalls metrics.LabeledTimer
+
+func init() {
+	ns := metrics.NewNamespace("engine", "volume_driver", nil)
+	driverCalls = ns.NewLabeledTimer("calls", "The number of seconds it takes a volume driver to complete each call", "driver", "call")
+	metrics.Register(ns)
+}
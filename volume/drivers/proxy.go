@@ -13,6 +13,8 @@ import (
 const (
 	longTimeout  = 2 * time.Minute
 	shortTimeout = 1 * time.Minute
+	// a driver's capabilities do not change while it is running
+	capabilitiesCacheTTL = 5 * time.Minute
 )
 
 type client interface {
@@ -241,7 +243,7 @@ func (pp *volumeDriverProxy) Capabilities() (capabilities volume.Capability, err
 		ret volumeDriverProxyCapabilitiesResponse
 	)
 
-	if err = pp.CallWithOptions("VolumeDriver.Capabilities", req, &ret, plugins.WithRequestTimeout(shortTimeout)); err != nil {
+	if err = pp.CallWithOptions("VolumeDriver.Capabilities", req, &ret, plugins.WithRequestTimeout(shortTimeout), plugins.WithRequestCacheable(capabilitiesCacheTTL)); err != nil {
 		return
 	}
 
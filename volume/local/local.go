@@ -44,10 +44,23 @@ type activeMount struct {
 	mounted bool
 }
 
+// Option can be passed to New to configure the resulting driver instance.
+type Option func(*Root)
+
+// WithValidRoots configures an allowlist of additional base paths under
+// which volumes may be placed with the "rootdir" creation option, e.g. to
+// put selected volumes on a different disk. The paths are created if they do
+// not exist.
+func WithValidRoots(roots ...string) Option {
+	return func(r *Root) {
+		r.altRoots = append(r.altRoots, roots...)
+	}
+}
+
 // New instantiates a new Root instance with the provided scope. Scope
 // is the base path that the Root instance uses to store its
 // volumes. The base path is created here if it does not exist.
-func New(scope string, rootIdentity idtools.Identity) (*Root, error) {
+func New(scope string, rootIdentity idtools.Identity, options ...Option) (*Root, error) {
 	rootDirectory := filepath.Join(scope, volumesPathName)
 
 	if err := idtools.MkdirAllAndChown(rootDirectory, 0701, idtools.CurrentIdentity()); err != nil {
@@ -60,26 +73,61 @@ func New(scope string, rootIdentity idtools.Identity) (*Root, error) {
 		volumes:      make(map[string]*localVolume),
 		rootIdentity: rootIdentity,
 	}
+	for _, o := range options {
+		o(r)
+	}
 
-	dirs, err := ioutil.ReadDir(rootDirectory)
-	if err != nil {
-		return nil, err
+	for i, p := range r.altRoots {
+		abs, err := filepath.Abs(filepath.Clean(p))
+		if err != nil {
+			return nil, err
+		}
+		r.altRoots[i] = abs
+		if err := idtools.MkdirAllAndChown(abs, 0701, idtools.CurrentIdentity()); err != nil {
+			return nil, err
+		}
 	}
 
+	var err error
 	if r.quotaCtl, err = quota.NewControl(rootDirectory); err != nil {
 		logrus.Debugf("No quota support for local volumes in %s: %v", rootDirectory, err)
 	}
 
+	if err := r.scanRoot(rootDirectory); err != nil {
+		return nil, err
+	}
+	for _, altRoot := range r.altRoots {
+		if err := r.scanRoot(altRoot); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// scanRoot restores the volumes backed by the directories under the given
+// root. Volumes whose name is already taken (e.g. the same name under two
+// roots) are skipped; the first root scanned wins.
+func (r *Root) scanRoot(rootDirectory string) error {
+	dirs, err := ioutil.ReadDir(rootDirectory)
+	if err != nil {
+		return err
+	}
+
 	for _, d := range dirs {
 		if !d.IsDir() {
 			continue
 		}
 
 		name := filepath.Base(d.Name())
+		if _, exists := r.volumes[name]; exists {
+			logrus.WithField("volume", name).WithField("path", rootDirectory).Warn("Skipping volume directory, name is already in use")
+			continue
+		}
 		v := &localVolume{
 			driverName: r.Name(),
 			name:       name,
-			path:       r.DataPath(name),
+			path:       filepath.Join(rootDirectory, name, VolumeDataPathName),
 			quotaCtl:   r.quotaCtl,
 		}
 		r.volumes[name] = v
@@ -87,7 +135,7 @@ func New(scope string, rootIdentity idtools.Identity) (*Root, error) {
 		if b, err := ioutil.ReadFile(optsFilePath); err == nil {
 			opts := optsConfig{}
 			if err := json.Unmarshal(b, &opts); err != nil {
-				return nil, errors.Wrapf(err, "error while unmarshaling volume options for volume: %s", name)
+				return errors.Wrapf(err, "error while unmarshaling volume options for volume: %s", name)
 			}
 			// Make sure this isn't an empty optsConfig.
 			// This could be empty due to buggy behavior in older versions of Docker.
@@ -99,8 +147,7 @@ func New(scope string, rootIdentity idtools.Identity) (*Root, error) {
 			unmount(v.path)
 		}
 	}
-
-	return r, nil
+	return nil
 }
 
 // Root implements the Driver interface for the volume package and
@@ -113,6 +160,9 @@ type Root struct {
 	quotaCtl     *quota.Control
 	volumes      map[string]*localVolume
 	rootIdentity idtools.Identity
+	// altRoots is the allowlist of additional base paths volumes may be
+	// placed under with the "rootdir" creation option
+	altRoots []string
 }
 
 // List lists all the volumes
@@ -153,6 +203,13 @@ func (r *Root) Create(name string, opts map[string]string) (volume.Volume, error
 	}
 
 	path := r.DataPath(name)
+	if dir := opts["rootdir"]; dir != "" {
+		base, err := r.resolveRootdir(dir)
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(base, name, VolumeDataPathName)
+	}
 	volRoot := filepath.Dir(path)
 	// Root dir does not need to be accessed by the remapped root
 	if err := idtools.MkdirAllAndChown(volRoot, 0701, idtools.CurrentIdentity()); err != nil {
@@ -263,6 +320,33 @@ func (r *Root) Scope() string {
 	return volume.LocalScope
 }
 
+// resolveRootdir validates the "rootdir" creation option against the
+// allowlisted roots. The requested path must resolve, after cleaning and
+// following symlinks, to exactly one of the configured roots; this blocks
+// escaping the allowlist via ".." segments or symlinked paths.
+func (r *Root) resolveRootdir(dir string) (string, error) {
+	if len(r.altRoots) == 0 {
+		return "", errdefs.InvalidParameter(errors.New("no alternate volume roots are configured"))
+	}
+	if !filepath.IsAbs(dir) {
+		return "", errdefs.InvalidParameter(errors.Errorf("rootdir must be an absolute path: %q", dir))
+	}
+	realPath, err := filepath.EvalSymlinks(filepath.Clean(dir))
+	if err != nil {
+		return "", errdefs.InvalidParameter(errors.Wrapf(err, "invalid rootdir %q", dir))
+	}
+	for _, root := range r.altRoots {
+		realRoot, err := filepath.EvalSymlinks(root)
+		if err != nil {
+			continue
+		}
+		if realPath == realRoot {
+			return root, nil
+		}
+	}
+	return "", errdefs.InvalidParameter(errors.Errorf("rootdir %q is not an allowed volume root", dir))
+}
+
 func (r *Root) validateName(name string) error {
 	if len(name) == 1 {
 		return errdefs.InvalidParameter(errors.New("volume name is too short, names should be at least two alphanumeric characters"))
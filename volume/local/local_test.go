@@ -333,3 +333,92 @@ func TestRelaodNoOpts(t *testing.T) {
 		}
 	}
 }
+
+func TestCreateWithRootdir(t *testing.T) {
+	skip.If(t, runtime.GOOS == "windows", "volume options are not supported on Windows")
+
+	rootDir, err := ioutil.TempDir("", "local-volume-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(rootDir)
+	altRoot, err := ioutil.TempDir("", "local-volume-test-alt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(altRoot)
+	outside, err := ioutil.TempDir("", "local-volume-test-outside")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outside)
+
+	ident := idtools.Identity{UID: os.Geteuid(), GID: os.Getegid()}
+	r, err := New(rootDir, ident, WithValidRoots(altRoot))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := r.Create("placed", map[string]string{"rootdir": altRoot})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(v.Path(), altRoot) {
+		t.Fatalf("expected volume data under %s, got %s", altRoot, v.Path())
+	}
+	if _, err := os.Stat(v.Path()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the placement must survive a driver restart
+	r2, err := New(rootDir, ident, WithValidRoots(altRoot))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2, err := r2.Get("placed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v2.Path() != v.Path() {
+		t.Fatalf("expected restored path %s, got %s", v.Path(), v2.Path())
+	}
+
+	// removal deletes the data under the alternate root
+	if err := r.Remove(v); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(altRoot, "placed")); !os.IsNotExist(err) {
+		t.Fatalf("expected volume dir to be removed, got %v", err)
+	}
+
+	// a symlink pointing outside the allowlist must not be usable as rootdir
+	link := filepath.Join(altRoot, "link")
+	if err := os.Symlink(outside, link); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, invalid := range []string{
+		outside,                      // not allowlisted
+		filepath.Join(altRoot, ".."), // traversal out of the allowlisted root
+		altRoot + "/../" + filepath.Base(outside), // traversal into a sibling
+		filepath.Base(altRoot),                    // relative path
+		link,                                      // symlink escaping the allowlisted root
+		filepath.Join(altRoot, "sub"),             // subdirectory of an allowlisted root
+	} {
+		if _, err := r.Create("escape", map[string]string{"rootdir": invalid}); err == nil {
+			t.Fatalf("expected error creating volume with rootdir %q", invalid)
+		}
+		if _, err := r.Get("escape"); err == nil {
+			t.Fatalf("volume must not exist after failed create with rootdir %q", invalid)
+		}
+	}
+
+	// without an allowlist the option is rejected entirely
+	rNone, err := New(rootDir, ident)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rNone.Create("placed2", map[string]string{"rootdir": altRoot}); err == nil {
+		t.Fatal("expected error creating volume with rootdir and no allowlisted roots")
+	}
+}
@@ -26,10 +26,11 @@ var (
 	oldVfsDir = filepath.Join("vfs", "dir")
 
 	validOpts = map[string]struct{}{
-		"type":   {}, // specify the filesystem type for mount, e.g. nfs
-		"o":      {}, // generic mount options
-		"device": {}, // device to mount from
-		"size":   {}, // quota size limit
+		"type":    {}, // specify the filesystem type for mount, e.g. nfs
+		"o":       {}, // generic mount options
+		"device":  {}, // device to mount from
+		"size":    {}, // quota size limit
+		"rootdir": {}, // allowlisted base path to place the volume under
 	}
 	mandatoryOpts = map[string][]string{
 		"device": {"type"},
@@ -43,6 +44,10 @@ type optsConfig struct {
 	MountOpts   string
 	MountDevice string
 	Quota       quota.Quota
+	// Rootdir is the allowlisted base path the volume was placed under, if
+	// the "rootdir" option was used. It is recorded so the volume's
+	// placement survives daemon restarts.
+	Rootdir string `json:",omitempty"`
 }
 
 func (o *optsConfig) String() string {
@@ -62,6 +67,13 @@ func (r *Root) scopedPath(realPath string) bool {
 		return true
 	}
 
+	// Allowlisted alternate roots
+	for _, root := range r.altRoots {
+		if strings.HasPrefix(realPath, root+string(filepath.Separator)) && realPath != root {
+			return true
+		}
+	}
+
 	return false
 }
 
@@ -77,6 +89,7 @@ func setOpts(v *localVolume, opts map[string]string) error {
 		MountType:   opts["type"],
 		MountOpts:   opts["o"],
 		MountDevice: opts["device"],
+		Rootdir:     opts["rootdir"],
 	}
 	if val, ok := opts["size"]; ok {
 		size, err := units.RAMInBytes(val)
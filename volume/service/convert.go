@@ -50,6 +50,7 @@ func (s *VolumesService) volumesToAPI(ctx context.Context, volumes []volume.Volu
 		default:
 		}
 		apiV := volumeToAPIType(v)
+		s.fillScope(&apiV)
 
 		if cachedPath {
 			if vv, ok := v.(pathCacher); ok {
@@ -77,6 +78,21 @@ func (s *VolumesService) volumesToAPI(ctx context.Context, volumes []volume.Volu
 	return out
 }
 
+// fillScope fills in the volume's scope from the driver's cached
+// capabilities when the volume itself doesn't carry one. This lets clients
+// tell cluster-wide (global) volumes apart from node-local ones.
+func (s *VolumesService) fillScope(apiV *types.Volume) {
+	if apiV.Scope != "" {
+		return
+	}
+	caps, err := s.vs.drivers.GetDriverCapabilities(apiV.Driver)
+	if err != nil {
+		logrus.WithError(err).WithField("driver", apiV.Driver).Debug("Failed to determine volume driver scope")
+		return
+	}
+	apiV.Scope = caps.Scope
+}
+
 func volumeToAPIType(v volume.Volume) types.Volume {
 	createdAt, _ := v.CreatedAt()
 	tv := types.Volume{
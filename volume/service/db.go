@@ -2,8 +2,10 @@ package service // import "github.com/docker/docker/volume/service"
 
 import (
 	"encoding/json"
+	"time"
 
 	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/volume/service/opts"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	bolt "go.etcd.io/bbolt"
@@ -16,6 +18,17 @@ type volumeMetadata struct {
 	Driver  string
 	Labels  map[string]string
 	Options map[string]string
+	// CreatedAt is the daemon-recorded creation time of the volume. It is
+	// persisted so that volumes backed by drivers which do not report a
+	// creation time keep a stable one across daemon restarts.
+	CreatedAt time.Time `json:",omitempty"`
+	// Adopted is set for volumes which were found in a driver without the
+	// store having any record of them (e.g. created out of band while the
+	// daemon was down). The creation time of such volumes is best-effort.
+	Adopted bool `json:",omitempty"`
+	// MountDefaults are default mount settings applied to every mount of
+	// the volume unless the container requests otherwise.
+	MountDefaults *opts.MountDefaults `json:",omitempty"`
 }
 
 func (s *VolumeStore) setMeta(name string, meta volumeMetadata) error {
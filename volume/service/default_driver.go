@@ -9,8 +9,8 @@ import (
 	"github.com/pkg/errors"
 )
 
-func setupDefaultDriver(store *drivers.Store, root string, rootIDs idtools.Identity) error {
-	d, err := local.New(root, rootIDs)
+func setupDefaultDriver(store *drivers.Store, root string, rootIDs idtools.Identity, volumeRoots []string) error {
+	d, err := local.New(root, rootIDs, local.WithValidRoots(volumeRoots...))
 	if err != nil {
 		return errors.Wrap(err, "error setting up default driver")
 	}
@@ -3,6 +3,8 @@ package service // import "github.com/docker/docker/volume/service"
 import (
 	"fmt"
 	"strings"
+
+	"github.com/docker/docker/errdefs"
 )
 
 const (
@@ -95,6 +97,45 @@ func isErr(err error, expected error) bool {
 	return err == expected
 }
 
+// DriverWarning describes a non-fatal problem with a volume driver
+// encountered while serving a request, such as a driver which failed to list
+// its volumes. Warnings are reported alongside the results from the healthy
+// drivers rather than failing the whole request.
+type DriverWarning struct {
+	// Driver is the name of the driver the warning is about
+	Driver string
+	// Err is the underlying error returned by the driver
+	Err error
+	// Incomplete is set when the driver's volumes may be missing from the
+	// returned results; only volumes cached by the store could be included.
+	Incomplete bool
+}
+
+// Class categorizes the underlying error so callers can distinguish, for
+// example, a driver timeout from other failures.
+func (w *DriverWarning) Class() string {
+	switch {
+	case errdefs.IsDeadline(w.Err):
+		return "deadline"
+	case errdefs.IsUnavailable(w.Err):
+		return "unavailable"
+	case errdefs.IsNotFound(w.Err):
+		return "not found"
+	default:
+		return "error"
+	}
+}
+
+// Error satisfies the built-in error interface type. The format is stable
+// and is what ends up in the API response's Warnings field.
+func (w *DriverWarning) Error() string {
+	msg := fmt.Sprintf("error from volume driver %s (%s): %v", w.Driver, w.Class(), w.Err)
+	if w.Incomplete {
+		msg += "; the list of volumes from this driver may be incomplete"
+	}
+	return msg
+}
+
 type invalidFilter struct {
 	filter string
 	value  interface{}
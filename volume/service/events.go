@@ -0,0 +1,166 @@
+package service // import "github.com/docker/docker/volume/service"
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/docker/docker/api/types/filters"
+)
+
+// eventSubscriptionBuffer is the size of the buffer on each subscription
+// channel. A subscriber that falls further behind than this has events
+// dropped rather than being able to block event emission.
+const eventSubscriptionBuffer = 64
+
+// VolumeEvent describes a volume lifecycle event as delivered to
+// subscribers. The actions match what external event consumers see through
+// the daemon's event stream: create, mount, unmount, destroy, and prune.
+type VolumeEvent struct {
+	// Action is the lifecycle action, e.g. "create" or "destroy"
+	Action string
+	// Name is the name of the volume; empty for prune events
+	Name string
+	// Attributes holds the same attributes logged on the daemon event
+	Attributes map[string]string
+}
+
+var acceptedEventFilters = map[string]bool{
+	"name":   true,
+	"driver": true,
+	"label":  true,
+}
+
+// EventSubscription is a handle on a stream of volume events created by
+// Subscribe.
+type EventSubscription struct {
+	ch      chan VolumeEvent
+	filter  filters.Args
+	dropped uint64
+	closed  chan struct{}
+	once    sync.Once
+}
+
+// C returns the channel events are delivered on. It is closed when the
+// subscription is cancelled or the service shuts down.
+func (es *EventSubscription) C() <-chan VolumeEvent {
+	return es.ch
+}
+
+// Dropped returns the number of events which matched the subscription but
+// were dropped because the subscriber was not keeping up.
+func (es *EventSubscription) Dropped() uint64 {
+	return atomic.LoadUint64(&es.dropped)
+}
+
+func (es *EventSubscription) close() {
+	es.once.Do(func() {
+		close(es.closed)
+		close(es.ch)
+	})
+}
+
+// Subscribe returns a subscription delivering volume events matching the
+// passed in filter. Supported filters are `name`, `driver`, and `label`; an
+// empty filter matches all events.
+// The subscription is backed by the same path as LogVolumeEvent, so event
+// ordering matches what external event consumers see. Subscribers cannot
+// block event emission: each subscription has a bounded buffer and events
+// beyond it are dropped and counted (see Dropped).
+// The subscription ends when ctx is cancelled or the service shuts down.
+func (s *VolumesService) Subscribe(ctx context.Context, filter filters.Args) (*EventSubscription, error) {
+	if err := filter.Validate(acceptedEventFilters); err != nil {
+		return nil, err
+	}
+
+	sub := &EventSubscription{
+		ch:     make(chan VolumeEvent, eventSubscriptionBuffer),
+		filter: filter,
+		closed: make(chan struct{}),
+	}
+
+	s.subsMu.Lock()
+	if s.subs == nil {
+		s.subs = make(map[*EventSubscription]struct{})
+	}
+	s.subs[sub] = struct{}{}
+	s.subsMu.Unlock()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.unsubscribe(sub)
+		case <-sub.closed:
+		}
+	}()
+	return sub, nil
+}
+
+func (s *VolumesService) unsubscribe(sub *EventSubscription) {
+	s.subsMu.Lock()
+	delete(s.subs, sub)
+	s.subsMu.Unlock()
+	sub.close()
+}
+
+// closeSubscriptions closes all event subscriptions; called on Shutdown.
+func (s *VolumesService) closeSubscriptions() {
+	s.subsMu.Lock()
+	subs := make([]*EventSubscription, 0, len(s.subs))
+	for sub := range s.subs {
+		subs = append(subs, sub)
+	}
+	s.subs = nil
+	s.subsMu.Unlock()
+
+	for _, sub := range subs {
+		sub.close()
+	}
+}
+
+// logVolumeEvent forwards the event to the daemon's event logger and fans it
+// out to matching subscribers.
+func (s *VolumesService) logVolumeEvent(name, action string, attributes map[string]string) {
+	s.eventLogger.LogVolumeEvent(name, action, attributes)
+
+	s.subsMu.Lock()
+	defer s.subsMu.Unlock()
+	if len(s.subs) == 0 {
+		return
+	}
+
+	ev := VolumeEvent{Action: action, Name: name, Attributes: attributes}
+	for sub := range s.subs {
+		if !s.eventMatches(sub.filter, ev) {
+			continue
+		}
+		select {
+		case sub.ch <- ev:
+		default:
+			atomic.AddUint64(&sub.dropped, 1)
+		}
+	}
+}
+
+// eventMatches checks the event against a subscription filter. Label
+// filtering uses the labels cached in the store for the event's volume.
+func (s *VolumesService) eventMatches(filter filters.Args, ev VolumeEvent) bool {
+	if filter.Len() == 0 {
+		return true
+	}
+	if filter.Contains("name") && !filter.Match("name", ev.Name) {
+		return false
+	}
+	if filter.Contains("driver") && !filter.ExactMatch("driver", ev.Attributes["driver"]) {
+		return false
+	}
+	if filter.Contains("label") {
+		s.vs.globalLock.RLock()
+		labels := s.vs.labels[ev.Name]
+		s.vs.globalLock.RUnlock()
+		if !filter.MatchKVList("label", labels) {
+			return false
+		}
+	}
+	return true
+}
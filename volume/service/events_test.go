@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/filters"
+	volumedrivers "github.com/docker/docker/volume/drivers"
+	"github.com/docker/docker/volume/testutils"
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+func collectEvents(t *testing.T, sub *EventSubscription, n int) []VolumeEvent {
+	t.Helper()
+	var out []VolumeEvent
+	timeout := time.After(10 * time.Second)
+	for len(out) < n {
+		select {
+		case ev, ok := <-sub.C():
+			if !ok {
+				t.Fatalf("subscription closed after %d events, wanted %d", len(out), n)
+			}
+			out = append(out, ev)
+		case <-timeout:
+			t.Fatalf("timed out after %d events, wanted %d", len(out), n)
+		}
+	}
+	return out
+}
+
+func TestServiceSubscribe(t *testing.T) {
+	t.Parallel()
+
+	ds := volumedrivers.NewStore(nil)
+	assert.Assert(t, ds.Register(testutils.NewFakeDriver("d1"), "d1"))
+	assert.Assert(t, ds.Register(testutils.NewFakeDriver("d2"), "d2"))
+
+	service, cleanup := newTestService(t, ds)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := service.Subscribe(ctx, filters.NewArgs(filters.Arg("bogus", "true")))
+	assert.Assert(t, err != nil)
+
+	all, err := service.Subscribe(ctx, filters.NewArgs())
+	assert.NilError(t, err)
+	byDriver, err := service.Subscribe(ctx, filters.NewArgs(filters.Arg("driver", "d2")))
+	assert.NilError(t, err)
+	byName, err := service.Subscribe(ctx, filters.NewArgs(filters.Arg("name", "v1")))
+	assert.NilError(t, err)
+
+	vol, err := service.Create(ctx, "v1", "d1")
+	assert.NilError(t, err)
+	_, err = service.Create(ctx, "v2", "d2")
+	assert.NilError(t, err)
+
+	_, err = service.Mount(ctx, vol, "c1")
+	assert.NilError(t, err)
+	assert.NilError(t, service.Unmount(ctx, vol, "c1"))
+
+	evs := collectEvents(t, all, 4)
+	assert.Check(t, is.Equal(evs[0].Action, "create"))
+	assert.Check(t, is.Equal(evs[0].Name, "v1"))
+	assert.Check(t, is.Equal(evs[1].Action, "create"))
+	assert.Check(t, is.Equal(evs[1].Name, "v2"))
+	assert.Check(t, is.Equal(evs[2].Action, "mount"))
+	assert.Check(t, is.Equal(evs[3].Action, "unmount"))
+
+	evs = collectEvents(t, byDriver, 1)
+	assert.Check(t, is.Equal(evs[0].Name, "v2"))
+
+	evs = collectEvents(t, byName, 3)
+	for _, ev := range evs {
+		assert.Check(t, is.Equal(ev.Name, "v1"))
+	}
+
+	// cancellation closes the subscription channel
+	cctx, cancel := context.WithCancel(ctx)
+	sub, err := service.Subscribe(cctx, filters.NewArgs())
+	assert.NilError(t, err)
+	cancel()
+	select {
+	case _, ok := <-sub.C():
+		assert.Check(t, !ok)
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for subscription to close")
+	}
+}
+
+func TestServiceSubscribeDoesNotBlock(t *testing.T) {
+	t.Parallel()
+
+	ds := volumedrivers.NewStore(nil)
+	assert.Assert(t, ds.Register(testutils.NewFakeDriver("d1"), "d1"))
+
+	service, cleanup := newTestService(t, ds)
+	defer cleanup()
+	ctx := context.Background()
+
+	sub, err := service.Subscribe(ctx, filters.NewArgs())
+	assert.NilError(t, err)
+
+	// never read from the subscription; emission must not block and
+	// overflow must be counted
+	for i := 0; i < eventSubscriptionBuffer+5; i++ {
+		service.logVolumeEvent("v1", "create", nil)
+	}
+	assert.Check(t, is.Equal(sub.Dropped(), uint64(5)))
+}
+
+func TestServiceShutdownClosesSubscriptions(t *testing.T) {
+	t.Parallel()
+
+	ds := volumedrivers.NewStore(nil)
+	service, cleanup := newTestService(t, ds)
+	defer cleanup()
+
+	sub, err := service.Subscribe(context.Background(), filters.NewArgs())
+	assert.NilError(t, err)
+
+	assert.NilError(t, service.Shutdown())
+	_, ok := <-sub.C()
+	assert.Check(t, !ok)
+}
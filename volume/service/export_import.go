@@ -0,0 +1,119 @@
+package service // import "github.com/docker/docker/volume/service"
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/archive"
+	"github.com/docker/docker/pkg/ioutils"
+	"github.com/docker/docker/pkg/stringid"
+	"github.com/docker/docker/volume"
+	"github.com/docker/docker/volume/service/opts"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Export streams a tar archive of the named volume's contents to w.
+// The volume is mounted for the duration of the export and unmounted when the
+// stream completes or ctx is cancelled. Ownership and extended attributes are
+// preserved in the archive.
+// Note that the driver interface has no notion of a read-only mount, so
+// writers concurrent with the export can still modify the contents.
+func (s *VolumesService) Export(ctx context.Context, name string, w io.Writer) error {
+	v, err := s.vs.Get(ctx, name)
+	if err != nil {
+		if IsNotExist(err) {
+			err = errdefs.NotFound(err)
+		}
+		return err
+	}
+
+	path, unmount, err := s.mountForTransfer(v, "export")
+	if err != nil {
+		return err
+	}
+	defer unmount()
+
+	rdr, err := archive.TarWithOptions(path, &archive.TarOptions{Compression: archive.Uncompressed})
+	if err != nil {
+		return err
+	}
+	rc := ioutils.NewCancelReadCloser(ctx, rdr)
+	defer rc.Close()
+
+	_, err = io.Copy(w, rc)
+	return err
+}
+
+// Import unpacks a tar stream, such as one produced by Export, into the named
+// volume. The volume is created if it does not exist yet; an existing volume
+// is reused, unless the `WithImportRequireEmpty` option is set and the volume
+// already contains data.
+func (s *VolumesService) Import(ctx context.Context, name string, r io.Reader, importOpts ...opts.ImportOption) (*types.Volume, error) {
+	var cfg opts.ImportConfig
+	for _, o := range importOpts {
+		o(&cfg)
+	}
+
+	v, err := s.vs.Create(ctx, name, cfg.Driver)
+	if err != nil {
+		return nil, err
+	}
+
+	path, unmount, err := s.mountForTransfer(v, "import")
+	if err != nil {
+		return nil, err
+	}
+	defer unmount()
+
+	if cfg.RequireEmpty {
+		entries, err := ioutil.ReadDir(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, errors.Wrap(err, "error checking volume contents")
+		}
+		if len(entries) > 0 {
+			return nil, errdefs.Conflict(errors.Errorf("volume %s is not empty", name))
+		}
+	}
+
+	rc := ioutils.NewCancelReadCloser(ctx, ioutil.NopCloser(r))
+	defer rc.Close()
+
+	if err := archive.Untar(rc, path, nil); err != nil {
+		return nil, errors.Wrap(err, "error extracting volume import")
+	}
+
+	apiV := volumeToAPIType(v)
+	return &apiV, nil
+}
+
+// mountForTransfer mounts the volume under a transient reference for an
+// export or import, recording a mount lease so a crash mid-transfer can be
+// repaired on the next start. The returned function unmounts the volume and
+// clears the lease.
+func (s *VolumesService) mountForTransfer(v volume.Volume, op string) (string, func(), error) {
+	mountID := op + "-" + stringid.GenerateRandomID()
+	path, err := v.Mount(mountID)
+	if err != nil {
+		return "", nil, errors.Wrapf(err, "error mounting volume for %s", op)
+	}
+	if err := s.vs.setLease(v.Name(), mountLease{MountID: mountID, Owner: mountID, CreatedAt: time.Now()}); err != nil {
+		logrus.WithError(err).WithField("volume", v.Name()).Warn("Error recording volume mount lease")
+	}
+
+	unmount := func() {
+		if err := v.Unmount(mountID); err != nil {
+			logrus.WithError(err).WithField("volume", v.Name()).Warnf("Error unmounting volume after %s", op)
+			return
+		}
+		if err := s.vs.removeLease(v.Name(), mountID); err != nil {
+			logrus.WithError(err).WithField("volume", v.Name()).Warn("Error removing volume mount lease")
+		}
+	}
+	return path, unmount, nil
+}
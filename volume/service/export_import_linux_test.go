@@ -0,0 +1,84 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/idtools"
+	"github.com/docker/docker/volume"
+	volumedrivers "github.com/docker/docker/volume/drivers"
+	"github.com/docker/docker/volume/local"
+	"github.com/docker/docker/volume/service/opts"
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+func TestExportImportRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	ds := volumedrivers.NewStore(nil)
+	dir, err := ioutil.TempDir("", t.Name())
+	assert.NilError(t, err)
+	defer os.RemoveAll(dir)
+
+	l, err := local.New(dir, idtools.Identity{UID: os.Getuid(), GID: os.Getegid()})
+	assert.NilError(t, err)
+	assert.Assert(t, ds.Register(l, volume.DefaultDriverName))
+
+	service, cleanup := newTestService(t, ds)
+	defer cleanup()
+	ctx := context.Background()
+
+	src, err := service.Create(ctx, "src", volume.DefaultDriverName)
+	assert.NilError(t, err)
+
+	// populate a tree with a subdirectory, a symlink, and a sparse file
+	assert.NilError(t, os.Mkdir(filepath.Join(src.Mountpoint, "sub"), 0755))
+	assert.NilError(t, ioutil.WriteFile(filepath.Join(src.Mountpoint, "sub", "a.txt"), []byte("hello"), 0644))
+	assert.NilError(t, os.Symlink("sub/a.txt", filepath.Join(src.Mountpoint, "link")))
+
+	sparse, err := os.Create(filepath.Join(src.Mountpoint, "sparse"))
+	assert.NilError(t, err)
+	assert.NilError(t, sparse.Truncate(64*1024))
+	_, err = sparse.WriteAt([]byte("end"), 64*1024-3)
+	assert.NilError(t, err)
+	assert.NilError(t, sparse.Close())
+
+	var buf bytes.Buffer
+	assert.NilError(t, service.Export(ctx, "src", &buf))
+
+	dst, err := service.Import(ctx, "dst", &buf)
+	assert.NilError(t, err)
+
+	content, err := ioutil.ReadFile(filepath.Join(dst.Mountpoint, "sub", "a.txt"))
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(string(content), "hello"))
+
+	target, err := os.Readlink(filepath.Join(dst.Mountpoint, "link"))
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(target, "sub/a.txt"))
+
+	origSparse, err := ioutil.ReadFile(filepath.Join(src.Mountpoint, "sparse"))
+	assert.NilError(t, err)
+	gotSparse, err := ioutil.ReadFile(filepath.Join(dst.Mountpoint, "sparse"))
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(origSparse, gotSparse))
+
+	// the destination now has content, so an import requiring an empty
+	// volume must refuse to touch it
+	_, err = service.Import(ctx, "dst", bytes.NewReader(nil), opts.WithImportRequireEmpty)
+	assert.Assert(t, errdefs.IsConflict(err), err)
+
+	// export into a cancelled context must not hang and must leave no lease
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.Assert(t, service.Export(cctx, "src", &bytes.Buffer{}) != nil)
+	leases, err := service.vs.getLeases("src")
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(leases, 0))
+}
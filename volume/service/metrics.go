@@ -0,0 +1,119 @@
+package service // import "github.com/docker/docker/volume/service"
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/errdefs"
+	metrics "github.com/docker/go-metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	volumeActions      metrics.LabeledTimer
+	volumeActionErrors metrics.LabeledCounter
+	pruneDuration      metrics.Timer
+	pruneReclaimed     metrics.Counter
+
+	volumeCountCtr *volumeCounter
+)
+
+func init() {
+	ns := metrics.NewNamespace("engine", "volume", nil)
+	volumeActions = ns.NewLabeledTimer("actions", "The number of seconds it takes to process each volume action", "driver", "action")
+	volumeActionErrors = ns.NewLabeledCounter("action_errors", "The total number of volume actions which returned an error", "driver", "action", "class")
+	pruneDuration = ns.NewTimer("prune", "The number of seconds it takes to run a volume prune")
+	pruneReclaimed = ns.NewCounter("prune_reclaimed_bytes", "The total number of bytes reclaimed by volume prunes")
+
+	volumeCountCtr = &volumeCounter{
+		stores: make(map[*VolumeStore]struct{}),
+		desc:   ns.NewDesc("count", "The current number of volumes known to the store", metrics.Unit("volumes"), "driver", "dangling"),
+	}
+	ns.Add(volumeCountCtr)
+
+	metrics.Register(ns)
+}
+
+// observeVolumeAction records the duration and outcome of a single volume
+// action for the given driver.
+func observeVolumeAction(driver, action string, start time.Time, err error) {
+	volumeActions.WithValues(driver, action).UpdateSince(start)
+	if err != nil {
+		volumeActionErrors.WithValues(driver, action, errClass(err)).Inc()
+	}
+}
+
+// errClass buckets an error into a small, stable set of errdefs-derived
+// classes suitable for use as a metric label value.
+func errClass(err error) string {
+	switch {
+	case errdefs.IsNotFound(err):
+		return "not_found"
+	case errdefs.IsConflict(err):
+		return "conflict"
+	case errdefs.IsInvalidParameter(err):
+		return "invalid_parameter"
+	case errdefs.IsUnavailable(err):
+		return "unavailable"
+	case errdefs.IsDeadline(err):
+		return "deadline"
+	case errdefs.IsCancelled(err):
+		return "cancelled"
+	case errdefs.IsSystem(err):
+		return "system"
+	default:
+		return "unknown"
+	}
+}
+
+// volumeCounter reports the current number of volumes by driver and dangling
+// state. Counts are computed from the store's in-memory cache at scrape time,
+// so collecting never hits the drivers.
+type volumeCounter struct {
+	mu     sync.Mutex
+	stores map[*VolumeStore]struct{}
+	desc   *prometheus.Desc
+}
+
+func (ctr *volumeCounter) register(s *VolumeStore) {
+	ctr.mu.Lock()
+	ctr.stores[s] = struct{}{}
+	ctr.mu.Unlock()
+}
+
+func (ctr *volumeCounter) deregister(s *VolumeStore) {
+	ctr.mu.Lock()
+	delete(ctr.stores, s)
+	ctr.mu.Unlock()
+}
+
+func (ctr *volumeCounter) Describe(ch chan<- *prometheus.Desc) {
+	ch <- ctr.desc
+}
+
+func (ctr *volumeCounter) Collect(ch chan<- prometheus.Metric) {
+	ctr.mu.Lock()
+	stores := make([]*VolumeStore, 0, len(ctr.stores))
+	for s := range ctr.stores {
+		stores = append(stores, s)
+	}
+	ctr.mu.Unlock()
+
+	type countKey struct {
+		driver   string
+		dangling bool
+	}
+	counts := make(map[countKey]int)
+	for _, s := range stores {
+		s.globalLock.RLock()
+		for name, v := range s.names {
+			counts[countKey{driver: v.DriverName(), dangling: len(s.refs[name]) == 0}]++
+		}
+		s.globalLock.RUnlock()
+	}
+
+	for k, count := range counts {
+		ch <- prometheus.MustNewConstMetric(ctr.desc, prometheus.GaugeValue, float64(count), k.driver, strconv.FormatBool(k.dangling))
+	}
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/volume"
+	volumedrivers "github.com/docker/docker/volume/drivers"
+	"github.com/docker/docker/volume/service/opts"
+	"github.com/docker/docker/volume/testutils"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"gotest.tools/v3/assert"
+)
+
+// gatherMetrics scrapes the global registry and returns the metric families
+// indexed by name.
+func gatherMetrics(t *testing.T) map[string]*dto.MetricFamily {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	assert.NilError(t, err)
+	out := make(map[string]*dto.MetricFamily, len(families))
+	for _, f := range families {
+		out[f.GetName()] = f
+	}
+	return out
+}
+
+// findSeries returns the metric in the family whose labels are a superset of
+// want, or nil if no such series exists.
+func findSeries(f *dto.MetricFamily, want map[string]string) *dto.Metric {
+	if f == nil {
+		return nil
+	}
+	for _, m := range f.GetMetric() {
+		labels := make(map[string]string, len(m.GetLabel()))
+		for _, lp := range m.GetLabel() {
+			labels[lp.GetName()] = lp.GetValue()
+		}
+		matched := true
+		for k, v := range want {
+			if labels[k] != v {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return m
+		}
+	}
+	return nil
+}
+
+func TestServiceMetrics(t *testing.T) {
+	ds := volumedrivers.NewStore(nil)
+	assert.Assert(t, ds.Register(testutils.NewFakeDriver(volume.DefaultDriverName), volume.DefaultDriverName))
+
+	ctx := context.Background()
+	service, cleanup := newTestService(t, ds)
+	defer cleanup()
+
+	v, err := service.Create(ctx, "metrics-v1", volume.DefaultDriverName)
+	assert.NilError(t, err)
+	_, err = service.Create(ctx, "metrics-v2", volume.DefaultDriverName, opts.WithCreateReference("ref1"))
+	assert.NilError(t, err)
+
+	// a create for a driver which does not exist must be counted as an error
+	_, err = service.Create(ctx, "metrics-bogus", "notexist")
+	assert.Assert(t, err != nil)
+
+	_, err = service.Mount(ctx, v, "mount-ref")
+	assert.NilError(t, err)
+	assert.NilError(t, service.Unmount(ctx, v, "mount-ref"))
+	assert.NilError(t, service.Remove(ctx, v.Name))
+
+	_, err = service.Prune(ctx, filters.NewArgs())
+	assert.NilError(t, err)
+
+	families := gatherMetrics(t)
+
+	actions := families["engine_volume_actions_seconds"]
+	assert.Assert(t, actions != nil, "expected engine_volume_actions_seconds to be registered")
+	for _, action := range []string{"create", "mount", "unmount", "remove"} {
+		m := findSeries(actions, map[string]string{"driver": volume.DefaultDriverName, "action": action})
+		assert.Assert(t, m != nil, "missing actions series for %q", action)
+		assert.Assert(t, m.GetHistogram().GetSampleCount() > 0, "no observations for %q", action)
+	}
+
+	errors := findSeries(families["engine_volume_action_errors_total"], map[string]string{"driver": "notexist", "action": "create", "class": "not_found"})
+	assert.Assert(t, errors != nil, "missing action_errors series for the failed create")
+	assert.Assert(t, errors.GetCounter().GetValue() >= 1)
+
+	prune := families["engine_volume_prune_seconds"]
+	assert.Assert(t, prune != nil, "expected engine_volume_prune_seconds to be registered")
+	assert.Assert(t, prune.GetMetric()[0].GetHistogram().GetSampleCount() > 0)
+	assert.Assert(t, families["engine_volume_prune_reclaimed_bytes_total"] != nil)
+
+	// metrics-v2 holds a reference, so it must be counted as non-dangling
+	counts := findSeries(families["engine_volume_count_volumes"], map[string]string{"driver": volume.DefaultDriverName, "dangling": strconv.FormatBool(false)})
+	assert.Assert(t, counts != nil, "missing count series for referenced volumes")
+	assert.Assert(t, counts.GetGauge().GetValue() >= 1)
+}
@@ -0,0 +1,95 @@
+package service // import "github.com/docker/docker/volume/service"
+
+import (
+	"context"
+	"runtime"
+
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/volume/service/opts"
+	"github.com/pkg/errors"
+)
+
+// validateMountDefaults checks stored default mount settings in a
+// platform-specific manner; mount propagation is only supported on linux.
+func validateMountDefaults(md *opts.MountDefaults) error {
+	if md == nil || md.Propagation == "" {
+		return nil
+	}
+	if runtime.GOOS != "linux" {
+		return errdefs.InvalidParameter(errors.Errorf("mount propagation is not supported on %s", runtime.GOOS))
+	}
+	for _, p := range mount.Propagations {
+		if md.Propagation == p {
+			return nil
+		}
+	}
+	return errdefs.InvalidParameter(errors.Errorf("invalid mount propagation mode: %q", md.Propagation))
+}
+
+// MountDefaults returns the default mount settings stored with the named
+// volume. A nil result means the volume has no stored defaults.
+func (s *VolumesService) MountDefaults(ctx context.Context, name string) (*opts.MountDefaults, error) {
+	name = normalizeVolumeName(name)
+	meta, err := s.vs.getMeta(name)
+	if err != nil {
+		return nil, err
+	}
+	if meta.Name == "" {
+		return nil, errdefs.NotFound(errNoSuchVolume)
+	}
+	return meta.MountDefaults, nil
+}
+
+// SetMountDefaults updates the default mount settings stored with the named
+// volume. Passing the zero value clears the stored defaults.
+func (s *VolumesService) SetMountDefaults(ctx context.Context, name string, md opts.MountDefaults) error {
+	if err := validateMountDefaults(&md); err != nil {
+		return err
+	}
+
+	name = normalizeVolumeName(name)
+	s.vs.locks.Lock(name)
+	defer s.vs.locks.Unlock(name)
+
+	meta, err := s.vs.getMeta(name)
+	if err != nil {
+		return err
+	}
+	if meta.Name == "" {
+		return errdefs.NotFound(errNoSuchVolume)
+	}
+	if md.Propagation == "" && len(md.Options) == 0 {
+		meta.MountDefaults = nil
+	} else {
+		meta.MountDefaults = &md
+	}
+	return s.vs.setMeta(name, meta)
+}
+
+// MergeMountDefaults merges a volume's stored default mount settings under
+// the container-specified values. A propagation mode requested by the
+// container always wins, and requested options keep their position ahead of
+// any defaults not already present.
+func MergeMountDefaults(requestedPropagation mount.Propagation, requestedOpts []string, defaults *opts.MountDefaults) (mount.Propagation, []string) {
+	if defaults == nil {
+		return requestedPropagation, requestedOpts
+	}
+
+	propagation := requestedPropagation
+	if propagation == "" {
+		propagation = defaults.Propagation
+	}
+
+	out := append([]string(nil), requestedOpts...)
+	seen := make(map[string]bool, len(out))
+	for _, o := range out {
+		seen[o] = true
+	}
+	for _, o := range defaults.Options {
+		if !seen[o] {
+			out = append(out, o)
+		}
+	}
+	return propagation, out
+}
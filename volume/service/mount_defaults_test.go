@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/errdefs"
+	volumedrivers "github.com/docker/docker/volume/drivers"
+	"github.com/docker/docker/volume/service/opts"
+	"github.com/docker/docker/volume/testutils"
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+func TestServiceMountDefaults(t *testing.T) {
+	t.Parallel()
+
+	ds := volumedrivers.NewStore(nil)
+	assert.Assert(t, ds.Register(testutils.NewFakeDriver("d1"), "d1"))
+
+	service, cleanup := newTestService(t, ds)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := service.Create(ctx, "v1", "d1", opts.WithCreateMountDefaults(opts.MountDefaults{
+		Propagation: mount.PropagationRShared,
+		Options:     []string{"noatime"},
+	}))
+	assert.NilError(t, err)
+
+	md, err := service.MountDefaults(ctx, "v1")
+	assert.NilError(t, err)
+	assert.Assert(t, md != nil)
+	assert.Check(t, is.Equal(md.Propagation, mount.PropagationRShared))
+	assert.Check(t, is.DeepEqual(md.Options, []string{"noatime"}))
+
+	// stored defaults are shown on inspect
+	v, err := service.Get(ctx, "v1", opts.WithGetResolveStatus)
+	assert.NilError(t, err)
+	assert.Check(t, v.Status["MountDefaults"] != nil)
+
+	// invalid propagation modes are rejected at create and update time
+	_, err = service.Create(ctx, "v2", "d1", opts.WithCreateMountDefaults(opts.MountDefaults{
+		Propagation: mount.Propagation("banana"),
+	}))
+	assert.Assert(t, errdefs.IsInvalidParameter(err), err)
+	err = service.SetMountDefaults(ctx, "v1", opts.MountDefaults{Propagation: mount.Propagation("banana")})
+	assert.Assert(t, errdefs.IsInvalidParameter(err), err)
+
+	assert.NilError(t, service.SetMountDefaults(ctx, "v1", opts.MountDefaults{Propagation: mount.PropagationRSlave}))
+	md, err = service.MountDefaults(ctx, "v1")
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(md.Propagation, mount.PropagationRSlave))
+
+	err = service.SetMountDefaults(ctx, "notexist", opts.MountDefaults{Propagation: mount.PropagationRSlave})
+	assert.Assert(t, errdefs.IsNotFound(err), err)
+
+	// clearing the defaults removes them entirely
+	assert.NilError(t, service.SetMountDefaults(ctx, "v1", opts.MountDefaults{}))
+	md, err = service.MountDefaults(ctx, "v1")
+	assert.NilError(t, err)
+	assert.Check(t, md == nil)
+}
+
+func TestMergeMountDefaults(t *testing.T) {
+	t.Parallel()
+
+	defaults := &opts.MountDefaults{
+		Propagation: mount.PropagationRShared,
+		Options:     []string{"noatime", "nodev"},
+	}
+
+	// container-specified values win
+	propagation, options := MergeMountDefaults(mount.PropagationPrivate, []string{"nodev", "ro"}, defaults)
+	assert.Check(t, is.Equal(propagation, mount.PropagationPrivate))
+	assert.Check(t, is.DeepEqual(options, []string{"nodev", "ro", "noatime"}))
+
+	// defaults fill in unset values
+	propagation, options = MergeMountDefaults("", nil, defaults)
+	assert.Check(t, is.Equal(propagation, mount.PropagationRShared))
+	assert.Check(t, is.DeepEqual(options, []string{"noatime", "nodev"}))
+
+	propagation, options = MergeMountDefaults(mount.PropagationSlave, []string{"ro"}, nil)
+	assert.Check(t, is.Equal(propagation, mount.PropagationSlave))
+	assert.Check(t, is.DeepEqual(options, []string{"ro"}))
+}
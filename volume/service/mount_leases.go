@@ -0,0 +1,174 @@
+package service // import "github.com/docker/docker/volume/service"
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+var mountLeasesBucketName = []byte("mount-leases")
+
+// mountLease records an active mount of a volume in the metadata db so that
+// mounts leaked by an unclean daemon shutdown can be repaired on the next
+// start instead of leaving the driver with a dangling reference.
+type mountLease struct {
+	// MountID is the reference passed to Mount/Unmount
+	MountID string
+	// Owner is the resource the mount was taken for, typically a container ID
+	Owner string
+	// CreatedAt is the time the lease was taken
+	CreatedAt time.Time
+}
+
+func (s *VolumeStore) setLease(name string, lease mountLease) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		ls, err := leasesFromBucket(tx, name)
+		if err != nil {
+			return err
+		}
+		for i, l := range ls {
+			if l.MountID == lease.MountID {
+				ls[i] = lease
+				return leasesToBucket(tx, name, ls)
+			}
+		}
+		return leasesToBucket(tx, name, append(ls, lease))
+	})
+}
+
+func (s *VolumeStore) removeLease(name, mountID string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		ls, err := leasesFromBucket(tx, name)
+		if err != nil {
+			return err
+		}
+		out := ls[:0]
+		for _, l := range ls {
+			if l.MountID != mountID {
+				out = append(out, l)
+			}
+		}
+		return leasesToBucket(tx, name, out)
+	})
+}
+
+func (s *VolumeStore) removeAllLeases(name string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return leasesToBucket(tx, name, nil)
+	})
+}
+
+func (s *VolumeStore) getLeases(name string) ([]mountLease, error) {
+	var ls []mountLease
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		ls, err = leasesFromBucket(tx, name)
+		return err
+	})
+	return ls, err
+}
+
+// listLeases returns all outstanding mount leases keyed by volume name.
+func (s *VolumeStore) listLeases() (map[string][]mountLease, error) {
+	out := make(map[string][]mountLease)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(mountLeasesBucketName)
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			if len(v) == 0 {
+				return nil
+			}
+			var ls []mountLease
+			if err := json.Unmarshal(v, &ls); err != nil {
+				logrus.Errorf("Error while reading mount leases for volume %q: %v", string(k), err)
+				return nil
+			}
+			if len(ls) > 0 {
+				out[string(k)] = ls
+			}
+			return nil
+		})
+	})
+	return out, err
+}
+
+func leasesFromBucket(tx *bolt.Tx, name string) ([]mountLease, error) {
+	b := tx.Bucket(mountLeasesBucketName)
+	if b == nil {
+		return nil, nil
+	}
+	val := b.Get([]byte(name))
+	if len(val) == 0 {
+		return nil, nil
+	}
+	var ls []mountLease
+	if err := json.Unmarshal(val, &ls); err != nil {
+		return nil, errors.Wrap(err, "error unmarshaling volume mount leases")
+	}
+	return ls, nil
+}
+
+func leasesToBucket(tx *bolt.Tx, name string, ls []mountLease) error {
+	b, err := tx.CreateBucketIfNotExists(mountLeasesBucketName)
+	if err != nil {
+		return errors.Wrap(err, "error creating mount leases bucket")
+	}
+	if len(ls) == 0 {
+		return errors.Wrap(b.Delete([]byte(name)), "error removing volume mount leases")
+	}
+	leasesJSON, err := json.Marshal(ls)
+	if err != nil {
+		return err
+	}
+	return errors.Wrap(b.Put([]byte(name), leasesJSON), "error setting volume mount leases")
+}
+
+// ReconcileMountLeases walks the outstanding mount leases and repairs mounts
+// leaked by an unclean daemon shutdown: for each lease whose owner no longer
+// exists according to the passed in callback, the volume is unmounted with
+// the recorded mount ID and the lease is dropped.
+// It is intended to be called once on daemon startup, with ownerExists
+// checking the daemon's container store.
+func (s *VolumesService) ReconcileMountLeases(ctx context.Context, ownerExists func(id string) bool) error {
+	leases, err := s.vs.listLeases()
+	if err != nil {
+		return err
+	}
+
+	for name, ls := range leases {
+		for _, lease := range ls {
+			if ownerExists(lease.Owner) {
+				continue
+			}
+
+			logger := logrus.WithField("volume", name).WithField("owner", lease.Owner).WithField("mount-id", lease.MountID)
+			v, err := s.vs.Get(ctx, name)
+			if err != nil {
+				if IsNotExist(err) {
+					// the volume is gone, just drop the lease
+					if err := s.vs.removeLease(name, lease.MountID); err != nil {
+						logger.WithError(err).Warn("Error removing mount lease for deleted volume")
+					}
+					continue
+				}
+				return err
+			}
+
+			if err := v.Unmount(lease.MountID); err != nil {
+				logger.WithError(err).Warn("Error unmounting leaked volume mount")
+			}
+			if err := s.vs.removeLease(name, lease.MountID); err != nil {
+				logger.WithError(err).Warn("Error removing mount lease")
+				continue
+			}
+			logger.Info("Repaired leaked volume mount")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	volumedrivers "github.com/docker/docker/volume/drivers"
+	"github.com/docker/docker/volume/service/opts"
+	"github.com/docker/docker/volume/testutils"
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+func TestServiceMountLeases(t *testing.T) {
+	t.Parallel()
+
+	ds := volumedrivers.NewStore(nil)
+	assert.Assert(t, ds.Register(testutils.NewFakeDriver("d1"), "d1"))
+
+	service, cleanup := newTestService(t, ds)
+	defer cleanup()
+	ctx := context.Background()
+
+	vol, err := service.Create(ctx, "v1", "d1")
+	assert.NilError(t, err)
+
+	_, err = service.Mount(ctx, vol, "c1")
+	assert.NilError(t, err)
+
+	leases, err := service.vs.getLeases("v1")
+	assert.NilError(t, err)
+	assert.Assert(t, is.Len(leases, 1))
+	assert.Check(t, is.Equal(leases[0].MountID, "c1"))
+	assert.Check(t, is.Equal(leases[0].Owner, "c1"))
+	assert.Check(t, !leases[0].CreatedAt.IsZero())
+
+	// outstanding leases show up in inspect for debugging
+	v, err := service.Get(ctx, "v1", opts.WithGetResolveStatus)
+	assert.NilError(t, err)
+	assert.Check(t, v.Status["ActiveMountLeases"] != nil)
+
+	// a clean unmount clears the lease
+	assert.NilError(t, service.Unmount(ctx, vol, "c1"))
+	leases, err = service.vs.getLeases("v1")
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(leases, 0))
+}
+
+func TestServiceReconcileMountLeases(t *testing.T) {
+	t.Parallel()
+
+	ds := volumedrivers.NewStore(nil)
+	assert.Assert(t, ds.Register(testutils.NewFakeDriver("d1"), "d1"))
+
+	service, cleanup := newTestService(t, ds)
+	defer cleanup()
+	ctx := context.Background()
+
+	vol, err := service.Create(ctx, "v1", "d1")
+	assert.NilError(t, err)
+
+	_, err = service.Mount(ctx, vol, "alive")
+	assert.NilError(t, err)
+	_, err = service.Mount(ctx, vol, "dead")
+	assert.NilError(t, err)
+
+	err = service.ReconcileMountLeases(ctx, func(id string) bool {
+		return id == "alive"
+	})
+	assert.NilError(t, err)
+
+	leases, err := service.vs.getLeases("v1")
+	assert.NilError(t, err)
+	assert.Assert(t, is.Len(leases, 1))
+	assert.Check(t, is.Equal(leases[0].Owner, "alive"))
+}
@@ -1,14 +1,32 @@
 package opts
 
+import (
+	"time"
+
+	"github.com/docker/docker/api/types/mount"
+)
+
 // CreateOption is used to pass options in when creating a volume
 type CreateOption func(*CreateConfig)
 
 // CreateConfig is the set of config options that can be set when creating
 // a volume
 type CreateConfig struct {
-	Options   map[string]string
-	Labels    map[string]string
-	Reference string
+	Options       map[string]string
+	Labels        map[string]string
+	Reference     string
+	MountDefaults *MountDefaults
+	Idempotent    bool
+}
+
+// MountDefaults holds default mount settings stored with a volume and applied
+// to every mount of the volume unless the container requests otherwise.
+type MountDefaults struct {
+	// Propagation is the default mount propagation mode (e.g. rshared)
+	Propagation mount.Propagation `json:",omitempty"`
+	// Options are default mount options merged under the options specified
+	// by the container
+	Options []string `json:",omitempty"`
 }
 
 // WithCreateLabels creates a CreateOption which sets the labels to the
@@ -27,6 +45,14 @@ func WithCreateOptions(opts map[string]string) CreateOption {
 	}
 }
 
+// WithCreateMountDefaults creates a CreateOption which stores default mount
+// options and propagation mode with the volume.
+func WithCreateMountDefaults(md MountDefaults) CreateOption {
+	return func(cfg *CreateConfig) {
+		cfg.MountDefaults = &md
+	}
+}
+
 // WithCreateReference creats a CreateOption which sets a reference to use
 // when creating a volume. This ensures that the volume is created with a reference
 // already attached to it to prevent race conditions with Create and volume cleanup.
@@ -36,12 +62,24 @@ func WithCreateReference(ref string) CreateOption {
 	}
 }
 
+// WithCreateIdempotent creates a CreateOption which makes Create return the
+// volume from the store's cache, without any driver round trip, when a volume
+// with the requested name and driver already exists. A cached volume belonging
+// to a different driver still yields the usual conflict error. Callers which
+// need the driver to re-validate the volume should not set this option.
+func WithCreateIdempotent() CreateOption {
+	return func(cfg *CreateConfig) {
+		cfg.Idempotent = true
+	}
+}
+
 // GetConfig is used with `GetOption` to set options for the volumes service's
 // `Get` implementation.
 type GetConfig struct {
 	Driver        string
 	Reference     string
 	ResolveStatus bool
+	StatusTimeout time.Duration
 }
 
 // GetOption is passed to the service `Get` add extra details on the get request
@@ -71,6 +109,71 @@ func WithGetResolveStatus(cfg *GetConfig) {
 	cfg.ResolveStatus = true
 }
 
+// WithGetResolveStatusTimeout is like `WithGetResolveStatus` but bounds how
+// long the driver may take to report status. When the timeout is exceeded the
+// status is returned empty with a warning. A zero timeout uses the service's
+// default.
+func WithGetResolveStatusTimeout(timeout time.Duration) GetOption {
+	return func(o *GetConfig) {
+		o.ResolveStatus = true
+		o.StatusTimeout = timeout
+	}
+}
+
+// ListConfig is used with `ListOption` to set options for the volumes
+// service's `List` implementation.
+type ListConfig struct {
+	ResolveStatus bool
+	StatusTimeout time.Duration
+}
+
+// ListOption is passed to the service `List` to add extra details on the list
+// request
+type ListOption func(*ListConfig)
+
+// WithListResolveStatus indicates to `List` to also fetch the status of each
+// volume from its driver. The driver calls are made concurrently but can
+// still add significant overhead to the list.
+func WithListResolveStatus(cfg *ListConfig) {
+	cfg.ResolveStatus = true
+}
+
+// WithListResolveStatusTimeout is like `WithListResolveStatus` but bounds how
+// long status resolution may take across the whole list. Volumes whose status
+// was not resolved in time are returned with an empty status and a warning.
+// A zero timeout uses the service's default.
+func WithListResolveStatusTimeout(timeout time.Duration) ListOption {
+	return func(o *ListConfig) {
+		o.ResolveStatus = true
+		o.StatusTimeout = timeout
+	}
+}
+
+// ImportConfig is used with `ImportOption` to set options for the volumes
+// service's `Import` implementation.
+type ImportConfig struct {
+	Driver       string
+	RequireEmpty bool
+}
+
+// ImportOption is passed to the service `Import` to add extra details on the
+// import request
+type ImportOption func(*ImportConfig)
+
+// WithImportDriver provides the driver to create the volume with if it does
+// not exist yet.
+func WithImportDriver(name string) ImportOption {
+	return func(o *ImportConfig) {
+		o.Driver = name
+	}
+}
+
+// WithImportRequireEmpty indicates to `Import` to fail if the volume already
+// contains any data.
+func WithImportRequireEmpty(cfg *ImportConfig) {
+	cfg.RequireEmpty = true
+}
+
 // RemoveConfig is used by `RemoveOption` to store config options for remove
 type RemoveConfig struct {
 	PurgeOnError bool
@@ -0,0 +1,195 @@
+package service // import "github.com/docker/docker/volume/service"
+
+import (
+	"context"
+	"time"
+
+	"github.com/docker/docker/errdefs"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// OrphanVolume identifies a volume involved in a discrepancy found while
+// restoring the volume store.
+type OrphanVolume struct {
+	Name   string
+	Driver string
+}
+
+// OrphanReport describes the discrepancies between the store's metadata and
+// the registered drivers as found during restore, grouped by category.
+// Orphans are only reported; resolving them is left to the explicit
+// AdoptOrphan and PurgeOrphan operations so nothing destructive happens
+// automatically at startup.
+type OrphanReport struct {
+	// NoMetadata are volumes a driver reports but which the store has no
+	// record of, e.g. because they were created out of band while the
+	// daemon was down.
+	NoMetadata []OrphanVolume
+	// StaleMetadata are volumes the store has a record of but which their
+	// driver no longer knows.
+	StaleMetadata []OrphanVolume
+}
+
+func (s *VolumeStore) addOrphanNoMetadata(o OrphanVolume) {
+	s.globalLock.Lock()
+	s.orphans.NoMetadata = append(s.orphans.NoMetadata, o)
+	s.globalLock.Unlock()
+}
+
+func (s *VolumeStore) addOrphanStaleMetadata(o OrphanVolume) {
+	s.globalLock.Lock()
+	s.orphans.StaleMetadata = append(s.orphans.StaleMetadata, o)
+	s.globalLock.Unlock()
+}
+
+// removeOrphan drops the named volume from both report categories; called
+// once an orphan has been adopted or purged.
+func (s *VolumeStore) removeOrphan(name string) {
+	remove := func(ls []OrphanVolume) []OrphanVolume {
+		out := ls[:0]
+		for _, o := range ls {
+			if o.Name != name {
+				out = append(out, o)
+			}
+		}
+		return out
+	}
+	s.globalLock.Lock()
+	s.orphans.NoMetadata = remove(s.orphans.NoMetadata)
+	s.orphans.StaleMetadata = remove(s.orphans.StaleMetadata)
+	s.globalLock.Unlock()
+}
+
+// Orphans returns a copy of the orphan report produced by the last restore,
+// minus any orphans which have since been adopted or purged.
+func (s *VolumeStore) Orphans() OrphanReport {
+	s.globalLock.RLock()
+	defer s.globalLock.RUnlock()
+	return OrphanReport{
+		NoMetadata:    append([]OrphanVolume(nil), s.orphans.NoMetadata...),
+		StaleMetadata: append([]OrphanVolume(nil), s.orphans.StaleMetadata...),
+	}
+}
+
+// AdoptOrphan creates store metadata for a volume the driver reports but the
+// store has no record of. The metadata is flagged as adopted and gets a
+// best-effort creation time.
+func (s *VolumeStore) AdoptOrphan(ctx context.Context, name, driverName string) error {
+	name = normalizeVolumeName(name)
+	s.locks.Lock(name)
+	defer s.locks.Unlock(name)
+
+	if _, exists := s.getNamed(name); exists {
+		return errdefs.Conflict(errors.Errorf("volume %s is already known to the store", name))
+	}
+
+	v, err := lookupVolume(ctx, s.drivers, driverName, name)
+	if err != nil {
+		return err
+	}
+	if v == nil {
+		return errdefs.NotFound(errors.Errorf("volume %s not found in driver %s", name, driverName))
+	}
+
+	createdAt, err := v.CreatedAt()
+	if err != nil || createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+	meta := volumeMetadata{
+		Name:      name,
+		Driver:    v.DriverName(),
+		CreatedAt: createdAt,
+		Adopted:   true,
+	}
+	if err := s.setMeta(name, meta); err != nil {
+		return err
+	}
+
+	// increment driver refcount
+	s.drivers.CreateDriver(meta.Driver)
+
+	// cache the volume
+	s.globalLock.Lock()
+	s.createdAt[name] = createdAt
+	s.names[name] = v
+	s.refs[name] = make(map[string]struct{})
+	s.globalLock.Unlock()
+
+	s.removeOrphan(name)
+	return nil
+}
+
+// PurgeOrphan removes the store's record of an orphaned volume. When
+// removeData is set and the volume still exists in its driver, the volume is
+// removed from the driver as well, deleting the backing data.
+func (s *VolumeStore) PurgeOrphan(ctx context.Context, name string, removeData bool) error {
+	name = normalizeVolumeName(name)
+	s.locks.Lock(name)
+	defer s.locks.Unlock(name)
+
+	if _, exists := s.getNamed(name); exists {
+		return errdefs.Conflict(errors.Errorf("volume %s is in use by the store; remove it with the regular volume remove", name))
+	}
+
+	meta, err := s.getMeta(name)
+	if err != nil {
+		return err
+	}
+
+	if removeData {
+		driverName := meta.Driver
+		if driverName == "" {
+			s.globalLock.RLock()
+			for _, o := range s.orphans.NoMetadata {
+				if o.Name == name {
+					driverName = o.Driver
+					break
+				}
+			}
+			s.globalLock.RUnlock()
+		}
+		if driverName != "" {
+			v, err := lookupVolume(ctx, s.drivers, driverName, name)
+			if err != nil {
+				return err
+			}
+			if v != nil {
+				d, err := s.drivers.GetDriver(driverName)
+				if err != nil {
+					return err
+				}
+				if err := d.Remove(v); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if meta.Name != "" {
+		if err := s.removeMeta(name); err != nil {
+			logrus.WithError(err).WithField("volume", name).Warn("Error removing metadata for purged orphan volume")
+		}
+	}
+	s.removeOrphan(name)
+	return nil
+}
+
+// Orphans returns the orphaned volumes found during the last store restore,
+// for surfacing through debug/inspect endpoints.
+func (s *VolumesService) Orphans(ctx context.Context) OrphanReport {
+	return s.vs.Orphans()
+}
+
+// AdoptOrphan takes over an orphaned volume reported by the given driver,
+// recording metadata for it with best-effort fields.
+func (s *VolumesService) AdoptOrphan(ctx context.Context, name, driverName string) error {
+	return s.vs.AdoptOrphan(ctx, name, driverName)
+}
+
+// PurgeOrphan drops the store's record of an orphaned volume. With removeData
+// set, the volume is removed from its driver as well, deleting the backing
+// data.
+func (s *VolumesService) PurgeOrphan(ctx context.Context, name string, removeData bool) error {
+	return s.vs.PurgeOrphan(ctx, name, removeData)
+}
@@ -12,9 +12,13 @@ import (
 // restore is called when a new volume store is created.
 // It's primary purpose is to ensure that all drivers' refcounts are set based
 // on known volumes after a restart.
-// This only attempts to track volumes that are actually stored in the on-disk db.
-// It does not probe the available drivers to find anything that may have been added
-// out of band.
+// Daemon-side metadata stored in the on-disk db (labels, options, creation
+// time) is re-attached to the restored volumes so it is not lost for volumes
+// backed by drivers which do not carry it.
+// Discrepancies between the db and the drivers (metadata without a backing
+// volume, volumes without metadata) are recorded in the store's orphan
+// report; nothing is adopted or removed automatically, that is left to the
+// explicit AdoptOrphan and PurgeOrphan operations.
 func (s *VolumeStore) restore() {
 	var ls []volumeMetadata
 	s.db.View(func(tx *bolt.Tx) error {
@@ -23,7 +27,6 @@ func (s *VolumeStore) restore() {
 	})
 	ctx := context.Background()
 
-	chRemove := make(chan *volumeMetadata, len(ls))
 	var wg sync.WaitGroup
 	for _, meta := range ls {
 		wg.Add(1)
@@ -40,15 +43,15 @@ func (s *VolumeStore) restore() {
 					return
 				}
 				if v == nil {
-					// doesn't exist in the driver, remove it from the db
-					chRemove <- &meta
+					// doesn't exist in the driver, report it as stale
+					s.addOrphanStaleMetadata(OrphanVolume{Name: meta.Name, Driver: meta.Driver})
 					return
 				}
 			} else {
 				v, err = s.getVolume(ctx, meta.Name, meta.Driver)
 				if err != nil {
 					if err == errNoSuchVolume {
-						chRemove <- &meta
+						s.addOrphanStaleMetadata(OrphanVolume{Name: meta.Name})
 					}
 					return
 				}
@@ -66,6 +69,7 @@ func (s *VolumeStore) restore() {
 			s.globalLock.Lock()
 			s.options[v.Name()] = meta.Options
 			s.labels[v.Name()] = meta.Labels
+			s.createdAt[v.Name()] = meta.CreatedAt
 			s.names[v.Name()] = v
 			s.refs[v.Name()] = make(map[string]struct{})
 			s.globalLock.Unlock()
@@ -73,13 +77,31 @@ func (s *VolumeStore) restore() {
 	}
 
 	wg.Wait()
-	close(chRemove)
-	s.db.Update(func(tx *bolt.Tx) error {
-		for meta := range chRemove {
-			if err := removeMeta(tx, meta.Name); err != nil {
-				logrus.WithField("volume", meta.Name).Warnf("Error removing stale entry from volume db: %v", err)
+	s.findUnknownVolumes()
+}
+
+// findUnknownVolumes asks each registered driver for its list of volumes and
+// records any volume the store has no record of in the orphan report. Such
+// volumes were created out of band (e.g. while the daemon was down); they can
+// be taken over with AdoptOrphan or cleaned up with PurgeOrphan.
+func (s *VolumeStore) findUnknownVolumes() {
+	dls, err := s.drivers.GetAllDrivers()
+	if err != nil {
+		logrus.WithError(err).Warn("Error getting driver list while restoring volumes")
+		return
+	}
+
+	for _, d := range dls {
+		vs, err := d.List()
+		if err != nil {
+			logrus.WithError(err).WithField("driver", d.Name()).Warn("Error listing volumes from driver while restoring volumes")
+			continue
+		}
+		for _, v := range vs {
+			if _, exists := s.getNamed(v.Name()); exists {
+				continue
 			}
+			s.addOrphanNoMetadata(OrphanVolume{Name: v.Name(), Driver: v.DriverName()})
 		}
-		return nil
-	})
+	}
 }
@@ -56,3 +56,147 @@ func TestRestore(t *testing.T) {
 	assert.DeepEqual(t, testOpts, dv.Options())
 	assert.DeepEqual(t, testLabels, dv.Labels())
 }
+
+func TestRestoreAdoptVolumes(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "test-restore-adopt")
+	assert.NilError(t, err)
+	defer os.RemoveAll(dir)
+
+	drivers := volumedrivers.NewStore(nil)
+	driverName := "test-restore-adopt"
+	d := volumetestutils.NewFakeDriver(driverName)
+	drivers.Register(d, driverName)
+
+	// simulate a volume created out of band, before the store is opened
+	_, err = d.Create("adopted", nil)
+	assert.NilError(t, err)
+
+	s, err := NewStore(dir, drivers)
+	assert.NilError(t, err)
+	defer s.Shutdown()
+
+	ctx := context.Background()
+	known, err := s.Create(ctx, "known", driverName)
+	assert.NilError(t, err)
+	knownCreated, err := known.CreatedAt()
+	assert.NilError(t, err)
+
+	// the out of band volume is reported as an orphan and must be adopted
+	// explicitly
+	report := s.Orphans()
+	assert.Assert(t, len(report.NoMetadata) == 1)
+	assert.Assert(t, report.NoMetadata[0].Name == "adopted")
+	assert.NilError(t, s.AdoptOrphan(ctx, "adopted", driverName))
+	assert.Assert(t, len(s.Orphans().NoMetadata) == 0)
+
+	// once adopted it has a best-effort timestamp
+	v, err := s.Get(ctx, "adopted")
+	assert.NilError(t, err)
+	adoptedCreated, err := v.CreatedAt()
+	assert.NilError(t, err)
+	assert.Assert(t, !adoptedCreated.IsZero())
+
+	meta, err := s.getMeta("adopted")
+	assert.NilError(t, err)
+	assert.Assert(t, meta.Adopted)
+
+	s.Shutdown()
+
+	s, err = NewStore(dir, drivers)
+	assert.NilError(t, err)
+
+	// both volumes keep their recorded creation time across the restart
+	v, err = s.Get(ctx, "known")
+	assert.NilError(t, err)
+	created, err := v.CreatedAt()
+	assert.NilError(t, err)
+	assert.Assert(t, created.Equal(knownCreated))
+
+	meta, err = s.getMeta("known")
+	assert.NilError(t, err)
+	assert.Assert(t, !meta.Adopted)
+
+	v, err = s.Get(ctx, "adopted")
+	assert.NilError(t, err)
+	created, err = v.CreatedAt()
+	assert.NilError(t, err)
+	assert.Assert(t, created.Equal(adoptedCreated))
+}
+
+func TestRestoreReportStaleMetadata(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "test-restore-stale")
+	assert.NilError(t, err)
+	defer os.RemoveAll(dir)
+
+	drivers := volumedrivers.NewStore(nil)
+	driverName := "test-restore-stale"
+	d := volumetestutils.NewFakeDriver(driverName)
+	drivers.Register(d, driverName)
+
+	s, err := NewStore(dir, drivers)
+	assert.NilError(t, err)
+
+	ctx := context.Background()
+	_, err = s.Create(ctx, "gone", driverName)
+	assert.NilError(t, err)
+	s.Shutdown()
+
+	// simulate the volume disappearing from the driver while the daemon is down
+	v, err := d.Get("gone")
+	assert.NilError(t, err)
+	assert.NilError(t, d.Remove(v))
+
+	s, err = NewStore(dir, drivers)
+	assert.NilError(t, err)
+	defer s.Shutdown()
+
+	report := s.Orphans()
+	assert.Assert(t, len(report.StaleMetadata) == 1)
+	assert.Assert(t, report.StaleMetadata[0].Name == "gone")
+
+	// the stale record is kept until explicitly purged
+	meta, err := s.getMeta("gone")
+	assert.NilError(t, err)
+	assert.Assert(t, meta.Name == "gone")
+
+	assert.NilError(t, s.PurgeOrphan(ctx, "gone", false))
+	assert.Assert(t, len(s.Orphans().StaleMetadata) == 0)
+	meta, err = s.getMeta("gone")
+	assert.NilError(t, err)
+	assert.Assert(t, meta.Name == "")
+}
+
+func TestPurgeOrphanRemoveData(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "test-purge-orphan")
+	assert.NilError(t, err)
+	defer os.RemoveAll(dir)
+
+	drivers := volumedrivers.NewStore(nil)
+	driverName := "test-purge-orphan"
+	d := volumetestutils.NewFakeDriver(driverName)
+	drivers.Register(d, driverName)
+
+	// volume created out of band, before the store is opened
+	_, err = d.Create("ob", nil)
+	assert.NilError(t, err)
+
+	s, err := NewStore(dir, drivers)
+	assert.NilError(t, err)
+	defer s.Shutdown()
+
+	ctx := context.Background()
+	assert.Assert(t, len(s.Orphans().NoMetadata) == 1)
+
+	assert.NilError(t, s.PurgeOrphan(ctx, "ob", true))
+	assert.Assert(t, len(s.Orphans().NoMetadata) == 0)
+
+	// the backing data was removed from the driver
+	_, err = d.Get("ob")
+	assert.Assert(t, err != nil)
+}
@@ -2,8 +2,11 @@ package service // import "github.com/docker/docker/volume/service"
 
 import (
 	"context"
+	"fmt"
 	"strconv"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/filters"
@@ -15,6 +18,7 @@ import (
 	"github.com/docker/docker/volume"
 	"github.com/docker/docker/volume/drivers"
 	"github.com/docker/docker/volume/service/opts"
+	metrics "github.com/docker/go-metrics"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
@@ -34,12 +38,17 @@ type VolumesService struct {
 	ds           ds
 	pruneRunning int32
 	eventLogger  volumeEventLogger
+
+	subsMu sync.Mutex
+	subs   map[*EventSubscription]struct{}
 }
 
 // NewVolumeService creates a new volume service
-func NewVolumeService(root string, pg plugingetter.PluginGetter, rootIDs idtools.Identity, logger volumeEventLogger) (*VolumesService, error) {
+// volumeRoots is the allowlist of additional base paths local volumes may be
+// placed under with the "rootdir" creation option.
+func NewVolumeService(root string, pg plugingetter.PluginGetter, rootIDs idtools.Identity, logger volumeEventLogger, volumeRoots ...string) (*VolumesService, error) {
 	ds := drivers.NewStore(pg)
-	if err := setupDefaultDriver(ds, root, rootIDs); err != nil {
+	if err := setupDefaultDriver(ds, root, rootIDs, volumeRoots); err != nil {
 		return nil, err
 	}
 
@@ -55,6 +64,37 @@ func (s *VolumesService) GetDriverList() []string {
 	return s.ds.GetDriverList()
 }
 
+// DriverCapabilities returns the capabilities of the volume driver with the
+// given name. The capabilities are cached by the driver store, so this is
+// cheap enough to call when rendering driver lists (e.g. for `docker info`).
+func (s *VolumesService) DriverCapabilities(name string) (volume.Capability, error) {
+	return s.vs.drivers.GetDriverCapabilities(name)
+}
+
+// DeregisterDriver removes the named driver from the set of registered volume
+// drivers. It is intended to be called when the plugin backing the driver is
+// disabled or removed.
+// Unless force is set, a Conflict error is returned while volumes belonging
+// to the driver still exist in the store.
+func (s *VolumesService) DeregisterDriver(ctx context.Context, name string, force bool) error {
+	if !force {
+		s.vs.globalLock.RLock()
+		var inUse bool
+		for _, v := range s.vs.names {
+			if v.DriverName() == name {
+				inUse = true
+				break
+			}
+		}
+		s.vs.globalLock.RUnlock()
+		if inUse {
+			return errdefs.Conflict(errors.Errorf("volume driver %q is in use by existing volumes", name))
+		}
+	}
+	s.vs.drivers.Deregister(name)
+	return nil
+}
+
 // Create creates a volume
 // If the caller is creating this volume to be consumed immediately, it is
 // expected that the caller specifies a reference ID.
@@ -66,12 +106,21 @@ func (s *VolumesService) Create(ctx context.Context, name, driverName string, op
 	if name == "" {
 		name = stringid.GenerateRandomID()
 	}
+	start := time.Now()
 	v, err := s.vs.Create(ctx, name, driverName, opts...)
+	d := driverName
+	if d == "" {
+		d = volume.DefaultDriverName
+	}
+	if v != nil {
+		d = v.DriverName()
+	}
+	observeVolumeAction(d, "create", start, err)
 	if err != nil {
 		return nil, err
 	}
 
-	s.eventLogger.LogVolumeEvent(v.Name(), "create", map[string]string{"driver": v.DriverName()})
+	s.logVolumeEvent(v.Name(), "create", map[string]string{"driver": v.DriverName()})
 	apiV := volumeToAPIType(v)
 	return &apiV, nil
 }
@@ -83,6 +132,7 @@ func (s *VolumesService) Get(ctx context.Context, name string, getOpts ...opts.G
 		return nil, err
 	}
 	vol := volumeToAPIType(v)
+	s.fillScope(&vol)
 
 	var cfg opts.GetConfig
 	for _, o := range getOpts {
@@ -90,7 +140,26 @@ func (s *VolumesService) Get(ctx context.Context, name string, getOpts ...opts.G
 	}
 
 	if cfg.ResolveStatus {
-		vol.Status = v.Status()
+		st, err := resolveStatus(ctx, v, cfg.StatusTimeout)
+		if err != nil {
+			logrus.WithError(err).WithField("volume", v.Name()).Warn("Timed out resolving volume status from the driver")
+			st = map[string]interface{}{
+				"Warning": fmt.Sprintf("volume status is unavailable: %v", err),
+			}
+		}
+		vol.Status = st
+		if leases, err := s.vs.getLeases(v.Name()); err == nil && len(leases) > 0 {
+			if vol.Status == nil {
+				vol.Status = make(map[string]interface{})
+			}
+			vol.Status["ActiveMountLeases"] = leases
+		}
+		if meta, err := s.vs.getMeta(v.Name()); err == nil && meta.MountDefaults != nil {
+			if vol.Status == nil {
+				vol.Status = make(map[string]interface{})
+			}
+			vol.Status["MountDefaults"] = meta.MountDefaults
+		}
 	}
 	return &vol, nil
 }
@@ -112,7 +181,17 @@ func (s *VolumesService) Mount(ctx context.Context, vol *types.Volume, ref strin
 		}
 		return "", err
 	}
-	return v.Mount(ref)
+	start := time.Now()
+	path, err := v.Mount(ref)
+	observeVolumeAction(v.DriverName(), "mount", start, err)
+	if err != nil {
+		return "", err
+	}
+	if err := s.vs.setLease(v.Name(), mountLease{MountID: ref, Owner: ref, CreatedAt: time.Now()}); err != nil {
+		logrus.WithError(err).WithField("volume", v.Name()).Warn("Error recording volume mount lease")
+	}
+	s.logVolumeEvent(v.Name(), "mount", map[string]string{"driver": v.DriverName(), "ref": ref})
+	return path, nil
 }
 
 // Unmount unmounts the volume.
@@ -129,7 +208,17 @@ func (s *VolumesService) Unmount(ctx context.Context, vol *types.Volume, ref str
 		}
 		return err
 	}
-	return v.Unmount(ref)
+	start := time.Now()
+	err = v.Unmount(ref)
+	observeVolumeAction(v.DriverName(), "unmount", start, err)
+	if err != nil {
+		return err
+	}
+	if err := s.vs.removeLease(v.Name(), ref); err != nil {
+		logrus.WithError(err).WithField("volume", v.Name()).Warn("Error removing volume mount lease")
+	}
+	s.logVolumeEvent(v.Name(), "unmount", map[string]string{"driver": v.DriverName(), "ref": ref})
+	return nil
 }
 
 // Release releases a volume reference
@@ -137,6 +226,14 @@ func (s *VolumesService) Release(ctx context.Context, name string, ref string) e
 	return s.vs.Release(ctx, name, ref)
 }
 
+// ReleaseByPrefix releases every volume reference which starts with the given
+// prefix (e.g. a container ID) and returns the (volume, reference) pairs that
+// were released. It is useful when the owner of the references is gone and
+// the exact reference strings are no longer known.
+func (s *VolumesService) ReleaseByPrefix(ctx context.Context, prefix string) ([]ReleasedRef, error) {
+	return s.vs.ReleaseByPrefix(ctx, prefix)
+}
+
 // Remove removes a volume
 // An error is returned if the volume is still referenced.
 func (s *VolumesService) Remove(ctx context.Context, name string, rmOpts ...opts.RemoveOption) error {
@@ -153,7 +250,9 @@ func (s *VolumesService) Remove(ctx context.Context, name string, rmOpts ...opts
 		return err
 	}
 
+	start := time.Now()
 	err = s.vs.Remove(ctx, v, rmOpts...)
+	observeVolumeAction(v.DriverName(), "remove", start, err)
 	if IsNotExist(err) {
 		err = nil
 	} else if IsInUse(err) {
@@ -163,14 +262,20 @@ func (s *VolumesService) Remove(ctx context.Context, name string, rmOpts ...opts
 	}
 
 	if err == nil {
-		s.eventLogger.LogVolumeEvent(v.Name(), "destroy", map[string]string{"driver": v.DriverName()})
+		s.logVolumeEvent(v.Name(), "destroy", map[string]string{"driver": v.DriverName()})
 	}
 	return err
 }
 
+// keepVolumeLabel marks a volume as protected from prune. Volumes carrying
+// this label set to "true" are skipped by Prune regardless of any other
+// filters, unless the `ignore-protection=true` filter is passed.
+const keepVolumeLabel = "com.docker.volume.keep"
+
 var acceptedPruneFilters = map[string]bool{
-	"label":  true,
-	"label!": true,
+	"label":             true,
+	"label!":            true,
+	"ignore-protection": true,
 }
 
 var acceptedListFilters = map[string]bool{
@@ -198,11 +303,27 @@ func (s *VolumesService) LocalVolumesSize(ctx context.Context) ([]*types.Volume,
 // Prune removes (local) volumes which match the past in filter arguments.
 // Note that this intentionally skips volumes with mount options as there would
 // be no space reclaimed in this case.
+// Individual removal failures do not abort the prune; they are reported in
+// the returned report. A top-level error is only returned for systemic
+// problems such as a failure listing the volumes or cancellation.
+// Volumes labeled with `com.docker.volume.keep=true` are protected from
+// pruning and reported as skipped, unless the `ignore-protection=true`
+// filter is passed.
 func (s *VolumesService) Prune(ctx context.Context, filter filters.Args) (*types.VolumesPruneReport, error) {
 	if !atomic.CompareAndSwapInt32(&s.pruneRunning, 0, 1) {
 		return nil, errdefs.Conflict(errors.New("a prune operation is already running"))
 	}
 	defer atomic.StoreInt32(&s.pruneRunning, 0)
+	defer metrics.StartTimer(pruneDuration)()
+
+	var ignoreProtection bool
+	if filter.Contains("ignore-protection") {
+		if filter.ExactMatch("ignore-protection", "true") || filter.ExactMatch("ignore-protection", "1") {
+			ignoreProtection = true
+		} else if !filter.ExactMatch("ignore-protection", "false") && !filter.ExactMatch("ignore-protection", "0") {
+			return nil, invalidFilter{"ignore-protection", filter.Get("ignore-protection")}
+		}
+	}
 
 	by, err := filtersToBy(filter, acceptedPruneFilters)
 	if err != nil {
@@ -228,18 +349,27 @@ func (s *VolumesService) Prune(ctx context.Context, filter filters.Args) (*types
 		default:
 		}
 
+		if !ignoreProtection {
+			if dv, ok := v.(volume.DetailedVolume); ok && dv.Labels()[keepVolumeLabel] == "true" {
+				rep.VolumesSkipped = append(rep.VolumesSkipped, v.Name())
+				continue
+			}
+		}
+
 		vSize, err := directory.Size(ctx, v.Path())
 		if err != nil {
 			logrus.WithField("volume", v.Name()).WithError(err).Warn("could not determine size of volume")
 		}
 		if err := s.vs.Remove(ctx, v); err != nil {
-			logrus.WithError(err).WithField("volume", v.Name()).Warnf("Could not determine size of volume")
+			logrus.WithError(err).WithField("volume", v.Name()).Warn("could not remove volume during prune")
+			rep.VolumesFailed = append(rep.VolumesFailed, types.VolumesPruneFailure{Name: v.Name(), Error: err.Error()})
 			continue
 		}
 		rep.SpaceReclaimed += uint64(vSize)
 		rep.VolumesDeleted = append(rep.VolumesDeleted, v.Name())
 	}
-	s.eventLogger.LogVolumeEvent("", "prune", map[string]string{
+	pruneReclaimed.Inc(float64(rep.SpaceReclaimed))
+	s.logVolumeEvent("", "prune", map[string]string{
 		"reclaimed": strconv.FormatInt(int64(rep.SpaceReclaimed), 10),
 	})
 	return rep, nil
@@ -247,21 +377,36 @@ func (s *VolumesService) Prune(ctx context.Context, filter filters.Args) (*types
 
 // List gets the list of volumes which match the past in filters
 // If filters is nil or empty all volumes are returned.
-func (s *VolumesService) List(ctx context.Context, filter filters.Args) (volumesOut []*types.Volume, warnings []string, err error) {
+func (s *VolumesService) List(ctx context.Context, filter filters.Args, listOpts ...opts.ListOption) (volumesOut []*types.Volume, warnings []string, err error) {
+	var cfg opts.ListConfig
+	for _, o := range listOpts {
+		o(&cfg)
+	}
+
 	by, err := filtersToBy(filter, acceptedListFilters)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	volumes, warnings, err := s.vs.Find(ctx, by)
+	volumes, driverWarnings, err := s.vs.Find(ctx, by)
 	if err != nil {
 		return nil, nil, err
 	}
+	// serialize the structured driver warnings in their stable format for
+	// the API response
+	for _, w := range driverWarnings {
+		warnings = append(warnings, w.Error())
+	}
 
-	return s.volumesToAPI(ctx, volumes, useCachedPath(true)), warnings, nil
+	out := s.volumesToAPI(ctx, volumes, useCachedPath(true))
+	if cfg.ResolveStatus && len(out) == len(volumes) {
+		warnings = append(warnings, s.resolveStatuses(ctx, volumes, out, cfg.StatusTimeout)...)
+	}
+	return out, warnings, nil
 }
 
 // Shutdown shuts down the image service and dependencies
 func (s *VolumesService) Shutdown() error {
+	s.closeSubscriptions()
 	return s.vs.Shutdown()
 }
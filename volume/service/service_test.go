@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"errors"
 	"io/ioutil"
 	"os"
 	"testing"
@@ -233,6 +234,172 @@ func TestServicePrune(t *testing.T) {
 	assert.Assert(t, is.Equal(pr.VolumesDeleted[0], "test"))
 }
 
+func TestServicePruneKeepLabel(t *testing.T) {
+	t.Parallel()
+
+	ds := volumedrivers.NewStore(nil)
+	assert.Assert(t, ds.Register(testutils.NewFakeDriver(volume.DefaultDriverName), volume.DefaultDriverName))
+
+	service, cleanup := newTestService(t, ds)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := service.Create(ctx, "protected", volume.DefaultDriverName, opts.WithCreateLabels(map[string]string{keepVolumeLabel: "true"}))
+	assert.NilError(t, err)
+	_, err = service.Create(ctx, "unprotected", volume.DefaultDriverName)
+	assert.NilError(t, err)
+
+	// the protected volume is skipped and reported, not deleted
+	pr, err := service.Prune(ctx, filters.NewArgs())
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(pr.VolumesDeleted, []string{"unprotected"}))
+	assert.Check(t, is.DeepEqual(pr.VolumesSkipped, []string{"protected"}))
+
+	_, err = service.Get(ctx, "protected")
+	assert.NilError(t, err)
+
+	// a bogus ignore-protection value is rejected
+	_, err = service.Prune(ctx, filters.NewArgs(filters.Arg("ignore-protection", "banana")))
+	assert.Assert(t, errdefs.IsInvalidParameter(err), err)
+
+	// ignore-protection=true overrides the keep label
+	pr, err = service.Prune(ctx, filters.NewArgs(filters.Arg("ignore-protection", "true")))
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(pr.VolumesDeleted, []string{"protected"}))
+	assert.Check(t, is.Len(pr.VolumesSkipped, 0))
+}
+
+func TestServiceListBrokenDriver(t *testing.T) {
+	t.Parallel()
+
+	ds := volumedrivers.NewStore(nil)
+	service, cleanup := newTestService(t, ds)
+	defer cleanup()
+	ctx := context.Background()
+
+	assert.Assert(t, ds.Register(testutils.NewFakeDriver("good"), "good"))
+	bad := testutils.NewFakeDriver("bad", testutils.WithFailNthCall("List", 1, errors.New("plugin is down")))
+	assert.Assert(t, ds.Register(bad, "bad"))
+
+	_, err := service.Create(ctx, "v1", "good")
+	assert.NilError(t, err)
+	_, err = service.Create(ctx, "v2", "bad")
+	assert.NilError(t, err)
+
+	// the broken driver produces a warning; the healthy driver's volumes
+	// (and whatever the store has cached for the broken one) still come back
+	vols, warnings, err := service.List(ctx, filters.NewArgs())
+	assert.NilError(t, err)
+	assert.Assert(t, is.Len(warnings, 1))
+	assert.Check(t, is.Contains(warnings[0], "volume driver bad"))
+	assert.Check(t, is.Contains(warnings[0], "plugin is down"))
+	assert.Check(t, is.Contains(warnings[0], "may be incomplete"))
+
+	names := make([]string, 0, len(vols))
+	for _, v := range vols {
+		names = append(names, v.Name)
+	}
+	assert.Check(t, is.Contains(names, "v1"))
+	assert.Check(t, is.Contains(names, "v2"))
+}
+
+func TestServicePrunePartialFailure(t *testing.T) {
+	t.Parallel()
+
+	ds := volumedrivers.NewStore(nil)
+	d := testutils.NewFakeDriver(volume.DefaultDriverName, testutils.WithFailNthCall("Remove", 1, errors.New("remove failed")))
+	assert.Assert(t, ds.Register(d, volume.DefaultDriverName))
+
+	service, cleanup := newTestService(t, ds)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := service.Create(ctx, "v1", volume.DefaultDriverName)
+	assert.NilError(t, err)
+	_, err = service.Create(ctx, "v2", volume.DefaultDriverName)
+	assert.NilError(t, err)
+
+	// the first Remove fails; prune must carry on and delete the other volume
+	pr, err := service.Prune(ctx, filters.NewArgs())
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(pr.VolumesDeleted, 1))
+	assert.Assert(t, is.Len(pr.VolumesFailed, 1))
+	assert.Check(t, is.Contains(pr.VolumesFailed[0].Error, "remove failed"))
+	assert.Check(t, pr.VolumesFailed[0].Name != pr.VolumesDeleted[0])
+}
+
+func TestServiceRemovePurgeOnError(t *testing.T) {
+	t.Parallel()
+
+	ds := volumedrivers.NewStore(nil)
+	d := testutils.NewFakeDriver("d1", testutils.WithFailNthCall("Remove", 1, errors.New("remove failed")))
+	assert.Assert(t, ds.Register(d, "d1"))
+
+	service, cleanup := newTestService(t, ds)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := service.Create(ctx, "v1", "d1")
+	assert.NilError(t, err)
+
+	// the driver error is surfaced, but the store must forget the volume
+	err = service.Remove(ctx, "v1", opts.WithPurgeOnError(true))
+	assert.ErrorContains(t, err, "remove failed")
+	_, exists := service.vs.getNamed("v1")
+	assert.Check(t, !exists)
+	assert.Check(t, is.Contains(d.CallLog(), "Remove"))
+}
+
+func TestServiceGetResolveStatusDriverError(t *testing.T) {
+	t.Parallel()
+
+	ds := volumedrivers.NewStore(nil)
+	// Create probes the driver once, so the second Get is the one issued by
+	// the status-resolving lookup below.
+	d := testutils.NewFakeDriver("d1", testutils.WithFailNthCall("Get", 2, errors.New("driver broke")))
+	assert.Assert(t, ds.Register(d, "d1"))
+
+	service, cleanup := newTestService(t, ds)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := service.Create(ctx, "v1", "d1")
+	assert.NilError(t, err)
+
+	// a driver that no longer knows the volume makes status resolution fail
+	// with "no such volume" rather than returning partial data
+	_, err = service.Get(ctx, "v1", opts.WithGetResolveStatus)
+	assert.Assert(t, IsNotExist(err), err)
+}
+
+func TestServiceDeregisterDriver(t *testing.T) {
+	t.Parallel()
+
+	ds := volumedrivers.NewStore(nil)
+	assert.Assert(t, ds.Register(testutils.NewFakeDriver("d1"), "d1"))
+
+	service, cleanup := newTestService(t, ds)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := service.Create(ctx, "v1", "d1")
+	assert.NilError(t, err)
+
+	err = service.DeregisterDriver(ctx, "d1", false)
+	assert.Assert(t, errdefs.IsConflict(err), err)
+
+	assert.NilError(t, service.Remove(ctx, "v1"))
+	assert.NilError(t, service.DeregisterDriver(ctx, "d1", false))
+	assert.Check(t, is.Len(ds.GetDriverList(), 0))
+
+	// force skips the volumes-in-use check
+	assert.Assert(t, ds.Register(testutils.NewFakeDriver("d1"), "d1"))
+	_, err = service.Create(ctx, "v1", "d1")
+	assert.NilError(t, err)
+	assert.NilError(t, service.DeregisterDriver(ctx, "d1", true))
+	assert.Check(t, is.Len(ds.GetDriverList(), 0))
+}
+
 func newTestService(t *testing.T, ds *volumedrivers.Store) (*VolumesService, func()) {
 	t.Helper()
 
@@ -0,0 +1,102 @@
+package service // import "github.com/docker/docker/volume/service"
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/volume"
+)
+
+const (
+	// defaultStatusTimeout bounds how long a driver may take to report a
+	// volume's status before the status is returned empty with a warning.
+	defaultStatusTimeout = 5 * time.Second
+	// statusWorkers bounds how many concurrent driver status calls are made
+	// when resolving status across a list of volumes.
+	statusWorkers = 8
+)
+
+// resolveStatus queries the volume's status from its driver, bounded by the
+// passed in timeout and by ctx. The driver call itself cannot be cancelled,
+// so on timeout it is abandoned and its result discarded.
+func resolveStatus(ctx context.Context, v volume.Volume, timeout time.Duration) (map[string]interface{}, error) {
+	if timeout <= 0 {
+		timeout = defaultStatusTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	chStatus := make(chan map[string]interface{}, 1)
+	go func() {
+		chStatus <- v.Status()
+	}()
+
+	select {
+	case st := <-chStatus:
+		return st, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// resolveStatuses fills in the Status field of the API volumes in out from
+// the corresponding volumes, fanning the driver calls out over a bounded
+// worker pool which shares a single deadline. Volumes whose status could not
+// be resolved in time get an empty status and a warning instead of blocking
+// the list.
+func (s *VolumesService) resolveStatuses(ctx context.Context, volumes []volume.Volume, out []*types.Volume, timeout time.Duration) []string {
+	if timeout <= 0 {
+		timeout = defaultStatusTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	workers := statusWorkers
+	if len(volumes) < workers {
+		workers = len(volumes)
+	}
+
+	jobs := make(chan int)
+	chWarnings := make(chan string, len(volumes))
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				st, err := resolveStatus(ctx, volumes[i], timeout)
+				if err != nil {
+					chWarnings <- fmt.Sprintf("failed to resolve status of volume %s from driver %s: %v", volumes[i].Name(), volumes[i].DriverName(), err)
+					continue
+				}
+				out[i].Status = st
+			}
+		}()
+	}
+
+	next := 0
+feed:
+	for ; next < len(volumes); next++ {
+		select {
+		case jobs <- next:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	close(chWarnings)
+
+	var warnings []string
+	for w := range chWarnings {
+		warnings = append(warnings, w)
+	}
+	// anything not handed to a worker before the deadline gets a warning too
+	for ; next < len(volumes); next++ {
+		warnings = append(warnings, fmt.Sprintf("failed to resolve status of volume %s from driver %s: %v", volumes[next].Name(), volumes[next].DriverName(), ctx.Err()))
+	}
+	return warnings
+}
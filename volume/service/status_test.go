@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/volume"
+	volumedrivers "github.com/docker/docker/volume/drivers"
+	"github.com/docker/docker/volume/service/opts"
+	"github.com/docker/docker/volume/testutils"
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+// slowStatusVolume blocks in Status for the configured delay, simulating a
+// volume plugin which is slow to answer its Status endpoint.
+type slowStatusVolume struct {
+	testutils.NoopVolume
+	delay time.Duration
+}
+
+func (v slowStatusVolume) Status() map[string]interface{} {
+	time.Sleep(v.delay)
+	return map[string]interface{}{"slow": true}
+}
+
+func TestResolveStatusTimeout(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	st, err := resolveStatus(ctx, testutils.FakeVolume{}, time.Minute)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(st["datakey"], "datavalue"))
+
+	_, err = resolveStatus(ctx, slowStatusVolume{delay: time.Minute}, 10*time.Millisecond)
+	assert.Assert(t, err != nil)
+
+	// context cancellation stops the wait before the timeout
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = resolveStatus(cctx, slowStatusVolume{delay: time.Minute}, time.Minute)
+	assert.Assert(t, err != nil)
+}
+
+func TestResolveStatusesPartialTimeout(t *testing.T) {
+	t.Parallel()
+
+	ds := volumedrivers.NewStore(nil)
+	service, cleanup := newTestService(t, ds)
+	defer cleanup()
+
+	vols := []volume.Volume{
+		slowStatusVolume{delay: time.Minute},
+		testutils.FakeVolume{},
+	}
+	out := []*types.Volume{{Name: "slow"}, {Name: "fast"}}
+
+	warnings := service.resolveStatuses(context.Background(), vols, out, 100*time.Millisecond)
+	assert.Assert(t, is.Len(warnings, 1))
+	assert.Check(t, strings.Contains(warnings[0], "noop"), warnings[0])
+	assert.Check(t, out[0].Status == nil)
+	assert.Check(t, is.Equal(out[1].Status["datakey"], "datavalue"))
+}
+
+func TestServiceListResolveStatus(t *testing.T) {
+	t.Parallel()
+
+	ds := volumedrivers.NewStore(nil)
+	assert.Assert(t, ds.Register(testutils.NewFakeDriver("d1"), "d1"))
+
+	service, cleanup := newTestService(t, ds)
+	defer cleanup()
+	ctx := context.Background()
+
+	for _, name := range []string{"v1", "v2", "v3"} {
+		_, err := service.Create(ctx, name, "d1")
+		assert.NilError(t, err)
+	}
+
+	// without the option the status is left unresolved
+	vols, warnings, err := service.List(ctx, filters.NewArgs())
+	assert.NilError(t, err)
+	assert.Assert(t, is.Len(vols, 3))
+	assert.Assert(t, is.Len(warnings, 0))
+	for _, v := range vols {
+		assert.Check(t, v.Status == nil)
+	}
+
+	vols, warnings, err = service.List(ctx, filters.NewArgs(), opts.WithListResolveStatus)
+	assert.NilError(t, err)
+	assert.Assert(t, is.Len(vols, 3))
+	assert.Assert(t, is.Len(warnings, 0))
+	for _, v := range vols {
+		assert.Check(t, is.Equal(v.Status["datakey"], "datavalue"))
+	}
+}
+
+func TestServiceGetStatusTimeout(t *testing.T) {
+	t.Parallel()
+
+	ds := volumedrivers.NewStore(nil)
+	assert.Assert(t, ds.Register(testutils.NewFakeDriver("d1"), "d1"))
+
+	service, cleanup := newTestService(t, ds)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := service.Create(ctx, "v1", "d1")
+	assert.NilError(t, err)
+
+	v, err := service.Get(ctx, "v1", opts.WithGetResolveStatusTimeout(time.Minute))
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(v.Status["datakey"], "datavalue"))
+}
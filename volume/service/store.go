@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"sync"
 	"time"
 
@@ -27,9 +28,10 @@ const (
 
 type volumeWrapper struct {
 	volume.Volume
-	labels  map[string]string
-	scope   string
-	options map[string]string
+	labels    map[string]string
+	scope     string
+	options   map[string]string
+	createdAt time.Time
 }
 
 func (v volumeWrapper) Options() map[string]string {
@@ -59,6 +61,15 @@ func (v volumeWrapper) Scope() string {
 	return v.scope
 }
 
+// CreatedAt returns the daemon-recorded creation time of the volume when one
+// was stored, and otherwise defers to the driver's volume.
+func (v volumeWrapper) CreatedAt() (time.Time, error) {
+	if !v.createdAt.IsZero() {
+		return v.createdAt, nil
+	}
+	return v.Volume.CreatedAt()
+}
+
 func (v volumeWrapper) CachedPath() string {
 	if vv, ok := v.Volume.(interface {
 		CachedPath() string
@@ -71,12 +82,14 @@ func (v volumeWrapper) CachedPath() string {
 // NewStore creates a new volume store at the given path
 func NewStore(rootPath string, drivers *drivers.Store) (*VolumeStore, error) {
 	vs := &VolumeStore{
-		locks:   &locker.Locker{},
-		names:   make(map[string]volume.Volume),
-		refs:    make(map[string]map[string]struct{}),
-		labels:  make(map[string]map[string]string),
-		options: make(map[string]map[string]string),
-		drivers: drivers,
+		locks:     &locker.Locker{},
+		names:     make(map[string]volume.Volume),
+		refs:      make(map[string]map[string]struct{}),
+		labels:    make(map[string]map[string]string),
+		options:   make(map[string]map[string]string),
+		createdAt: make(map[string]time.Time),
+		inflight:  make(map[string]*inflightCreate),
+		drivers:   drivers,
 	}
 
 	if rootPath != "" {
@@ -97,6 +110,9 @@ func NewStore(rootPath string, drivers *drivers.Store) (*VolumeStore, error) {
 			if _, err := tx.CreateBucketIfNotExists(volumeBucketName); err != nil {
 				return errors.Wrap(err, "error while setting up volume store metadata database")
 			}
+			if _, err := tx.CreateBucketIfNotExists(mountLeasesBucketName); err != nil {
+				return errors.Wrap(err, "error while setting up volume store metadata database")
+			}
 			return nil
 		}); err != nil {
 			return nil, err
@@ -104,6 +120,7 @@ func NewStore(rootPath string, drivers *drivers.Store) (*VolumeStore, error) {
 	}
 
 	vs.restore()
+	volumeCountCtr.register(vs)
 
 	return vs, nil
 }
@@ -174,10 +191,14 @@ func (s *VolumeStore) purge(ctx context.Context, name string) error {
 	if err := s.removeMeta(name); err != nil {
 		logrus.Errorf("Error removing volume metadata for volume %q: %v", name, err)
 	}
+	if err := s.removeAllLeases(name); err != nil {
+		logrus.Errorf("Error removing mount leases for volume %q: %v", name, err)
+	}
 	delete(s.names, name)
 	delete(s.refs, name)
 	delete(s.labels, name)
 	delete(s.options, name)
+	delete(s.createdAt, name)
 	return nil
 }
 
@@ -198,9 +219,26 @@ type VolumeStore struct {
 	labels map[string]map[string]string
 	// options stores volume options for each volume
 	options map[string]map[string]string
+	// createdAt stores the daemon-recorded creation time for each volume
+	createdAt map[string]time.Time
+	// inflight tracks Create calls in progress so concurrent creators of the
+	// same volume share a single driver call instead of piling up on the
+	// per-name lock and each hitting the driver.
+	inflight map[string]*inflightCreate
+	// orphans is the report of discrepancies found during the last restore
+	orphans OrphanReport
 	db      *bolt.DB
 }
 
+// inflightCreate is a Create call in progress. Concurrent Create calls for
+// the same name and driver wait on done and share the stored result.
+type inflightCreate struct {
+	driverName string
+	done       chan struct{}
+	v          volume.Volume
+	err        error
+}
+
 func filterByDriver(names []string) filterFunc {
 	return func(v volume.Volume) bool {
 		for _, name := range names {
@@ -218,7 +256,7 @@ func (s *VolumeStore) byReferenced(referenced bool) filterFunc {
 	}
 }
 
-func (s *VolumeStore) filter(ctx context.Context, vols *[]volume.Volume, by By) (warnings []string, err error) {
+func (s *VolumeStore) filter(ctx context.Context, vols *[]volume.Volume, by By) (warnings []*DriverWarning, err error) {
 	// note that this specifically does not support the `FromList` By type.
 	switch f := by.(type) {
 	case nil:
@@ -317,7 +355,7 @@ func unique(ls *[]volume.Volume) {
 // Find lists volumes filtered by the past in filter.
 // If a driver returns a volume that has name which conflicts with another volume from a different driver,
 // the first volume is chosen and the conflicting volume is dropped.
-func (s *VolumeStore) Find(ctx context.Context, by By) (vols []volume.Volume, warnings []string, err error) {
+func (s *VolumeStore) Find(ctx context.Context, by By) (vols []volume.Volume, warnings []*DriverWarning, err error) {
 	logrus.WithField("ByType", fmt.Sprintf("%T", by)).WithField("ByValue", fmt.Sprintf("%+v", by)).Debug("VolumeStore.Find")
 	switch f := by.(type) {
 	case nil, orCombinator, andCombinator, byDriver, ByReferenced, CustomFilter:
@@ -372,10 +410,10 @@ func filter(vols *[]volume.Volume, fn filterFunc) {
 
 // list goes through each volume driver and asks for its list of volumes.
 // TODO(@cpuguy83): plumb context through
-func (s *VolumeStore) list(ctx context.Context, driverNames ...string) ([]volume.Volume, []string, error) {
+func (s *VolumeStore) list(ctx context.Context, driverNames ...string) ([]volume.Volume, []*DriverWarning, error) {
 	var (
 		ls       = []volume.Volume{} // do not return a nil value as this affects filtering
-		warnings []string
+		warnings []*DriverWarning
 	)
 
 	var dls []volume.Driver
@@ -409,12 +447,12 @@ func (s *VolumeStore) list(ctx context.Context, driverNames ...string) ([]volume
 		go func(d volume.Driver) {
 			vs, err := d.List()
 			if err != nil {
-				chVols <- vols{driverName: d.Name(), err: &OpErr{Err: err, Name: d.Name(), Op: "list"}}
+				chVols <- vols{driverName: d.Name(), err: err}
 				return
 			}
 			for i, v := range vs {
 				s.globalLock.RLock()
-				vs[i] = volumeWrapper{v, s.labels[v.Name()], d.Scope(), s.options[v.Name()]}
+				vs[i] = volumeWrapper{v, s.labels[v.Name()], d.Scope(), s.options[v.Name()], s.createdAt[v.Name()]}
 				s.globalLock.RUnlock()
 			}
 
@@ -427,7 +465,7 @@ func (s *VolumeStore) list(ctx context.Context, driverNames ...string) ([]volume
 		vs := <-chVols
 
 		if vs.err != nil {
-			warnings = append(warnings, vs.err.Error())
+			warnings = append(warnings, &DriverWarning{Driver: vs.driverName, Err: vs.err, Incomplete: true})
 			badDrivers[vs.driverName] = struct{}{}
 		}
 		ls = append(ls, vs.vols...)
@@ -448,6 +486,10 @@ func (s *VolumeStore) list(ctx context.Context, driverNames ...string) ([]volume
 // Create creates a volume with the given name and driver
 // If the volume needs to be created with a reference to prevent race conditions
 // with volume cleanup, make sure to use the `CreateWithReference` option.
+// Concurrent Create calls for the same name and driver are coalesced into a
+// single driver call; all callers share the result. A concurrent Create for
+// the same name but a different driver takes the normal path and gets the
+// usual conflict handling.
 func (s *VolumeStore) Create(ctx context.Context, name, driverName string, createOpts ...opts.CreateOption) (volume.Volume, error) {
 	var cfg opts.CreateConfig
 	for _, o := range createOpts {
@@ -455,27 +497,103 @@ func (s *VolumeStore) Create(ctx context.Context, name, driverName string, creat
 	}
 
 	name = normalizeVolumeName(name)
+
+	if cfg.Idempotent {
+		v, err := s.cachedVolume(name, driverName)
+		if err != nil {
+			return nil, err
+		}
+		if v != nil {
+			s.setNamed(v, cfg.Reference)
+			return v, nil
+		}
+	}
+
+	s.globalLock.Lock()
+	fl := s.inflight[name]
+	if fl != nil && fl.driverName == driverName {
+		s.globalLock.Unlock()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-fl.done:
+		}
+		if fl.err != nil {
+			return nil, fl.err
+		}
+		s.setNamed(fl.v, cfg.Reference)
+		return fl.v, nil
+	}
+	var lead *inflightCreate
+	if fl == nil {
+		lead = &inflightCreate{driverName: driverName, done: make(chan struct{})}
+		s.inflight[name] = lead
+	}
+	s.globalLock.Unlock()
+
+	if lead != nil {
+		defer func() {
+			s.globalLock.Lock()
+			delete(s.inflight, name)
+			s.globalLock.Unlock()
+			close(lead.done)
+		}()
+	}
+
 	s.locks.Lock(name)
 	defer s.locks.Unlock(name)
 
 	select {
 	case <-ctx.Done():
+		if lead != nil {
+			lead.err = ctx.Err()
+		}
 		return nil, ctx.Err()
 	default:
 	}
 
-	v, err := s.create(ctx, name, driverName, cfg.Options, cfg.Labels)
+	v, err := s.create(ctx, name, driverName, cfg)
 	if err != nil {
-		if _, ok := err.(*OpErr); ok {
-			return nil, err
+		if _, ok := err.(*OpErr); !ok {
+			err = &OpErr{Err: err, Name: name, Op: "create"}
 		}
-		return nil, &OpErr{Err: err, Name: name, Op: "create"}
+		if lead != nil {
+			lead.err = err
+		}
+		return nil, err
 	}
 
+	if lead != nil {
+		lead.v = v
+	}
 	s.setNamed(v, cfg.Reference)
 	return v, nil
 }
 
+// cachedVolume returns the volume with the given name from the store's cache
+// if it belongs to the requested driver, without calling the driver. It is the
+// fast path for idempotent creates. A cached volume belonging to a different
+// driver yields the usual name conflict; a cache miss returns nil, nil so the
+// caller can fall through to the normal create path.
+func (s *VolumeStore) cachedVolume(name, driverName string) (volume.Volume, error) {
+	v, exists := s.getNamed(name)
+	if !exists {
+		return nil, nil
+	}
+	if driverName != "" {
+		// Retrieve canonical driver name to avoid inconsistencies (for example
+		// "plugin" vs. "plugin:latest")
+		vd, err := s.drivers.GetDriver(driverName)
+		if err != nil {
+			return nil, &OpErr{Op: "create", Name: name, Err: err}
+		}
+		if v.DriverName() != vd.Name() {
+			return nil, &OpErr{Op: "create", Name: name, Err: errors.Wrapf(errNameConflict, "driver '%s' already has volume '%s'", v.DriverName(), name)}
+		}
+	}
+	return v, nil
+}
+
 // checkConflict checks the local cache for name collisions with the passed in name,
 // for existing volumes with the same name but in a different driver.
 // This is used by `Create` as a best effort to prevent name collisions for volumes.
@@ -552,7 +670,7 @@ func volumeExists(ctx context.Context, store *drivers.Store, v volume.Volume) (b
 //  for the given volume name, an error is returned after checking if the reference is stale.
 // If the reference is stale, it will be purged and this create can continue.
 // It is expected that callers of this function hold any necessary locks.
-func (s *VolumeStore) create(ctx context.Context, name, driverName string, opts, labels map[string]string) (volume.Volume, error) {
+func (s *VolumeStore) create(ctx context.Context, name, driverName string, cfg opts.CreateConfig) (volume.Volume, error) {
 	// Validate the name in a platform-specific manner
 
 	// volume name validation is specific to the host os and not on container image
@@ -563,6 +681,10 @@ func (s *VolumeStore) create(ctx context.Context, name, driverName string, opts,
 		return nil, err
 	}
 
+	if err := validateMountDefaults(cfg.MountDefaults); err != nil {
+		return nil, err
+	}
+
 	v, err := s.checkConflict(ctx, name, driverName)
 	if err != nil {
 		return nil, err
@@ -594,7 +716,7 @@ func (s *VolumeStore) create(ctx context.Context, name, driverName string, opts,
 
 	logrus.Debugf("Registering new volume reference: driver %q, name %q", vd.Name(), name)
 	if v, _ = vd.Get(name); v == nil {
-		v, err = vd.Create(name, opts)
+		v, err = vd.Create(name, cfg.Options)
 		if err != nil {
 			if _, err := s.drivers.ReleaseDriver(driverName); err != nil {
 				logrus.WithError(err).WithField("driver", driverName).Error("Error releasing reference to volume driver")
@@ -603,23 +725,33 @@ func (s *VolumeStore) create(ctx context.Context, name, driverName string, opts,
 		}
 	}
 
+	createdAt, err := v.CreatedAt()
+	if err != nil || createdAt.IsZero() {
+		// the driver doesn't report a creation time, record our own so it
+		// survives daemon restarts
+		createdAt = time.Now()
+	}
+
 	s.globalLock.Lock()
-	s.labels[name] = labels
-	s.options[name] = opts
+	s.labels[name] = cfg.Labels
+	s.options[name] = cfg.Options
+	s.createdAt[name] = createdAt
 	s.refs[name] = make(map[string]struct{})
 	s.globalLock.Unlock()
 
 	metadata := volumeMetadata{
-		Name:    name,
-		Driver:  vd.Name(),
-		Labels:  labels,
-		Options: opts,
+		Name:          name,
+		Driver:        vd.Name(),
+		Labels:        cfg.Labels,
+		Options:       cfg.Options,
+		CreatedAt:     createdAt,
+		MountDefaults: cfg.MountDefaults,
 	}
 
 	if err := s.setMeta(name, metadata); err != nil {
 		return nil, err
 	}
-	return volumeWrapper{v, labels, vd.Scope(), opts}, nil
+	return volumeWrapper{v, cfg.Labels, vd.Scope(), cfg.Options, createdAt}, nil
 }
 
 // Get looks if a volume with the given name exists and returns it if so
@@ -698,7 +830,7 @@ func (s *VolumeStore) getVolume(ctx context.Context, name, driverName string) (v
 		if err == nil {
 			scope = vd.Scope()
 		}
-		return volumeWrapper{vol, meta.Labels, scope, meta.Options}, nil
+		return volumeWrapper{vol, meta.Labels, scope, meta.Options, meta.CreatedAt}, nil
 	}
 
 	logrus.Debugf("Probing all drivers for volume with name: %s", name)
@@ -721,7 +853,7 @@ func (s *VolumeStore) getVolume(ctx context.Context, name, driverName string) (v
 		if err := s.setMeta(name, meta); err != nil {
 			return nil, err
 		}
-		return volumeWrapper{v, meta.Labels, d.Scope(), meta.Options}, nil
+		return volumeWrapper{v, meta.Labels, d.Scope(), meta.Options, meta.CreatedAt}, nil
 	}
 	return nil, errNoSuchVolume
 }
@@ -830,6 +962,44 @@ func (s *VolumeStore) Release(ctx context.Context, name string, ref string) erro
 	return nil
 }
 
+// ReleasedRef describes a single volume reference removed by ReleaseByPrefix.
+type ReleasedRef struct {
+	// Name is the name of the volume the reference was held on
+	Name string
+	// Ref is the reference that was released
+	Ref string
+}
+
+// ReleaseByPrefix releases all references, across all volumes, whose value
+// starts with the given prefix. It is used to clean up references left behind
+// by an owner which no longer exists, such as a force-removed container,
+// where the exact reference strings may not be known anymore.
+// An empty prefix is rejected as it would release every reference.
+func (s *VolumeStore) ReleaseByPrefix(ctx context.Context, prefix string) ([]ReleasedRef, error) {
+	if prefix == "" {
+		return nil, errdefs.InvalidParameter(errors.New("refusing to release volume references with an empty prefix"))
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	s.globalLock.Lock()
+	defer s.globalLock.Unlock()
+
+	var released []ReleasedRef
+	for name, refs := range s.refs {
+		for ref := range refs {
+			if strings.HasPrefix(ref, prefix) {
+				delete(refs, ref)
+				released = append(released, ReleasedRef{Name: name, Ref: ref})
+			}
+		}
+	}
+	return released, nil
+}
+
 // CountReferences gives a count of all references for a given volume.
 func (s *VolumeStore) CountReferences(v volume.Volume) int {
 	name := normalizeVolumeName(v.Name())
@@ -853,5 +1023,6 @@ func unwrapVolume(v volume.Volume) volume.Volume {
 // Shutdown releases all resources used by the volume store
 // It does not make any changes to volumes, drivers, etc.
 func (s *VolumeStore) Shutdown() error {
+	volumeCountCtr.deregister(s)
 	return s.db.Close()
 }
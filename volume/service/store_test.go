@@ -8,8 +8,11 @@ import (
 	"net"
 	"os"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/docker/docker/errdefs"
 	"github.com/docker/docker/volume"
 	volumedrivers "github.com/docker/docker/volume/drivers"
 	"github.com/docker/docker/volume/service/opts"
@@ -419,3 +422,171 @@ func TestFilterFunc(t *testing.T) {
 		})
 	}
 }
+
+func TestCreateConcurrentSameName(t *testing.T) {
+	t.Parallel()
+
+	s, cleanup := setupTest(t)
+	defer cleanup()
+	d := volumetestutils.NewFakeDriver("fake", volumetestutils.WithLatency("Create", 50*time.Millisecond))
+	s.drivers.Register(d, "fake")
+
+	ctx := context.Background()
+	const workers = 25
+
+	var wg sync.WaitGroup
+	vols := make([]volume.Volume, workers)
+	errs := make([]error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			vols[i], errs[i] = s.Create(ctx, "v1", "fake", opts.WithCreateReference(fmt.Sprintf("ref%d", i)))
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < workers; i++ {
+		assert.NilError(t, errs[i])
+		assert.Check(t, is.Equal(vols[i].Name(), "v1"))
+	}
+
+	var creates int
+	for _, call := range d.CallLog() {
+		if call == "Create" {
+			creates++
+		}
+	}
+	assert.Check(t, is.Equal(creates, 1))
+
+	// every caller's reference must have been recorded
+	v, err := s.Get(ctx, "v1")
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(s.getRefs(v.Name()), workers))
+}
+
+func TestCreateConcurrentSameNameError(t *testing.T) {
+	t.Parallel()
+
+	s, cleanup := setupTest(t)
+	defer cleanup()
+	const workers = 5
+
+	injected := errors.New("create error")
+	driverOpts := []volumetestutils.FakeDriverOption{volumetestutils.WithLatency("Create", 50*time.Millisecond)}
+	// fail every Create so stragglers which miss the in-flight call and
+	// retry still see the injected error
+	for n := 1; n <= workers; n++ {
+		driverOpts = append(driverOpts, volumetestutils.WithFailNthCall("Create", n, injected))
+	}
+	d := volumetestutils.NewFakeDriver("fake", driverOpts...)
+	s.drivers.Register(d, "fake")
+
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	errs := make([]error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = s.Create(ctx, "v1", "fake")
+		}(i)
+	}
+	wg.Wait()
+
+	// all coalesced callers see the same error
+	for i := 0; i < workers; i++ {
+		assert.Check(t, errs[i] != nil)
+		assert.Check(t, is.ErrorContains(errs[i], injected.Error()))
+	}
+}
+
+func TestCreateIdempotent(t *testing.T) {
+	t.Parallel()
+
+	s, cleanup := setupTest(t)
+	defer cleanup()
+	d := volumetestutils.NewFakeDriver("fake")
+	s.drivers.Register(d, "fake")
+	d2 := volumetestutils.NewFakeDriver("fake2")
+	s.drivers.Register(d2, "fake2")
+
+	ctx := context.Background()
+
+	countCalls := func(d *volumetestutils.FakeDriver) int {
+		return len(d.CallLog())
+	}
+
+	// an idempotent create of an unknown volume takes the normal path and
+	// calls the driver
+	v, err := s.Create(ctx, "v1", "fake", opts.WithCreateIdempotent())
+	assert.NilError(t, err)
+	created := countCalls(d)
+	assert.Check(t, created > 0)
+
+	// the volume is now cached, so an idempotent create must not touch the
+	// driver at all
+	vv, err := s.Create(ctx, "v1", "fake", opts.WithCreateIdempotent(), opts.WithCreateReference("ref1"))
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(vv.Name(), v.Name()))
+	assert.Check(t, is.Equal(countCalls(d), created))
+
+	// the reference passed to the fast path must still be recorded
+	assert.Check(t, is.Len(s.getRefs("v1"), 1))
+
+	// without the option the driver is asked to re-validate the volume
+	_, err = s.Create(ctx, "v1", "fake")
+	assert.NilError(t, err)
+	assert.Check(t, countCalls(d) > created)
+
+	// a cached volume under a different driver is still a conflict, decided
+	// from the cache without calling either driver
+	before, before2 := countCalls(d), countCalls(d2)
+	_, err = s.Create(ctx, "v1", "fake2", opts.WithCreateIdempotent())
+	assert.Check(t, IsNameConflict(err), err)
+	assert.Check(t, is.Equal(countCalls(d), before))
+	assert.Check(t, is.Equal(countCalls(d2), before2))
+}
+
+func TestReleaseByPrefix(t *testing.T) {
+	t.Parallel()
+
+	s, cleanup := setupTest(t)
+	defer cleanup()
+	s.drivers.Register(volumetestutils.NewFakeDriver("fake"), "fake")
+
+	ctx := context.Background()
+	v1, err := s.Create(ctx, "v1", "fake", opts.WithCreateReference("cid1-mount-a"))
+	assert.NilError(t, err)
+	s.setNamed(v1, "cid1-mount-b")
+	v2, err := s.Create(ctx, "v2", "fake", opts.WithCreateReference("cid1-mount-c"))
+	assert.NilError(t, err)
+	s.setNamed(v2, "cid2-mount-a")
+
+	_, err = s.ReleaseByPrefix(ctx, "")
+	assert.Check(t, errdefs.IsInvalidParameter(err))
+
+	released, err := s.ReleaseByPrefix(ctx, "cid1")
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(released, 3))
+	names := make(map[string]int)
+	for _, r := range released {
+		assert.Check(t, strings.HasPrefix(r.Ref, "cid1"), r.Ref)
+		names[r.Name]++
+	}
+	assert.Check(t, is.Equal(names["v1"], 2))
+	assert.Check(t, is.Equal(names["v2"], 1))
+
+	// v1 must now be dangling while v2 keeps its remaining reference
+	dangling, _, err := s.Find(ctx, ByReferenced(false))
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(dangling, 1))
+	assert.Check(t, is.Equal(dangling[0].Name(), "v1"))
+	assert.Check(t, is.Len(s.getRefs("v2"), 1))
+
+	// nothing left matching the prefix
+	released, err = s.ReleaseByPrefix(ctx, "cid1")
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(released, 0))
+}
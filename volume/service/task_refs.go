@@ -0,0 +1,106 @@
+package service // import "github.com/docker/docker/volume/service"
+
+import (
+	"context"
+	"strings"
+
+	"github.com/docker/docker/volume/service/opts"
+	"github.com/sirupsen/logrus"
+)
+
+// taskRefPrefix namespaces references held on behalf of swarm tasks so they
+// can be told apart from container references and rebuilt during
+// reconciliation on daemon/cluster start.
+const taskRefPrefix = "task:"
+
+// taskReference builds the reference ID used to track a swarm task's use of a
+// volume.
+func taskReference(taskID string) string {
+	return taskRefPrefix + taskID
+}
+
+// isTaskReference returns true if the passed in reference ID was created by
+// taskReference.
+func isTaskReference(ref string) bool {
+	return strings.HasPrefix(ref, taskRefPrefix)
+}
+
+// RegisterTaskReference registers a reference on the named volume on behalf
+// of the given swarm task.
+// Volumes referenced by a task are not considered dangling and are protected
+// from prune, even while no container for the task exists (e.g. while the
+// task is being rescheduled).
+func (s *VolumesService) RegisterTaskReference(ctx context.Context, name, taskID string) error {
+	_, err := s.vs.Get(ctx, name, opts.WithGetReference(taskReference(taskID)))
+	return err
+}
+
+// ReleaseTaskReference releases the reference held on the named volume for
+// the given swarm task.
+func (s *VolumesService) ReleaseTaskReference(ctx context.Context, name, taskID string) error {
+	return s.vs.Release(ctx, name, taskReference(taskID))
+}
+
+// ReconcileTaskReferences rebuilds task-level references from the passed in
+// set of tasks, mapping task ID to the names of the volumes the task uses.
+// It is intended to be called by the cluster layer when it starts, so that
+// references lost on a daemon restart, or left behind by tasks that went away
+// while the daemon was down, match the current task set.
+func (s *VolumesService) ReconcileTaskReferences(ctx context.Context, tasks map[string][]string) error {
+	desired := make(map[string]map[string]struct{}) // volume name -> set of task references
+	for taskID, names := range tasks {
+		for _, name := range names {
+			if desired[name] == nil {
+				desired[name] = make(map[string]struct{})
+			}
+			desired[name][taskReference(taskID)] = struct{}{}
+		}
+	}
+
+	// release references for tasks that no longer exist
+	for name, refs := range s.vs.taskReferences() {
+		for _, ref := range refs {
+			if _, exists := desired[name][ref]; exists {
+				// already registered, no need to hit the store again below
+				delete(desired[name], ref)
+				continue
+			}
+			if err := s.vs.Release(ctx, name, ref); err != nil {
+				return err
+			}
+		}
+	}
+
+	// register references for tasks the store doesn't know about yet
+	for name, refs := range desired {
+		for ref := range refs {
+			if _, err := s.vs.Get(ctx, name, opts.WithGetReference(ref)); err != nil {
+				if IsNotExist(err) {
+					// the volume may not have been created yet, in which case
+					// it gets its reference when it is created
+					logrus.WithField("volume", name).WithField("ref", ref).Debug("Skipping task reference for unknown volume")
+					continue
+				}
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// taskReferences returns the task-level references currently held in the
+// store as a mapping of volume name to reference IDs.
+func (s *VolumeStore) taskReferences() map[string][]string {
+	s.globalLock.RLock()
+	defer s.globalLock.RUnlock()
+
+	out := make(map[string][]string)
+	for name, refs := range s.refs {
+		for ref := range refs {
+			if isTaskReference(ref) {
+				out[name] = append(out[name], ref)
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/volume"
+	volumedrivers "github.com/docker/docker/volume/drivers"
+	"github.com/docker/docker/volume/testutils"
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+func TestServiceTaskReference(t *testing.T) {
+	t.Parallel()
+
+	ds := volumedrivers.NewStore(nil)
+	assert.Assert(t, ds.Register(testutils.NewFakeDriver(volume.DefaultDriverName), volume.DefaultDriverName))
+
+	service, cleanup := newTestService(t, ds)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := service.Create(ctx, "v1", volume.DefaultDriverName)
+	assert.NilError(t, err)
+
+	assert.NilError(t, service.RegisterTaskReference(ctx, "v1", "task1"))
+
+	ls, _, err := service.List(ctx, filters.NewArgs(filters.Arg("dangling", "true")))
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(ls, 0))
+
+	pr, err := service.Prune(ctx, filters.NewArgs())
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(pr.VolumesDeleted, 0))
+
+	assert.NilError(t, service.ReleaseTaskReference(ctx, "v1", "task1"))
+
+	ls, _, err = service.List(ctx, filters.NewArgs(filters.Arg("dangling", "true")))
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(ls, 1))
+
+	pr, err = service.Prune(ctx, filters.NewArgs())
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(pr.VolumesDeleted, 1))
+}
+
+func TestServiceReconcileTaskReferences(t *testing.T) {
+	t.Parallel()
+
+	ds := volumedrivers.NewStore(nil)
+	assert.Assert(t, ds.Register(testutils.NewFakeDriver("d1"), "d1"))
+
+	service, cleanup := newTestService(t, ds)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := service.Create(ctx, "v1", "d1")
+	assert.NilError(t, err)
+	_, err = service.Create(ctx, "v2", "d1")
+	assert.NilError(t, err)
+
+	assert.NilError(t, service.RegisterTaskReference(ctx, "v1", "stale"))
+	assert.NilError(t, service.RegisterTaskReference(ctx, "v1", "task1"))
+
+	// "stale" goes away, "task1" is kept, "task2" is new, and a reference to
+	// a volume that doesn't exist yet must not fail the reconciliation.
+	err = service.ReconcileTaskReferences(ctx, map[string][]string{
+		"task1": {"v1"},
+		"task2": {"v2", "notexist"},
+	})
+	assert.NilError(t, err)
+
+	assert.Check(t, is.DeepEqual(service.vs.getRefs("v1"), []string{taskReference("task1")}))
+	assert.Check(t, is.DeepEqual(service.vs.getRefs("v2"), []string{taskReference("task2")}))
+
+	// reconciling with an empty task set releases everything
+	assert.NilError(t, service.ReconcileTaskReferences(ctx, nil))
+	assert.Check(t, is.Len(service.vs.getRefs("v1"), 0))
+	assert.Check(t, is.Len(service.vs.getRefs("v2"), 0))
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/docker/docker/pkg/plugingetter"
@@ -78,14 +79,71 @@ func (f FakeVolume) CreatedAt() (time.Time, error) {
 type FakeDriver struct {
 	name string
 	vols map[string]volume.Volume
+
+	mu       sync.Mutex
+	calls    []string
+	counts   map[string]int
+	failures map[string]map[int]error
+	latency  map[string]time.Duration
+}
+
+// FakeDriverOption is used to configure the behavior of a FakeDriver
+type FakeDriverOption func(*FakeDriver)
+
+// WithFailNthCall makes the driver fail the nth call (1-indexed) to the named
+// method ("Create", "Remove", "List", "Get") with the passed in error.
+func WithFailNthCall(method string, n int, err error) FakeDriverOption {
+	return func(d *FakeDriver) {
+		if d.failures[method] == nil {
+			d.failures[method] = make(map[int]error)
+		}
+		d.failures[method][n] = err
+	}
+}
+
+// WithLatency adds artificial latency to every call of the named method.
+func WithLatency(method string, latency time.Duration) FakeDriverOption {
+	return func(d *FakeDriver) {
+		d.latency[method] = latency
+	}
 }
 
 // NewFakeDriver creates a new FakeDriver with the specified name
-func NewFakeDriver(name string) volume.Driver {
-	return &FakeDriver{
-		name: name,
-		vols: make(map[string]volume.Volume),
+func NewFakeDriver(name string, options ...FakeDriverOption) *FakeDriver {
+	d := &FakeDriver{
+		name:     name,
+		vols:     make(map[string]volume.Volume),
+		counts:   make(map[string]int),
+		failures: make(map[string]map[int]error),
+		latency:  make(map[string]time.Duration),
+	}
+	for _, o := range options {
+		o(d)
+	}
+	return d
+}
+
+// CallLog returns the names of the driver methods called so far, in order.
+func (d *FakeDriver) CallLog() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.calls...)
+}
+
+// begin records a call to the named method, applies any configured latency,
+// and returns the error injected for this call, if any.
+func (d *FakeDriver) begin(method string) error {
+	d.mu.Lock()
+	d.calls = append(d.calls, method)
+	d.counts[method]++
+	err := d.failures[method][d.counts[method]]
+	latency := d.latency[method]
+	d.mu.Unlock()
+
+	if latency > 0 {
+		time.Sleep(latency)
 	}
+	return err
 }
 
 // Name is the name of the driver
@@ -94,6 +152,9 @@ func (d *FakeDriver) Name() string { return d.name }
 // Create initializes a fake volume.
 // It returns an error if the options include an "error" key with a message
 func (d *FakeDriver) Create(name string, opts map[string]string) (volume.Volume, error) {
+	if err := d.begin("Create"); err != nil {
+		return nil, err
+	}
 	if opts != nil && opts["error"] != "" {
 		return nil, fmt.Errorf(opts["error"])
 	}
@@ -104,6 +165,9 @@ func (d *FakeDriver) Create(name string, opts map[string]string) (volume.Volume,
 
 // Remove deletes a volume.
 func (d *FakeDriver) Remove(v volume.Volume) error {
+	if err := d.begin("Remove"); err != nil {
+		return err
+	}
 	if _, exists := d.vols[v.Name()]; !exists {
 		return fmt.Errorf("no such volume")
 	}
@@ -113,6 +177,9 @@ func (d *FakeDriver) Remove(v volume.Volume) error {
 
 // List lists the volumes
 func (d *FakeDriver) List() ([]volume.Volume, error) {
+	if err := d.begin("List"); err != nil {
+		return nil, err
+	}
 	var vols []volume.Volume
 	for _, v := range d.vols {
 		vols = append(vols, v)
@@ -122,6 +189,9 @@ func (d *FakeDriver) List() ([]volume.Volume, error) {
 
 // Get gets the volume
 func (d *FakeDriver) Get(name string) (volume.Volume, error) {
+	if err := d.begin("Get"); err != nil {
+		return nil, err
+	}
 	if v, exists := d.vols[name]; exists {
 		return v, nil
 	}